@@ -0,0 +1,30 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package api contains the public schemas and samples of the riconto files.
+package api
+
+import (
+	_ "embed"
+)
+
+// ConfigSchema is the json schema of the project configuration file
+//
+//go:embed config.schema.json
+var ConfigSchema []byte
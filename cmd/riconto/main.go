@@ -22,9 +22,12 @@ package main
 import (
 	"log/slog"
 	"os"
+	"path/filepath"
 	"time"
 
+	"emperror.dev/errors"
 	"github.com/chordflower/riconto/internal/commands"
+	"github.com/chordflower/riconto/internal/model"
 	"github.com/phsym/console-slog"
 	"github.com/spf13/afero"
 	"github.com/tucnak/climax"
@@ -45,12 +48,60 @@ func main() {
 		logger.Error("Error creating the project", slog.Any("error", err))
 	}
 
-	createCommand := commands.NewCreateCommand(afero.NewBasePathFs(
-		osFs, currdir,
-	), logger)
+	// The global --project or -C flag changes the directory where the project is searched
+	project, args := commands.ProjectFlag(os.Args[1:])
+	if project != "" {
+		if !filepath.IsAbs(project) {
+			project = filepath.Join(currdir, project)
+		}
+		currdir = project
+	}
+
+	// The global --define or -D flags change the values of the configuration, over the user
+	// configuration file, the project configuration file and the environment variables
+	defines, args, err := commands.DefineFlags(args)
+	if err != nil {
+		logger.Error("Invalid global options", slog.Any("error", err))
+		os.Exit(1)
+	}
+	// climax parses the arguments of the program, so it gets them without the global flags, as
+	// the commands do, which use them to find their repeated flags
+	os.Args = append([]string{os.Args[0]}, args...)
+	sources := model.NewSources(osFs, defines)
+
+	// The commands that create projects work in the current directory, while the others work
+	// in the root of the project containing it. An invalid configuration file is reported by the
+	// commands, with the other sources of values, so that it can still be validated
+	workingFs := afero.NewBasePathFs(osFs, currdir)
+	projectFs := workingFs
+	loaded, err := model.LoadProject(osFs, currdir)
+	var noProject *model.NoProjectError
+	switch {
+	case loaded != nil:
+		projectFs = loaded.Fs
+	case !errors.As(err, &noProject):
+		logger.Error("Unable to load the project", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	createCommand := commands.NewCreateCommand(workingFs, logger)
+	fetchCommand := commands.NewFetchCommand(workingFs, logger)
+	buildCommand := commands.NewBuildCommand(projectFs, logger, sources)
+	cleanCommand := commands.NewCleanCommand(projectFs, logger)
+	addCommand := commands.NewAddCommand(projectFs, logger)
+	removeCommand := commands.NewRemoveCommand(projectFs, logger)
+	listCommand := commands.NewListCommand(projectFs, logger, os.Stdout)
+	configCommand := commands.NewConfigCommand(projectFs, logger, os.Stdout, sources)
 	riconto := climax.New("riconto")
-	riconto.Brief = "A tool to create markdown based documents"
+	riconto.Brief = "A tool to create markdown based documents, use --project or -C to select the project directory and --define or -D key=value to change a configuration value"
 	riconto.Version = "0.0.1"
-	riconto.AddCommand(createCommand.Command())
-	riconto.Run()
+	riconto.AddCommand(createCommand.Command(args))
+	riconto.AddCommand(fetchCommand.Command(args))
+	riconto.AddCommand(buildCommand.Command(args))
+	riconto.AddCommand(cleanCommand.Command(args))
+	riconto.AddCommand(addCommand.Command(args))
+	riconto.AddCommand(removeCommand.Command(args))
+	riconto.AddCommand(listCommand.Command(args))
+	riconto.AddCommand(configCommand.Command(args))
+	os.Exit(riconto.Run())
 }
@@ -6,21 +6,29 @@ require (
 	emperror.dev/errors v0.8.1
 	github.com/buger/goterm v1.0.4
 	github.com/goccy/go-yaml v1.12.0
+	github.com/jlaffaye/ftp v0.2.0
 	github.com/json-iterator/go v1.1.12
 	github.com/muesli/reflow v0.3.0
 	github.com/pelletier/go-toml/v2 v2.2.3
 	github.com/phsym/console-slog v0.3.1
 	github.com/primalskill/golog v1.3.0
 	github.com/progrium/darwinkit v0.5.0
+	github.com/santhosh-tekuri/jsonschema/v6 v6.0.1
 	github.com/smartystreets/goconvey v1.8.1
 	github.com/spf13/afero v1.11.0
 	github.com/tucnak/climax v0.0.0-20200905070204-9f87fd172d1c
+	github.com/yuin/goldmark v1.7.8
+	golang.org/x/image v0.21.0
+	golang.org/x/net v0.30.0
 	golang.org/x/sys v0.26.0
+	golang.org/x/text v0.19.0
 )
 
 require (
 	github.com/fatih/color v1.17.0 // indirect
 	github.com/gopherjs/gopherjs v1.17.2 // indirect
+	github.com/hashicorp/errwrap v1.0.0 // indirect
+	github.com/hashicorp/go-multierror v1.1.1 // indirect
 	github.com/jtolds/gls v4.20.0+incompatible // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
@@ -33,6 +41,5 @@ require (
 	github.com/smarty/assertions v1.15.0 // indirect
 	github.com/tidwall/pretty v1.2.1 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
-	golang.org/x/text v0.19.0 // indirect
 	golang.org/x/xerrors v0.0.0-20240903120638-7835f813f4da // indirect
 )
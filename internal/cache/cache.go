@@ -0,0 +1,149 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package cache implements the build cache, which records the sources and outputs of each
+// built file, to know if it is up to date and what to clean
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"slices"
+
+	"emperror.dev/errors"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/afero"
+)
+
+// Dir is the directory of the build cache, relative to the project root
+const Dir = ".riconto/cache"
+
+// Entry represents the result of building one of the files of the configuration
+type Entry struct {
+	// Name is the name of the file in the configuration.
+	Name string `json:"name"`
+	// Sources maps the path of each file used by the build, like the configuration file, the
+	// markdown files, the images and the fonts, to the hash of its contents.
+	Sources map[string]string `json:"sources"`
+	// Outputs contains the paths of the written files.
+	Outputs []string `json:"outputs"`
+}
+
+// NewEntry creates a new empty entry for the file with the given name
+func NewEntry(name string) *Entry {
+	return &Entry{
+		Name:    name,
+		Sources: make(map[string]string),
+		Outputs: make([]string, 0),
+	}
+}
+
+// EntryPath returns the path of the cache entry of the file with the given name
+func EntryPath(name string) string {
+	hash := sha256.Sum256([]byte(name))
+	return path.Join(Dir, hex.EncodeToString(hash[:8])+".json")
+}
+
+// HashFile returns the hash of the contents of the given file
+func HashFile(fs afero.Fs, filename string) (string, error) {
+	file, err := fs.Open(filename)
+	if err != nil {
+		return "", errors.Wrapf(err, "Unable to open the file %s", filename)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", errors.Wrapf(err, "Unable to read the file %s", filename)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// AddSources records the current hash of the given source files
+func (e *Entry) AddSources(fs afero.Fs, filenames ...string) error {
+	for _, filename := range filenames {
+		hash, err := HashFile(fs, filename)
+		if err != nil {
+			return err
+		}
+		e.Sources[filename] = hash
+	}
+	return nil
+}
+
+// AddOutput records a written file
+func (e *Entry) AddOutput(filename string) {
+	if !slices.Contains(e.Outputs, filename) {
+		e.Outputs = append(e.Outputs, filename)
+	}
+}
+
+// UpToDate checks if all the outputs exist and none of the sources changed since the build
+func (e *Entry) UpToDate(fs afero.Fs) bool {
+	if len(e.Sources) == 0 || len(e.Outputs) == 0 {
+		return false
+	}
+	for _, output := range e.Outputs {
+		if _, err := fs.Stat(output); err != nil {
+			return false
+		}
+	}
+	for source, hash := range e.Sources {
+		current, err := HashFile(fs, source)
+		if err != nil || current != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// Load reads the cache entry of the file with the given name, returning nil if there is none
+func Load(fs afero.Fs, name string) (*Entry, error) {
+	data, err := afero.ReadFile(fs, EntryPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read the build cache of %s", name)
+	}
+	entry := NewEntry(name)
+	if err := jsoniter.Unmarshal(data, entry); err != nil {
+		return nil, errors.Wrapf(err, "Unable to decode the build cache of %s", name)
+	}
+	return entry, nil
+}
+
+// Save writes the cache entry, replacing any previous one for the same file
+func Save(fs afero.Fs, entry *Entry) error {
+	data, err := jsoniter.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "Unable to encode the build cache of %s", entry.Name)
+	}
+	if err := fs.MkdirAll(Dir, 0o755); err != nil {
+		return errors.Wrap(err, "Unable to create the build cache directory")
+	}
+	if err := afero.WriteFile(fs, EntryPath(entry.Name), data, 0o644); err != nil {
+		return errors.Wrapf(err, "Unable to write the build cache of %s", entry.Name)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cache
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+func TestEntry(t *testing.T) {
+	Convey("#Entry", t, func() {
+		fs := afero.NewMemMapFs()
+		So(afero.WriteFile(fs, "src/main.md", []byte("# Main\n"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "dist/main.pdf", []byte("%PDF-1.7\n"), 0o644), ShouldBeNil)
+		entry := NewEntry("Main")
+		So(entry.AddSources(fs, "src/main.md"), ShouldBeNil)
+		entry.AddOutput("dist/main.pdf")
+
+		Convey("It should be saved and loaded by name", func() {
+			So(Save(fs, entry), ShouldBeNil)
+			loaded, err := Load(fs, "Main")
+			So(err, ShouldBeNil)
+			So(loaded, ShouldResemble, entry)
+			missing, err := Load(fs, "Other")
+			So(err, ShouldBeNil)
+			So(missing, ShouldBeNil)
+		})
+
+		Convey("It should be up to date until a source changes", func() {
+			So(entry.UpToDate(fs), ShouldBeTrue)
+			So(afero.WriteFile(fs, "src/main.md", []byte("# Changed\n"), 0o644), ShouldBeNil)
+			So(entry.UpToDate(fs), ShouldBeFalse)
+		})
+
+		Convey("It should not be up to date without its outputs", func() {
+			So(fs.Remove("dist/main.pdf"), ShouldBeNil)
+			So(entry.UpToDate(fs), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/buger/goterm"
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+)
+
+type AddCommand struct {
+	name     string
+	brief    string
+	usage    string
+	help     string
+	group    string
+	flags    []climax.Flag
+	examples []climax.Example
+	logger   *slog.Logger
+	fs       afero.Fs
+}
+
+func NewAddCommand(fs afero.Fs, logger *slog.Logger) *AddCommand {
+	terminalWidth := goterm.Width()
+	helpStr := "" +
+		"This command will add a new file to a riconto project, updating the configuration file " +
+		"in the directory where the executable is called, in its own format.\n" +
+		"The name of the file is given with the option --name or -n, and must not exist in the " +
+		"configuration.\n" +
+		"The option --output or -o gives the output of the file, without extension, and by default " +
+		"it is ./dist/<name>, while the option --source or -s gives its markdown file, by default " +
+		"./src/<name>/main.md.\n" +
+		"If the markdown file does not exist, it is created with its title."
+	flags := make([]climax.Flag, 0, 3)
+	flags = append(flags, climax.Flag{
+		Name:     "name",
+		Short:    "n",
+		Usage:    "--name NAME",
+		Help:     "The name of the file to add",
+		Variable: true,
+	})
+	flags = append(flags, climax.Flag{
+		Name:     "output",
+		Short:    "o",
+		Usage:    "--output PATH",
+		Help:     "The output of the file, without extension (default ./dist/<name>)",
+		Variable: true,
+	})
+	flags = append(flags, climax.Flag{
+		Name:     "source",
+		Short:    "s",
+		Usage:    "--source PATH",
+		Help:     "The markdown file of the file (default ./src/<name>/main.md)",
+		Variable: true,
+	})
+	examples := make([]climax.Example, 0, 2)
+	examples = append(examples, climax.Example{
+		Usecase:     `--name bookC`,
+		Description: "Adds bookC, written from ./src/bookC/main.md to ./dist/bookC",
+	})
+	examples = append(examples, climax.Example{
+		Usecase:     `--name "Book C" --output ./dist/c --source ./src/c.md`,
+		Description: "Adds Book C, written from ./src/c.md to ./dist/c",
+	})
+	return &AddCommand{
+		name:     "add",
+		brief:    "adds a file to the project",
+		usage:    "--name name [--output path] [--source path]",
+		help:     wordwrap.String(strings.TrimSpace(helpStr), terminalWidth),
+		group:    "",
+		flags:    flags,
+		examples: examples,
+		fs:       fs,
+		logger:   logger,
+	}
+}
+
+func (i *AddCommand) Name() string {
+	return i.name
+}
+
+func (i *AddCommand) Brief() string {
+	return i.brief
+}
+
+func (i *AddCommand) Usage() string {
+	return i.usage
+}
+
+func (i *AddCommand) Help() string {
+	return i.help
+}
+
+func (i *AddCommand) Group() string {
+	return i.group
+}
+
+func (i *AddCommand) Flags() []climax.Flag {
+	return i.flags
+}
+
+func (i *AddCommand) Examples() []climax.Example {
+	return i.examples
+}
+
+func (i *AddCommand) Run(context climax.Context) int {
+	// 1. Validate if name is passed
+	name, _ := context.Get("name")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		i.logger.Error("The name parameter is required!")
+		return 1
+	}
+
+	// 2. Load the configuration file
+	config, err := loadConfig(i.fs)
+	if err != nil {
+		i.logger.Error("Unable to load the configuration file", slog.Any("error", err))
+		return 1
+	}
+
+	// 3. Get the output and source to use
+	output := "./" + path.Join("dist", name)
+	if context.Is("output") {
+		output, _ = context.Get("output")
+	}
+	source := "./" + path.Join("src", name, "main.md")
+	if context.Is("source") {
+		source, _ = context.Get("source")
+	}
+
+	// 4. Add the file to the configuration
+	file := model.NewFile(name, output, source)
+	if !config.AddFile(file) {
+		i.logger.Error(fmt.Sprintf("There is already a file named %s in the configuration file", name))
+		return 1
+	}
+
+	// 5. Create the markdown file, if it does not exist
+	exists, err := afero.Exists(i.fs, source)
+	if err != nil {
+		i.logger.Error("Unable to check the markdown file", slog.String("file", source), slog.Any("error", err))
+		return 1
+	}
+	// created is the first directory, or the markdown file, that did not exist before
+	created := ""
+	if !exists {
+		created = path.Clean(source)
+		for dir := path.Dir(created); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if found, _ := afero.DirExists(i.fs, dir); found {
+				break
+			}
+			created = dir
+		}
+		err = i.fs.MkdirAll(path.Dir(path.Clean(source)), 0o750)
+		if err == nil {
+			err = afero.WriteFile(i.fs, source, []byte(scaffold(name)), 0o644)
+		}
+		if err != nil {
+			i.logger.Error("Unable to create the markdown file", slog.String("file", source), slog.Any("error", err))
+			return 1
+		}
+	}
+
+	// 6. Save the configuration file, removing the created markdown file when it fails
+	err = saveConfig(i.fs, config)
+	if err != nil {
+		if created != "" {
+			_ = i.fs.RemoveAll(created)
+		}
+		i.logger.Error("Unable to save the configuration file", slog.Any("error", err))
+		return 1
+	}
+	i.logger.Info(fmt.Sprintf("Added %s", name), slog.String("output", output), slog.String("source", source))
+
+	return 0
+}
+
+func (i *AddCommand) Command(args []string) climax.Command {
+	return FromCommand(i, args)
+}
+
+// scaffold returns the initial content of the markdown file of a new file with the given name
+func scaffold(name string) string {
+	return fmt.Sprintf("---\ntitle: %s\n---\n\n# %s\n", strconv.Quote(name), name)
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/primalskill/golog"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+)
+
+const addYamlConfig = `name: sample
+version: 0.0.1
+files:
+  - name: Book A
+    output: ./dist/bookA
+    path: ./src/bookA/main.md
+license: []
+description: ""
+authors: []
+`
+
+func TestAddCommand(t *testing.T) {
+	Convey("#AddCommand", t, func() {
+
+		Convey("It should be able to create a new command", func() {
+			addCommand := NewAddCommand(afero.NewMemMapFs(), golog.NewDiscard())
+			So(addCommand, ShouldNotBeNil)
+			So(addCommand.Name(), ShouldEqual, "add")
+			So(addCommand.Brief(), ShouldEqual, "adds a file to the project")
+		})
+
+		Convey("Given a project", func() {
+			memFs := newBuildFs()
+			addCommand := NewAddCommand(memFs, golog.NewDiscard())
+
+			Convey("It should add a file with the default output and source", func() {
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"name": "bookC"},
+				}
+				So(addCommand.Run(context), ShouldEqual, 0)
+				config, err := loadConfig(memFs)
+				So(err, ShouldBeNil)
+				So(config.Files, ShouldHaveLength, 3)
+				So(config.Files[2], ShouldResemble, *model.NewFile("bookC", "./dist/bookC", "./src/bookC/main.md"))
+				content, err := afero.ReadFile(memFs, "src/bookC/main.md")
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, "---\ntitle: \"bookC\"\n---\n\n# bookC\n")
+				So(NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{}).Run(climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"name": "bookC"},
+				}), ShouldEqual, 0)
+			})
+
+			Convey("It should keep an existing markdown file", func() {
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"name": "Book C", "output": "./out/c", "source": "./src/bookA/main.md"},
+				}
+				So(addCommand.Run(context), ShouldEqual, 0)
+				config, err := loadConfig(memFs)
+				So(err, ShouldBeNil)
+				So(config.Files[2], ShouldResemble, *model.NewFile("Book C", "./out/c", "./src/bookA/main.md"))
+				content, err := afero.ReadFile(memFs, "src/bookA/main.md")
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, buildMarkdown)
+			})
+
+			Convey("It should fail when the name already exists", func() {
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"name": "Book A"},
+				}
+				So(addCommand.Run(context), ShouldEqual, 1)
+				content, err := afero.ReadFile(memFs, "riconto.toml")
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, buildConfig)
+			})
+
+			Convey("It should remove the created markdown file when the configuration can not be saved", func() {
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"name": "bookC", "output": ""},
+				}
+				So(addCommand.Run(context), ShouldEqual, 1)
+				content, err := afero.ReadFile(memFs, "riconto.toml")
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, buildConfig)
+				exists, err := afero.Exists(memFs, "src/bookC")
+				So(err, ShouldBeNil)
+				So(exists, ShouldBeFalse)
+				exists, err = afero.Exists(memFs, "src/bookA/main.md")
+				So(err, ShouldBeNil)
+				So(exists, ShouldBeTrue)
+			})
+
+			Convey("It should fail without a name", func() {
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    make(map[string]string),
+				}
+				So(addCommand.Run(context), ShouldEqual, 1)
+			})
+		})
+
+		Convey("It should save the configuration in its format and order", func() {
+			memFs := afero.NewMemMapFs()
+			So(afero.WriteFile(memFs, "riconto.yaml", []byte(addYamlConfig), 0o644), ShouldBeNil)
+			context := climax.Context{
+				Args:        []string{},
+				NonVariable: make(map[string]bool),
+				Variable:    map[string]string{"name": "bookB"},
+			}
+			So(NewAddCommand(memFs, golog.NewDiscard()).Run(context), ShouldEqual, 0)
+			content, err := afero.ReadFile(memFs, "riconto.yaml")
+			So(err, ShouldBeNil)
+			So(string(content), ShouldStartWith, "name: sample\nversion: 0.0.1\nfiles:\n")
+			So(string(content), ShouldEndWith, "description: \"\"\nauthors: []\n")
+			So(strings.Index(string(content), "bookB"), ShouldBeLessThan, strings.Index(string(content), "license"))
+		})
+
+		Convey("It should fail without a configuration file", func() {
+			context := climax.Context{
+				Args:        []string{},
+				NonVariable: make(map[string]bool),
+				Variable:    map[string]string{"name": "bookC"},
+			}
+			So(NewAddCommand(afero.NewMemMapFs(), golog.NewDiscard()).Run(context), ShouldEqual, 1)
+		})
+	})
+}
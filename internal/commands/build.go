@@ -0,0 +1,288 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/buger/goterm"
+	"github.com/chordflower/riconto/internal/cache"
+	"github.com/chordflower/riconto/internal/markdown"
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/chordflower/riconto/internal/render"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+)
+
+type BuildCommand struct {
+	name     string
+	brief    string
+	usage    string
+	help     string
+	group    string
+	flags    []climax.Flag
+	examples []climax.Example
+	logger   *slog.Logger
+	fs       afero.Fs
+	sources  *model.Sources
+}
+
+func NewBuildCommand(fs afero.Fs, logger *slog.Logger, sources *model.Sources) *BuildCommand {
+	terminalWidth := goterm.Width()
+	helpStr := "" +
+		"This command will build the files of a riconto project, reading the configuration file " +
+		"in the directory where the executable is called.\n" +
+		"For each file in the configuration, the markdown file in its path is parsed and each one " +
+		"of its formats is written to its output, like a pdf with the .pdf extension appended or an " +
+		"html site in a directory.\n\n" +
+		"By default all the files are built, but it is possible to select which ones to build, by " +
+		"their names, with the option --name or -n, which can be given more than once or contain " +
+		"several names separated by commas.\n" +
+		"The option --warnings-as-errors or -w makes the command end with an error code if any " +
+		"warnings are found while building.\n" +
+		"The option --profile or -p builds the files with the settings of one of the profiles of " +
+		"the configuration file, merged over the settings of the project.\n" +
+		"The option --format or -f builds all the files in the given formats, instead of the ones of " +
+		"their settings, and it can be given more than once or contain several formats separated " +
+		"by commas.\n\n" +
+		"The configuration file is merged over the user configuration file, and the RICONTO_* " +
+		"environment variables and the global --define or -D options are merged over both."
+	flags := make([]climax.Flag, 0, 4)
+	flags = append(flags, climax.Flag{
+		Name:     "name",
+		Short:    "n",
+		Usage:    "--name NAME[,NAME...]",
+		Help:     "The name(s) of the files to build (default all)",
+		Variable: true,
+	})
+	flags = append(flags, climax.Flag{
+		Name:     "warnings-as-errors",
+		Short:    "w",
+		Usage:    "--warnings-as-errors",
+		Help:     "Ends with an error code if there are any warnings",
+		Variable: false,
+	})
+	flags = append(flags, climax.Flag{
+		Name:     "profile",
+		Short:    "p",
+		Usage:    "--profile PROFILE",
+		Help:     "The profile whose settings are used to build (default none)",
+		Variable: true,
+	})
+	flags = append(flags, climax.Flag{
+		Name:     "format",
+		Short:    "f",
+		Usage:    "--format FORMAT[,FORMAT...]",
+		Help:     "The format(s) of the files to build (default the ones of their settings)",
+		Variable: true,
+	})
+	examples := make([]climax.Example, 0, 5)
+	examples = append(examples, climax.Example{
+		Usecase:     "",
+		Description: "Builds all the files of the project in the current directory",
+	})
+	examples = append(examples, climax.Example{
+		Usecase:     `--name "Book A" --name "Book B"`,
+		Description: "Builds only the files named Book A and Book B",
+	})
+	examples = append(examples, climax.Example{
+		Usecase:     "--warnings-as-errors",
+		Description: "Builds all the files, failing if there are any warnings",
+	})
+	examples = append(examples, climax.Example{
+		Usecase:     "--profile print",
+		Description: "Builds all the files with the settings of the print profile",
+	})
+	examples = append(examples, climax.Example{
+		Usecase:     "--format pdf,html",
+		Description: "Builds all the files as pdf files and html sites",
+	})
+	return &BuildCommand{
+		name:     "build",
+		brief:    "builds the project files",
+		usage:    "[--name name[,name...]] [--warnings-as-errors] [--profile profile] [--format format[,format...]]",
+		help:     wordwrap.String(strings.TrimSpace(helpStr), terminalWidth),
+		group:    "",
+		flags:    flags,
+		examples: examples,
+		fs:       fs,
+		logger:   logger,
+		sources:  sources,
+	}
+}
+
+func (i *BuildCommand) Name() string {
+	return i.name
+}
+
+func (i *BuildCommand) Brief() string {
+	return i.brief
+}
+
+func (i *BuildCommand) Usage() string {
+	return i.usage
+}
+
+func (i *BuildCommand) Help() string {
+	return i.help
+}
+
+func (i *BuildCommand) Group() string {
+	return i.group
+}
+
+func (i *BuildCommand) Flags() []climax.Flag {
+	return i.flags
+}
+
+func (i *BuildCommand) Examples() []climax.Example {
+	return i.examples
+}
+
+func (i *BuildCommand) Run(context climax.Context) int {
+	// 1. Load the configuration file, with the other sources of values
+	layered, err := loadLayeredConfig(i.fs, i.sources)
+	if err != nil {
+		i.logger.Error("Unable to load the configuration file", slog.Any("error", err))
+		return 1
+	}
+	config := layered.Config
+	profile, _ := context.Get("profile")
+	if _, err = config.ProfileSettings(profile); err != nil {
+		i.logger.Error("Unable to find the profile", slog.Any("error", err))
+		return 1
+	}
+
+	// 2. Select the files to build, with their settings
+	files, err := selectFiles(config, listFlag(context, "name"))
+	if err != nil {
+		i.logger.Error("Unable to select the files to build", slog.Any("error", err))
+		return 1
+	}
+	formats := listFlag(context, "format")
+	options := make([]*render.Options, 0, len(files))
+	for _, file := range files {
+		settings, err := config.FileSettings(&file, profile)
+		if err != nil {
+			i.logger.Error("Unable to find the profile", slog.String("file", file.Name), slog.Any("error", err))
+			return 1
+		}
+		if len(formats) > 0 {
+			settings.Formats = formats
+		}
+		fileOptions, err := render.NewOptions(settings)
+		if err != nil {
+			i.logger.Error("Invalid settings", slog.String("file", file.Name), slog.Any("error", err))
+			return 1
+		}
+		options = append(options, fileOptions)
+	}
+	warningsAsErrors := context.Is("warnings-as-errors")
+	warnings := 0
+	if len(files) == 0 {
+		i.logger.Warn("There are no files to build in the configuration file")
+		warnings++
+	}
+
+	// 3. Build each one of the files, recording the files used by each one in the build cache
+	configFile, _, err := findConfig(i.fs)
+	if err != nil {
+		i.logger.Error("Unable to find the configuration file", slog.Any("error", err))
+		return 1
+	}
+	parser := markdown.NewParser(i.fs)
+	for index, file := range files {
+		i.logger.Info(fmt.Sprintf("Building %s", file.Name))
+		doc, fileWarnings, err := parser.ParseFile(file.Path)
+		if err != nil {
+			i.logger.Error("Unable to parse the markdown file", slog.String("file", file.Name), slog.Any("error", err))
+			return 1
+		}
+		for _, warning := range fileWarnings {
+			i.logger.Warn(warning.String(), slog.String("file", file.Name))
+		}
+		warnings += len(fileWarnings)
+		doc.Meta.Merge(config, &file)
+		if err := doc.Meta.Validate(); err != nil {
+			i.logger.Error("Invalid document metadata", slog.String("file", file.Name), slog.Any("error", err))
+			return 1
+		}
+		entry := cache.NewEntry(file.Name)
+		for _, format := range options[index].Formats {
+			renderer, err := render.NewRenderer(format, options[index])
+			if err == nil {
+				err = renderer.Render(doc, i.fs, path.Clean(file.Output))
+			}
+			if err != nil {
+				i.logger.Error("Unable to render the output file", slog.String("file", file.Name), slog.Any("error", err))
+				return 1
+			}
+			entry.AddOutput(renderer.Output(path.Clean(file.Output)))
+		}
+		sources, err := render.Sources(i.fs, doc, options[index])
+		if err == nil {
+			dependencies := parser.Graph().Dependencies(path.Clean(file.Path))
+			err = entry.AddSources(i.fs, slices.Concat([]string{configFile}, dependencies, sources)...)
+		}
+		if err == nil {
+			err = cache.Save(i.fs, entry)
+		}
+		if err != nil {
+			i.logger.Error("Unable to update the build cache", slog.String("file", file.Name), slog.Any("error", err))
+			return 1
+		}
+	}
+
+	// 4. Fail if there were warnings and they are errors
+	if warningsAsErrors && warnings > 0 {
+		i.logger.Error(fmt.Sprintf("The build had %d warning(s)", warnings))
+		return 1
+	}
+	return 0
+}
+
+func (i *BuildCommand) Command(args []string) climax.Command {
+	return FromCommand(i, args)
+}
+
+// selectFiles returns the files of the configuration with the given names, or all of them if
+// no names are given, failing if any of the names does not exist
+func selectFiles(config *model.Config, names []string) ([]model.File, error) {
+	if len(names) == 0 {
+		return config.Files, nil
+	}
+	result := make([]model.File, 0, len(names))
+	for _, name := range names {
+		index := slices.IndexFunc(config.Files, func(f model.File) bool {
+			return f.Name == name
+		})
+		if index < 0 {
+			return nil, errors.Errorf("There is no file named %s in the configuration", name)
+		}
+		result = append(result, config.Files[index])
+	}
+	return result, nil
+}
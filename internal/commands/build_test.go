@@ -0,0 +1,373 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+
+	"github.com/chordflower/riconto/internal/cache"
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/primalskill/golog"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	buildConfig = `
+name = "sample"
+version = "0.0.1"
+
+[[files]]
+name = "Book A"
+output = "./dist/bookA"
+path = "./src/bookA/main.md"
+
+[[files]]
+name = "Book B"
+output = "./dist/bookB"
+path = "./src/bookB/main.md"
+`
+	buildMarkdown = `# Book
+
+Some paragraph with *emphasis* and ` + "`code`" + `.
+
+- First item
+- Second item
+
+> A quote
+
+` + "```go\nfunc main() {}\n```\n"
+)
+
+// newSiteFs returns a project with a file that includes others, linked between them, with the given settings
+func newSiteFs(settings string) afero.Fs {
+	memFs := afero.NewMemMapFs()
+	config := "name = \"site\"\n\n[[files]]\nname = \"Site\"\noutput = \"./dist/site\"\npath = \"./src/site/main.md\"\n" + settings
+	_ = afero.WriteFile(memFs, "riconto.toml", []byte(config), 0644)
+	_ = afero.WriteFile(memFs, "src/site/main.md", []byte("# Site\n\n[Details](./two.md#details) and "+
+		"![A logo](../../resources/logo.png)\n\n::include[./one.md]\n::include[./two.md]\n"), 0644)
+	_ = afero.WriteFile(memFs, "src/site/one.md", []byte("# One\n\nSee [two](two.md) & more[^more].\n\n[^more]: A note.\n"), 0644)
+	_ = afero.WriteFile(memFs, "src/site/two.md", []byte("# Two\n\n## Details\n\nBack to [one](./one.md) "+
+		"and [the top](#site).\n\n| Page | Size |\n|:-----|-----:|\n| One | 1 |\n"), 0644)
+	_ = afero.WriteFile(memFs, "resources/logo.png", []byte("logo"), 0644)
+	return memFs
+}
+
+func newBuildFs() afero.Fs {
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "riconto.toml", []byte(buildConfig), 0644)
+	_ = afero.WriteFile(memFs, "src/bookA/main.md", []byte(buildMarkdown), 0644)
+	_ = afero.WriteFile(memFs, "src/bookB/main.md", []byte(buildMarkdown+"\n<div>html</div>\n"), 0644)
+	return memFs
+}
+
+func TestBuildCommand(t *testing.T) {
+	Convey("#BuildCommand", t, func() {
+
+		Convey("It should be able to create a new command", func() {
+			buildCommand := NewBuildCommand(afero.NewMemMapFs(), golog.NewDiscard(), &model.Sources{})
+			So(buildCommand, ShouldNotBeNil)
+			So(buildCommand.Name(), ShouldEqual, "build")
+			So(buildCommand.Brief(), ShouldEqual, "builds the project files")
+		})
+
+		Convey("Given a project", func() {
+			memFs := newBuildFs()
+			buildCommand := NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{})
+
+			Convey("It should build all the files", func() {
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    make(map[string]string),
+				}
+				So(buildCommand.Run(context), ShouldEqual, 0)
+				data, err := afero.ReadFile(memFs, "dist/bookA.pdf")
+				So(err, ShouldBeNil)
+				So(bytes.HasPrefix(data, []byte("%PDF-")), ShouldBeTrue)
+				So(bytes.HasSuffix(data, []byte("%%EOF\n")), ShouldBeTrue)
+				_, err = memFs.Stat("dist/bookB.pdf")
+				So(err, ShouldBeNil)
+			})
+
+			Convey("It should fail with an unknown theme", func() {
+				sources := &model.Sources{Flags: map[string]string{"theme": "fancy"}}
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    make(map[string]string),
+				}
+				So(NewBuildCommand(memFs, golog.NewDiscard(), sources).Run(context), ShouldEqual, 1)
+				_, err := memFs.Stat("dist/bookA.pdf")
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("It should build with the settings of a profile", func() {
+				config := buildConfig + "\n[profiles.print]\nbleed = \"3mm\"\ncrop_marks = true\nwatermark = \"DRAFT\"\n"
+				So(afero.WriteFile(memFs, "riconto.toml", []byte(config), 0644), ShouldBeNil)
+				context := climax.Context{
+					Args:        []string{"--profile", "print"},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"profile": "print"},
+				}
+				So(buildCommand.Run(context), ShouldEqual, 0)
+				data, err := afero.ReadFile(memFs, "dist/bookA.pdf")
+				So(err, ShouldBeNil)
+				So(bytes.Contains(data, []byte("/TrimBox")), ShouldBeTrue)
+				So(bytes.Contains(data, []byte("/BleedBox")), ShouldBeTrue)
+			})
+
+			Convey("It should build with the settings of each file", func() {
+				config := strings.Replace(buildConfig, `path = "./src/bookA/main.md"`, `path = "./src/bookA/main.md"
+page_size = "a5"
+language = "pt-PT"
+metadata = { Publisher = "Someone" }`, 1)
+				So(afero.WriteFile(memFs, "riconto.toml", []byte(config), 0644), ShouldBeNil)
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    make(map[string]string),
+				}
+				So(buildCommand.Run(context), ShouldEqual, 0)
+				data, err := afero.ReadFile(memFs, "dist/bookA.pdf")
+				So(err, ShouldBeNil)
+				So(bytes.Contains(data, []byte("/MediaBox [0 0 419.53 595.28]")), ShouldBeTrue)
+				So(bytes.Contains(data, []byte("/Lang (pt-PT)")), ShouldBeTrue)
+				So(bytes.Contains(data, []byte("/Publisher (Someone)")), ShouldBeTrue)
+				data, err = afero.ReadFile(memFs, "dist/bookB.pdf")
+				So(err, ShouldBeNil)
+				So(bytes.Contains(data, []byte("/MediaBox [0 0 595.28 841.89]")), ShouldBeTrue)
+			})
+
+			Convey("It should fail with a missing font", func() {
+				So(afero.WriteFile(memFs, "riconto.toml", []byte("fonts = { regular = \"./fonts/missing.ttf\" }\n"+buildConfig), 0644), ShouldBeNil)
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    make(map[string]string),
+				}
+				So(buildCommand.Run(context), ShouldEqual, 1)
+				_, err := memFs.Stat("dist/bookA.pdf")
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("It should fail with an unknown profile", func() {
+				context := climax.Context{
+					Args:        []string{"--profile", "print"},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"profile": "print"},
+				}
+				So(buildCommand.Run(context), ShouldEqual, 1)
+				_, err := memFs.Stat("dist/bookA.pdf")
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("It should build only the named files", func() {
+				context := climax.Context{
+					Args:        []string{"--name", "Book B"},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"name": "Book B"},
+				}
+				So(buildCommand.Run(context), ShouldEqual, 0)
+				_, err := memFs.Stat("dist/bookA.pdf")
+				So(err, ShouldNotBeNil)
+				_, err = memFs.Stat("dist/bookB.pdf")
+				So(err, ShouldBeNil)
+			})
+
+			Convey("It should accept several comma separated names", func() {
+				context := climax.Context{
+					Args:        []string{"--name", "Book A,Book B"},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"name": "Book A,Book B"},
+				}
+				So(buildCommand.Run(context), ShouldEqual, 0)
+				_, err := memFs.Stat("dist/bookA.pdf")
+				So(err, ShouldBeNil)
+				_, err = memFs.Stat("dist/bookB.pdf")
+				So(err, ShouldBeNil)
+			})
+
+			Convey("It should fail with an unknown name", func() {
+				context := climax.Context{
+					Args:        []string{"--name", "Book C"},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"name": "Book C"},
+				}
+				So(buildCommand.Run(context), ShouldEqual, 1)
+			})
+
+			Convey("It should fail with warnings when they are errors", func() {
+				context := climax.Context{
+					Args:        []string{"--name", "Book B", "--warnings-as-errors"},
+					NonVariable: map[string]bool{"warnings-as-errors": true},
+					Variable:    map[string]string{"name": "Book B"},
+				}
+				So(buildCommand.Run(context), ShouldEqual, 1)
+			})
+
+			Convey("It should not fail without warnings when they are errors", func() {
+				context := climax.Context{
+					Args:        []string{"--name", "Book A", "--warnings-as-errors"},
+					NonVariable: map[string]bool{"warnings-as-errors": true},
+					Variable:    map[string]string{"name": "Book A"},
+				}
+				So(buildCommand.Run(context), ShouldEqual, 0)
+			})
+		})
+
+		Convey("Given a project with included files", func() {
+			context := climax.Context{
+				Args:        []string{"--format", "html"},
+				NonVariable: make(map[string]bool),
+				Variable:    map[string]string{"format": "html"},
+			}
+
+			Convey("It should build an html site with a page per include", func() {
+				memFs := newSiteFs("")
+				So(NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{}).Run(context), ShouldEqual, 0)
+				_, err := memFs.Stat("dist/site.pdf")
+				So(err, ShouldNotBeNil)
+				index, err := afero.ReadFile(memFs, "dist/site/index.html")
+				So(err, ShouldBeNil)
+				So(string(index), ShouldContainSubstring, "<title>Site</title>")
+				So(string(index), ShouldContainSubstring, `<li><a href="#site">Site</a>`)
+				So(string(index), ShouldContainSubstring, `<li><a href="two.html#details">Details</a></li>`)
+				So(string(index), ShouldContainSubstring, `<a href="two.html#details">Details</a> and <img src="resources/logo.png" alt="A logo">`)
+				So(string(index), ShouldContainSubstring, `<a rel="next" href="one.html">One</a>`)
+				one, err := afero.ReadFile(memFs, "dist/site/one.html")
+				So(err, ShouldBeNil)
+				So(string(one), ShouldContainSubstring, "<title>One - Site</title>")
+				So(string(one), ShouldContainSubstring, `<h1 id="one">One</h1>`)
+				So(string(one), ShouldContainSubstring, `<p>See <a href="two.html">two</a> &amp; more<sup><a class="footnote-ref" id="footnote-ref-1" href="#footnote-1">1</a></sup>.</p>`)
+				So(string(one), ShouldContainSubstring, "<li id=\"footnote-1\">\n<p>A note.</p>\n<a class=\"footnote-back\" href=\"#footnote-ref-1\">")
+				two, err := afero.ReadFile(memFs, "dist/site/two.html")
+				So(err, ShouldBeNil)
+				So(string(two), ShouldContainSubstring, `Back to <a href="one.html">one</a> and <a href="index.html#site">the top</a>.`)
+				So(string(two), ShouldContainSubstring, `<a rel="prev" href="one.html">One</a>`)
+				So(string(two), ShouldContainSubstring, "<thead>\n<tr><th style=\"text-align: left\">Page</th><th style=\"text-align: right\">Size</th></tr>\n</thead>")
+				logo, err := afero.ReadFile(memFs, "dist/site/resources/logo.png")
+				So(err, ShouldBeNil)
+				So(string(logo), ShouldEqual, "logo")
+				style, err := afero.ReadFile(memFs, "dist/site/style.css")
+				So(err, ShouldBeNil)
+				So(string(style), ShouldContainSubstring, "a { color: ")
+				entry, err := cache.Load(memFs, "Site")
+				So(err, ShouldBeNil)
+				So(entry.Outputs, ShouldResemble, []string{"dist/site"})
+			})
+
+			Convey("It should build an html site in a single page", func() {
+				memFs := newSiteFs("split = \"none\"\nformats = [\"html\", \"pdf\"]\n")
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    make(map[string]string),
+				}
+				So(NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{}).Run(context), ShouldEqual, 0)
+				_, err := memFs.Stat("dist/site.pdf")
+				So(err, ShouldBeNil)
+				_, err = memFs.Stat("dist/site/one.html")
+				So(err, ShouldNotBeNil)
+				index, err := afero.ReadFile(memFs, "dist/site/index.html")
+				So(err, ShouldBeNil)
+				So(string(index), ShouldContainSubstring, `<a href="#details">Details</a> and`)
+				So(string(index), ShouldContainSubstring, `<p>See <a href="#two">two</a> &amp; more<sup>`)
+				So(string(index), ShouldNotContainSubstring, `rel="next"`)
+			})
+
+			Convey("It should build an epub book", func() {
+				memFs := newSiteFs("")
+				context.Variable["format"] = "epub"
+				So(NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{}).Run(context), ShouldEqual, 0)
+				data, err := afero.ReadFile(memFs, "dist/site.epub")
+				So(err, ShouldBeNil)
+				So(bytes.HasPrefix(data, []byte("PK")), ShouldBeTrue)
+				So(bytes.Contains(data, []byte("mimetypeapplication/epub+zip")), ShouldBeTrue)
+			})
+
+			Convey("It should build a docx document", func() {
+				memFs := newSiteFs("")
+				context.Variable["format"] = "docx"
+				So(NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{}).Run(context), ShouldEqual, 0)
+				data, err := afero.ReadFile(memFs, "dist/site.docx")
+				So(err, ShouldBeNil)
+				So(bytes.HasPrefix(data, []byte("PK")), ShouldBeTrue)
+				So(bytes.Contains(data, []byte("word/document.xml")), ShouldBeTrue)
+			})
+
+			Convey("It should build an odt document", func() {
+				memFs := newSiteFs("")
+				context.Variable["format"] = "odt"
+				So(NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{}).Run(context), ShouldEqual, 0)
+				data, err := afero.ReadFile(memFs, "dist/site.odt")
+				So(err, ShouldBeNil)
+				So(bytes.HasPrefix(data, []byte("PK")), ShouldBeTrue)
+				So(bytes.Contains(data, []byte("mimetypeapplication/vnd.oasis.opendocument.text")), ShouldBeTrue)
+			})
+
+			Convey("It should build a latex project", func() {
+				memFs := newSiteFs("")
+				context.Variable["format"] = "latex"
+				So(NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{}).Run(context), ShouldEqual, 0)
+				data, err := afero.ReadFile(memFs, "dist/site-latex/site.tex")
+				So(err, ShouldBeNil)
+				So(string(data), ShouldContainSubstring, "\\begin{document}")
+			})
+
+			Convey("It should fail with an unknown format", func() {
+				memFs := newSiteFs("")
+				context.Variable["format"] = "rtf"
+				So(NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{}).Run(context), ShouldEqual, 1)
+			})
+		})
+
+		Convey("Without a project", func() {
+			buildCommand := NewBuildCommand(afero.NewMemMapFs(), golog.NewDiscard(), &model.Sources{})
+
+			Convey("It should fail", func() {
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    make(map[string]string),
+				}
+				So(buildCommand.Run(context), ShouldEqual, 1)
+			})
+		})
+
+		Convey("Given repeated flags", func() {
+			buildCommand := NewBuildCommand(afero.NewMemMapFs(), golog.NewDiscard(), &model.Sources{})
+
+			Convey("It should join their values", func() {
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"name": "b"},
+				}
+				context = joinRepeatedFlags(context, buildCommand.Flags(), []string{"--name", "a", "-n=b", "-w"})
+				So(listFlag(context, "name"), ShouldResemble, []string{"a", "b"})
+			})
+		})
+	})
+}
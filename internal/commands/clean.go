@@ -0,0 +1,238 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/buger/goterm"
+	"github.com/chordflower/riconto/internal/cache"
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/chordflower/riconto/internal/render"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+)
+
+type CleanCommand struct {
+	name     string
+	brief    string
+	usage    string
+	help     string
+	group    string
+	flags    []climax.Flag
+	examples []climax.Example
+	logger   *slog.Logger
+	fs       afero.Fs
+}
+
+func NewCleanCommand(fs afero.Fs, logger *slog.Logger) *CleanCommand {
+	terminalWidth := goterm.Width()
+	helpStr := "" +
+		"This command will delete the built files of a riconto project, reading the configuration " +
+		"file in the directory where the executable is called.\n" +
+		"For each file in the configuration, the files written to its output are deleted, together " +
+		"with its entry in the build cache.\n\n" +
+		"By default all the files are cleaned, and the whole build cache is deleted, but it is " +
+		"possible to select which ones to clean, by their names, with the option --name or -n, which " +
+		"can be given more than once or contain several names separated by commas.\n" +
+		"The option --dry-run or -d only lists the files that would be deleted.\n" +
+		"Files outside of the project directory are never deleted."
+	flags := make([]climax.Flag, 0, 2)
+	flags = append(flags, climax.Flag{
+		Name:     "name",
+		Short:    "n",
+		Usage:    "--name NAME[,NAME...]",
+		Help:     "The name(s) of the files to clean (default all)",
+		Variable: true,
+	})
+	flags = append(flags, climax.Flag{
+		Name:     "dry-run",
+		Short:    "d",
+		Usage:    "--dry-run",
+		Help:     "Only lists the files that would be deleted",
+		Variable: false,
+	})
+	examples := make([]climax.Example, 0, 3)
+	examples = append(examples, climax.Example{
+		Usecase:     "",
+		Description: "Deletes the built files of the project in the current directory",
+	})
+	examples = append(examples, climax.Example{
+		Usecase:     `--name "Book A"`,
+		Description: "Deletes only the built files of Book A",
+	})
+	examples = append(examples, climax.Example{
+		Usecase:     "--dry-run",
+		Description: "Lists the built files, without deleting them",
+	})
+	return &CleanCommand{
+		name:     "clean",
+		brief:    "cleans the built files",
+		usage:    "[--name name[,name...]] [--dry-run]",
+		help:     wordwrap.String(strings.TrimSpace(helpStr), terminalWidth),
+		group:    "",
+		flags:    flags,
+		examples: examples,
+		fs:       fs,
+		logger:   logger,
+	}
+}
+
+func (i *CleanCommand) Name() string {
+	return i.name
+}
+
+func (i *CleanCommand) Brief() string {
+	return i.brief
+}
+
+func (i *CleanCommand) Usage() string {
+	return i.usage
+}
+
+func (i *CleanCommand) Help() string {
+	return i.help
+}
+
+func (i *CleanCommand) Group() string {
+	return i.group
+}
+
+func (i *CleanCommand) Flags() []climax.Flag {
+	return i.flags
+}
+
+func (i *CleanCommand) Examples() []climax.Example {
+	return i.examples
+}
+
+func (i *CleanCommand) Run(context climax.Context) int {
+	// 1. Load the configuration file
+	config, err := loadConfig(i.fs)
+	if err != nil {
+		i.logger.Error("Unable to load the configuration file", slog.Any("error", err))
+		return 1
+	}
+
+	// 2. Select the files to clean
+	names := listFlag(context, "name")
+	files, err := selectFiles(config, names)
+	if err != nil {
+		i.logger.Error("Unable to select the files to clean", slog.Any("error", err))
+		return 1
+	}
+
+	// 3. Find what to delete, refusing paths outside the project
+	targets, err := i.targets(config, files, len(names) == 0)
+	if err != nil {
+		i.logger.Error("Unable to find the files to clean", slog.Any("error", err))
+		return 1
+	}
+	for _, target := range targets {
+		if outsideProject(target) {
+			i.logger.Error(fmt.Sprintf("Refusing to delete %s, which is outside the project", target))
+			return 1
+		}
+	}
+
+	// 4. Delete them, or only list them in a dry run
+	dryRun := context.Is("dry-run")
+	for _, target := range targets {
+		if _, err := i.fs.Stat(target); err != nil {
+			continue
+		}
+		if dryRun {
+			i.logger.Info(fmt.Sprintf("Would delete %s", target))
+			continue
+		}
+		if err := i.fs.RemoveAll(target); err != nil {
+			i.logger.Error(fmt.Sprintf("Unable to delete %s", target), slog.Any("error", err))
+			return 1
+		}
+		i.logger.Info(fmt.Sprintf("Deleted %s", target))
+	}
+	return 0
+}
+
+// targets returns the paths to delete for the given files, which include the whole build
+// cache when all the files are cleaned
+func (i *CleanCommand) targets(config *model.Config, files []model.File, all bool) ([]string, error) {
+	result := make([]string, 0)
+	add := func(target string) {
+		if target = path.Clean(target); !slices.Contains(result, target) {
+			result = append(result, target)
+		}
+	}
+	for _, file := range files {
+		for _, output := range outputs(config, &file) {
+			add(output)
+		}
+		entry, err := cache.Load(i.fs, file.Name)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			for _, output := range entry.Outputs {
+				add(output)
+			}
+		}
+		if !all {
+			add(cache.EntryPath(file.Name))
+		}
+	}
+	if all {
+		add(cache.Dir)
+	}
+	return result, nil
+}
+
+// outputs returns the paths written for each format of the given file, from its settings merged
+// with the ones of the project
+func outputs(config *model.Config, file *model.File) []string {
+	options := render.DefaultOptions()
+	if settings, err := config.FileSettings(file, ""); err == nil && len(settings.Formats) > 0 {
+		options.Formats = settings.Formats
+	}
+	result := make([]string, 0, len(options.Formats))
+	for _, format := range options.Formats {
+		if renderer, err := render.NewRenderer(format, options); err == nil {
+			result = append(result, renderer.Output(path.Clean(file.Output)))
+		}
+	}
+	return result
+}
+
+// outsideProject checks if the given path refers to the project directory itself, or to
+// something outside of it
+func outsideProject(target string) bool {
+	target = path.Clean(strings.ReplaceAll(target, string(os.PathSeparator), "/"))
+	target = strings.TrimPrefix(target, "/")
+	return target == "" || target == "." || target == ".." || strings.HasPrefix(target, "../")
+}
+
+func (i *CleanCommand) Command(args []string) climax.Command {
+	return FromCommand(i, args)
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"testing"
+
+	"github.com/chordflower/riconto/internal/cache"
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/primalskill/golog"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+)
+
+// newBuiltFs returns a project where all the files were built
+func newBuiltFs() afero.Fs {
+	memFs := newBuildFs()
+	NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{}).Run(climax.Context{
+		Args:        []string{},
+		NonVariable: make(map[string]bool),
+		Variable:    make(map[string]string),
+	})
+	return memFs
+}
+
+func TestCleanCommand(t *testing.T) {
+	Convey("#CleanCommand", t, func() {
+
+		Convey("It should be able to create a new command", func() {
+			cleanCommand := NewCleanCommand(afero.NewMemMapFs(), golog.NewDiscard())
+			So(cleanCommand, ShouldNotBeNil)
+			So(cleanCommand.Name(), ShouldEqual, "clean")
+			So(cleanCommand.Brief(), ShouldEqual, "cleans the built files")
+		})
+
+		Convey("Given a built project", func() {
+			memFs := newBuiltFs()
+			cleanCommand := NewCleanCommand(memFs, golog.NewDiscard())
+			entry, err := cache.Load(memFs, "Book A")
+			So(err, ShouldBeNil)
+			So(entry, ShouldNotBeNil)
+			So(entry.Outputs, ShouldResemble, []string{"dist/bookA.pdf"})
+			So(entry.UpToDate(memFs), ShouldBeTrue)
+
+			Convey("It should delete all the outputs and the build cache", func() {
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    make(map[string]string),
+				}
+				So(cleanCommand.Run(context), ShouldEqual, 0)
+				for _, name := range []string{"dist/bookA.pdf", "dist/bookB.pdf", cache.Dir} {
+					_, err := memFs.Stat(name)
+					So(err, ShouldNotBeNil)
+				}
+				_, err := memFs.Stat("src/bookA/main.md")
+				So(err, ShouldBeNil)
+			})
+
+			Convey("It should delete only the named files and their cache", func() {
+				context := climax.Context{
+					Args:        []string{"--name", "Book A"},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"name": "Book A"},
+				}
+				So(cleanCommand.Run(context), ShouldEqual, 0)
+				_, err := memFs.Stat("dist/bookA.pdf")
+				So(err, ShouldNotBeNil)
+				_, err = memFs.Stat(cache.EntryPath("Book A"))
+				So(err, ShouldNotBeNil)
+				_, err = memFs.Stat("dist/bookB.pdf")
+				So(err, ShouldBeNil)
+				_, err = memFs.Stat(cache.EntryPath("Book B"))
+				So(err, ShouldBeNil)
+			})
+
+			Convey("It should not delete anything in a dry run", func() {
+				context := climax.Context{
+					Args:        []string{"--dry-run"},
+					NonVariable: map[string]bool{"dry-run": true},
+					Variable:    make(map[string]string),
+				}
+				So(cleanCommand.Run(context), ShouldEqual, 0)
+				_, err := memFs.Stat("dist/bookA.pdf")
+				So(err, ShouldBeNil)
+				_, err = memFs.Stat(cache.Dir)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("It should fail with an unknown name", func() {
+				context := climax.Context{
+					Args:        []string{"--name", "Book C"},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"name": "Book C"},
+				}
+				So(cleanCommand.Run(context), ShouldEqual, 1)
+			})
+		})
+
+		Convey("Given outputs outside the project", func() {
+			memFs := afero.NewMemMapFs()
+			_ = afero.WriteFile(memFs, "riconto.toml", []byte(`
+name = "sample"
+
+[[files]]
+name = "Outside"
+output = "../elsewhere/book"
+path = "./src/main.md"
+`), 0644)
+			cleanCommand := NewCleanCommand(memFs, golog.NewDiscard())
+
+			Convey("It should refuse to delete them", func() {
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    make(map[string]string),
+				}
+				So(cleanCommand.Run(context), ShouldEqual, 1)
+			})
+		})
+
+		Convey("Given paths", func() {
+			Convey("It should detect the ones outside the project", func() {
+				So(outsideProject("dist/book.pdf"), ShouldBeFalse)
+				So(outsideProject("./dist/../book.pdf"), ShouldBeFalse)
+				So(outsideProject("/dist/book.pdf"), ShouldBeFalse)
+				So(outsideProject("../book.pdf"), ShouldBeTrue)
+				So(outsideProject("dist/../../book.pdf"), ShouldBeTrue)
+				So(outsideProject("."), ShouldBeTrue)
+			})
+		})
+	})
+}
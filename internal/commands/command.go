@@ -20,6 +20,13 @@
 package commands
 
 import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/spf13/afero"
 	"github.com/tucnak/climax"
 )
 
@@ -32,17 +39,24 @@ type Command interface {
 	Flags() []climax.Flag
 	Examples() []climax.Example
 	Run(context climax.Context) int
-	Command() climax.Command
+	Command(args []string) climax.Command
 }
 
-func FromCommand(c Command) climax.Command {
+// FromCommand returns the climax command of the given command, where args are the arguments of
+// the program, without its name, which are used to find the flags given more than once
+func FromCommand(c Command, args []string) climax.Command {
 	result := climax.Command{
-		Name:   c.Name(),
-		Brief:  c.Brief(),
-		Usage:  c.Usage(),
-		Help:   c.Help(),
-		Group:  c.Group(),
-		Handle: c.Run,
+		Name:  c.Name(),
+		Brief: c.Brief(),
+		Usage: c.Usage(),
+		Help:  c.Help(),
+		Group: c.Group(),
+		Handle: func(context climax.Context) int {
+			if index := slices.Index(args, c.Name()); index >= 0 {
+				context = joinRepeatedFlags(context, c.Flags(), args[index+1:])
+			}
+			return c.Run(context)
+		},
 	}
 	for _, flag := range c.Flags() {
 		result.AddFlag(flag)
@@ -52,3 +66,166 @@ func FromCommand(c Command) climax.Command {
 	}
 	return result
 }
+
+// joinRepeatedFlags joins, with commas, the values of the variable flags that are given
+// more than once in the arguments, since climax only keeps the last one
+func joinRepeatedFlags(context climax.Context, flags []climax.Flag, args []string) climax.Context {
+	values := make(map[string][]string)
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		for _, flag := range flags {
+			if !flag.Variable || (flag.Name != name && flag.Short != name) {
+				continue
+			}
+			if !hasValue && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			values[flag.Name] = append(values[flag.Name], value)
+		}
+	}
+	for name, list := range values {
+		if len(list) > 1 {
+			context.Variable[name] = strings.Join(list, ",")
+		}
+	}
+	return context
+}
+
+// ProjectFlag returns the value of the global --project or -C flag, which selects the directory
+// where to look for the project, and the given arguments without it
+func ProjectFlag(args []string) (string, []string) {
+	project := ""
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if name != "--project" && name != "-C" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		project = value
+	}
+	return project, rest
+}
+
+// DefineFlags returns the values of the global --define or -D flags, given as key=value, which
+// change the values of the project configuration, and the given arguments without them
+func DefineFlags(args []string) (map[string]string, []string, error) {
+	defines := make(map[string]string)
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if name != "--define" && name != "-D" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		key, text, ok := strings.Cut(value, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, nil, errors.Errorf("The value of %s must be key=value, not %s", name, value)
+		}
+		defines[strings.TrimSpace(key)] = text
+	}
+	return defines, rest, nil
+}
+
+// listFlag returns the comma separated values of the given flag, without empty values
+func listFlag(context climax.Context, name string) []string {
+	result := make([]string, 0)
+	value, ok := context.Get(name)
+	if !ok {
+		return result
+	}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// findConfig returns the name and format of the project configuration file, in any of the
+// supported formats, in the root of the given filesystem
+func findConfig(fs afero.Fs) (string, model.Format, error) {
+	filename, format, err := model.ConfigFile(fs, ".")
+	if err != nil {
+		return "", "", err
+	}
+	if filename == "" {
+		return "", "", errors.New("There is no configuration file in the project directory")
+	}
+	return filename, format, nil
+}
+
+// loadConfig loads the project configuration file, in any of the supported formats,
+// from the root of the given filesystem
+func loadConfig(fs afero.Fs) (*model.Config, error) {
+	filename, format, err := findConfig(fs)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := fs.Open(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to open the configuration file %s", filename)
+	}
+	defer func(reader afero.File) {
+		_ = reader.Close()
+	}(reader)
+	return model.ConfigFromFile(reader, format)
+}
+
+// loadLayeredConfig loads the project configuration file, in any of the supported formats, from the
+// root of the given filesystem, merged with the values of the given sources
+func loadLayeredConfig(fs afero.Fs, sources *model.Sources) (*model.Layered, error) {
+	filename, format, err := findConfig(fs)
+	if err != nil {
+		return nil, err
+	}
+	data, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read the configuration file %s", filename)
+	}
+	return sources.Merge(data, format, filename)
+}
+
+// saveConfig saves the given configuration over the project configuration file, in its format,
+// keeping the order of its fields, unless it does not follow the configuration schema
+func saveConfig(fs afero.Fs, config *model.Config) error {
+	filename, format, err := findConfig(fs)
+	if err != nil {
+		return err
+	}
+	original, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to read the configuration file %s", filename)
+	}
+	var buffer bytes.Buffer
+	if err = config.UpdateTo(original, &buffer, format); err != nil {
+		return errors.Wrapf(err, "Unable to update the configuration file %s", filename)
+	}
+	if err = model.ValidateConfig(buffer.Bytes(), format); err != nil {
+		return errors.Wrapf(err, "The updated configuration file %s would not be valid", filename)
+	}
+	if err = afero.WriteFile(fs, filename, buffer.Bytes(), 0o644); err != nil {
+		return errors.Wrapf(err, "Unable to write the configuration file %s", filename)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"testing"
+
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/primalskill/golog"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+)
+
+// contextCommand is a command that keeps the context it was run with
+type contextCommand struct {
+	*BuildCommand
+	context climax.Context
+}
+
+func (c *contextCommand) Run(context climax.Context) int {
+	c.context = context
+	return 0
+}
+
+func TestFromCommand(t *testing.T) {
+	Convey("#FromCommand", t, func() {
+		command := &contextCommand{BuildCommand: NewBuildCommand(afero.NewMemMapFs(), golog.NewDiscard(), &model.Sources{})}
+		context := climax.Context{
+			Args:        []string{},
+			NonVariable: make(map[string]bool),
+			Variable:    map[string]string{"name": "b"},
+		}
+
+		Convey("It should join the values of the flags given more than once after the command name", func() {
+			handle := FromCommand(command, []string{"--name", "c", "build", "--name", "a", "-n=b"}).Handle
+			So(handle(context), ShouldEqual, 0)
+			So(listFlag(command.context, "name"), ShouldResemble, []string{"a", "b"})
+		})
+
+		Convey("It should keep the values when the command name is not in the arguments", func() {
+			handle := FromCommand(command, []string{"--name", "a", "-n=b"}).Handle
+			So(handle(context), ShouldEqual, 0)
+			So(listFlag(command.context, "name"), ShouldResemble, []string{"b"})
+		})
+	})
+}
+
+func TestProjectFlag(t *testing.T) {
+	Convey("#ProjectFlag", t, func() {
+
+		Convey("It should remove the project flag from the arguments", func() {
+			project, args := ProjectFlag([]string{"-C", "books", "build", "--name", "Book A"})
+			So(project, ShouldEqual, "books")
+			So(args, ShouldResemble, []string{"build", "--name", "Book A"})
+		})
+
+		Convey("It should accept the long form with a value", func() {
+			project, args := ProjectFlag([]string{"list", "--project=../books", "-f", "json"})
+			So(project, ShouldEqual, "../books")
+			So(args, ShouldResemble, []string{"list", "-f", "json"})
+		})
+
+		Convey("It should keep the arguments after a double dash", func() {
+			project, args := ProjectFlag([]string{"fetch", "--", "-C", "books"})
+			So(project, ShouldBeEmpty)
+			So(args, ShouldResemble, []string{"fetch", "--", "-C", "books"})
+		})
+	})
+}
+
+func TestDefineFlags(t *testing.T) {
+	Convey("#DefineFlags", t, func() {
+
+		Convey("It should remove the define flags from the arguments", func() {
+			defines, args, err := DefineFlags([]string{"-D", "theme=book", "build", "--define=description=A = B", "-n", "Book A"})
+			So(err, ShouldBeNil)
+			So(defines, ShouldResemble, map[string]string{"theme": "book", "description": "A = B"})
+			So(args, ShouldResemble, []string{"build", "-n", "Book A"})
+		})
+
+		Convey("It should fail on values without a key", func() {
+			_, _, err := DefineFlags([]string{"build", "-D", "book"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "The value of -D must be key=value, not book")
+		})
+	})
+}
@@ -0,0 +1,428 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"text/tabwriter"
+
+	"emperror.dev/errors"
+	"github.com/buger/goterm"
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+)
+
+// configActions are the actions of the config command
+const configActions = "validate, get, set, show, add-author or convert"
+
+type ConfigCommand struct {
+	name     string
+	brief    string
+	usage    string
+	help     string
+	group    string
+	flags    []climax.Flag
+	examples []climax.Example
+	logger   *slog.Logger
+	fs       afero.Fs
+	out      io.Writer
+	sources  *model.Sources
+}
+
+func NewConfigCommand(fs afero.Fs, logger *slog.Logger, out io.Writer, sources *model.Sources) *ConfigCommand {
+	helpStr := "" +
+		"This command will work with the configuration file of a riconto project, found in the " +
+		"directory where the executable is called, running the action given as its first argument.\n\n" +
+		"The action validate checks the configuration file against the configuration schema, " +
+		"printing each problem found with its position in the file and the json pointer of the " +
+		"value, like riconto.toml:7:1: /files/0/name: minLength: got 0, want 1.\n\n" +
+		"The action get prints the value in the configuration file of the key given after it, which " +
+		"can be name, version, description, license or theme, with one license per line.\n\n" +
+		"The action set changes the value of the key given after it to the value given after the " +
+		"key, where the license is a comma separated list of licenses.\n\n" +
+		"The action show prints the effective values of the configuration, which is the configuration " +
+		"file merged over the user configuration file, riconto/config.toml in the user configuration " +
+		"directory, with the RICONTO_* environment variables, like RICONTO_THEME, and the global " +
+		"--define or -D options, like -D theme=book, merged over both. With --origin or -o it also " +
+		"prints where each value came from.\n\n" +
+		"The action add-author adds the author with the name given with --name or -n, and the " +
+		"optional --email or -e and --url or -u, to the configuration file.\n\n" +
+		"The action convert writes the configuration file in the format given with --to or -t, " +
+		"which can be json, yaml or toml, and removes the old one."
+	flags := make([]climax.Flag, 0, 5)
+	flags = append(flags, climax.Flag{
+		Name:     "name",
+		Short:    "n",
+		Usage:    "--name <name>",
+		Help:     "The name of the author to add",
+		Variable: true,
+	})
+	flags = append(flags, climax.Flag{
+		Name:     "email",
+		Short:    "e",
+		Usage:    "--email <email>",
+		Help:     "The email of the author to add",
+		Variable: true,
+	})
+	flags = append(flags, climax.Flag{
+		Name:     "url",
+		Short:    "u",
+		Usage:    "--url <url>",
+		Help:     "The site of the author to add",
+		Variable: true,
+	})
+	flags = append(flags, climax.Flag{
+		Name:     "to",
+		Short:    "t",
+		Usage:    "--to json|yaml|toml",
+		Help:     "The format to convert the configuration file to",
+		Variable: true,
+	})
+	flags = append(flags, climax.Flag{
+		Name:     "origin",
+		Short:    "o",
+		Usage:    "--origin",
+		Help:     "Prints where each value shown came from",
+		Variable: false,
+	})
+	examples := make([]climax.Example, 0, 6)
+	examples = append(examples, climax.Example{
+		Usecase:     "validate",
+		Description: "Validates the configuration file of the project in the current directory",
+	})
+	examples = append(examples, climax.Example{
+		Usecase:     "get version",
+		Description: "Prints the version of the project in the current directory",
+	})
+	examples = append(examples, climax.Example{
+		Usecase:     "set version 1.0.0",
+		Description: "Changes the version of the project in the current directory to 1.0.0",
+	})
+	examples = append(examples, climax.Example{
+		Usecase:     "show --origin",
+		Description: "Prints the effective configuration of the project in the current directory, and where its values came from",
+	})
+	examples = append(examples, climax.Example{
+		Usecase:     "add-author --name someone --email someone@example.com",
+		Description: "Adds the author someone to the project in the current directory",
+	})
+	examples = append(examples, climax.Example{
+		Usecase:     "convert --to yaml",
+		Description: "Converts the configuration file of the project in the current directory to yaml",
+	})
+	return &ConfigCommand{
+		name:     "config",
+		brief:    "works with the project configuration",
+		usage:    "validate|get <key>|set <key> <value>|show [--origin]|add-author --name <name>|convert --to <format>",
+		help:     wordwrap.String(strings.TrimSpace(helpStr), goterm.Width()),
+		group:    "",
+		flags:    flags,
+		examples: examples,
+		fs:       fs,
+		logger:   logger,
+		out:      out,
+		sources:  sources,
+	}
+}
+
+func (i *ConfigCommand) Name() string {
+	return i.name
+}
+
+func (i *ConfigCommand) Brief() string {
+	return i.brief
+}
+
+func (i *ConfigCommand) Usage() string {
+	return i.usage
+}
+
+func (i *ConfigCommand) Help() string {
+	return i.help
+}
+
+func (i *ConfigCommand) Group() string {
+	return i.group
+}
+
+func (i *ConfigCommand) Flags() []climax.Flag {
+	return i.flags
+}
+
+func (i *ConfigCommand) Examples() []climax.Example {
+	return i.examples
+}
+
+func (i *ConfigCommand) Run(context climax.Context) int {
+	if len(context.Args) == 0 {
+		i.logger.Error("The action is required, use " + configActions)
+		return 1
+	}
+	action, args := context.Args[0], context.Args[1:]
+	switch action {
+	case "validate":
+		return i.validate(args)
+	case "get":
+		return i.get(args)
+	case "set":
+		return i.set(args)
+	case "show":
+		return i.show(context, args)
+	case "add-author":
+		return i.addAuthor(context, args)
+	case "convert":
+		return i.convert(context, args)
+	default:
+		i.logger.Error(fmt.Sprintf("The action %s is not supported, use %s", action, configActions))
+		return 1
+	}
+}
+
+func (i *ConfigCommand) Command(args []string) climax.Command {
+	return FromCommand(i, args)
+}
+
+// validate validates the configuration file against the schema, printing its problems
+func (i *ConfigCommand) validate(args []string) int {
+	if len(args) > 0 {
+		i.logger.Error("The validate action has no arguments")
+		return 1
+	}
+	filename, format, err := findConfig(i.fs)
+	if err != nil {
+		i.logger.Error("Unable to find the configuration file", slog.Any("error", err))
+		return 1
+	}
+	data, err := afero.ReadFile(i.fs, filename)
+	if err != nil {
+		i.logger.Error("Unable to read the configuration file", slog.String("file", filename), slog.Any("error", err))
+		return 1
+	}
+	err = model.ValidateConfig(data, format)
+	var schemaErr *model.SchemaError
+	switch {
+	case errors.As(err, &schemaErr):
+		for _, problem := range schemaErr.Problems {
+			_, _ = fmt.Fprintf(i.out, "%s:%s\n", filename, problem)
+		}
+		i.logger.Error("The configuration file does not follow its schema", slog.String("file", filename), slog.Int("problems", len(schemaErr.Problems)))
+		return 1
+	case err != nil:
+		i.logger.Error("Unable to validate the configuration file", slog.String("file", filename), slog.Any("error", err))
+		return 1
+	}
+	_, _ = fmt.Fprintf(i.out, "%s is valid\n", filename)
+	return 0
+}
+
+// get prints the value of the given key of the configuration
+func (i *ConfigCommand) get(args []string) int {
+	if len(args) != 1 {
+		i.logger.Error("The get action needs the key to print")
+		return 1
+	}
+	config, err := loadConfig(i.fs)
+	if err != nil {
+		i.logger.Error("Unable to load the configuration file", slog.Any("error", err))
+		return 1
+	}
+	values, err := config.Get(args[0])
+	if err != nil {
+		i.logger.Error("Unable to get the value", slog.Any("error", err))
+		return 1
+	}
+	for _, value := range values {
+		_, _ = fmt.Fprintln(i.out, value)
+	}
+	return 0
+}
+
+// set changes the value of the given key of the configuration and saves it
+func (i *ConfigCommand) set(args []string) int {
+	if len(args) != 2 {
+		i.logger.Error("The set action needs the key and the value to set")
+		return 1
+	}
+	config, err := loadConfig(i.fs)
+	if err != nil {
+		i.logger.Error("Unable to load the configuration file", slog.Any("error", err))
+		return 1
+	}
+	key := args[0]
+	if err = config.Set(key, args[1]); err != nil {
+		i.logger.Error("Unable to set the value", slog.Any("error", err))
+		return 1
+	}
+	if err = saveConfig(i.fs, config); err != nil {
+		i.logger.Error("Unable to save the configuration file", slog.Any("error", err))
+		return 1
+	}
+	i.logger.Info(fmt.Sprintf("Changed the %s of the project", key))
+	return 0
+}
+
+// show prints the effective values of the configuration, and where they came from with --origin
+func (i *ConfigCommand) show(context climax.Context, args []string) int {
+	if len(args) > 0 {
+		i.logger.Error("The show action has no arguments, use its options")
+		return 1
+	}
+	layered, err := loadLayeredConfig(i.fs, i.sources)
+	if err != nil {
+		i.logger.Error("Unable to load the configuration file", slog.Any("error", err))
+		return 1
+	}
+	writer := tabwriter.NewWriter(i.out, 0, 0, listPadding, ' ', 0)
+	for _, key := range model.LayeredKeys {
+		_, _ = fmt.Fprintf(writer, "%s\t%s", key, layeredValue(layered.Config, key))
+		if context.Is("origin") {
+			_, _ = fmt.Fprintf(writer, "\t%s", layered.Origins[key])
+		}
+		_, _ = fmt.Fprintln(writer)
+	}
+	if err = writer.Flush(); err != nil {
+		i.logger.Error("Unable to write the configuration", slog.Any("error", err))
+		return 1
+	}
+	return 0
+}
+
+// layeredValue returns the value of the given key of the layered configuration as text
+func layeredValue(config *model.Config, key string) string {
+	switch key {
+	case "authors":
+		authors := make([]string, 0, len(config.Authors))
+		for _, author := range config.Authors {
+			text := author.Name
+			if author.Email != "" {
+				text += " <" + author.Email + ">"
+			}
+			if author.URL != "" {
+				text += " (" + author.URL + ")"
+			}
+			authors = append(authors, text)
+		}
+		return strings.Join(authors, ", ")
+	case "files":
+		files := make([]string, 0, len(config.Files))
+		for _, file := range config.Files {
+			files = append(files, file.Name)
+		}
+		return strings.Join(files, ", ")
+	}
+	values, _ := config.Get(key)
+	return strings.Join(values, ", ")
+}
+
+// addAuthor adds the author given in the flags to the configuration and saves it
+func (i *ConfigCommand) addAuthor(context climax.Context, args []string) int {
+	if len(args) > 0 {
+		i.logger.Error("The add-author action has no arguments, use its options")
+		return 1
+	}
+	name, _ := context.Get("name")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		i.logger.Error("The name parameter is required!")
+		return 1
+	}
+	config, err := loadConfig(i.fs)
+	if err != nil {
+		i.logger.Error("Unable to load the configuration file", slog.Any("error", err))
+		return 1
+	}
+	author := model.NewAuthor(name)
+	author.Email, _ = context.Get("email")
+	author.URL, _ = context.Get("url")
+	if !config.AddAuthor(author) {
+		i.logger.Error(fmt.Sprintf("There is already an author named %s in the configuration file", name))
+		return 1
+	}
+	if err = saveConfig(i.fs, config); err != nil {
+		i.logger.Error("Unable to save the configuration file", slog.Any("error", err))
+		return 1
+	}
+	i.logger.Info(fmt.Sprintf("Added the author %s to the project", name))
+	return 0
+}
+
+// convert writes the configuration in the format given in the flags and removes the old file
+func (i *ConfigCommand) convert(context climax.Context, args []string) int {
+	if len(args) > 0 {
+		i.logger.Error("The convert action has no arguments, use its options")
+		return 1
+	}
+	to, _ := context.Get("to")
+	format, err := model.ParseFormat(strings.ToLower(strings.TrimSpace(to)))
+	if err != nil {
+		i.logger.Error("The to parameter must be json, yaml or toml", slog.Any("error", err))
+		return 1
+	}
+	filename, current, err := findConfig(i.fs)
+	if err != nil {
+		i.logger.Error("Unable to find the configuration file", slog.Any("error", err))
+		return 1
+	}
+	if current == format {
+		i.logger.Error(fmt.Sprintf("The configuration file is already in %s", format))
+		return 1
+	}
+	config, err := loadConfig(i.fs)
+	if err != nil {
+		i.logger.Error("Unable to load the configuration file", slog.Any("error", err))
+		return 1
+	}
+
+	var buffer bytes.Buffer
+	if err = config.SaveTo(&buffer, format); err == nil && format == model.FormatJson {
+		// the json encoder writes everything in one line
+		var indented bytes.Buffer
+		if err = json.Indent(&indented, buffer.Bytes(), "", "  "); err == nil {
+			buffer = indented
+		}
+	}
+	if err == nil {
+		err = model.ValidateConfig(buffer.Bytes(), format)
+	}
+	if err != nil {
+		i.logger.Error("Unable to convert the configuration file", slog.Any("error", err))
+		return 1
+	}
+	converted := "riconto." + format.String()
+	if err = afero.WriteFile(i.fs, converted, buffer.Bytes(), 0o644); err != nil {
+		i.logger.Error("Unable to write the configuration file", slog.String("file", converted), slog.Any("error", err))
+		return 1
+	}
+	// Only one configuration file can exist, so the converted one is removed when the old one stays
+	if err = i.fs.Remove(filename); err != nil {
+		_ = i.fs.Remove(converted)
+		i.logger.Error("Unable to remove the old configuration file", slog.String("file", filename), slog.Any("error", err))
+		return 1
+	}
+	i.logger.Info(fmt.Sprintf("Converted %s to %s", filename, converted))
+	return 0
+}
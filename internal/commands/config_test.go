@@ -0,0 +1,205 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/primalskill/golog"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+)
+
+// keepFs is a filesystem where the given file can not be removed
+type keepFs struct {
+	afero.Fs
+	name string
+}
+
+func (f *keepFs) Remove(name string) error {
+	if name == f.name {
+		return os.ErrPermission
+	}
+	return f.Fs.Remove(name)
+}
+
+func TestConfigCommand(t *testing.T) {
+	Convey("#ConfigCommand", t, func() {
+
+		Convey("It should be able to create a new command", func() {
+			configCommand := NewConfigCommand(afero.NewMemMapFs(), golog.NewDiscard(), &bytes.Buffer{}, &model.Sources{})
+			So(configCommand, ShouldNotBeNil)
+			So(configCommand.Name(), ShouldEqual, "config")
+			So(configCommand.Brief(), ShouldEqual, "works with the project configuration")
+		})
+
+		Convey("Given a project", func() {
+			memFs := afero.NewMemMapFs()
+			out := &bytes.Buffer{}
+			configCommand := NewConfigCommand(memFs, golog.NewDiscard(), out, &model.Sources{})
+			runWith := func(variable map[string]string, args ...string) int {
+				return configCommand.Run(climax.Context{
+					Args:        args,
+					NonVariable: make(map[string]bool),
+					Variable:    variable,
+				})
+			}
+			run := func(args ...string) int {
+				return runWith(make(map[string]string), args...)
+			}
+
+			Convey("It should validate a valid configuration file", func() {
+				So(afero.WriteFile(memFs, "riconto.toml", []byte(buildConfig), 0o644), ShouldBeNil)
+				So(run("validate"), ShouldEqual, 0)
+				So(out.String(), ShouldEqual, "riconto.toml is valid\n")
+			})
+
+			Convey("It should print the problems of an invalid configuration file", func() {
+				So(afero.WriteFile(memFs, "riconto.yaml", []byte("name: sample\nfiles:\n  - name: \"\"\n    path: ./src/main.md\n    output: ./dist/main\nstyle: plain\n"), 0o644), ShouldBeNil)
+				So(run("validate"), ShouldEqual, 1)
+				So(out.String(), ShouldEqual, ""+
+					"riconto.yaml:3:5: /files/0/name: minLength: got 0, want 1\n"+
+					"riconto.yaml:6:1: /style: additional properties 'style' not allowed\n")
+			})
+
+			Convey("It should fail without a configuration file", func() {
+				So(run("validate"), ShouldEqual, 1)
+				So(run("get", "name"), ShouldEqual, 1)
+			})
+
+			Convey("Given a valid configuration file", func() {
+				So(afero.WriteFile(memFs, "riconto.toml", []byte(buildConfig), 0o644), ShouldBeNil)
+				exists := func(name string) bool {
+					exists, err := afero.Exists(memFs, name)
+					So(err, ShouldBeNil)
+					return exists
+				}
+				config := func() *model.Config {
+					config, err := loadConfig(memFs)
+					So(err, ShouldBeNil)
+					return config
+				}
+
+				Convey("It should print the values of the keys", func() {
+					So(run("get", "name"), ShouldEqual, 0)
+					So(run("get", "version"), ShouldEqual, 0)
+					So(out.String(), ShouldEqual, "sample\n0.0.1\n")
+				})
+
+				Convey("It should fail on unknown keys", func() {
+					So(run("get", "style"), ShouldEqual, 1)
+					So(run("set", "style", "plain"), ShouldEqual, 1)
+					So(run("get"), ShouldEqual, 1)
+				})
+
+				Convey("It should change the values of the keys", func() {
+					So(run("set", "version", "1.0.0"), ShouldEqual, 0)
+					So(run("set", "description", "A sample project"), ShouldEqual, 0)
+					So(run("set", "license", "MIT, CC0,MIT"), ShouldEqual, 0)
+					So(config().Version, ShouldEqual, "1.0.0")
+					So(config().Description, ShouldEqual, "A sample project")
+					So(config().License, ShouldResemble, []string{"MIT", "CC0"})
+					content, err := afero.ReadFile(memFs, "riconto.toml")
+					So(err, ShouldBeNil)
+					So(string(content), ShouldEqual, "\nname = \"sample\"\nversion = \"1.0.0\"\ndescription = \"A sample project\"\nlicense = [\"MIT\", \"CC0\"]\n"+buildConfig[strings.Index(buildConfig, "\n\n")+1:])
+				})
+
+				Convey("It should not save invalid values", func() {
+					So(run("set", "name", ""), ShouldEqual, 1)
+					So(config().Name, ShouldEqual, "sample")
+				})
+
+				Convey("It should show the effective values and their origins", func() {
+					sources := &model.Sources{
+						Lookup: func(name string) (string, bool) {
+							return "MIT", name == "RICONTO_LICENSE"
+						},
+						Flags: map[string]string{"theme": "book"},
+					}
+					showCommand := NewConfigCommand(memFs, golog.NewDiscard(), out, sources)
+					context := climax.Context{
+						Args:        []string{"show"},
+						NonVariable: map[string]bool{"origin": true},
+						Variable:    make(map[string]string),
+					}
+					So(showCommand.Run(context), ShouldEqual, 0)
+					So(out.String(), ShouldEqual, ""+
+						"name         sample          project riconto.toml\n"+
+						"version      0.0.1           project riconto.toml\n"+
+						"description                  default\n"+
+						"license      MIT             environment RICONTO_LICENSE\n"+
+						"theme        book            flag theme\n"+
+						"authors                      default\n"+
+						"files        Book A, Book B  project riconto.toml\n")
+					So(config().Theme, ShouldBeEmpty)
+				})
+
+				Convey("It should add authors", func() {
+					So(runWith(map[string]string{"name": "someone", "email": "someone@example.com", "url": "https://example.com"}, "add-author"), ShouldEqual, 0)
+					So(config().Authors, ShouldResemble, []model.Author{{Name: "someone", URL: "https://example.com", Email: "someone@example.com"}})
+					So(runWith(map[string]string{"name": "someone"}, "add-author"), ShouldEqual, 1)
+					So(runWith(map[string]string{}, "add-author"), ShouldEqual, 1)
+					So(runWith(map[string]string{"name": "other", "email": "not an email"}, "add-author"), ShouldEqual, 1)
+					So(config().Authors, ShouldHaveLength, 1)
+				})
+
+				Convey("It should convert the configuration file to other formats", func() {
+					original := config()
+					So(runWith(map[string]string{"to": "yaml"}, "convert"), ShouldEqual, 0)
+					So(exists("riconto.toml"), ShouldBeFalse)
+					So(config(), ShouldResemble, original)
+					So(runWith(map[string]string{"to": "JSON"}, "convert"), ShouldEqual, 0)
+					So(exists("riconto.yaml"), ShouldBeFalse)
+					content, err := afero.ReadFile(memFs, "riconto.json")
+					So(err, ShouldBeNil)
+					So(string(content), ShouldStartWith, "{\n  \"name\": \"sample\",\n")
+					So(config(), ShouldResemble, original)
+				})
+
+				Convey("It should keep only the old configuration file when it can not be removed", func() {
+					command := NewConfigCommand(&keepFs{Fs: memFs, name: "riconto.toml"}, golog.NewDiscard(), out, &model.Sources{})
+					So(command.Run(climax.Context{
+						Args:        []string{"convert"},
+						NonVariable: make(map[string]bool),
+						Variable:    map[string]string{"to": "yaml"},
+					}), ShouldEqual, 1)
+					So(exists("riconto.toml"), ShouldBeTrue)
+					So(exists("riconto.yaml"), ShouldBeFalse)
+				})
+
+				Convey("It should not convert to the same or unknown formats", func() {
+					So(runWith(map[string]string{"to": "toml"}, "convert"), ShouldEqual, 1)
+					So(runWith(map[string]string{"to": "xml"}, "convert"), ShouldEqual, 1)
+					So(exists("riconto.toml"), ShouldBeTrue)
+				})
+			})
+
+			Convey("It should fail without an action or with an unknown one", func() {
+				So(run(), ShouldEqual, 1)
+				So(run("unknown"), ShouldEqual, 1)
+			})
+		})
+	})
+}
@@ -229,9 +229,7 @@ func (i *CreateCommand) Run(context climax.Context) int {
 	strict := context.Is("strict")
 
 	// 6. Check if a configuration file already exists in the current directory
-	if i.fileExists("riconto.json") ||
-		i.fileExists("riconto.toml") ||
-		i.fileExists("riconto.yaml") {
+	if filename, _, err := model.ConfigFile(i.fs, "."); err != nil || filename != "" {
 		i.logger.Error("There is already a configuration file in the current directory!")
 		if strict {
 			return 1
@@ -292,16 +290,8 @@ func (i *CreateCommand) Run(context climax.Context) int {
 	return 0
 }
 
-func (i *CreateCommand) Command() climax.Command {
-	return FromCommand(i)
-}
-
-func (i *CreateCommand) fileExists(filename string) bool {
-	info, err := i.fs.Stat(filename)
-	if os.IsNotExist(err) {
-		return false
-	}
-	return !info.IsDir()
+func (i *CreateCommand) Command(args []string) climax.Command {
+	return FromCommand(i, args)
 }
 
 func createTmpFs() afero.Fs {
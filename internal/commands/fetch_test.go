@@ -0,0 +1,140 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/chordflower/riconto/internal/fetch"
+	"github.com/primalskill/golog"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+)
+
+// stubFetcher copies its files into the destiny of every fetch
+type stubFetcher struct {
+	files map[string]string
+}
+
+func (s *stubFetcher) Schemes() []string {
+	return []string{"stub"}
+}
+
+func (s *stubFetcher) Fetch(_ *url.URL, destiny afero.Fs) error {
+	for name, content := range s.files {
+		if err := afero.WriteFile(destiny, name, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestFetchCommand(t *testing.T) {
+	Convey("#FetchCommand", t, func() {
+
+		Convey("It should be able to create a new command", func() {
+			fetchCommand := NewFetchCommand(afero.NewMemMapFs(), golog.NewDiscard())
+			So(fetchCommand, ShouldNotBeNil)
+			So(fetchCommand.Name(), ShouldEqual, "fetch")
+			So(fetchCommand.Brief(), ShouldEqual, "fetches a project from a remote source")
+		})
+
+		Convey("Given a remote project", func() {
+			memFs := afero.NewMemMapFs()
+			stub := &stubFetcher{files: map[string]string{
+				"riconto.toml":      buildConfig,
+				"src/bookA/main.md": buildMarkdown,
+			}}
+			fetchCommand := NewFetchCommand(memFs, golog.NewDiscard())
+			registry, err := fetch.NewRegistry(stub)
+			So(err, ShouldBeNil)
+			fetchCommand.registry = registry
+			run := func(variable map[string]string, args ...string) int {
+				return fetchCommand.Run(climax.Context{
+					Args:        args,
+					NonVariable: make(map[string]bool),
+					Variable:    variable,
+				})
+			}
+
+			Convey("It should copy it into the current directory", func() {
+				So(run(map[string]string{}, "stub://example.com/project"), ShouldEqual, 0)
+				config, err := loadConfig(memFs)
+				So(err, ShouldBeNil)
+				So(config.Name, ShouldEqual, "sample")
+				content, err := afero.ReadFile(memFs, "src/bookA/main.md")
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, buildMarkdown)
+			})
+
+			Convey("It should copy it into the given path", func() {
+				So(run(map[string]string{}, "stub://example.com/project", "books/sample"), ShouldEqual, 0)
+				_, err := memFs.Stat("books/sample/riconto.toml")
+				So(err, ShouldBeNil)
+				_, err = memFs.Stat("books/sample/src/bookA/main.md")
+				So(err, ShouldBeNil)
+			})
+
+			Convey("It should not copy anything when files already exist", func() {
+				So(afero.WriteFile(memFs, "src/bookA/main.md", []byte("# Mine\n"), 0o644), ShouldBeNil)
+				So(run(map[string]string{}, "stub://example.com/project"), ShouldEqual, 1)
+				_, err := memFs.Stat("riconto.toml")
+				So(err, ShouldNotBeNil)
+
+				Convey("Unless they should be kept", func() {
+					So(run(map[string]string{"conflict": "keep"}, "stub://example.com/project"), ShouldEqual, 0)
+					content, err := afero.ReadFile(memFs, "src/bookA/main.md")
+					So(err, ShouldBeNil)
+					So(string(content), ShouldEqual, "# Mine\n")
+					_, err = memFs.Stat("riconto.toml")
+					So(err, ShouldBeNil)
+				})
+
+				Convey("Or overwritten", func() {
+					So(run(map[string]string{"conflict": "overwrite"}, "stub://example.com/project"), ShouldEqual, 0)
+					content, err := afero.ReadFile(memFs, "src/bookA/main.md")
+					So(err, ShouldBeNil)
+					So(string(content), ShouldEqual, buildMarkdown)
+				})
+			})
+
+			Convey("It should fail when the fetched files are not a project", func() {
+				delete(stub.files, "riconto.toml")
+				So(run(map[string]string{}, "stub://example.com/project"), ShouldEqual, 1)
+				_, err := memFs.Stat("src/bookA/main.md")
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("It should fail with an unsupported scheme", func() {
+				So(run(map[string]string{}, "svn://example.com/project"), ShouldEqual, 1)
+			})
+
+			Convey("It should fail with an invalid conflict resolution", func() {
+				So(run(map[string]string{"conflict": "merge"}, "stub://example.com/project"), ShouldEqual, 1)
+			})
+
+			Convey("It should fail without an url", func() {
+				So(run(map[string]string{}), ShouldEqual, 1)
+			})
+		})
+	})
+}
@@ -0,0 +1,297 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"emperror.dev/errors"
+	"github.com/buger/goterm"
+	"github.com/chordflower/riconto/internal/cache"
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/goccy/go-yaml"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+)
+
+// listPadding is the space between the columns of the text table
+const listPadding = 2
+
+// listMinWidth is the minimum width of a column, when the table is shrunk to the terminal width
+const listMinWidth = 8
+
+// listItem represents one of the files of the configuration in the output of the list command
+type listItem struct {
+	Name     string `json:"name" yaml:"name" toml:"name"`
+	Output   string `json:"output" yaml:"output" toml:"output"`
+	Path     string `json:"path" yaml:"path" toml:"path"`
+	Built    bool   `json:"built" yaml:"built" toml:"built"`
+	UpToDate bool   `json:"up_to_date" yaml:"up_to_date" toml:"up_to_date"`
+}
+
+// Status returns the build status of the item as text
+func (l *listItem) Status() string {
+	switch {
+	case l.UpToDate:
+		return "up to date"
+	case l.Built:
+		return "outdated"
+	default:
+		return "not built"
+	}
+}
+
+type ListCommand struct {
+	name     string
+	brief    string
+	usage    string
+	help     string
+	group    string
+	flags    []climax.Flag
+	examples []climax.Example
+	logger   *slog.Logger
+	fs       afero.Fs
+	out      io.Writer
+	width    int
+}
+
+func NewListCommand(fs afero.Fs, logger *slog.Logger, out io.Writer) *ListCommand {
+	terminalWidth := goterm.Width()
+	helpStr := "" +
+		"This command will list the files of a riconto project, reading the configuration " +
+		"file in the directory where the executable is called.\n" +
+		"For each file its name, output, markdown file and build status are printed, where the " +
+		"status tells if the file was built and if its output is up to date with its sources.\n\n" +
+		"The option --format or -f selects the output format, which can be text, the default, " +
+		"for a table that fits the terminal, or json, yaml, toml and csv for scripts."
+	flags := make([]climax.Flag, 0, 1)
+	flags = append(flags, climax.Flag{
+		Name:     "format",
+		Short:    "f",
+		Usage:    "--format text|json|yaml|toml|csv",
+		Help:     "The output format (default text)",
+		Variable: true,
+	})
+	examples := make([]climax.Example, 0, 2)
+	examples = append(examples, climax.Example{
+		Usecase:     "",
+		Description: "Lists the files of the project in the current directory as a table",
+	})
+	examples = append(examples, climax.Example{
+		Usecase:     "--format json",
+		Description: "Lists the files of the project in the current directory in json",
+	})
+	return &ListCommand{
+		name:     "list",
+		brief:    "lists the project files",
+		usage:    "[--format text|json|yaml|toml|csv]",
+		help:     wordwrap.String(strings.TrimSpace(helpStr), terminalWidth),
+		group:    "",
+		flags:    flags,
+		examples: examples,
+		fs:       fs,
+		logger:   logger,
+		out:      out,
+		width:    terminalWidth,
+	}
+}
+
+func (i *ListCommand) Name() string {
+	return i.name
+}
+
+func (i *ListCommand) Brief() string {
+	return i.brief
+}
+
+func (i *ListCommand) Usage() string {
+	return i.usage
+}
+
+func (i *ListCommand) Help() string {
+	return i.help
+}
+
+func (i *ListCommand) Group() string {
+	return i.group
+}
+
+func (i *ListCommand) Flags() []climax.Flag {
+	return i.flags
+}
+
+func (i *ListCommand) Examples() []climax.Example {
+	return i.examples
+}
+
+func (i *ListCommand) Run(context climax.Context) int {
+	// 1. Get the format to use
+	format := "text"
+	if context.Is("format") {
+		format, _ = context.Get("format")
+		format = strings.ToLower(strings.TrimSpace(format))
+	}
+
+	// 2. Load the configuration file
+	config, err := loadConfig(i.fs)
+	if err != nil {
+		i.logger.Error("Unable to load the configuration file", slog.Any("error", err))
+		return 1
+	}
+
+	// 3. Find the build status of each of the files
+	items := make([]listItem, 0, len(config.Files))
+	for _, file := range config.Files {
+		item, err := i.item(config, file)
+		if err != nil {
+			i.logger.Error("Unable to read the build cache", slog.String("file", file.Name), slog.Any("error", err))
+			return 1
+		}
+		items = append(items, item)
+	}
+
+	// 4. Write the files in the given format
+	switch format {
+	case "text":
+		err = i.writeText(items)
+	case "json":
+		err = jsoniter.NewEncoder(i.out).Encode(items)
+	case "yaml":
+		err = yaml.NewEncoder(i.out).Encode(items)
+	case "toml":
+		err = toml.NewEncoder(i.out).Encode(map[string][]listItem{"files": items})
+	case "csv":
+		err = i.writeCsv(items)
+	default:
+		i.logger.Error(fmt.Sprintf("The format %s is not supported", format))
+		return 1
+	}
+	if err != nil {
+		i.logger.Error("Unable to write the list of files", slog.Any("error", err))
+		return 1
+	}
+
+	return 0
+}
+
+func (i *ListCommand) Command(args []string) climax.Command {
+	return FromCommand(i, args)
+}
+
+// item returns the given file of the configuration with its build status
+func (i *ListCommand) item(config *model.Config, file model.File) (listItem, error) {
+	item := listItem{Name: file.Name, Output: file.Output, Path: file.Path}
+	entry, err := cache.Load(i.fs, file.Name)
+	if err != nil || entry == nil {
+		return item, err
+	}
+	item.Built = true
+	// the configuration may have changed the file since it was built, and it is only up to date
+	// when it was built in all of its formats
+	_, sameSource := entry.Sources[path.Clean(file.Path)]
+	allOutputs := true
+	for _, output := range outputs(config, &file) {
+		if !slices.Contains(entry.Outputs, output) {
+			allOutputs = false
+		}
+	}
+	item.UpToDate = sameSource && allOutputs && entry.UpToDate(i.fs)
+	return item, nil
+}
+
+// writeText writes the items as a table, shrinking the widest columns to fit the terminal
+func (i *ListCommand) writeText(items []listItem) error {
+	rows := [][]string{{"NAME", "OUTPUT", "SOURCE", "STATUS"}}
+	for _, item := range items {
+		rows = append(rows, []string{item.Name, item.Output, item.Path, item.Status()})
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for column, cell := range row {
+			widths[column] = max(widths[column], utf8.RuneCountInString(cell))
+		}
+	}
+	if i.width > 0 {
+		for total(widths) > i.width {
+			widest := 0
+			for column := range widths {
+				if widths[column] > widths[widest] {
+					widest = column
+				}
+			}
+			if widths[widest] <= listMinWidth {
+				break
+			}
+			widths[widest]--
+		}
+	}
+	table := goterm.NewTable(0, 0, listPadding, ' ', 0)
+	for _, row := range rows {
+		for column, cell := range row {
+			if column > 0 {
+				_, _ = io.WriteString(table, "\t")
+			}
+			_, _ = io.WriteString(table, truncate(cell, widths[column]))
+		}
+		_, _ = io.WriteString(table, "\n")
+	}
+	_, err := io.WriteString(i.out, table.String())
+	return err
+}
+
+// writeCsv writes the items as csv, with a header
+func (i *ListCommand) writeCsv(items []listItem) error {
+	writer := csv.NewWriter(i.out)
+	_ = writer.Write([]string{"name", "output", "path", "built", "up_to_date"})
+	for _, item := range items {
+		_ = writer.Write([]string{item.Name, item.Output, item.Path, strconv.FormatBool(item.Built), strconv.FormatBool(item.UpToDate)})
+	}
+	writer.Flush()
+	return errors.Wrap(writer.Error(), "Unable to write the csv")
+}
+
+// total returns the width of a table with columns of the given widths
+func total(widths []int) int {
+	result := listPadding * (len(widths) - 1)
+	for _, width := range widths {
+		result += width
+	}
+	return result
+}
+
+// truncate shortens the given text to the given width, ending it with an ellipsis when shortened
+func truncate(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:width-1]) + "…"
+}
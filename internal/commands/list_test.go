@@ -0,0 +1,216 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/primalskill/golog"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+)
+
+func TestListCommand(t *testing.T) {
+	Convey("#ListCommand", t, func() {
+
+		Convey("It should be able to create a new command", func() {
+			listCommand := NewListCommand(afero.NewMemMapFs(), golog.NewDiscard(), &bytes.Buffer{})
+			So(listCommand, ShouldNotBeNil)
+			So(listCommand.Name(), ShouldEqual, "list")
+			So(listCommand.Brief(), ShouldEqual, "lists the project files")
+		})
+
+		Convey("Given a project where one file was built", func() {
+			memFs := newBuildFs()
+			So(NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{}).Run(climax.Context{
+				Args:        []string{},
+				NonVariable: make(map[string]bool),
+				Variable:    map[string]string{"name": "Book A"},
+			}), ShouldEqual, 0)
+			out := &bytes.Buffer{}
+			listCommand := NewListCommand(memFs, golog.NewDiscard(), out)
+			listCommand.width = 0
+			run := func(format string) int {
+				variable := make(map[string]string)
+				if format != "" {
+					variable["format"] = format
+				}
+				return listCommand.Run(climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    variable,
+				})
+			}
+
+			Convey("It should list the files as a table", func() {
+				So(run(""), ShouldEqual, 0)
+				So(out.String(), ShouldEqual, ""+
+					"NAME    OUTPUT        SOURCE               STATUS\n"+
+					"Book A  ./dist/bookA  ./src/bookA/main.md  up to date\n"+
+					"Book B  ./dist/bookB  ./src/bookB/main.md  not built\n")
+			})
+
+			Convey("It should shrink the table to the terminal width", func() {
+				listCommand.width = 40
+				So(run("text"), ShouldEqual, 0)
+				lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+				So(lines, ShouldHaveLength, 3)
+				for _, line := range lines {
+					So(len([]rune(line)), ShouldBeLessThanOrEqualTo, 40)
+				}
+				So(lines[1], ShouldEqual, "Book A  ./dist/b…  ./src/bo…  up to date")
+			})
+
+			Convey("It should mark the changed files as outdated", func() {
+				So(afero.WriteFile(memFs, "src/bookA/main.md", []byte("# Changed\n"), 0o644), ShouldBeNil)
+				So(run("csv"), ShouldEqual, 0)
+				So(out.String(), ShouldEqual, ""+
+					"name,output,path,built,up_to_date\n"+
+					"Book A,./dist/bookA,./src/bookA/main.md,true,false\n"+
+					"Book B,./dist/bookB,./src/bookB/main.md,false,false\n")
+			})
+
+			Convey("It should list the files in json", func() {
+				So(run("json"), ShouldEqual, 0)
+				So(out.String(), ShouldStartWith, `[{"name":"Book A","output":"./dist/bookA","path":"./src/bookA/main.md","built":true,"up_to_date":true},`)
+			})
+
+			Convey("It should list the files in yaml", func() {
+				So(run("yaml"), ShouldEqual, 0)
+				So(out.String(), ShouldStartWith, "- name: Book A\n  output: ./dist/bookA\n")
+			})
+
+			Convey("It should list the files in toml", func() {
+				So(run("toml"), ShouldEqual, 0)
+				So(out.String(), ShouldStartWith, "[[files]]\nname = 'Book A'\n")
+			})
+
+			Convey("It should fail with an unknown format", func() {
+				So(run("xml"), ShouldEqual, 1)
+				So(out.String(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("Given a project built as a latex project", func() {
+			memFs := newBuildFs()
+			config := strings.Replace(buildConfig, `output = "./dist/bookA"`, `output = "./dist/paper.v1"
+formats = ["latex"]`, 1)
+			So(afero.WriteFile(memFs, "riconto.toml", []byte(config), 0644), ShouldBeNil)
+			So(NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{}).Run(climax.Context{
+				Args:        []string{},
+				NonVariable: make(map[string]bool),
+				Variable:    map[string]string{"name": "Book A"},
+			}), ShouldEqual, 0)
+			out := &bytes.Buffer{}
+
+			Convey("It should list the file as up to date", func() {
+				So(NewListCommand(memFs, golog.NewDiscard(), out).Run(climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"format": "csv"},
+				}), ShouldEqual, 0)
+				So(out.String(), ShouldContainSubstring, "Book A,./dist/paper.v1,./src/bookA/main.md,true,true\n")
+			})
+		})
+
+		Convey("Given a project built as an html site with a dot in its output", func() {
+			memFs := newBuildFs()
+			config := strings.Replace(buildConfig, `output = "./dist/bookA"`, `output = "./dist/book.v2"
+formats = ["html"]`, 1)
+			So(afero.WriteFile(memFs, "riconto.toml", []byte(config), 0644), ShouldBeNil)
+			So(NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{}).Run(climax.Context{
+				Args:        []string{},
+				NonVariable: make(map[string]bool),
+				Variable:    map[string]string{"name": "Book A"},
+			}), ShouldEqual, 0)
+			out := &bytes.Buffer{}
+
+			Convey("It should list the file as up to date", func() {
+				So(NewListCommand(memFs, golog.NewDiscard(), out).Run(climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"format": "csv"},
+				}), ShouldEqual, 0)
+				So(out.String(), ShouldContainSubstring, "Book A,./dist/book.v2,./src/bookA/main.md,true,true\n")
+			})
+		})
+
+		Convey("Given a site built with its images, fonts and bibliography", func() {
+			memFs := newSiteFs("formats = [\"html\", \"epub\"]\nfonts = { regular = \"./fonts/Serif.ttf\" }\n")
+			So(afero.WriteFile(memFs, "fonts/Serif.ttf", []byte("ttf"), 0o644), ShouldBeNil)
+			So(afero.WriteFile(memFs, "src/site/refs.bib", []byte("@book{knuth84, title={The TeXbook}}\n"), 0o644), ShouldBeNil)
+			So(afero.WriteFile(memFs, "src/site/figure.png", []byte("figure"), 0o644), ShouldBeNil)
+			So(afero.WriteFile(memFs, "src/site/one.md", []byte("# One\n\n![A figure](figure.png)\n"), 0o644), ShouldBeNil)
+			content, err := afero.ReadFile(memFs, "src/site/main.md")
+			So(err, ShouldBeNil)
+			So(afero.WriteFile(memFs, "src/site/main.md", append([]byte("---\nbibliography: refs.bib\n---\n"), content...), 0o644), ShouldBeNil)
+			build := func(variable map[string]string) {
+				So(NewBuildCommand(memFs, golog.NewDiscard(), &model.Sources{}).Run(climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    variable,
+				}), ShouldEqual, 0)
+			}
+			status := func() string {
+				out := &bytes.Buffer{}
+				So(NewListCommand(memFs, golog.NewDiscard(), out).Run(climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"format": "csv"},
+				}), ShouldEqual, 0)
+				return strings.Split(strings.TrimSpace(out.String()), "\n")[1]
+			}
+			build(make(map[string]string))
+
+			Convey("It should list the file as up to date", func() {
+				So(status(), ShouldEqual, "Site,./dist/site,./src/site/main.md,true,true")
+			})
+
+			Convey("It should mark the file as outdated when the files it uses change", func() {
+				for _, filename := range []string{"riconto.toml", "resources/logo.png", "fonts/Serif.ttf", "src/site/refs.bib", "src/site/figure.png"} {
+					build(make(map[string]string))
+					So(status(), ShouldEqual, "Site,./dist/site,./src/site/main.md,true,true")
+					content, err := afero.ReadFile(memFs, filename)
+					So(err, ShouldBeNil)
+					So(afero.WriteFile(memFs, filename, append(content, '\n'), 0o644), ShouldBeNil)
+					So(status(), ShouldEqual, "Site,./dist/site,./src/site/main.md,true,false")
+				}
+			})
+
+			Convey("It should mark the file as outdated when it was not built in all of its formats", func() {
+				build(map[string]string{"format": "html"})
+				So(status(), ShouldEqual, "Site,./dist/site,./src/site/main.md,true,false")
+			})
+		})
+
+		Convey("It should fail without a configuration file", func() {
+			listCommand := NewListCommand(afero.NewMemMapFs(), golog.NewDiscard(), &bytes.Buffer{})
+			So(listCommand.Run(climax.Context{
+				Args:        []string{},
+				NonVariable: make(map[string]bool),
+				Variable:    make(map[string]string),
+			}), ShouldEqual, 1)
+		})
+	})
+}
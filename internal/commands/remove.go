@@ -0,0 +1,144 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/buger/goterm"
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+)
+
+type RemoveCommand struct {
+	name     string
+	brief    string
+	usage    string
+	help     string
+	group    string
+	flags    []climax.Flag
+	examples []climax.Example
+	logger   *slog.Logger
+	fs       afero.Fs
+}
+
+func NewRemoveCommand(fs afero.Fs, logger *slog.Logger) *RemoveCommand {
+	terminalWidth := goterm.Width()
+	helpStr := "" +
+		"This command will remove a file from a riconto project, updating the configuration file " +
+		"in the directory where the executable is called, in its own format.\n" +
+		"The name of the file is given with the option --name or -n, and must exist in the " +
+		"configuration.\n" +
+		"The markdown files and the built files are kept, the clean command can be used before to " +
+		"delete the built ones."
+	flags := make([]climax.Flag, 0, 1)
+	flags = append(flags, climax.Flag{
+		Name:     "name",
+		Short:    "n",
+		Usage:    "--name NAME",
+		Help:     "The name of the file to remove",
+		Variable: true,
+	})
+	examples := make([]climax.Example, 0, 1)
+	examples = append(examples, climax.Example{
+		Usecase:     `--name "Book A"`,
+		Description: "Removes Book A from the project",
+	})
+	return &RemoveCommand{
+		name:     "remove",
+		brief:    "removes a file from the project",
+		usage:    "--name name",
+		help:     wordwrap.String(strings.TrimSpace(helpStr), terminalWidth),
+		group:    "",
+		flags:    flags,
+		examples: examples,
+		fs:       fs,
+		logger:   logger,
+	}
+}
+
+func (i *RemoveCommand) Name() string {
+	return i.name
+}
+
+func (i *RemoveCommand) Brief() string {
+	return i.brief
+}
+
+func (i *RemoveCommand) Usage() string {
+	return i.usage
+}
+
+func (i *RemoveCommand) Help() string {
+	return i.help
+}
+
+func (i *RemoveCommand) Group() string {
+	return i.group
+}
+
+func (i *RemoveCommand) Flags() []climax.Flag {
+	return i.flags
+}
+
+func (i *RemoveCommand) Examples() []climax.Example {
+	return i.examples
+}
+
+func (i *RemoveCommand) Run(context climax.Context) int {
+	// 1. Validate if name is passed
+	name, _ := context.Get("name")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		i.logger.Error("The name parameter is required!")
+		return 1
+	}
+
+	// 2. Load the configuration file
+	config, err := loadConfig(i.fs)
+	if err != nil {
+		i.logger.Error("Unable to load the configuration file", slog.Any("error", err))
+		return 1
+	}
+
+	// 3. Remove the file from the configuration
+	if !config.RemoveFile(model.NewFile(name, "", "")) {
+		i.logger.Error(fmt.Sprintf("There is no file named %s in the configuration file", name))
+		return 1
+	}
+
+	// 4. Save the configuration file
+	err = saveConfig(i.fs, config)
+	if err != nil {
+		i.logger.Error("Unable to save the configuration file", slog.Any("error", err))
+		return 1
+	}
+	i.logger.Info(fmt.Sprintf("Removed %s", name))
+
+	return 0
+}
+
+func (i *RemoveCommand) Command(args []string) climax.Command {
+	return FromCommand(i, args)
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package commands
+
+import (
+	"testing"
+
+	"github.com/primalskill/golog"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+	"github.com/tucnak/climax"
+)
+
+func TestRemoveCommand(t *testing.T) {
+	Convey("#RemoveCommand", t, func() {
+
+		Convey("It should be able to create a new command", func() {
+			removeCommand := NewRemoveCommand(afero.NewMemMapFs(), golog.NewDiscard())
+			So(removeCommand, ShouldNotBeNil)
+			So(removeCommand.Name(), ShouldEqual, "remove")
+			So(removeCommand.Brief(), ShouldEqual, "removes a file from the project")
+		})
+
+		Convey("Given a project", func() {
+			memFs := newBuildFs()
+			removeCommand := NewRemoveCommand(memFs, golog.NewDiscard())
+
+			Convey("It should remove the named file and keep its sources", func() {
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"name": "Book A"},
+				}
+				So(removeCommand.Run(context), ShouldEqual, 0)
+				config, err := loadConfig(memFs)
+				So(err, ShouldBeNil)
+				So(config.Files, ShouldHaveLength, 1)
+				So(config.Files[0].Name, ShouldEqual, "Book B")
+				So(config.Name, ShouldEqual, "sample")
+				_, err = memFs.Stat("src/bookA/main.md")
+				So(err, ShouldBeNil)
+			})
+
+			Convey("It should fail when the name does not exist", func() {
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    map[string]string{"name": "Book C"},
+				}
+				So(removeCommand.Run(context), ShouldEqual, 1)
+				content, err := afero.ReadFile(memFs, "riconto.toml")
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, buildConfig)
+			})
+
+			Convey("It should fail without a name", func() {
+				context := climax.Context{
+					Args:        []string{},
+					NonVariable: make(map[string]bool),
+					Variable:    make(map[string]string),
+				}
+				So(removeCommand.Run(context), ShouldEqual, 1)
+			})
+		})
+	})
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package directive
+
+import (
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/document"
+)
+
+// Cite represents the `:cite[key1, key2]{locator="p. 12"}` directive, which is replaced by a
+// citation of the given entries of the bibliography of the document
+type Cite struct{}
+
+func (d *Cite) Name() string {
+	return "cite"
+}
+
+func (d *Cite) Inline(_ *Context, node *Node) ([]document.Inline, error) {
+	keys := make([]string, 0)
+	for _, key := range strings.Split(node.Content, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if strings.ContainsAny(key, " \t{}%#\\") {
+			return nil, errors.Errorf("Invalid cite directive in %s: The key %q is invalid", node.Position, key)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.Errorf("The cite directive in %s has no keys", node.Position)
+	}
+	return []document.Inline{&document.Citation{Keys: keys, Locator: node.Attributes.String("locator", "")}}, nil
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package directive implements the generic directives of the markdown files, which are written
+// as `:name[content]{key=value}` inside text, or as `::name[content]{key=value}` in a line of their own.
+//
+// Each directive is handled by a Directive registered by name, that replaces it with blocks or
+// inlines of the document model.
+package directive
+
+import (
+	"fmt"
+	"path"
+	"slices"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/document"
+	"github.com/spf13/afero"
+)
+
+// Position represents the location of a directive in its source file
+type Position struct {
+	Path   string
+	Line   int
+	Column int
+}
+
+// String returns the position in the usual path:line:column form
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.Path, p.Line, p.Column)
+}
+
+// Node represents one use of a directive in a markdown file
+type Node struct {
+	Name       string
+	Content    string
+	Attributes *Attributes
+	Position   Position
+	// Leaf tells if the directive was written as a leaf block, in a line of its own.
+	Leaf bool
+}
+
+// Context represents the file where the directives are being handled
+type Context struct {
+	// Fs is the filesystem where the markdown files are.
+	Fs afero.Fs
+	// Path is the path of the markdown file with the directives.
+	Path string
+	// Parse parses another markdown file, that was included by the current one.
+	Parse func(filename string) (*document.Document, error)
+	// Warn registers a non fatal problem with a directive.
+	Warn func(position Position, message string)
+}
+
+// Resolve returns the path of the given file, relative to the directory of the current file,
+// unless it is absolute
+func (c *Context) Resolve(filename string) string {
+	if path.IsAbs(filename) {
+		return path.Clean(filename)
+	}
+	return path.Join(path.Dir(c.Path), filename)
+}
+
+// Directive represents the handler of a directive, which must also implement BlockDirective,
+// InlineDirective or both
+type Directive interface {
+	// Name returns the name used to write the directive.
+	Name() string
+}
+
+// BlockDirective represents a directive that can be used as a leaf block
+type BlockDirective interface {
+	Directive
+
+	// Block returns the blocks that replace the given directive.
+	Block(context *Context, node *Node) ([]document.Block, error)
+}
+
+// InlineDirective represents a directive that can be used inside text
+type InlineDirective interface {
+	Directive
+
+	// Inline returns the inlines that replace the given directive.
+	Inline(context *Context, node *Node) ([]document.Inline, error)
+}
+
+// Registry represents a set of directives, by name
+type Registry struct {
+	directives map[string]Directive
+}
+
+// NewRegistry creates a new registry with the given directives
+func NewRegistry(directives ...Directive) (*Registry, error) {
+	registry := &Registry{directives: make(map[string]Directive)}
+	for _, directive := range directives {
+		if err := registry.Register(directive); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// DefaultRegistry creates a new registry with the builtin directives
+func DefaultRegistry() *Registry {
+	registry, _ := NewRegistry(&Cite{}, &Include{}, &Toc{})
+	return registry
+}
+
+// Register adds the given directive to the registry, failing if there is already one with the same name
+func (r *Registry) Register(directive Directive) error {
+	if !IsName(directive.Name()) {
+		return errors.Errorf("The directive name %q is invalid", directive.Name())
+	}
+	if _, ok := r.directives[directive.Name()]; ok {
+		return errors.Errorf("There is already a directive named %s", directive.Name())
+	}
+	r.directives[directive.Name()] = directive
+	return nil
+}
+
+// Lookup returns the directive with the given name, if there is one
+func (r *Registry) Lookup(name string) (Directive, bool) {
+	directive, ok := r.directives[name]
+	return directive, ok
+}
+
+// Names returns the sorted names of the registered directives
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.directives))
+	for name := range r.directives {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package directive
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testDirective struct {
+	name string
+}
+
+func (d *testDirective) Name() string {
+	return d.name
+}
+
+func TestSyntax(t *testing.T) {
+	Convey("#ScanInline", t, func() {
+
+		Convey("It should scan the name, content and attributes", func() {
+			match, ok := ScanInline([]byte(`:abbr[HTML]{title="Hyper Text" lang=en} and more`))
+			So(ok, ShouldBeTrue)
+			So(match.Name, ShouldEqual, "abbr")
+			So(match.Content, ShouldEqual, "HTML")
+			So(match.Attributes, ShouldEqual, `title="Hyper Text" lang=en`)
+			So(match.Length, ShouldEqual, len(`:abbr[HTML]{title="Hyper Text" lang=en}`))
+		})
+
+		Convey("It should allow nested and escaped brackets in the content", func() {
+			match, ok := ScanInline([]byte(`:kbd[a [b] \]c]`))
+			So(ok, ShouldBeTrue)
+			So(match.Content, ShouldEqual, "a [b] ]c")
+		})
+
+		Convey("It should require the content", func() {
+			_, ok := ScanInline([]byte(":toc and more"))
+			So(ok, ShouldBeFalse)
+			_, ok = ScanInline([]byte(":name[unclosed"))
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("#ScanLeaf", t, func() {
+
+		Convey("It should scan directives without content or attributes", func() {
+			match, ok := ScanLeaf([]byte("::toc\n"))
+			So(ok, ShouldBeTrue)
+			So(match.Name, ShouldEqual, "toc")
+			So(match.Content, ShouldBeEmpty)
+			So(match.Attributes, ShouldBeEmpty)
+		})
+
+		Convey("It should allow spaces between the parts", func() {
+			match, ok := ScanLeaf([]byte("  :: include [./file.md] {level=2}  \n"))
+			So(ok, ShouldBeTrue)
+			So(match.Name, ShouldEqual, "include")
+			So(match.Content, ShouldEqual, "./file.md")
+			So(match.Attributes, ShouldEqual, "level=2")
+		})
+
+		Convey("It should reject lines with other text", func() {
+			_, ok := ScanLeaf([]byte("::include[./file.md] and more\n"))
+			So(ok, ShouldBeFalse)
+			_, ok = ScanLeaf([]byte(":include[./file.md]\n"))
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("#ParseAttributes", t, func() {
+
+		Convey("It should parse quoted values, bare values and flags", func() {
+			attributes, err := ParseAttributes(`type=video title="A \"good\" one" caption='x y' numbered`)
+			So(err, ShouldBeNil)
+			So(attributes.Keys(), ShouldResemble, []string{"type", "title", "caption", "numbered"})
+			So(attributes.String("type", ""), ShouldEqual, "video")
+			So(attributes.String("title", ""), ShouldEqual, `A "good" one`)
+			So(attributes.String("caption", ""), ShouldEqual, "x y")
+			So(attributes.String("missing", "default"), ShouldEqual, "default")
+			numbered, err := attributes.Bool("numbered", false)
+			So(err, ShouldBeNil)
+			So(numbered, ShouldBeTrue)
+		})
+
+		Convey("It should convert integer and boolean values", func() {
+			attributes, err := ParseAttributes("depth=3 numbered=false")
+			So(err, ShouldBeNil)
+			depth, err := attributes.Int("depth", 6)
+			So(err, ShouldBeNil)
+			So(depth, ShouldEqual, 3)
+			numbered, err := attributes.Bool("numbered", true)
+			So(err, ShouldBeNil)
+			So(numbered, ShouldBeFalse)
+			_, err = attributes.Int("numbered", 0)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("It should fail on unclosed quotes and missing names", func() {
+			_, err := ParseAttributes(`title="unclosed`)
+			So(err, ShouldNotBeNil)
+			_, err = ParseAttributes(`=value`)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	Convey("#Registry", t, func() {
+		registry := DefaultRegistry()
+
+		Convey("It should have the builtin directives", func() {
+			directive, ok := registry.Lookup("include")
+			So(ok, ShouldBeTrue)
+			So(directive, ShouldImplement, (*BlockDirective)(nil))
+		})
+
+		Convey("It should register new directives", func() {
+			So(registry.Register(&testDirective{name: "embed"}), ShouldBeNil)
+			So(registry.Names(), ShouldResemble, []string{"cite", "embed", "include", "toc"})
+		})
+
+		Convey("It should reject repeated and invalid names", func() {
+			So(registry.Register(&testDirective{name: "include"}), ShouldNotBeNil)
+			So(registry.Register(&testDirective{name: "two words"}), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package directive
+
+import (
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/document"
+)
+
+// Include represents the `::include[file]` directive, which is replaced by the contents of the
+// given markdown file, relative to the including one
+type Include struct{}
+
+func (d *Include) Name() string {
+	return "include"
+}
+
+func (d *Include) Block(context *Context, node *Node) ([]document.Block, error) {
+	target := strings.TrimSpace(node.Content)
+	if target == "" {
+		return nil, errors.Errorf("The include directive in %s has no file", node.Position)
+	}
+	filename := context.Resolve(target)
+	if _, err := context.Fs.Stat(filename); err != nil {
+		return nil, errors.Wrapf(err, "Unable to include %s in %s", target, node.Position)
+	}
+	doc, err := context.Parse(filename)
+	if err != nil {
+		return nil, err
+	}
+	return []document.Block{&document.Include{Path: filename, Blocks: doc.Blocks}}, nil
+}
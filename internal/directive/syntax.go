@@ -0,0 +1,311 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package directive
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"emperror.dev/errors"
+)
+
+// Match represents the parts of a directive found in a text, before its attributes are parsed
+type Match struct {
+	Name string
+	// Content is the text between the brackets, without the escapes.
+	Content string
+	// Attributes is the text between the braces, which is parsed by ParseAttributes.
+	Attributes string
+	// Length is the number of bytes of the directive in the scanned text.
+	Length int
+}
+
+// IsName checks if the given text is a valid directive name, that is, a letter followed by letters,
+// digits, dashes or underscores
+func IsName(name string) bool {
+	return len(name) > 0 && nameLength([]byte(name)) == len(name)
+}
+
+// nameLength returns the length of the name at the start of the given text
+func nameLength(text []byte) int {
+	for i, c := range text {
+		letter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || !((c >= '0' && c <= '9') || c == '-' || c == '_')) {
+			return i
+		}
+	}
+	return len(text)
+}
+
+// skipSpaces returns the position of the first character that is not a space or tab
+func skipSpaces(text []byte, position int) int {
+	for position < len(text) && (text[position] == ' ' || text[position] == '\t') {
+		position++
+	}
+	return position
+}
+
+// scanContent scans the content between brackets, starting at the opening bracket, allowing
+// nested brackets and backslash escapes
+func scanContent(text []byte, position int) (string, int, bool) {
+	var builder strings.Builder
+	depth := 0
+	for i := position + 1; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '\\':
+			if i+1 < len(text) && (text[i+1] == '[' || text[i+1] == ']' || text[i+1] == '\\') {
+				i++
+				builder.WriteByte(text[i])
+				continue
+			}
+			builder.WriteByte(c)
+		case '[':
+			depth++
+			builder.WriteByte(c)
+		case ']':
+			if depth == 0 {
+				return builder.String(), i + 1, true
+			}
+			depth--
+			builder.WriteByte(c)
+		case '\n', '\r':
+			return "", 0, false
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
+
+// scanAttributes scans the attributes between braces, starting at the opening brace
+func scanAttributes(text []byte, position int) (string, int, bool) {
+	quote := byte(0)
+	for i := position + 1; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\n' || c == '\r':
+			return "", 0, false
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}':
+			return string(text[position+1 : i]), i + 1, true
+		}
+	}
+	return "", 0, false
+}
+
+// ScanInline scans an inline directive at the start of the given text, which must start with
+// the colon, as in `:name[content]{key=value}`, where the attributes are optional
+func ScanInline(text []byte) (Match, bool) {
+	var match Match
+	if len(text) < 2 || text[0] != ':' {
+		return match, false
+	}
+	length := nameLength(text[1:])
+	if length == 0 || 1+length >= len(text) || text[1+length] != '[' {
+		return match, false
+	}
+	match.Name = string(text[1 : 1+length])
+	content, position, ok := scanContent(text, 1+length)
+	if !ok {
+		return match, false
+	}
+	match.Content = content
+	if position < len(text) && text[position] == '{' {
+		attributes, end, ok := scanAttributes(text, position)
+		if !ok {
+			return match, false
+		}
+		match.Attributes, position = attributes, end
+	}
+	match.Length = position
+	return match, true
+}
+
+// ScanLeaf scans a leaf directive that must fill the whole given line, except for spaces, as in
+// `::name[content]{key=value}`, where both the content and the attributes are optional
+func ScanLeaf(line []byte) (Match, bool) {
+	var match Match
+	position := skipSpaces(line, 0)
+	if position+2 >= len(line) || line[position] != ':' || line[position+1] != ':' {
+		return match, false
+	}
+	position = skipSpaces(line, position+2)
+	length := nameLength(line[position:])
+	if length == 0 {
+		return match, false
+	}
+	match.Name = string(line[position : position+length])
+	position = skipSpaces(line, position+length)
+	if position < len(line) && line[position] == '[' {
+		content, end, ok := scanContent(line, position)
+		if !ok {
+			return match, false
+		}
+		match.Content, position = content, skipSpaces(line, end)
+	}
+	if position < len(line) && line[position] == '{' {
+		attributes, end, ok := scanAttributes(line, position)
+		if !ok {
+			return match, false
+		}
+		match.Attributes, position = attributes, skipSpaces(line, end)
+	}
+	if len(strings.TrimRight(string(line[position:]), "\r\n")) > 0 {
+		return match, false
+	}
+	match.Length = len(line)
+	return match, true
+}
+
+// Attributes represents the key value pairs of a directive, keeping the order they were written
+type Attributes struct {
+	keys   []string
+	values map[string]string
+}
+
+// NewAttributes creates a new empty set of attributes
+func NewAttributes() *Attributes {
+	return &Attributes{
+		keys:   make([]string, 0),
+		values: make(map[string]string),
+	}
+}
+
+// ParseAttributes parses attributes written as `key=value key2="value 2" flag`, where the values
+// may be quoted with single or double quotes and keys without a value are flags
+func ParseAttributes(text string) (*Attributes, error) {
+	attributes := NewAttributes()
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) || runes[i] == ',' {
+			i++
+			continue
+		}
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '=' && runes[i] != ',' {
+			i++
+		}
+		key := string(runes[start:i])
+		if key == "" {
+			return nil, errors.Errorf("The attribute at column %d has no name", start+1)
+		}
+		if i >= len(runes) || runes[i] != '=' {
+			attributes.Set(key, "")
+			continue
+		}
+		i++
+		var value strings.Builder
+		if i < len(runes) && (runes[i] == '"' || runes[i] == '\'') {
+			quote := runes[i]
+			closed := false
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					value.WriteRune(runes[i])
+					continue
+				}
+				if runes[i] == quote {
+					closed = true
+					i++
+					break
+				}
+				value.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, errors.Errorf("The value of the attribute %s has no closing quote", key)
+			}
+		} else {
+			for ; i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != ','; i++ {
+				value.WriteRune(runes[i])
+			}
+		}
+		attributes.Set(key, value.String())
+	}
+	return attributes, nil
+}
+
+// Set changes the value of the given attribute, adding it if needed
+func (a *Attributes) Set(key, value string) {
+	if _, ok := a.values[key]; !ok {
+		a.keys = append(a.keys, key)
+	}
+	a.values[key] = value
+}
+
+// Keys returns the attribute keys, in the order they were written
+func (a *Attributes) Keys() []string {
+	return append(make([]string, 0, len(a.keys)), a.keys...)
+}
+
+// Has checks if the given attribute was written, with or without a value
+func (a *Attributes) Has(key string) bool {
+	_, ok := a.values[key]
+	return ok
+}
+
+// Get returns the value of the given attribute, which is empty for flags
+func (a *Attributes) Get(key string) (string, bool) {
+	value, ok := a.values[key]
+	return value, ok
+}
+
+// String returns the value of the given attribute, or the default value if it was not written
+func (a *Attributes) String(key, defaultValue string) string {
+	if value, ok := a.values[key]; ok {
+		return value
+	}
+	return defaultValue
+}
+
+// Bool returns the value of the given boolean attribute, where a flag means true
+func (a *Attributes) Bool(key string, defaultValue bool) (bool, error) {
+	value, ok := a.values[key]
+	if !ok {
+		return defaultValue, nil
+	}
+	if value == "" {
+		return true, nil
+	}
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.Errorf("The attribute %s must be true or false, not %q", key, value)
+	}
+	return result, nil
+}
+
+// Int returns the value of the given integer attribute
+func (a *Attributes) Int(key string, defaultValue int) (int, error) {
+	value, ok := a.values[key]
+	if !ok {
+		return defaultValue, nil
+	}
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.Errorf("The attribute %s must be an integer, not %q", key, value)
+	}
+	return result, nil
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package directive
+
+import (
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/document"
+)
+
+// Toc represents the `::toc{depth=3 numbered}` directive, which is replaced by the table of
+// contents of the whole document, with the headings up to the given depth
+type Toc struct{}
+
+func (d *Toc) Name() string {
+	return "toc"
+}
+
+func (d *Toc) Block(_ *Context, node *Node) ([]document.Block, error) {
+	depth, err := node.Attributes.Int("depth", 3)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid toc directive in %s", node.Position)
+	}
+	if depth < 1 || depth > 6 {
+		return nil, errors.Errorf("Invalid toc directive in %s: The depth must be between 1 and 6", node.Position)
+	}
+	numbered, err := node.Attributes.Bool("numbered", false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid toc directive in %s", node.Position)
+	}
+	return []document.Block{&document.TableOfContents{Depth: depth, Numbered: numbered}}, nil
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package document
+
+import (
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The ways of splitting a document into chapters
+const (
+	// SplitIncludes starts a chapter at each file included by the main file.
+	SplitIncludes = "includes"
+	// SplitHeadings starts a chapter at each heading of the highest level.
+	SplitHeadings = "headings"
+	// SplitNone keeps the whole document in a single chapter.
+	SplitNone = "none"
+)
+
+// Chapter represents a part of a document that is written on its own, like a page of a site
+type Chapter struct {
+	// Name identifies the chapter, from the file or heading where it starts, unique in the document.
+	Name string
+	// Title is the text of the first heading of the chapter, or empty when it has none.
+	Title string
+	// Path is the path of the file where the chapter starts.
+	Path string
+	// Sources are the paths of the files whose content starts in the chapter.
+	Sources []string
+	// Blocks are the blocks of the chapter, inside includes of the files they came from.
+	Blocks []Block
+}
+
+// chapterItem is a block of the document, with the paths of the includes that contain it
+type chapterItem struct {
+	block Block
+	// top is the index of the block of the main file where the item is.
+	top      int
+	includes []string
+}
+
+// Chapters splits the given document into chapters in the given way, where the includes that
+// are split keep the blocks of each chapter
+func Chapters(doc *Document, split string) []*Chapter {
+	items := chapterItems(doc.Blocks, -1, make([]string, 0))
+	if len(items) == 0 {
+		name := strings.TrimSuffix(path.Base(doc.Path), path.Ext(doc.Path))
+		return []*Chapter{{Name: name, Path: doc.Path, Sources: []string{doc.Path}, Blocks: make([]Block, 0)}}
+	}
+	top := 6
+	for _, heading := range Headings(doc.Blocks) {
+		top = min(top, heading.Level)
+	}
+	groups := make([][]chapterItem, 0)
+	for i, item := range items {
+		start := i == 0
+		switch split {
+		case SplitIncludes:
+			start = start || item.top != items[i-1].top && (len(item.includes) > 0 || len(items[i-1].includes) > 0)
+		case SplitHeadings:
+			heading, ok := item.block.(*Heading)
+			start = start || ok && heading.Level == top
+		}
+		if start {
+			groups = append(groups, make([]chapterItem, 0))
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], item)
+	}
+
+	result := make([]*Chapter, 0, len(groups))
+	seen := map[string]bool{doc.Path: true}
+	used := make(map[string]bool)
+	for _, group := range groups {
+		chapter := &Chapter{Path: doc.Path, Sources: make([]string, 0), Blocks: nestItems(group, 0)}
+		if includes := group[0].includes; len(includes) > 0 {
+			chapter.Path = includes[len(includes)-1]
+		}
+		if len(result) == 0 {
+			chapter.Sources = append(chapter.Sources, doc.Path)
+		}
+		for _, item := range group {
+			for _, include := range item.includes {
+				if !seen[include] {
+					seen[include] = true
+					chapter.Sources = append(chapter.Sources, include)
+				}
+			}
+		}
+		headings := Headings(chapter.Blocks)
+		if len(headings) > 0 {
+			chapter.Title = PlainText(headings[0].Content)
+		}
+		name := strings.TrimSuffix(path.Base(chapter.Path), path.Ext(chapter.Path))
+		if split == SplitHeadings && len(headings) > 0 && headings[0].ID != "" {
+			name = headings[0].ID
+		}
+		chapter.Name = name
+		for i := 1; used[chapter.Name]; i++ {
+			chapter.Name = name + "-" + strconv.Itoa(i)
+		}
+		used[chapter.Name] = true
+		result = append(result, chapter)
+	}
+	return result
+}
+
+// chapterItems returns the blocks inside the given ones, expanding the includes, where top is
+// the index of the blocks in the main file, or -1 when the blocks are the ones of the main file
+func chapterItems(blocks []Block, top int, includes []string) []chapterItem {
+	result := make([]chapterItem, 0, len(blocks))
+	for index, block := range blocks {
+		itemTop := top
+		if top < 0 {
+			itemTop = index
+		}
+		if include, ok := block.(*Include); ok {
+			result = append(result, chapterItems(include.Blocks, itemTop, append(slices.Clone(includes), include.Path))...)
+			continue
+		}
+		result = append(result, chapterItem{block: block, top: itemTop, includes: includes})
+	}
+	return result
+}
+
+// nestItems returns the blocks of the given items, inside includes from the given depth
+func nestItems(items []chapterItem, depth int) []Block {
+	result := make([]Block, 0, len(items))
+	for start := 0; start < len(items); {
+		if len(items[start].includes) <= depth {
+			result = append(result, items[start].block)
+			start++
+			continue
+		}
+		end := start + 1
+		for end < len(items) && len(items[end].includes) > depth &&
+			items[end].includes[depth] == items[start].includes[depth] && items[end].top == items[start].top {
+			end++
+		}
+		result = append(result, &Include{Path: items[start].includes[depth], Blocks: nestItems(items[start:end], depth+1)})
+		start = end
+	}
+	return result
+}
+
+// ChapterOf returns the index of the chapter where the given file starts, or -1 when there is none
+func ChapterOf(chapters []*Chapter, filename string) int {
+	return slices.IndexFunc(chapters, func(chapter *Chapter) bool {
+		return slices.Contains(chapter.Sources, filename)
+	})
+}
+
+// AnchorChapters returns the index of the chapter of each heading identifier in the given chapters
+func AnchorChapters(chapters []*Chapter) map[string]int {
+	result := make(map[string]int)
+	for index, chapter := range chapters {
+		for _, heading := range Headings(chapter.Blocks) {
+			if heading.ID != "" {
+				result[heading.ID] = index
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package document
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestChapters(t *testing.T) {
+	Convey("#Chapters", t, func() {
+		doc := &Document{Path: "src/main.md", Blocks: []Block{
+			heading(1, "book", "Book"),
+			&Paragraph{Content: []Inline{&Text{Value: "Preface"}}},
+			&Include{Path: "src/one.md", Blocks: []Block{
+				heading(1, "one", "One"),
+				&Include{Path: "src/note.md", Blocks: []Block{
+					&Paragraph{Content: []Inline{&Text{Value: "A note"}}},
+					heading(1, "two", "Two"),
+				}},
+				&Paragraph{Content: []Inline{&Text{Value: "More"}}},
+			}},
+			&Include{Path: "src/one.md", Blocks: []Block{heading(1, "one-1", "One")}},
+			&Paragraph{Content: []Inline{&Text{Value: "The end"}}},
+		}}
+
+		Convey("It should split at the includes of the main file", func() {
+			chapters := Chapters(doc, SplitIncludes)
+			So(chapters, ShouldHaveLength, 4)
+			So(chapters[0].Name, ShouldEqual, "main")
+			So(chapters[0].Title, ShouldEqual, "Book")
+			So(chapters[0].Sources, ShouldResemble, []string{"src/main.md"})
+			So(chapters[1].Name, ShouldEqual, "one")
+			So(chapters[1].Path, ShouldEqual, "src/one.md")
+			So(chapters[1].Sources, ShouldResemble, []string{"src/one.md", "src/note.md"})
+			So(chapters[1].Blocks, ShouldHaveLength, 1)
+			So(chapters[1].Blocks[0].(*Include).Blocks, ShouldHaveLength, 3)
+			So(chapters[2].Name, ShouldEqual, "one-1")
+			So(chapters[2].Sources, ShouldBeEmpty)
+			So(chapters[3].Name, ShouldEqual, "main-1")
+			So(chapters[3].Title, ShouldBeEmpty)
+			So(ChapterOf(chapters, "src/note.md"), ShouldEqual, 1)
+			So(AnchorChapters(chapters)["two"], ShouldEqual, 1)
+		})
+
+		Convey("It should split at the headings of the highest level, keeping their includes", func() {
+			chapters := Chapters(doc, SplitHeadings)
+			So(chapters, ShouldHaveLength, 4)
+			So(chapters[0].Name, ShouldEqual, "book")
+			So(chapters[1].Name, ShouldEqual, "one")
+			So(chapters[1].Sources, ShouldResemble, []string{"src/one.md", "src/note.md"})
+			one := chapters[1].Blocks[0].(*Include)
+			So(one.Path, ShouldEqual, "src/one.md")
+			So(one.Blocks, ShouldHaveLength, 2)
+			So(one.Blocks[1].(*Include).Path, ShouldEqual, "src/note.md")
+			So(chapters[2].Name, ShouldEqual, "two")
+			So(chapters[2].Path, ShouldEqual, "src/note.md")
+			two := chapters[2].Blocks[0].(*Include)
+			So(two.Blocks[0].(*Include).Blocks[0], ShouldHaveSameTypeAs, &Heading{})
+			So(two.Blocks, ShouldHaveLength, 2)
+			So(chapters[3].Name, ShouldEqual, "one-1")
+			So(chapters[3].Blocks, ShouldHaveLength, 2)
+		})
+
+		Convey("It should keep the whole document without splitting", func() {
+			chapters := Chapters(doc, SplitNone)
+			So(chapters, ShouldHaveLength, 1)
+			So(chapters[0].Blocks, ShouldHaveLength, 5)
+			So(chapters[0].Sources, ShouldResemble, []string{"src/main.md", "src/one.md", "src/note.md"})
+		})
+
+		Convey("It should have a chapter for empty documents", func() {
+			chapters := Chapters(&Document{Path: "empty.md"}, SplitIncludes)
+			So(chapters, ShouldHaveLength, 1)
+			So(chapters[0].Name, ShouldEqual, "empty")
+		})
+	})
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package document
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TableOfContents represents the place where the table of contents of the document is written
+type TableOfContents struct {
+	// Depth is the deepest heading level in the table.
+	Depth int
+	// Numbered tells if the entries are prefixed with their section numbers.
+	Numbered bool
+}
+
+func (*TableOfContents) isBlock() {}
+
+// ContentsEntry represents an heading in the table of contents, with the headings below it
+type ContentsEntry struct {
+	Heading *Heading
+	// Number is the section number of the heading, as in 1.2.3, or empty when not numbered.
+	Number   string
+	Children []*ContentsEntry
+}
+
+// Title returns the plain text of the entry, prefixed by its number
+func (e *ContentsEntry) Title() string {
+	title := PlainText(e.Heading.Content)
+	if e.Number != "" {
+		return e.Number + " " + title
+	}
+	return title
+}
+
+// Headings returns the headings in the given blocks, including the ones in included files,
+// but not the ones inside lists or quotes
+func Headings(blocks []Block) []*Heading {
+	result := make([]*Heading, 0)
+	for _, block := range blocks {
+		switch value := block.(type) {
+		case *Heading:
+			result = append(result, value)
+		case *Include:
+			result = append(result, Headings(value.Blocks)...)
+		}
+	}
+	return result
+}
+
+// Contents returns the tree of headings of the given blocks, up to the given depth, where the
+// numbers of the entries start at the highest heading level found
+func Contents(blocks []Block, depth int, numbered bool) []*ContentsEntry {
+	headings := Headings(blocks)
+	top := 6
+	for _, heading := range headings {
+		top = min(top, heading.Level)
+	}
+	result := make([]*ContentsEntry, 0)
+	counters := make([]int, 6)
+	stack := make([]*ContentsEntry, 0, 6)
+	for _, heading := range headings {
+		if heading.Level > depth {
+			continue
+		}
+		counters[heading.Level-1]++
+		clear(counters[heading.Level:])
+		entry := &ContentsEntry{Heading: heading, Children: make([]*ContentsEntry, 0)}
+		if numbered {
+			parts := make([]string, 0, heading.Level-top+1)
+			for _, counter := range counters[top-1 : heading.Level] {
+				parts = append(parts, strconv.Itoa(counter))
+			}
+			entry.Number = strings.Join(parts, ".")
+		}
+		for len(stack) > 0 && stack[len(stack)-1].Heading.Level >= heading.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			result = append(result, entry)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		stack = append(stack, entry)
+	}
+	return result
+}
+
+// UniqueIDs changes the identifiers of the given headings that repeat previous ones, by adding
+// a numeric suffix, since the identifiers are only unique inside each file
+func UniqueIDs(headings []*Heading) {
+	used := make(map[string]bool)
+	for _, heading := range headings {
+		if heading.ID == "" {
+			continue
+		}
+		id := heading.ID
+		for i := 1; used[id]; i++ {
+			id = heading.ID + "-" + strconv.Itoa(i)
+		}
+		heading.ID = id
+		used[id] = true
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package document
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func heading(level int, id, title string) *Heading {
+	return &Heading{Level: level, ID: id, Content: []Inline{&Text{Value: title}}}
+}
+
+func TestContents(t *testing.T) {
+	Convey("#Contents", t, func() {
+		blocks := []Block{
+			heading(2, "intro", "Introduction"),
+			&Paragraph{Content: []Inline{&Text{Value: "Some text"}}},
+			&Include{Path: "chapter.md", Blocks: []Block{
+				heading(2, "usage", "Usage"),
+				heading(3, "install", "Install"),
+				heading(4, "linux", "Linux"),
+				&BlockQuote{Blocks: []Block{heading(3, "quoted", "Quoted")}},
+			}},
+			heading(3, "run", "Run"),
+		}
+
+		Convey("It should find the headings in the included files", func() {
+			headings := Headings(blocks)
+			So(headings, ShouldHaveLength, 5)
+			So(headings[4].ID, ShouldEqual, "run")
+		})
+
+		Convey("It should nest the entries up to the given depth", func() {
+			entries := Contents(blocks, 3, false)
+			So(entries, ShouldHaveLength, 2)
+			So(entries[0].Title(), ShouldEqual, "Introduction")
+			So(entries[0].Children, ShouldBeEmpty)
+			So(entries[1].Children, ShouldHaveLength, 2)
+			So(entries[1].Children[0].Title(), ShouldEqual, "Install")
+			So(entries[1].Children[0].Children, ShouldBeEmpty)
+		})
+
+		Convey("It should number the entries from the highest level", func() {
+			entries := Contents(blocks, 6, true)
+			So(entries[0].Number, ShouldEqual, "1")
+			So(entries[1].Title(), ShouldEqual, "2 Usage")
+			So(entries[1].Children[0].Children[0].Title(), ShouldEqual, "2.1.1 Linux")
+			So(entries[1].Children[1].Title(), ShouldEqual, "2.2 Run")
+		})
+
+		Convey("It should make the repeated identifiers unique", func() {
+			headings := []*Heading{heading(1, "a", "A"), heading(2, "a", "A"), heading(2, "a-1", "A"), heading(2, "a", "A")}
+			UniqueIDs(headings)
+			So(headings[0].ID, ShouldEqual, "a")
+			So(headings[1].ID, ShouldEqual, "a-1")
+			So(headings[2].ID, ShouldEqual, "a-1-1")
+			So(headings[3].ID, ShouldEqual, "a-2")
+		})
+	})
+}
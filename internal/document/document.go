@@ -0,0 +1,212 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package document
+
+import (
+	"strings"
+
+	"github.com/chordflower/riconto/internal/model"
+)
+
+// Document represents a parsed document, independent of the source and output formats
+type Document struct {
+	Path   string
+	Meta   *model.DocumentMeta
+	Blocks []Block
+}
+
+// Block represents a block level element of a document
+type Block interface {
+	isBlock()
+}
+
+// Inline represents an inline element of a document, inside a block
+type Inline interface {
+	isInline()
+}
+
+// Heading represents a section heading, with a level from 1 to 6
+type Heading struct {
+	Level   int
+	ID      string
+	Content []Inline
+}
+
+// Paragraph represents a paragraph of text
+type Paragraph struct {
+	Content []Inline
+}
+
+// List represents an ordered or unordered list
+type List struct {
+	Ordered bool
+	Start   int
+	Tight   bool
+	Items   []*ListItem
+}
+
+// ListItem represents one item of a list
+type ListItem struct {
+	Blocks []Block
+}
+
+// BlockQuote represents a quoted group of blocks
+type BlockQuote struct {
+	Blocks []Block
+}
+
+// CodeBlock represents a block of preformatted code
+type CodeBlock struct {
+	Language string
+	Code     string
+}
+
+// ThematicBreak represents an horizontal rule
+type ThematicBreak struct{}
+
+// Table represents a table, with a header row and the alignments of its columns
+type Table struct {
+	// Alignments are the alignments of each column, one of the alignment constants.
+	Alignments []string
+	Header     []*TableCell
+	Rows       [][]*TableCell
+}
+
+// TableCell represents a cell of a table
+type TableCell struct {
+	Content []Inline
+}
+
+// The alignments of the table columns
+const (
+	AlignNone   = ""
+	AlignLeft   = "left"
+	AlignCenter = "center"
+	AlignRight  = "right"
+)
+
+// Include represents the blocks of another file, spliced where that file was included
+type Include struct {
+	Path   string
+	Blocks []Block
+}
+
+func (*Heading) isBlock()       {}
+func (*Paragraph) isBlock()     {}
+func (*List) isBlock()          {}
+func (*ListItem) isBlock()      {}
+func (*BlockQuote) isBlock()    {}
+func (*CodeBlock) isBlock()     {}
+func (*ThematicBreak) isBlock() {}
+func (*Table) isBlock()         {}
+func (*Include) isBlock()       {}
+
+// Text represents a run of plain text
+type Text struct {
+	Value string
+}
+
+// Emphasis represents emphasized text, which is strong when the level is 2
+type Emphasis struct {
+	Level   int
+	Content []Inline
+}
+
+// Code represents an inline code span
+type Code struct {
+	Value string
+}
+
+// Link represents an hyperlink
+type Link struct {
+	Destination string
+	Title       string
+	Content     []Inline
+}
+
+// Image represents an inline image
+type Image struct {
+	Destination string
+	Title       string
+	Alt         string
+}
+
+// Break represents a line break, which is only kept in the output when it is hard
+type Break struct {
+	Hard bool
+}
+
+// Footnote represents a note referenced from the text, with the blocks of the note
+type Footnote struct {
+	Blocks []Block
+}
+
+// Citation represents a reference to entries of the bibliography of the document, by their keys,
+// with an optional locator inside them, like a page number
+type Citation struct {
+	Keys    []string
+	Locator string
+}
+
+// Text returns the citation as text, for the formats without a bibliography, like `[key1; key2, p. 12]`
+func (c *Citation) Text() string {
+	text := strings.Join(c.Keys, "; ")
+	if c.Locator != "" {
+		text += ", " + c.Locator
+	}
+	return "[" + text + "]"
+}
+
+func (*Text) isInline()     {}
+func (*Emphasis) isInline() {}
+func (*Code) isInline()     {}
+func (*Link) isInline()     {}
+func (*Image) isInline()    {}
+func (*Break) isInline()    {}
+func (*Footnote) isInline() {}
+func (*Citation) isInline() {}
+
+// PlainText returns the text content of the given inlines, without any formatting
+func PlainText(inlines []Inline) string {
+	var builder strings.Builder
+	writePlainText(&builder, inlines)
+	return builder.String()
+}
+
+func writePlainText(builder *strings.Builder, inlines []Inline) {
+	for _, inline := range inlines {
+		switch value := inline.(type) {
+		case *Text:
+			builder.WriteString(value.Value)
+		case *Code:
+			builder.WriteString(value.Value)
+		case *Emphasis:
+			writePlainText(builder, value.Content)
+		case *Link:
+			writePlainText(builder, value.Content)
+		case *Image:
+			builder.WriteString(value.Alt)
+		case *Break:
+			builder.WriteString(" ")
+		case *Citation:
+			builder.WriteString(value.Text())
+		}
+	}
+}
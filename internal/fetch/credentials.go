@@ -0,0 +1,107 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fetch
+
+import (
+	"bufio"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// credentials returns the user and password to use for the given url, taken from the url itself
+// or from the netrc file, returning false when there are none
+func credentials(source *url.URL) (string, string, bool) {
+	user := ""
+	if source.User != nil {
+		user = source.User.Username()
+		if password, ok := source.User.Password(); ok {
+			return user, password, true
+		}
+	}
+	login, password, ok := netrcCredentials(source.Hostname())
+	switch {
+	case ok && (user == "" || user == login):
+		return login, password, true
+	case user != "":
+		return user, "", true
+	default:
+		return "", "", false
+	}
+}
+
+// netrcCredentials returns the login and password of the given host, or of the default machine,
+// in the netrc file given by the NETRC environment variable or in the home directory
+func netrcCredentials(host string) (string, string, bool) {
+	filename := os.Getenv("NETRC")
+	if filename == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", false
+		}
+		filename = filepath.Join(home, ".netrc")
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", "", false
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanWords)
+	tokens := make([]string, 0)
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Text())
+	}
+
+	// the machines are searched in order, the default one matching any host
+	matches := false
+	login, password := "", ""
+	for i := 0; i < len(tokens); i++ {
+		next := func() string {
+			if i+1 < len(tokens) {
+				i++
+				return tokens[i]
+			}
+			return ""
+		}
+		switch tokens[i] {
+		case "machine", "default":
+			if matches {
+				return login, password, true
+			}
+			matches = tokens[i] == "default" || strings.EqualFold(next(), host)
+			login, password = "", ""
+		case "login":
+			login = next()
+		case "password":
+			password = next()
+		case "account":
+			next()
+		case "macdef":
+			// macros run until an empty line, which the words do not keep, so nothing after them is read
+			return login, password, matches
+		}
+	}
+	return login, password, matches
+}
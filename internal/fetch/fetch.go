@@ -0,0 +1,202 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package fetch implements the fetching of riconto projects from remote sources, with a
+// fetcher for each kind of source, selected by the scheme of the url
+package fetch
+
+import (
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/spf13/afero"
+)
+
+// Fetcher copies a project from a kind of remote source
+type Fetcher interface {
+	// Schemes returns the url schemes handled by the fetcher, like git.https.
+	Schemes() []string
+	// Fetch copies the project at the given url into the root of the given filesystem.
+	Fetch(source *url.URL, destiny afero.Fs) error
+}
+
+// Registry keeps the known fetchers by their url schemes
+type Registry struct {
+	fetchers map[string]Fetcher
+}
+
+// NewRegistry creates a new registry with the given fetchers
+func NewRegistry(fetchers ...Fetcher) (*Registry, error) {
+	registry := &Registry{fetchers: make(map[string]Fetcher)}
+	for _, fetcher := range fetchers {
+		if err := registry.Register(fetcher); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// DefaultRegistry creates a new registry with the builtin fetchers
+func DefaultRegistry() *Registry {
+	registry, _ := NewRegistry(NewGit(), NewHg(), NewFTP(), NewWebDAV())
+	return registry
+}
+
+// Register adds the given fetcher to the registry, failing if one of its schemes already has a fetcher
+func (r *Registry) Register(fetcher Fetcher) error {
+	for _, scheme := range fetcher.Schemes() {
+		if _, ok := r.fetchers[strings.ToLower(scheme)]; ok {
+			return errors.Errorf("There is already a fetcher for the scheme %s", scheme)
+		}
+	}
+	for _, scheme := range fetcher.Schemes() {
+		r.fetchers[strings.ToLower(scheme)] = fetcher
+	}
+	return nil
+}
+
+// Lookup returns the fetcher of the given scheme, if there is one
+func (r *Registry) Lookup(scheme string) (Fetcher, bool) {
+	fetcher, ok := r.fetchers[strings.ToLower(scheme)]
+	return fetcher, ok
+}
+
+// Schemes returns the sorted schemes of the registered fetchers
+func (r *Registry) Schemes() []string {
+	schemes := make([]string, 0, len(r.fetchers))
+	for scheme := range r.fetchers {
+		schemes = append(schemes, scheme)
+	}
+	slices.Sort(schemes)
+	return schemes
+}
+
+// Fetch copies the project at the given url into the root of the given filesystem, with the
+// fetcher of its scheme
+func (r *Registry) Fetch(rawURL string, destiny afero.Fs) error {
+	source, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.Wrapf(err, "Invalid url %s", rawURL)
+	}
+	fetcher, ok := r.Lookup(source.Scheme)
+	if !ok {
+		return errors.Errorf("The url scheme %q is not supported, use one of %s", source.Scheme, strings.Join(r.Schemes(), ", "))
+	}
+	if err := fetcher.Fetch(source, destiny); err != nil {
+		return errors.Wrapf(err, "Unable to fetch %s", source.Redacted())
+	}
+	return nil
+}
+
+// copyDir copies the contents of the given directory of the operating system into the root of
+// the given filesystem, without the entries with the given names, like the ones of the version
+// control systems
+func copyDir(dir string, destiny afero.Fs, skip ...string) error {
+	origin := afero.NewBasePathFs(afero.NewOsFs(), dir)
+	return afero.Walk(origin, string(filepath.Separator), func(name string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if slices.Contains(skip, info.Name()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name = strings.TrimPrefix(filepath.ToSlash(name), "/")
+		switch {
+		case name == "":
+			return nil
+		case info.IsDir():
+			return destiny.MkdirAll(name, 0o750)
+		case info.Mode().IsRegular():
+			data, err := afero.ReadFile(origin, name)
+			if err != nil {
+				return err
+			}
+			return afero.WriteFile(destiny, name, data, info.Mode().Perm())
+		default:
+			// links and other special files are not part of a project
+			return nil
+		}
+	})
+}
+
+// download writes the file with the given name and size, or -1 when it is unknown, to the given
+// filesystem, with the contents returned by open from the given offset, resuming the transfer
+// where it stopped when it fails, up to the given number of attempts; open returns the offset
+// where the contents start, which can be zero when the source is unable to resume
+func download(destiny afero.Fs, name string, size int64, attempts int, open func(offset int64) (io.ReadCloser, int64, error)) error {
+	if err := destiny.MkdirAll(path.Dir(name), 0o750); err != nil {
+		return errors.Wrapf(err, "Unable to create the directory of %s", name)
+	}
+	file, err := destiny.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to create the file %s", name)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var offset int64
+	for attempt := 1; ; attempt++ {
+		reader, start, err := open(offset)
+		if err == nil {
+			if start != offset {
+				err = file.Truncate(start)
+			}
+			if err == nil {
+				_, err = file.Seek(start, io.SeekStart)
+			}
+			if err == nil {
+				var written int64
+				written, err = io.Copy(file, reader)
+				offset = start + written
+			}
+			err = errors.Combine(err, reader.Close())
+		}
+		if err == nil && size >= 0 && offset < size {
+			err = errors.Errorf("The transfer stopped at %d of %d bytes", offset, size)
+		}
+		if err == nil {
+			return nil
+		}
+		if attempt >= attempts {
+			return errors.Wrapf(err, "Unable to download %s", name)
+		}
+	}
+}
+
+// tempDir creates a new temporary directory for a fetch, returning a function to remove it
+func tempDir() (string, func(), error) {
+	dir, err := os.MkdirTemp("", "riconto-fetch-")
+	if err != nil {
+		return "", nil, errors.Wrap(err, "Unable to create a temporary directory")
+	}
+	return dir, func() {
+		_ = os.RemoveAll(dir)
+	}, nil
+}
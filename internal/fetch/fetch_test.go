@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fetch
+
+import (
+	"net/url"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+// fakeFetcher writes a configuration file with the host of the url as the project name
+type fakeFetcher struct {
+	schemes []string
+	urls    []string
+}
+
+func (f *fakeFetcher) Schemes() []string {
+	return f.schemes
+}
+
+func (f *fakeFetcher) Fetch(source *url.URL, destiny afero.Fs) error {
+	f.urls = append(f.urls, source.String())
+	return afero.WriteFile(destiny, "riconto.toml", []byte("name = \""+source.Host+"\"\n"), 0o644)
+}
+
+func TestRegistry(t *testing.T) {
+	Convey("#Registry", t, func() {
+		fake := &fakeFetcher{schemes: []string{"fake", "fake.https"}}
+		registry, err := NewRegistry(fake)
+		So(err, ShouldBeNil)
+
+		Convey("It should fetch with the fetcher of the url scheme", func() {
+			memFs := afero.NewMemMapFs()
+			So(registry.Fetch("FAKE.https://example.com/project#v1", memFs), ShouldBeNil)
+			So(fake.urls, ShouldResemble, []string{"fake.https://example.com/project#v1"})
+			content, err := afero.ReadFile(memFs, "riconto.toml")
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "name = \"example.com\"\n")
+		})
+
+		Convey("It should fail with an unknown scheme", func() {
+			err := registry.Fetch("svn://example.com/project", afero.NewMemMapFs())
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, `The url scheme "svn" is not supported, use one of fake, fake.https`)
+		})
+
+		Convey("It should not register two fetchers for the same scheme", func() {
+			So(registry.Register(&fakeFetcher{schemes: []string{"other", "fake"}}), ShouldNotBeNil)
+			_, ok := registry.Lookup("other")
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("It should contain the builtin fetchers by default", func() {
+			_, ok := DefaultRegistry().Lookup("git.file")
+			So(ok, ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fetch
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/jlaffaye/ftp"
+	"github.com/spf13/afero"
+)
+
+// FTP fetches projects from the directories of ftp sites, from urls like ftp://host/directory,
+// or ftps://host/directory for ftp with explicit tls
+type FTP struct {
+	// TLSConfig is the tls configuration of the ftps connections, nil for the default one.
+	TLSConfig *tls.Config
+	// Timeout is the timeout of the connections.
+	Timeout time.Duration
+	// Attempts is the number of times a transfer is tried, resuming it where it stopped.
+	Attempts int
+}
+
+// ftpFile represents a file to download from an ftp site
+type ftpFile struct {
+	remote string
+	local  string
+	size   int64
+}
+
+// NewFTP creates a new ftp fetcher
+func NewFTP() *FTP {
+	return &FTP{Timeout: 30 * time.Second, Attempts: 3}
+}
+
+func (f *FTP) Schemes() []string {
+	return []string{"ftp", "ftps"}
+}
+
+func (f *FTP) Fetch(source *url.URL, destiny afero.Fs) error {
+	conn, err := f.dial(source)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if conn != nil {
+			_ = conn.Quit()
+		}
+	}()
+
+	root := strings.TrimPrefix(path.Clean("/"+source.Path), "/")
+	if root == "" {
+		root = "."
+	}
+	files := make([]ftpFile, 0)
+	if err := f.list(conn, root, "", &files); err != nil {
+		return err
+	}
+	for _, file := range files {
+		err := download(destiny, file.local, file.size, f.Attempts, func(offset int64) (io.ReadCloser, int64, error) {
+			response, err := conn.RetrFrom(file.remote, uint64(offset))
+			if err != nil {
+				// the connection may be lost, so the transfer is tried again in a new one, which only
+				// replaces the old one when it is made
+				_ = conn.Quit()
+				redialed, dialErr := f.dial(source)
+				if dialErr != nil {
+					return nil, 0, dialErr
+				}
+				conn = redialed
+				response, err = conn.RetrFrom(file.remote, uint64(offset))
+			}
+			return response, offset, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dial connects and logs in to the ftp site of the given url
+func (f *FTP) dial(source *url.URL) (*ftp.ServerConn, error) {
+	address := source.Host
+	if source.Port() == "" {
+		address = net.JoinHostPort(source.Hostname(), "21")
+	}
+	options := []ftp.DialOption{ftp.DialWithTimeout(f.Timeout)}
+	if strings.EqualFold(source.Scheme, "ftps") {
+		config := &tls.Config{}
+		if f.TLSConfig != nil {
+			config = f.TLSConfig.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName = source.Hostname()
+		}
+		options = append(options, ftp.DialWithExplicitTLS(config))
+	}
+	conn, err := ftp.Dial(address, options...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to connect to %s", address)
+	}
+	user, password, ok := credentials(source)
+	if !ok {
+		user, password = "anonymous", "anonymous"
+	}
+	if err := conn.Login(user, password); err != nil {
+		_ = conn.Quit()
+		return nil, errors.Wrapf(err, "Unable to login to %s as %s", address, user)
+	}
+	return conn, nil
+}
+
+// list adds the files in the given remote directory, and its subdirectories, to the given files,
+// with their local paths relative to the given one
+func (f *FTP) list(conn *ftp.ServerConn, remote, local string, files *[]ftpFile) error {
+	entries, err := conn.List(remote)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to list the directory %s", remote)
+	}
+	for _, entry := range entries {
+		if entry.Name == "." || entry.Name == ".." || strings.Contains(entry.Name, "/") {
+			continue
+		}
+		switch entry.Type {
+		case ftp.EntryTypeFolder:
+			if err := f.list(conn, path.Join(remote, entry.Name), path.Join(local, entry.Name), files); err != nil {
+				return err
+			}
+		case ftp.EntryTypeFile:
+			*files = append(*files, ftpFile{
+				remote: path.Join(remote, entry.Name),
+				local:  path.Join(local, entry.Name),
+				size:   int64(entry.Size),
+			})
+		}
+	}
+	return nil
+}
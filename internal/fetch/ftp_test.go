@@ -0,0 +1,313 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fetch
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+// ftpServer is a minimal ftp server, that serves the files of a filesystem, with explicit tls
+// when it has a tls configuration, and that can abort the first transfer of some files
+type ftpServer struct {
+	listener  net.Listener
+	fs        afero.Fs
+	user      string
+	password  string
+	tlsConfig *tls.Config
+	// failures maps the files whose first transfer is aborted to the bytes sent before.
+	failures map[string]int
+	// drops are the files whose transfer drops the connection, after which new connections are refused.
+	drops    map[string]bool
+	refused  bool
+	mutex    sync.Mutex
+	commands []string
+}
+
+func newFTPServer(t *testing.T, fs afero.Fs, tlsConfig *tls.Config) *ftpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &ftpServer{
+		listener:  listener,
+		fs:        fs,
+		user:      "user",
+		password:  "secret",
+		tlsConfig: tlsConfig,
+		failures:  make(map[string]int),
+		drops:     make(map[string]bool),
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+// url returns the url of the given directory in the server
+func (s *ftpServer) url(scheme, userinfo, dir string) string {
+	return fmt.Sprintf("%s://%s%s/%s", scheme, userinfo, s.listener.Addr().String(), dir)
+}
+
+// sent returns the commands sent by the clients
+func (s *ftpServer) sent() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *ftpServer) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	reply := func(format string, args ...any) {
+		_, _ = fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	var passive net.Listener
+	accept := func(protected bool) net.Conn {
+		if passive == nil {
+			return nil
+		}
+		defer func() {
+			_ = passive.Close()
+			passive = nil
+		}()
+		data, err := passive.Accept()
+		if err != nil {
+			return nil
+		}
+		if protected {
+			return tls.Server(data, s.tlsConfig)
+		}
+		return data
+	}
+	user, protected, offset := "", false, 0
+
+	s.mutex.Lock()
+	refused := s.refused
+	s.mutex.Unlock()
+	if refused {
+		reply("421 Service not available")
+		return
+	}
+	reply("220 Ready")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command, argument, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		command = strings.ToUpper(command)
+		s.mutex.Lock()
+		s.commands = append(s.commands, command)
+		s.mutex.Unlock()
+
+		switch command {
+		case "AUTH":
+			if s.tlsConfig == nil {
+				reply("502 No tls")
+				continue
+			}
+			reply("234 Starting tls")
+			conn = tls.Server(conn, s.tlsConfig)
+			reader = bufio.NewReader(conn)
+		case "USER":
+			user = argument
+			reply("331 Password required")
+		case "PASS":
+			if user != s.user || argument != s.password {
+				reply("530 Login incorrect")
+				continue
+			}
+			reply("230 Logged in")
+		case "FEAT":
+			reply("211-Features:\r\n MLST type*;size*;\r\n211 End")
+		case "TYPE", "PBSZ":
+			reply("200 Ok")
+		case "PROT":
+			protected = argument == "P"
+			reply("200 Ok")
+		case "EPSV":
+			if passive, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				reply("425 Unable to listen")
+				continue
+			}
+			reply("229 Entering Extended Passive Mode (|||%d|)", passive.Addr().(*net.TCPAddr).Port)
+		case "REST":
+			offset, _ = strconv.Atoi(argument)
+			reply("350 Restarting")
+		case "MLSD":
+			entries, err := afero.ReadDir(s.fs, path.Clean(argument))
+			data := accept(protected)
+			if err != nil || data == nil {
+				reply("550 No such directory")
+				continue
+			}
+			reply("150 Listing")
+			for _, entry := range entries {
+				kind := "file"
+				if entry.IsDir() {
+					kind = "dir"
+				}
+				_, _ = fmt.Fprintf(data, "type=%s;size=%d; %s\r\n", kind, entry.Size(), entry.Name())
+			}
+			_ = data.Close()
+			reply("226 Done")
+		case "RETR":
+			name := path.Clean(argument)
+			s.mutex.Lock()
+			drop := s.drops[name]
+			s.refused = s.refused || drop
+			s.mutex.Unlock()
+			if drop {
+				return
+			}
+			content, err := afero.ReadFile(s.fs, name)
+			data := accept(protected)
+			if err != nil || data == nil || offset > len(content) {
+				reply("550 No such file")
+				continue
+			}
+			reply("150 Sending")
+			content, offset = content[offset:], 0
+			s.mutex.Lock()
+			limit, fail := s.failures[name]
+			delete(s.failures, name)
+			s.mutex.Unlock()
+			if fail && limit < len(content) {
+				_, _ = data.Write(content[:limit])
+				_ = data.Close()
+				reply("426 Transfer aborted")
+				continue
+			}
+			_, _ = data.Write(content)
+			_ = data.Close()
+			reply("226 Done")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+// newFTPFs returns a filesystem with a project in the project directory
+func newFTPFs() afero.Fs {
+	memFs := afero.NewMemMapFs()
+	_ = afero.WriteFile(memFs, "project/riconto.toml", []byte("name = \"fetched\"\n"), 0o644)
+	_ = afero.WriteFile(memFs, "project/src/main.md", []byte("# A title\n\nWith a long enough paragraph.\n"), 0o644)
+	_ = afero.WriteFile(memFs, "other.md", []byte("# Other\n"), 0o644)
+	return memFs
+}
+
+// newTLSConfigs returns the tls configurations of a server and of its clients
+func newTLSConfigs() (*tls.Config, *tls.Config) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	return server.TLS.Clone(), server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+}
+
+func TestFTP(t *testing.T) {
+	t.Setenv("NETRC", path.Join(t.TempDir(), "missing"))
+	serverTLS, clientTLS := newTLSConfigs()
+
+	Convey("#FTP", t, func() {
+		memFs := afero.NewMemMapFs()
+		server := newFTPServer(t, newFTPFs(), serverTLS)
+
+		Convey("It should fetch the files of a directory", func() {
+			So(NewFTP().Fetch(mustParse(server.url("ftp", "user:secret@", "project")), memFs), ShouldBeNil)
+			content, err := afero.ReadFile(memFs, "src/main.md")
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "# A title\n\nWith a long enough paragraph.\n")
+			_, err = memFs.Stat("riconto.toml")
+			So(err, ShouldBeNil)
+			_, err = memFs.Stat("other.md")
+			So(err, ShouldNotBeNil)
+			So(server.sent(), ShouldNotContain, "AUTH")
+		})
+
+		Convey("It should resume an aborted transfer", func() {
+			server.failures["project/src/main.md"] = 10
+			So(NewFTP().Fetch(mustParse(server.url("ftp", "user:secret@", "project")), memFs), ShouldBeNil)
+			content, err := afero.ReadFile(memFs, "src/main.md")
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "# A title\n\nWith a long enough paragraph.\n")
+			So(server.sent(), ShouldContain, "REST")
+		})
+
+		Convey("It should fetch with tls", func() {
+			fetcher := NewFTP()
+			fetcher.TLSConfig = clientTLS
+			So(fetcher.Fetch(mustParse(server.url("ftps", "user:secret@", "project")), memFs), ShouldBeNil)
+			content, err := afero.ReadFile(memFs, "riconto.toml")
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "name = \"fetched\"\n")
+			So(server.sent(), ShouldContain, "AUTH")
+			So(server.sent(), ShouldContain, "PROT")
+		})
+
+		Convey("It should fail with the wrong credentials", func() {
+			err := NewFTP().Fetch(mustParse(server.url("ftp", "user:wrong@", "project")), memFs)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "Unable to login to")
+		})
+
+		Convey("It should fail when the connection is lost and can not be made again", func() {
+			server.drops["project/src/main.md"] = true
+			err := NewFTP().Fetch(mustParse(server.url("ftp", "user:secret@", "project")), memFs)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "Unable to download src/main.md")
+			So(err.Error(), ShouldContainSubstring, "Unable to connect to")
+		})
+
+		Convey("It should fail after the given attempts", func() {
+			server.failures["project/src/main.md"] = 10
+			fetcher := NewFTP()
+			fetcher.Attempts = 1
+			err := fetcher.Fetch(mustParse(server.url("ftp", "user:secret@", "project")), memFs)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "Unable to download src/main.md")
+		})
+	})
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fetch
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/spf13/afero"
+)
+
+// Git fetches projects from git repositories, with the git executable, from urls like
+// git.https://host/repository.git#tag, where the optional fragment selects a branch or tag
+type Git struct {
+	// Command is the git executable to use.
+	Command string
+}
+
+// NewGit creates a new git fetcher using the git executable in the path
+func NewGit() *Git {
+	return &Git{Command: "git"}
+}
+
+func (g *Git) Schemes() []string {
+	return []string{"git.https", "git.http", "git.git", "git.ssh", "git.file"}
+}
+
+func (g *Git) Fetch(source *url.URL, destiny afero.Fs) error {
+	dir, remove, err := tempDir()
+	if err != nil {
+		return err
+	}
+	defer remove()
+
+	remote := *source
+	remote.Scheme = strings.TrimPrefix(strings.ToLower(source.Scheme), "git.")
+	remote.Fragment = ""
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if source.Fragment != "" {
+		args = append(args, "--branch", source.Fragment)
+	}
+	args = append(args, "--", remote.String(), filepath.Join(dir, "clone"))
+	if err := g.run(args...); err != nil {
+		return err
+	}
+	return copyDir(filepath.Join(dir, "clone"), destiny, ".git")
+}
+
+// run runs git with the given arguments, without asking for credentials in the terminal
+func (g *Git) run(args ...string) error {
+	var stderr bytes.Buffer
+	command := exec.Command(g.Command, args...)
+	command.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return errors.Wrap(errors.New(message), "Unable to clone the git repository")
+		}
+		return errors.Wrap(err, "Unable to clone the git repository")
+	}
+	return nil
+}
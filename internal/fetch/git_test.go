@@ -0,0 +1,113 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fetch
+
+import (
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+// newGitRepository creates a bare git repository with a project, tagged v1, and a later commit,
+// returning its path
+func newGitRepository(t *testing.T) string {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	git := func(args ...string) {
+		command := exec.Command("git", append([]string{"-C", work}, args...)...)
+		command.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+			"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1",
+		)
+		if output, err := command.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %s", args, output)
+		}
+	}
+	write := func(name, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(work, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(work, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "--quiet", "--initial-branch", "main")
+	write("riconto.toml", "name = \"fetched\"\n")
+	write("src/main.md", "# First\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "First")
+	git("tag", "v1")
+	write("src/main.md", "# Second\n")
+	git("commit", "--quiet", "-am", "Second")
+	git("clone", "--quiet", "--bare", ".", filepath.Join(dir, "project.git"))
+	return filepath.Join(dir, "project.git")
+}
+
+func TestGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repository := newGitRepository(t)
+
+	Convey("#Git", t, func() {
+		memFs := afero.NewMemMapFs()
+
+		Convey("It should fetch the default branch of a repository, without its metadata", func() {
+			So(NewGit().Fetch(mustParse("git.file://"+filepath.ToSlash(repository)), memFs), ShouldBeNil)
+			content, err := afero.ReadFile(memFs, "src/main.md")
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "# Second\n")
+			_, err = memFs.Stat("riconto.toml")
+			So(err, ShouldBeNil)
+			_, err = memFs.Stat(".git")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("It should fetch the tag in the fragment", func() {
+			So(NewGit().Fetch(mustParse("git.file://"+filepath.ToSlash(repository)+"#v1"), memFs), ShouldBeNil)
+			content, err := afero.ReadFile(memFs, "src/main.md")
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "# First\n")
+		})
+
+		Convey("It should fail with a missing repository", func() {
+			err := NewGit().Fetch(mustParse("git.file://"+filepath.ToSlash(repository)+"-missing"), memFs)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "Unable to clone the git repository")
+		})
+	})
+}
+
+func mustParse(rawURL string) *url.URL {
+	result, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fetch
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/spf13/afero"
+)
+
+// Hg fetches projects from mercurial repositories, with the hg executable, from urls like
+// hg.https://host/repository#tag, where the optional fragment selects a revision, branch or tag;
+// urls of bundle files, ending in .hg or .bundle, are imported into a new repository instead
+type Hg struct {
+	// Command is the hg executable to use.
+	Command string
+	// Client is the http client used to download the bundle files.
+	Client *http.Client
+}
+
+// NewHg creates a new mercurial fetcher using the hg executable in the path
+func NewHg() *Hg {
+	return &Hg{Command: "hg", Client: http.DefaultClient}
+}
+
+func (h *Hg) Schemes() []string {
+	return []string{"hg.https", "hg.http", "hg.ssh", "hg.file"}
+}
+
+func (h *Hg) Fetch(source *url.URL, destiny afero.Fs) error {
+	dir, remove, err := tempDir()
+	if err != nil {
+		return err
+	}
+	defer remove()
+
+	remote := *source
+	remote.Scheme = strings.TrimPrefix(strings.ToLower(source.Scheme), "hg.")
+	remote.Fragment = ""
+	clone := filepath.Join(dir, "clone")
+	if isBundle(remote.Path) {
+		err = h.unbundle(&remote, source.Fragment, dir, clone)
+	} else {
+		args := []string{"clone", "--noninteractive"}
+		if source.Fragment != "" {
+			args = append(args, "--updaterev", source.Fragment)
+		}
+		err = h.run(append(args, "--", remote.String(), clone)...)
+	}
+	if err != nil {
+		return err
+	}
+	return copyDir(clone, destiny, ".hg")
+}
+
+// unbundle imports the bundle file at the given url into a new repository, updating it to the
+// given revision, or to the tip when it is empty
+func (h *Hg) unbundle(remote *url.URL, revision, dir, clone string) error {
+	bundle := filepath.FromSlash(remote.Path)
+	if remote.Scheme != "file" {
+		bundle = filepath.Join(dir, "bundle.hg")
+		if err := h.download(remote, bundle); err != nil {
+			return err
+		}
+	}
+	if revision == "" {
+		revision = "tip"
+	}
+	if err := h.run("init", clone); err != nil {
+		return err
+	}
+	if err := h.run("--repository", clone, "unbundle", "--noninteractive", bundle); err != nil {
+		return err
+	}
+	return h.run("--repository", clone, "update", "--noninteractive", "--rev", revision)
+}
+
+// download writes the bundle file at the given url to the given file
+func (h *Hg) download(remote *url.URL, filename string) error {
+	response, err := h.Client.Get(remote.String())
+	if err != nil {
+		return errors.Wrap(err, "Unable to download the mercurial bundle")
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusOK {
+		return errors.Errorf("Unable to download the mercurial bundle: %s", response.Status)
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return errors.Wrap(err, "Unable to create the mercurial bundle")
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	if _, err := io.Copy(file, response.Body); err != nil {
+		return errors.Wrap(err, "Unable to download the mercurial bundle")
+	}
+	return nil
+}
+
+// run runs hg with the given arguments, ignoring the user configuration that changes its output
+func (h *Hg) run(args ...string) error {
+	var stderr bytes.Buffer
+	command := exec.Command(h.Command, args...)
+	command.Env = append(os.Environ(), "HGPLAIN=1")
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return errors.Wrap(errors.New(message), "Unable to clone the mercurial repository")
+		}
+		return errors.Wrap(err, "Unable to clone the mercurial repository")
+	}
+	return nil
+}
+
+// isBundle checks if the given path is of a mercurial bundle file
+func isBundle(name string) bool {
+	extension := strings.ToLower(path.Ext(name))
+	return extension == ".hg" || extension == ".bundle"
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+// fakeHg is a stand-in for the hg executable, that logs its arguments and creates a project
+// in the repositories it clones or updates
+const fakeHg = `#!/bin/sh
+echo "$@" >> "$FAKE_HG_LOG"
+case "$1" in
+clone)
+	for dest; do :; done
+	mkdir -p "$dest/.hg" && echo 'name = "cloned"' > "$dest/riconto.toml";;
+init)
+	mkdir -p "$2/.hg";;
+--repository)
+	if [ "$3" = update ]; then echo 'name = "unbundled"' > "$2/riconto.toml"; fi;;
+esac
+`
+
+// newHgRepository creates a mercurial repository with a project, tagged v1, and a later commit,
+// together with a bundle of it, returning their paths
+func newHgRepository(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	work := filepath.Join(dir, "work")
+	hg := func(args ...string) {
+		command := exec.Command("hg", append([]string{"--repository", work}, args...)...)
+		command.Env = append(os.Environ(), "HGPLAIN=1", "HGUSER=test <test@example.com>", "HGRCPATH=")
+		if output, err := command.CombinedOutput(); err != nil {
+			t.Fatalf("hg %v failed: %s", args, output)
+		}
+	}
+	write := func(name, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(work, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(work, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if output, err := exec.Command("hg", "init", work).CombinedOutput(); err != nil {
+		t.Fatalf("hg init failed: %s", output)
+	}
+	write("riconto.toml", "name = \"fetched\"\n")
+	write("src/main.md", "# First\n")
+	hg("commit", "--addremove", "--message", "First")
+	hg("tag", "v1")
+	write("src/main.md", "# Second\n")
+	hg("commit", "--message", "Second")
+	hg("bundle", "--all", filepath.Join(dir, "project.hg"))
+	return work, filepath.Join(dir, "project.hg")
+}
+
+func TestHg(t *testing.T) {
+	if _, err := exec.LookPath("hg"); err != nil {
+		t.Skip("hg is not installed")
+	}
+	repository, bundle := newHgRepository(t)
+
+	Convey("#Hg", t, func() {
+		memFs := afero.NewMemMapFs()
+		check := func(rawURL, content string) {
+			So(NewHg().Fetch(mustParse(rawURL), memFs), ShouldBeNil)
+			data, err := afero.ReadFile(memFs, "src/main.md")
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, content)
+			_, err = memFs.Stat(".hg")
+			So(err, ShouldNotBeNil)
+		}
+
+		Convey("It should fetch the tip of a repository, without its metadata", func() {
+			check("hg.file://"+filepath.ToSlash(repository), "# Second\n")
+		})
+
+		Convey("It should fetch the revision in the fragment", func() {
+			check("hg.file://"+filepath.ToSlash(repository)+"#v1", "# First\n")
+		})
+
+		Convey("It should import a bundle file", func() {
+			check("hg.file://"+filepath.ToSlash(bundle)+"#v1", "# First\n")
+		})
+
+		Convey("It should download and import a bundle file", func() {
+			server := httptest.NewServer(http.FileServer(http.Dir(filepath.Dir(bundle))))
+			defer server.Close()
+			check(strings.Replace(server.URL, "http://", "hg.http://", 1)+"/project.hg", "# Second\n")
+		})
+	})
+}
+
+func TestHgCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	dir := t.TempDir()
+	command := filepath.Join(dir, "hg")
+	if err := os.WriteFile(command, []byte(fakeHg), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(dir, "log")
+	t.Setenv("FAKE_HG_LOG", log)
+
+	Convey("#Hg", t, func() {
+		memFs := afero.NewMemMapFs()
+		fetcher := NewHg()
+		fetcher.Command = command
+		_ = os.Remove(log)
+		calls := func() []string {
+			data, err := os.ReadFile(log)
+			So(err, ShouldBeNil)
+			return strings.Split(strings.TrimSpace(string(data)), "\n")
+		}
+
+		Convey("It should clone at the revision in the fragment", func() {
+			So(fetcher.Fetch(mustParse("hg.ssh://hg@example.com/project#v1.0"), memFs), ShouldBeNil)
+			lines := calls()
+			So(lines, ShouldHaveLength, 1)
+			So(lines[0], ShouldStartWith, "clone --noninteractive --updaterev v1.0 -- ssh://hg@example.com/project ")
+			data, err := afero.ReadFile(memFs, "riconto.toml")
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "name = \"cloned\"\n")
+			_, err = memFs.Stat(".hg")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("It should unbundle the bundle files", func() {
+			So(fetcher.Fetch(mustParse("hg.file:///srv/bundles/project.hg"), memFs), ShouldBeNil)
+			lines := calls()
+			So(lines, ShouldHaveLength, 3)
+			So(lines[0], ShouldStartWith, "init ")
+			So(lines[1], ShouldEndWith, "unbundle --noninteractive /srv/bundles/project.hg")
+			So(lines[2], ShouldEndWith, "update --noninteractive --rev tip")
+			data, err := afero.ReadFile(memFs, "riconto.toml")
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "name = \"unbundled\"\n")
+		})
+
+		Convey("It should fail when the bundle can not be downloaded", func() {
+			server := httptest.NewServer(http.NotFoundHandler())
+			defer server.Close()
+			err := fetcher.Fetch(mustParse(strings.Replace(server.URL, "http://", "hg.http://", 1)+"/project.bundle"), memFs)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "Unable to download the mercurial bundle: 404 Not Found")
+		})
+	})
+}
@@ -0,0 +1,199 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fetch
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/spf13/afero"
+)
+
+// webdavPropfind is the body of the requests listing the members of a collection
+const webdavPropfind = `<?xml version="1.0" encoding="utf-8"?>` +
+	`<propfind xmlns="DAV:"><prop><resourcetype/><getcontentlength/></prop></propfind>`
+
+// webdavMultistatus is the response to the requests listing the members of a collection
+type webdavMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength string `xml:"getcontentlength"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// WebDAV fetches projects from the collections of webdav servers, from urls like
+// webdav.https://host/collection/
+type WebDAV struct {
+	// Client is the http client used for the requests.
+	Client *http.Client
+	// Attempts is the number of times a transfer is tried, resuming it where it stopped.
+	Attempts int
+}
+
+// NewWebDAV creates a new webdav fetcher
+func NewWebDAV() *WebDAV {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	return &WebDAV{Client: &http.Client{Transport: transport}, Attempts: 3}
+}
+
+func (w *WebDAV) Schemes() []string {
+	return []string{"webdav.http", "webdav.https"}
+}
+
+func (w *WebDAV) Fetch(source *url.URL, destiny afero.Fs) error {
+	root := *source
+	root.Scheme = strings.TrimPrefix(strings.ToLower(source.Scheme), "webdav.")
+	root.User = nil
+	root.Fragment = ""
+	if !strings.HasSuffix(root.Path, "/") {
+		root.Path += "/"
+	}
+	return w.fetch(source, &root, "", destiny, make(map[string]bool))
+}
+
+// fetch downloads the members of the given collection, and of its subcollections, to the given
+// local path, where visited are the paths of the members already fetched.
+//
+// The members must be inside the collection, in the same server, since the credentials of the
+// source are sent with their requests.
+func (w *WebDAV) fetch(source, collection *url.URL, local string, destiny afero.Fs, visited map[string]bool) error {
+	base := path.Clean(collection.Path)
+	visited[base] = true
+	prefix := strings.TrimSuffix(base, "/") + "/"
+	request, err := w.request(source, "PROPFIND", collection, strings.NewReader(webdavPropfind))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Depth", "1")
+	request.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	response, err := w.Client.Do(request)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to list the collection %s", collection.Path)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode != http.StatusMultiStatus {
+		return errors.Errorf("Unable to list the collection %s: %s", collection.Path, response.Status)
+	}
+	var multistatus webdavMultistatus
+	if err := xml.NewDecoder(response.Body).Decode(&multistatus); err != nil {
+		return errors.Wrapf(err, "Unable to read the members of the collection %s", collection.Path)
+	}
+
+	for _, member := range multistatus.Responses {
+		href, err := url.Parse(member.Href)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid member %s of the collection %s", member.Href, collection.Path)
+		}
+		target := collection.ResolveReference(href)
+		if !strings.EqualFold(target.Scheme, collection.Scheme) || !strings.EqualFold(target.Host, collection.Host) {
+			return errors.Errorf("The member %s of the collection %s is in another server", member.Href, collection.Path)
+		}
+		cleaned := path.Clean(target.Path)
+		if cleaned == base {
+			continue
+		}
+		if !strings.HasPrefix(cleaned, prefix) {
+			return errors.Errorf("The member %s of the collection %s is outside of it", member.Href, collection.Path)
+		}
+		if visited[cleaned] {
+			continue
+		}
+		visited[cleaned] = true
+		name := path.Base(cleaned)
+		isCollection, size := false, int64(-1)
+		for _, propstat := range member.Propstat {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			isCollection = isCollection || propstat.Prop.ResourceType.Collection != nil
+			if _, err := fmt.Sscan(propstat.Prop.ContentLength, &size); err != nil {
+				size = -1
+			}
+		}
+		if isCollection {
+			if !strings.HasSuffix(target.Path, "/") {
+				target.Path += "/"
+			}
+			err = w.fetch(source, target, path.Join(local, name), destiny, visited)
+		} else {
+			err = w.download(source, target, path.Join(local, name), size, destiny)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// download writes the given member to the given local path, resuming it with range requests
+func (w *WebDAV) download(source, member *url.URL, local string, size int64, destiny afero.Fs) error {
+	return download(destiny, local, size, w.Attempts, func(offset int64) (io.ReadCloser, int64, error) {
+		request, err := w.request(source, http.MethodGet, member, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		if offset > 0 {
+			request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+		response, err := w.Client.Do(request)
+		if err != nil {
+			return nil, 0, err
+		}
+		switch {
+		case response.StatusCode == http.StatusPartialContent && offset > 0:
+			return response.Body, offset, nil
+		case response.StatusCode == http.StatusOK:
+			return response.Body, 0, nil
+		default:
+			_ = response.Body.Close()
+			return nil, 0, errors.Errorf("Unable to get %s: %s", member.Path, response.Status)
+		}
+	})
+}
+
+// request creates a new request to the given url, with the credentials of the source
+func (w *WebDAV) request(source *url.URL, method string, target *url.URL, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequest(method, target.String(), body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid url %s", target.Redacted())
+	}
+	if user, password, ok := credentials(source); ok {
+		request.SetBasicAuth(user, password)
+	}
+	return request, nil
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+	"golang.org/x/net/webdav"
+)
+
+// webdavServer is a webdav server in memory, with basic authentication, that can abort the
+// first transfer of some files
+type webdavServer struct {
+	*httptest.Server
+	// failures maps the files whose first transfer is aborted to the bytes sent before.
+	failures map[string]int
+	ranges   []string
+	mutex    sync.Mutex
+}
+
+// abortWriter aborts the response after writing the given number of bytes
+type abortWriter struct {
+	http.ResponseWriter
+	limit int
+}
+
+func (a *abortWriter) Write(data []byte) (int, error) {
+	if len(data) > a.limit {
+		_, _ = a.ResponseWriter.Write(data[:a.limit])
+		a.ResponseWriter.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	a.limit -= len(data)
+	return a.ResponseWriter.Write(data)
+}
+
+func newWebDAVServer(t *testing.T, files map[string]string) *webdavServer {
+	fs := webdav.NewMemFS()
+	for name, content := range files {
+		dir := name[:strings.LastIndex(name, "/")]
+		for i := 1; i <= len(dir); i++ {
+			if i == len(dir) || dir[i] == '/' {
+				_ = fs.Mkdir(context.Background(), dir[:i], 0o755)
+			}
+		}
+		file, err := fs.OpenFile(context.Background(), name, os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = file.Write([]byte(content))
+		_ = file.Close()
+	}
+	handler := &webdav.Handler{FileSystem: fs, LockSystem: webdav.NewMemLS()}
+	server := &webdavServer{failures: make(map[string]int)}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodGet {
+			server.mutex.Lock()
+			limit, fail := server.failures[r.URL.Path]
+			delete(server.failures, r.URL.Path)
+			if value := r.Header.Get("Range"); value != "" {
+				server.ranges = append(server.ranges, value)
+			}
+			server.mutex.Unlock()
+			if fail {
+				w = &abortWriter{ResponseWriter: w, limit: limit}
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newHostileServer returns a server whose collection lists the given hrefs, as collections, and
+// whose files have their path as content
+func newHostileServer(t *testing.T, hrefs ...string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PROPFIND" {
+			_, _ = w.Write([]byte(r.URL.Path))
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		_, _ = fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><D:multistatus xmlns:D="DAV:">`)
+		for _, href := range hrefs {
+			_, _ = fmt.Fprintf(w, `<D:response><D:href>%s</D:href><D:propstat><D:prop><D:resourcetype><D:collection/></D:resourcetype>`+
+				`</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`, href)
+		}
+		_, _ = fmt.Fprint(w, `<D:response><D:href>/books/file.md</D:href><D:propstat><D:prop><D:resourcetype/></D:prop>`+
+			`<D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response></D:multistatus>`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// url returns the webdav url of the given collection in the server
+func (s *webdavServer) url(userinfo, collection string) string {
+	return strings.Replace(s.URL, "http://", "webdav.http://"+userinfo, 1) + collection
+}
+
+func TestWebDAV(t *testing.T) {
+	netrc := t.TempDir() + "/netrc"
+	t.Setenv("NETRC", netrc)
+
+	Convey("#WebDAV", t, func() {
+		memFs := afero.NewMemMapFs()
+		server := newWebDAVServer(t, map[string]string{
+			"/books/project/riconto.toml":         "name = \"fetched\"\n",
+			"/books/project/src/main.md":          "# A title\n\nWith a long enough paragraph.\n",
+			"/books/project/src/chapters/one.md":  "# One\n",
+			"/books/other/riconto.toml":           "name = \"other\"\n",
+			"/books/project/src/chapters/two.md":  "# Two\n",
+			"/books/project/resources/image name": "data",
+		})
+
+		Convey("It should fetch the members of a collection", func() {
+			So(NewWebDAV().Fetch(mustParse(server.url("user:secret@", "/books/project")), memFs), ShouldBeNil)
+			for name, content := range map[string]string{
+				"riconto.toml":         "name = \"fetched\"\n",
+				"src/main.md":          "# A title\n\nWith a long enough paragraph.\n",
+				"src/chapters/one.md":  "# One\n",
+				"src/chapters/two.md":  "# Two\n",
+				"resources/image name": "data",
+			} {
+				data, err := afero.ReadFile(memFs, name)
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, content)
+			}
+			_, err := memFs.Stat("other")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("It should resume an aborted transfer with a range", func() {
+			server.failures["/books/project/src/main.md"] = 10
+			So(NewWebDAV().Fetch(mustParse(server.url("user:secret@", "/books/project/")), memFs), ShouldBeNil)
+			data, err := afero.ReadFile(memFs, "src/main.md")
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "# A title\n\nWith a long enough paragraph.\n")
+			So(server.ranges, ShouldResemble, []string{"bytes=10-"})
+		})
+
+		Convey("It should use the credentials of the netrc file", func() {
+			So(os.WriteFile(netrc, []byte("machine 127.0.0.1\n  login user\n  password secret\n"), 0o600), ShouldBeNil)
+			So(NewWebDAV().Fetch(mustParse(server.url("", "/books/project")), memFs), ShouldBeNil)
+			_, err := memFs.Stat("riconto.toml")
+			So(err, ShouldBeNil)
+			So(os.Remove(netrc), ShouldBeNil)
+		})
+
+		Convey("It should skip the collection itself, however it is written", func() {
+			hostile := newHostileServer(t, "/books/", "/books", "/books/./", "/books/../books/")
+			So(NewWebDAV().Fetch(mustParse(strings.Replace(hostile.URL, "http://", "webdav.http://user:secret@", 1)+"/books"), memFs), ShouldBeNil)
+			data, err := afero.ReadFile(memFs, "file.md")
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "/books/file.md")
+		})
+
+		Convey("It should not follow the members outside of the collection", func() {
+			hostile := newHostileServer(t, "/", "/other/")
+			err := NewWebDAV().Fetch(mustParse(strings.Replace(hostile.URL, "http://", "webdav.http://user:secret@", 1)+"/books"), memFs)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "The member / of the collection /books/ is outside of it")
+		})
+
+		Convey("It should not send the credentials to other servers", func() {
+			var authorization []string
+			var mutex sync.Mutex
+			other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mutex.Lock()
+				authorization = append(authorization, r.Header.Get("Authorization"))
+				mutex.Unlock()
+			}))
+			defer other.Close()
+			hostile := newHostileServer(t, other.URL+"/books/stolen/")
+			err := NewWebDAV().Fetch(mustParse(strings.Replace(hostile.URL, "http://", "webdav.http://user:secret@", 1)+"/books"), memFs)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "is in another server")
+			mutex.Lock()
+			defer mutex.Unlock()
+			So(authorization, ShouldBeEmpty)
+		})
+
+		Convey("It should fail without credentials", func() {
+			err := NewWebDAV().Fetch(mustParse(server.url("", "/books/project")), memFs)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "401 Unauthorized")
+		})
+	})
+}
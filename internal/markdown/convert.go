@@ -0,0 +1,331 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/directive"
+	"github.com/chordflower/riconto/internal/document"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+// converter converts a goldmark ast into the document model
+type converter struct {
+	path     string
+	source   []byte
+	warnings []Warning
+	registry *directive.Registry
+	context  *directive.Context
+	// footnotes are the blocks of each footnote of the document, by their index
+	footnotes map[int][]document.Block
+	err       error
+}
+
+func newConverter(path string, source []byte, registry *directive.Registry, context *directive.Context) *converter {
+	c := &converter{
+		path:      path,
+		source:    source,
+		warnings:  make([]Warning, 0),
+		registry:  registry,
+		context:   context,
+		footnotes: make(map[int][]document.Block),
+	}
+	context.Warn = func(position directive.Position, message string) {
+		c.warnings = append(c.warnings, Warning{Path: position.Path, Line: position.Line, Message: message})
+	}
+	return c
+}
+
+// warn registers a warning for the given node
+func (c *converter) warn(node ast.Node, format string, args ...any) {
+	c.warnings = append(c.warnings, Warning{
+		Path:    c.path,
+		Line:    c.line(node),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// line returns the line number of the given node, or 0 if it is unknown
+func (c *converter) line(node ast.Node) int {
+	for ; node != nil; node = node.Parent() {
+		if node.Type() == ast.TypeBlock && node.Lines().Len() > 0 {
+			offset := node.Lines().At(0).Start
+			return bytes.Count(c.source[:offset], []byte("\n")) + 1
+		}
+	}
+	return 0
+}
+
+// document converts the given document node into blocks, with its footnotes already
+// converted so that they can be attached to their references
+func (c *converter) document(root ast.Node) []document.Block {
+	for node := root.FirstChild(); node != nil; node = node.NextSibling() {
+		list, ok := node.(*east.FootnoteList)
+		if !ok {
+			continue
+		}
+		for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+			if footnote, ok := item.(*east.Footnote); ok {
+				c.footnotes[footnote.Index] = c.blocks(footnote)
+			}
+		}
+	}
+	return c.blocks(root)
+}
+
+// blocks converts the children of the given node into blocks
+func (c *converter) blocks(parent ast.Node) []document.Block {
+	result := make([]document.Block, 0, parent.ChildCount())
+	for node := parent.FirstChild(); node != nil && c.err == nil; node = node.NextSibling() {
+		if leaf, ok := node.(*leafDirective); ok {
+			result = append(result, c.leafDirective(leaf)...)
+			continue
+		}
+		if block := c.block(node); block != nil {
+			result = append(result, block)
+		}
+	}
+	return result
+}
+
+// directive returns the directive node for the given match, or nil if the directive is not
+// registered or its attributes are invalid
+func (c *converter) directive(match directive.Match, offset int, leaf bool) (*directive.Node, directive.Directive) {
+	line, column := position(c.source, offset)
+	node := &directive.Node{
+		Name:     match.Name,
+		Content:  match.Content,
+		Position: directive.Position{Path: c.path, Line: line, Column: column},
+		Leaf:     leaf,
+	}
+	handler, ok := c.registry.Lookup(match.Name)
+	if !ok {
+		c.context.Warn(node.Position, fmt.Sprintf("unknown directive %s will be ignored", match.Name))
+		return nil, nil
+	}
+	attributes, err := directive.ParseAttributes(match.Attributes)
+	if err != nil {
+		c.err = errors.Wrapf(err, "Invalid attributes in the %s directive at %s", match.Name, node.Position)
+		return nil, nil
+	}
+	node.Attributes = attributes
+	return node, handler
+}
+
+// leafDirective returns the blocks that replace the given leaf directive
+func (c *converter) leafDirective(leaf *leafDirective) []document.Block {
+	node, handler := c.directive(leaf.match, leaf.offset, true)
+	if node == nil {
+		return nil
+	}
+	block, ok := handler.(directive.BlockDirective)
+	if !ok {
+		c.context.Warn(node.Position, fmt.Sprintf("the %s directive can not be used as a block and will be ignored", node.Name))
+		return nil
+	}
+	blocks, err := block.Block(c.context, node)
+	if err != nil {
+		c.err = err
+		return nil
+	}
+	return blocks
+}
+
+// inlineDirective returns the inlines that replace the given inline directive, which is kept
+// as text when it can not be handled
+func (c *converter) inlineDirective(inline *inlineDirective) []document.Inline {
+	raw := []document.Inline{&document.Text{Value: string(c.source[inline.offset : inline.offset+inline.match.Length])}}
+	node, handler := c.directive(inline.match, inline.offset, false)
+	if node == nil {
+		return raw
+	}
+	value, ok := handler.(directive.InlineDirective)
+	if !ok {
+		c.context.Warn(node.Position, fmt.Sprintf("the %s directive can not be used inside text and will be kept as text", node.Name))
+		return raw
+	}
+	inlines, err := value.Inline(c.context, node)
+	if err != nil {
+		c.err = err
+		return raw
+	}
+	return inlines
+}
+
+func (c *converter) block(node ast.Node) document.Block {
+	switch value := node.(type) {
+	case *ast.Heading:
+		heading := &document.Heading{
+			Level:   value.Level,
+			Content: c.inlines(value),
+		}
+		if id, ok := value.AttributeString("id"); ok {
+			if data, ok := id.([]byte); ok {
+				heading.ID = string(data)
+			}
+		}
+		return heading
+	case *ast.Paragraph, *ast.TextBlock:
+		return &document.Paragraph{Content: c.inlines(value)}
+	case *ast.List:
+		list := &document.List{
+			Ordered: value.IsOrdered(),
+			Start:   value.Start,
+			Tight:   value.IsTight,
+			Items:   make([]*document.ListItem, 0, value.ChildCount()),
+		}
+		for item := value.FirstChild(); item != nil; item = item.NextSibling() {
+			list.Items = append(list.Items, &document.ListItem{Blocks: c.blocks(item)})
+		}
+		return list
+	case *ast.Blockquote:
+		return &document.BlockQuote{Blocks: c.blocks(value)}
+	case *ast.FencedCodeBlock:
+		code := &document.CodeBlock{Code: c.lines(value)}
+		if value.Info != nil {
+			code.Language = string(value.Language(c.source))
+		}
+		return code
+	case *ast.CodeBlock:
+		return &document.CodeBlock{Code: c.lines(value)}
+	case *ast.ThematicBreak:
+		return &document.ThematicBreak{}
+	case *east.Table:
+		return c.table(value)
+	case *east.FootnoteList:
+		// The footnotes are converted beforehand and attached to their references
+		return nil
+	case *ast.HTMLBlock:
+		c.warn(node, "raw html blocks are not supported and will be ignored")
+	default:
+		c.warn(node, "unsupported block %s will be ignored", node.Kind().String())
+	}
+	return nil
+}
+
+// table converts the given table node, whose first child is the header row
+func (c *converter) table(node *east.Table) *document.Table {
+	table := &document.Table{
+		Alignments: make([]string, 0, len(node.Alignments)),
+		Rows:       make([][]*document.TableCell, 0, node.ChildCount()),
+	}
+	for _, alignment := range node.Alignments {
+		switch alignment {
+		case east.AlignLeft:
+			table.Alignments = append(table.Alignments, document.AlignLeft)
+		case east.AlignCenter:
+			table.Alignments = append(table.Alignments, document.AlignCenter)
+		case east.AlignRight:
+			table.Alignments = append(table.Alignments, document.AlignRight)
+		default:
+			table.Alignments = append(table.Alignments, document.AlignNone)
+		}
+	}
+	for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+		cells := make([]*document.TableCell, 0, row.ChildCount())
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cells = append(cells, &document.TableCell{Content: c.inlines(cell)})
+		}
+		if _, ok := row.(*east.TableHeader); ok {
+			table.Header = cells
+		} else {
+			table.Rows = append(table.Rows, cells)
+		}
+	}
+	return table
+}
+
+// lines returns the raw source lines of the given node
+func (c *converter) lines(node ast.Node) string {
+	var builder strings.Builder
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		builder.Write(segment.Value(c.source))
+	}
+	return builder.String()
+}
+
+// inlines converts the children of the given node into inlines
+func (c *converter) inlines(parent ast.Node) []document.Inline {
+	result := make([]document.Inline, 0, parent.ChildCount())
+	for node := parent.FirstChild(); node != nil; node = node.NextSibling() {
+		result = c.inline(result, node)
+	}
+	return result
+}
+
+func (c *converter) inline(result []document.Inline, node ast.Node) []document.Inline {
+	switch value := node.(type) {
+	case *ast.Text:
+		result = append(result, &document.Text{Value: string(value.Segment.Value(c.source))})
+		if value.HardLineBreak() {
+			result = append(result, &document.Break{Hard: true})
+		} else if value.SoftLineBreak() {
+			result = append(result, &document.Break{Hard: false})
+		}
+	case *ast.String:
+		result = append(result, &document.Text{Value: string(value.Value)})
+	case *ast.Emphasis:
+		result = append(result, &document.Emphasis{Level: value.Level, Content: c.inlines(value)})
+	case *ast.CodeSpan:
+		var builder strings.Builder
+		for child := value.FirstChild(); child != nil; child = child.NextSibling() {
+			if text, ok := child.(*ast.Text); ok {
+				builder.Write(text.Segment.Value(c.source))
+			}
+		}
+		result = append(result, &document.Code{Value: builder.String()})
+	case *ast.Link:
+		result = append(result, &document.Link{
+			Destination: string(value.Destination),
+			Title:       string(value.Title),
+			Content:     c.inlines(value),
+		})
+	case *ast.AutoLink:
+		url := string(value.URL(c.source))
+		result = append(result, &document.Link{
+			Destination: url,
+			Content:     []document.Inline{&document.Text{Value: string(value.Label(c.source))}},
+		})
+	case *ast.Image:
+		result = append(result, &document.Image{
+			Destination: string(value.Destination),
+			Title:       string(value.Title),
+			Alt:         document.PlainText(c.inlines(value)),
+		})
+	case *east.FootnoteLink:
+		result = append(result, &document.Footnote{Blocks: c.footnotes[value.Index]})
+	case *east.FootnoteBacklink:
+		// The back links are created by each output format
+	case *inlineDirective:
+		result = append(result, c.inlineDirective(value)...)
+	case *ast.RawHTML:
+		c.warn(node, "raw html is not supported and will be ignored")
+	default:
+		c.warn(node, "unsupported inline %s will be ignored", node.Kind().String())
+	}
+	return result
+}
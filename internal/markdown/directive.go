@@ -0,0 +1,147 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package markdown
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/chordflower/riconto/internal/directive"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	// KindLeafDirective is the kind of the leaf directive nodes
+	KindLeafDirective = ast.NewNodeKind("LeafDirective")
+	// KindInlineDirective is the kind of the inline directive nodes
+	KindInlineDirective = ast.NewNodeKind("InlineDirective")
+)
+
+// leafDirective represents a `::name[content]{key=value}` directive, in a line of its own
+type leafDirective struct {
+	ast.BaseBlock
+	match  directive.Match
+	offset int
+}
+
+func (n *leafDirective) Kind() ast.NodeKind {
+	return KindLeafDirective
+}
+
+func (n *leafDirective) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.match.Name}, nil)
+}
+
+// inlineDirective represents a `:name[content]{key=value}` directive, inside text
+type inlineDirective struct {
+	ast.BaseInline
+	match  directive.Match
+	offset int
+}
+
+func (n *inlineDirective) Kind() ast.NodeKind {
+	return KindInlineDirective
+}
+
+func (n *inlineDirective) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Name": n.match.Name}, nil)
+}
+
+// leafDirectiveParser parses the leaf directives, which can not interrupt a paragraph
+type leafDirectiveParser struct{}
+
+func (p *leafDirectiveParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (p *leafDirectiveParser) Open(_ ast.Node, reader text.Reader, _ parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	match, ok := directive.ScanLeaf(line)
+	if !ok {
+		return nil, parser.NoChildren
+	}
+	node := &leafDirective{match: match, offset: segment.Start}
+	for node.offset < segment.Stop && reader.Source()[node.offset] != ':' {
+		node.offset++
+	}
+	node.Lines().Append(segment)
+	reader.Advance(segment.Len() - 1)
+	return node, parser.NoChildren
+}
+
+func (p *leafDirectiveParser) Continue(_ ast.Node, _ text.Reader, _ parser.Context) parser.State {
+	return parser.Close
+}
+
+func (p *leafDirectiveParser) Close(_ ast.Node, _ text.Reader, _ parser.Context) {}
+
+func (p *leafDirectiveParser) CanInterruptParagraph() bool {
+	return false
+}
+
+func (p *leafDirectiveParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// inlineDirectiveParser parses the inline directives, which must not follow a letter or digit
+type inlineDirectiveParser struct{}
+
+func (p *inlineDirectiveParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (p *inlineDirectiveParser) Parse(_ ast.Node, block text.Reader, _ parser.Context) ast.Node {
+	if previous := block.PrecendingCharacter(); previous == ':' || unicode.IsLetter(previous) || unicode.IsDigit(previous) {
+		return nil
+	}
+	line, segment := block.PeekLine()
+	match, ok := directive.ScanInline(line)
+	if !ok {
+		return nil
+	}
+	block.Advance(match.Length)
+	return &inlineDirective{match: match, offset: segment.Start}
+}
+
+// directiveExtension adds the directive parsers to goldmark
+type directiveExtension struct{}
+
+func (e *directiveExtension) Extend(markdown goldmark.Markdown) {
+	markdown.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&leafDirectiveParser{}, 500)),
+		parser.WithInlineParsers(util.Prioritized(&inlineDirectiveParser{}, 600)),
+	)
+}
+
+// position returns the line and column, starting at 1, of the given offset in the source
+func position(source []byte, offset int) (int, int) {
+	line, start := 1, 0
+	for i := 0; i < offset && i < len(source); i++ {
+		if source[i] == '\n' {
+			line++
+			start = i + 1
+		}
+	}
+	return line, utf8.RuneCount(source[start:min(offset, len(source))]) + 1
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package markdown
+
+import "bytes"
+
+// splitFrontMatter separates the yaml front matter, between --- lines at the start of the
+// source, from the markdown; the front matter lines are replaced by empty lines in the returned
+// markdown, so that the line numbers are kept
+func splitFrontMatter(source []byte) ([]byte, []byte) {
+	source = bytes.TrimPrefix(source, []byte("\xef\xbb\xbf"))
+	first, rest, ok := bytes.Cut(source, []byte("\n"))
+	if !ok || string(bytes.TrimRight(first, " \t\r")) != "---" {
+		return nil, source
+	}
+	offset := len(first) + 1
+	for len(rest) > 0 {
+		line, next, _ := bytes.Cut(rest, []byte("\n"))
+		if delimiter := string(bytes.TrimRight(line, " \t\r")); delimiter == "---" || delimiter == "..." {
+			frontMatter := source[len(first)+1 : offset]
+			end := offset + len(line)
+			markdown := append(bytes.Repeat([]byte("\n"), bytes.Count(source[:end], []byte("\n"))), source[end:]...)
+			return frontMatter, markdown
+		}
+		offset += len(line) + 1
+		rest = next
+	}
+	return nil, source
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package markdown
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Graph represents the dependencies between markdown files, created by their includes
+type Graph struct {
+	files    []string
+	includes map[string][]string
+}
+
+// NewGraph creates a new empty dependency graph
+func NewGraph() *Graph {
+	return &Graph{
+		files:    make([]string, 0),
+		includes: make(map[string][]string),
+	}
+}
+
+// AddFile adds the given file to the graph, if it is not already there
+func (g *Graph) AddFile(file string) {
+	if _, ok := g.includes[file]; !ok {
+		g.files = append(g.files, file)
+		g.includes[file] = make([]string, 0)
+	}
+}
+
+// AddInclude registers that the file from includes the file to
+func (g *Graph) AddInclude(from, to string) {
+	g.AddFile(from)
+	g.AddFile(to)
+	if !slices.Contains(g.includes[from], to) {
+		g.includes[from] = append(g.includes[from], to)
+	}
+}
+
+// Files returns all the files in the graph, in the order they were added
+func (g *Graph) Files() []string {
+	return slices.Clone(g.files)
+}
+
+// Includes returns the files directly included by the given file
+func (g *Graph) Includes(file string) []string {
+	return slices.Clone(g.includes[file])
+}
+
+// IncludedBy returns the files that directly include the given file
+func (g *Graph) IncludedBy(file string) []string {
+	result := make([]string, 0)
+	for _, from := range g.files {
+		if slices.Contains(g.includes[from], file) {
+			result = append(result, from)
+		}
+	}
+	return result
+}
+
+// Dependencies returns the given file and all the files it includes, directly or not,
+// in depth first order
+func (g *Graph) Dependencies(file string) []string {
+	result := make([]string, 0)
+	var visit func(string)
+	visit = func(current string) {
+		if slices.Contains(result, current) {
+			return
+		}
+		result = append(result, current)
+		for _, included := range g.includes[current] {
+			visit(included)
+		}
+	}
+	if _, ok := g.includes[file]; ok {
+		visit(file)
+	}
+	return result
+}
+
+// IncludeCycleError represents a file that includes itself, directly or through other files
+type IncludeCycleError struct {
+	// Chain contains the files from the first inclusion of the repeated file, until it is included again.
+	Chain []string
+}
+
+func (e *IncludeCycleError) Error() string {
+	return fmt.Sprintf("Recursive include of %s: %s", e.Chain[len(e.Chain)-1], strings.Join(e.Chain, " -> "))
+}
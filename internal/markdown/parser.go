@@ -0,0 +1,141 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package markdown
+
+import (
+	"fmt"
+	"path"
+	"slices"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/directive"
+	"github.com/chordflower/riconto/internal/document"
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/spf13/afero"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Warning represents a non fatal problem found while parsing a markdown file
+type Warning struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+// String returns the warning in a human readable form
+func (w Warning) String() string {
+	if w.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", w.Path, w.Line, w.Message)
+	}
+	return fmt.Sprintf("%s: %s", w.Path, w.Message)
+}
+
+// Parser parses markdown files from a filesystem into documents.
+//
+// The directives found in the files are handled by the directives in the registry of the parser,
+// and the dependencies between files, created by the `::include[file]` directives, are kept in
+// the graph of the parser.
+type Parser struct {
+	fs       afero.Fs
+	markdown goldmark.Markdown
+	registry *directive.Registry
+	graph    *Graph
+}
+
+// NewParser creates a new parser that reads the files from the given filesystem
+func NewParser(fs afero.Fs) *Parser {
+	return &Parser{
+		fs: fs,
+		markdown: goldmark.New(
+			goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+			goldmark.WithExtensions(&directiveExtension{}, extension.Table, extension.Footnote),
+		),
+		registry: directive.DefaultRegistry(),
+		graph:    NewGraph(),
+	}
+}
+
+// Registry returns the directives handled by the parser, where new directives may be registered
+func (p *Parser) Registry() *directive.Registry {
+	return p.registry
+}
+
+// Graph returns the dependencies between all the files parsed so far
+func (p *Parser) Graph() *Graph {
+	return p.graph
+}
+
+// ParseFile parses the markdown file in the given path, returning the resulting
+// document and any warnings found while converting it
+func (p *Parser) ParseFile(filename string) (*document.Document, []Warning, error) {
+	filename = path.Clean(filename)
+	p.graph.AddFile(filename)
+	doc, warnings, err := p.parse(filename, make([]string, 0))
+	if err != nil {
+		return nil, nil, err
+	}
+	document.UniqueIDs(document.Headings(doc.Blocks))
+	return doc, warnings, nil
+}
+
+// parse parses the given file, where chain contains the files that are including it
+func (p *Parser) parse(filename string, chain []string) (*document.Document, []Warning, error) {
+	if index := slices.Index(chain, filename); index >= 0 {
+		return nil, nil, &IncludeCycleError{Chain: append(slices.Clone(chain[index:]), filename)}
+	}
+	source, err := afero.ReadFile(p.fs, filename)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Unable to read the markdown file %s", filename)
+	}
+	frontMatter, source := splitFrontMatter(source)
+	meta, err := model.DocumentMetaFromFrontMatter(frontMatter)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Invalid front matter in %s", filename)
+	}
+	root := p.markdown.Parser().Parse(text.NewReader(source))
+	chain = append(slices.Clone(chain), filename)
+	var conv *converter
+	context := &directive.Context{
+		Fs:   p.fs,
+		Path: filename,
+		Parse: func(included string) (*document.Document, error) {
+			p.graph.AddInclude(filename, included)
+			doc, warnings, err := p.parse(included, chain)
+			if err != nil {
+				return nil, err
+			}
+			conv.warnings = append(conv.warnings, warnings...)
+			return doc, nil
+		},
+	}
+	conv = newConverter(filename, source, p.registry, context)
+	doc := &document.Document{
+		Path:   filename,
+		Meta:   meta,
+		Blocks: conv.document(root),
+	}
+	if conv.err != nil {
+		return nil, nil, conv.err
+	}
+	return doc, conv.warnings, nil
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/directive"
+	"github.com/chordflower/riconto/internal/document"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+// upperDirective writes its content in upper case, and records where it was used
+type upperDirective struct {
+	nodes []*directive.Node
+}
+
+func (d *upperDirective) Name() string {
+	return "upper"
+}
+
+func (d *upperDirective) Inline(_ *directive.Context, node *directive.Node) ([]document.Inline, error) {
+	d.nodes = append(d.nodes, node)
+	return []document.Inline{&document.Text{Value: strings.ToUpper(node.Content)}}, nil
+}
+
+func (d *upperDirective) Block(_ *directive.Context, node *directive.Node) ([]document.Block, error) {
+	d.nodes = append(d.nodes, node)
+	return []document.Block{&document.Paragraph{Content: []document.Inline{&document.Text{Value: strings.ToUpper(node.Content)}}}}, nil
+}
+
+func TestParser(t *testing.T) {
+	Convey("#Parser", t, func() {
+		fs := afero.NewMemMapFs()
+		parser := NewParser(fs)
+
+		Convey("It should splice the included files in place", func() {
+			So(afero.WriteFile(fs, "src/main.md", []byte("# Main\n\n::include[./chapters/one.md]\n::include[chapters/two.md]\n\nThe end.\n"), 0o644), ShouldBeNil)
+			So(afero.WriteFile(fs, "src/chapters/one.md", []byte("# One\n\n::include[../common/note.md]\n"), 0o644), ShouldBeNil)
+			So(afero.WriteFile(fs, "src/chapters/two.md", []byte("# Two\n"), 0o644), ShouldBeNil)
+			So(afero.WriteFile(fs, "src/common/note.md", []byte("A note.\n"), 0o644), ShouldBeNil)
+
+			doc, warnings, err := parser.ParseFile("./src/main.md")
+			So(err, ShouldBeNil)
+			So(warnings, ShouldBeEmpty)
+			So(doc.Blocks, ShouldHaveLength, 4)
+			one, ok := doc.Blocks[1].(*document.Include)
+			So(ok, ShouldBeTrue)
+			So(one.Path, ShouldEqual, "src/chapters/one.md")
+			So(one.Blocks, ShouldHaveLength, 2)
+			note, ok := one.Blocks[1].(*document.Include)
+			So(ok, ShouldBeTrue)
+			So(note.Path, ShouldEqual, "src/common/note.md")
+			two, ok := doc.Blocks[2].(*document.Include)
+			So(ok, ShouldBeTrue)
+			So(two.Path, ShouldEqual, "src/chapters/two.md")
+			So(doc.Blocks[3], ShouldHaveSameTypeAs, &document.Paragraph{})
+
+			graph := parser.Graph()
+			So(graph.Includes("src/main.md"), ShouldResemble, []string{"src/chapters/one.md", "src/chapters/two.md"})
+			So(graph.IncludedBy("src/common/note.md"), ShouldResemble, []string{"src/chapters/one.md"})
+			So(graph.Dependencies("src/main.md"), ShouldResemble, []string{
+				"src/main.md", "src/chapters/one.md", "src/common/note.md", "src/chapters/two.md",
+			})
+		})
+
+		Convey("It should read the front matter and keep the line numbers", func() {
+			So(afero.WriteFile(fs, "main.md", []byte("---\ntitle: Main\ntags:\n  - one\n---\n\n# Main\n\n<div>html</div>\n"), 0o644), ShouldBeNil)
+			doc, warnings, err := parser.ParseFile("main.md")
+			So(err, ShouldBeNil)
+			So(doc.Meta.Title, ShouldEqual, "Main")
+			So(doc.Meta.Tags, ShouldResemble, []string{"one"})
+			So(doc.Blocks, ShouldHaveLength, 1)
+			So(doc.Blocks[0], ShouldHaveSameTypeAs, &document.Heading{})
+			So(warnings, ShouldHaveLength, 1)
+			So(warnings[0].Line, ShouldEqual, 9)
+		})
+
+		Convey("It should fail on invalid front matter", func() {
+			So(afero.WriteFile(fs, "main.md", []byte("---\ntitle: [unclosed\n---\n# Main\n"), 0o644), ShouldBeNil)
+			_, _, err := parser.ParseFile("main.md")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "Invalid front matter in main.md")
+		})
+
+		Convey("It should keep include directives mixed with text as text", func() {
+			So(afero.WriteFile(fs, "main.md", []byte("See ::include[./other.md] for more.\n"), 0o644), ShouldBeNil)
+			doc, _, err := parser.ParseFile("main.md")
+			So(err, ShouldBeNil)
+			So(doc.Blocks, ShouldHaveLength, 1)
+			So(doc.Blocks[0], ShouldHaveSameTypeAs, &document.Paragraph{})
+		})
+
+		Convey("It should replace the registered directives, with their positions", func() {
+			upper := &upperDirective{}
+			So(parser.Registry().Register(upper), ShouldBeNil)
+			So(afero.WriteFile(fs, "main.md", []byte("Some :upper[loud]{size=big} text.\n\n::upper[block]{quiet}\n"), 0o644), ShouldBeNil)
+			doc, warnings, err := parser.ParseFile("main.md")
+			So(err, ShouldBeNil)
+			So(warnings, ShouldBeEmpty)
+			So(doc.Blocks, ShouldHaveLength, 2)
+			So(document.PlainText(doc.Blocks[0].(*document.Paragraph).Content), ShouldEqual, "Some LOUD text.")
+			So(document.PlainText(doc.Blocks[1].(*document.Paragraph).Content), ShouldEqual, "BLOCK")
+			So(upper.nodes, ShouldHaveLength, 2)
+			So(upper.nodes[0].Position, ShouldResemble, directive.Position{Path: "main.md", Line: 1, Column: 6})
+			So(upper.nodes[0].Attributes.String("size", ""), ShouldEqual, "big")
+			So(upper.nodes[0].Leaf, ShouldBeFalse)
+			So(upper.nodes[1].Position, ShouldResemble, directive.Position{Path: "main.md", Line: 3, Column: 1})
+			So(upper.nodes[1].Attributes.Has("quiet"), ShouldBeTrue)
+			So(upper.nodes[1].Leaf, ShouldBeTrue)
+		})
+
+		Convey("It should warn about unknown directives", func() {
+			So(afero.WriteFile(fs, "main.md", []byte("Some :unknown[text] here.\n\n::other\n"), 0o644), ShouldBeNil)
+			doc, warnings, err := parser.ParseFile("main.md")
+			So(err, ShouldBeNil)
+			So(warnings, ShouldHaveLength, 2)
+			So(warnings[0].String(), ShouldEqual, "main.md:1: unknown directive unknown will be ignored")
+			So(warnings[1].String(), ShouldEqual, "main.md:3: unknown directive other will be ignored")
+			So(doc.Blocks, ShouldHaveLength, 1)
+			So(document.PlainText(doc.Blocks[0].(*document.Paragraph).Content), ShouldEqual, "Some :unknown[text] here.")
+		})
+
+		Convey("It should convert tables with their header and alignments", func() {
+			So(afero.WriteFile(fs, "main.md", []byte("| Name | Price |\n|:-----|------:|\n| Tea | *1* |\n| Cake | 2 |\n"), 0o644), ShouldBeNil)
+			doc, warnings, err := parser.ParseFile("main.md")
+			So(err, ShouldBeNil)
+			So(warnings, ShouldBeEmpty)
+			So(doc.Blocks, ShouldHaveLength, 1)
+			table, ok := doc.Blocks[0].(*document.Table)
+			So(ok, ShouldBeTrue)
+			So(table.Alignments, ShouldResemble, []string{document.AlignLeft, document.AlignRight})
+			So(table.Header, ShouldHaveLength, 2)
+			So(document.PlainText(table.Header[1].Content), ShouldEqual, "Price")
+			So(table.Rows, ShouldHaveLength, 2)
+			So(table.Rows[0][1].Content[0], ShouldHaveSameTypeAs, &document.Emphasis{})
+			So(document.PlainText(table.Rows[1][0].Content), ShouldEqual, "Cake")
+		})
+
+		Convey("It should attach the footnotes to their references", func() {
+			So(afero.WriteFile(fs, "main.md", []byte("Some text[^note] and more[^other].\n\n[^other]: The other note.\n[^note]: The first note.\n"), 0o644), ShouldBeNil)
+			doc, warnings, err := parser.ParseFile("main.md")
+			So(err, ShouldBeNil)
+			So(warnings, ShouldBeEmpty)
+			So(doc.Blocks, ShouldHaveLength, 1)
+			content := doc.Blocks[0].(*document.Paragraph).Content
+			So(document.PlainText(content), ShouldEqual, "Some text and more.")
+			notes := make([]string, 0)
+			for _, inline := range content {
+				if footnote, ok := inline.(*document.Footnote); ok {
+					So(footnote.Blocks, ShouldHaveLength, 1)
+					notes = append(notes, document.PlainText(footnote.Blocks[0].(*document.Paragraph).Content))
+				}
+			}
+			So(notes, ShouldResemble, []string{"The first note.", "The other note."})
+		})
+
+		Convey("It should convert the citations, with their keys and locator", func() {
+			So(afero.WriteFile(fs, "main.md", []byte("---\nbibliography: refs.bib\n---\nAs shown :cite[knuth84, lamport94]{locator=\"p. 12\"}.\n"), 0o644), ShouldBeNil)
+			doc, warnings, err := parser.ParseFile("main.md")
+			So(err, ShouldBeNil)
+			So(warnings, ShouldBeEmpty)
+			So(doc.Meta.Bibliography, ShouldEqual, "refs.bib")
+			content := doc.Blocks[0].(*document.Paragraph).Content
+			So(content[1], ShouldResemble, &document.Citation{Keys: []string{"knuth84", "lamport94"}, Locator: "p. 12"})
+			So(document.PlainText(content), ShouldEqual, "As shown [knuth84; lamport94, p. 12].")
+
+			So(afero.WriteFile(fs, "main.md", []byte("As shown :cite[ , ].\n"), 0o644), ShouldBeNil)
+			_, _, err = parser.ParseFile("main.md")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "The cite directive in main.md:1:10 has no keys")
+		})
+
+		Convey("It should fail on invalid directive attributes", func() {
+			So(afero.WriteFile(fs, "main.md", []byte("::include[./other.md]{=open}\n"), 0o644), ShouldBeNil)
+			_, _, err := parser.ParseFile("main.md")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "Invalid attributes in the include directive at main.md:1:1")
+		})
+
+		Convey("It should fail on recursive includes, listing the include chain", func() {
+			So(afero.WriteFile(fs, "a.md", []byte("::include[./b.md]\n"), 0o644), ShouldBeNil)
+			So(afero.WriteFile(fs, "b.md", []byte("::include[./c.md]\n"), 0o644), ShouldBeNil)
+			So(afero.WriteFile(fs, "c.md", []byte("::include[./b.md]\n"), 0o644), ShouldBeNil)
+			_, _, err := parser.ParseFile("a.md")
+			So(err, ShouldNotBeNil)
+			var cycle *IncludeCycleError
+			So(errors.As(err, &cycle), ShouldBeTrue)
+			So(cycle.Chain, ShouldResemble, []string{"b.md", "c.md", "b.md"})
+			So(err.Error(), ShouldEqual, "Recursive include of b.md: b.md -> c.md -> b.md")
+		})
+
+		Convey("It should fail when the included file does not exist", func() {
+			So(afero.WriteFile(fs, "main.md", []byte("# Title\n\n::include[./missing.md]\n"), 0o644), ShouldBeNil)
+			_, _, err := parser.ParseFile("main.md")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "Unable to include ./missing.md in main.md:3")
+		})
+	})
+}
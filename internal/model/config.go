@@ -20,10 +20,12 @@
 package model
 
 //go:generate go-enum --marshal
+//go:generate go run ../schema/schemagen -type Config -title config -description "This is the schema of a configuration file for riconto" -output ../../api/config.schema.json
 
 import (
 	"io"
 	"slices"
+	"strings"
 
 	jsoniter "github.com/json-iterator/go"
 
@@ -34,14 +36,29 @@ import (
 
 // Config represents the project configuration file
 type Config struct {
-	Name        string   `json:"name" yaml:"name" toml:"name"`
-	Version     string   `json:"version" yaml:"version"  toml:"version"`
-	Description string   `json:"description" yaml:"description" toml:"description"`
-	Files       []File   `json:"files" toml:"files" yaml:"files"`
-	License     []string `json:"license" yaml:"license" toml:"license"`
-	Authors     []Author `json:"authors" yaml:"authors" toml:"authors"`
+	// Schema is the schema of the configuration file, for editors.
+	Schema string `json:"$schema,omitempty" yaml:"$schema,omitempty" toml:"$schema,omitempty" jsonschema:"format=uri-reference"`
+	// Name is the name of the project.
+	Name string `json:"name" yaml:"name" toml:"name" jsonschema:"required,minLength=1"`
+	// Version is the project version.
+	Version string `json:"version" yaml:"version"  toml:"version" jsonschema:"default=0.0.1"`
+	// Description is the project description.
+	Description string `json:"description" yaml:"description" toml:"description" jsonschema:"default="`
+	// Files are the root files to build.
+	Files []File `json:"files" toml:"files" yaml:"files"`
+	// License is the project license(s) in spdx format.
+	License []string `json:"license" yaml:"license" toml:"license"`
+	// Authors are the project author(s).
+	Authors []Author `json:"authors" yaml:"authors" toml:"authors"`
+	// Settings are the settings of all the files.
+	Settings `yaml:",inline"`
+	// Profiles are named sets of settings, which change the ones of the project when selected.
+	Profiles map[string]Profile `json:"profiles,omitempty" yaml:"profiles,omitempty" toml:"profiles,omitempty"`
 }
 
+// ConfigKeys are the keys of the configuration values that can be read and changed as text
+var ConfigKeys = []string{"name", "version", "description", "license", "theme"}
+
 func newConfig() *Config {
 	return &Config{
 		Version: "0.0.1",
@@ -66,12 +83,20 @@ func NewConfig(name string, version string, description string) *Config {
 // NewConfigFrom copies the given configuration.
 func NewConfigFrom(config *Config) *Config {
 	res := &Config{
+		Schema:      config.Schema,
 		Name:        config.Name,
 		Version:     config.Version,
 		Description: config.Description,
 		Files:       make([]File, 0, len(config.Files)),
 		License:     slices.Clone(config.License),
 		Authors:     make([]Author, 0, len(config.Authors)),
+		Settings:    *NewSettingsFrom(&config.Settings),
+	}
+	if config.Profiles != nil {
+		res.Profiles = make(map[string]Profile, len(config.Profiles))
+		for name, profile := range config.Profiles {
+			res.Profiles[name] = *NewProfileFrom(&profile)
+		}
 	}
 	for _, author := range config.Authors {
 		res.Authors = append(res.Authors, *NewAuthorFrom(&author))
@@ -108,6 +133,47 @@ func (c *Config) SaveTo(writer io.Writer, format Format) error {
 	return nil
 }
 
+// Get returns the value of the given key as text, with one line for each license
+func (c *Config) Get(key string) ([]string, error) {
+	switch key {
+	case "name":
+		return []string{c.Name}, nil
+	case "version":
+		return []string{c.Version}, nil
+	case "description":
+		return []string{c.Description}, nil
+	case "license":
+		return slices.Clone(c.License), nil
+	case "theme":
+		return []string{c.Theme}, nil
+	}
+	return nil, errors.Errorf("The key %s is not supported, use %s", key, strings.Join(ConfigKeys, ", "))
+}
+
+// Set changes the value of the given key from its text, where the license is a comma separated list
+func (c *Config) Set(key string, value string) error {
+	switch key {
+	case "name":
+		c.Name = value
+	case "version":
+		c.Version = value
+	case "description":
+		c.Description = value
+	case "license":
+		c.License = make([]string, 0)
+		for _, license := range strings.Split(value, ",") {
+			if license = strings.TrimSpace(license); license != "" {
+				c.AddLicense(license)
+			}
+		}
+	case "theme":
+		c.Theme = value
+	default:
+		return errors.Errorf("The key %s is not supported, use %s", key, strings.Join(ConfigKeys, ", "))
+	}
+	return nil
+}
+
 // AddLicense adds the given license to this configuration
 func (c *Config) AddLicense(license string) bool {
 	if !slices.Contains(c.License, license) {
@@ -197,9 +263,12 @@ func (c *Config) ContainsFile(file *File) bool {
 
 // Author represents an package author
 type Author struct {
-	Name  string `json:"name" yaml:"name" toml:"name"`
-	URL   string `json:"url" yaml:"URL" toml:"url"`
-	Email string `json:"email" yaml:"email" toml:"email"`
+	// Name is the author name.
+	Name string `json:"name" yaml:"name" toml:"name" jsonschema:"required"`
+	// URL is the author site.
+	URL string `json:"url,omitempty" yaml:"url,omitempty" toml:"url,omitempty" jsonschema:"format=iri-reference"`
+	// Email is the author email.
+	Email string `json:"email,omitempty" yaml:"email,omitempty" toml:"email,omitempty" jsonschema:"format=idn-email"`
 }
 
 // NewAuthor creates a new author with the given data
@@ -220,9 +289,14 @@ func NewAuthorFrom(author *Author) *Author {
 
 // File represents a list of root files to build
 type File struct {
-	Name   string `json:"name" toml:"name" yaml:"name"`
-	Output string `json:"output" toml:"output" yaml:"output"`
-	Path   string `json:"path" toml:"path" yaml:"path"`
+	// Name is a name to identify the output file.
+	Name string `json:"name" toml:"name" yaml:"name" jsonschema:"required,minLength=1,example=Book A"`
+	// Output is the name of the output file without extension (it will be suffixed).
+	Output string `json:"output" toml:"output" yaml:"output" jsonschema:"required,minLength=1,example=./dist/bookA"`
+	// Path is the path of the main markdown file.
+	Path string `json:"path" toml:"path" yaml:"path" jsonschema:"required,minLength=1,example=./src/bookA/main.md"`
+	// Settings are the settings of this file, which change the ones of the project.
+	Settings `yaml:",inline"`
 }
 
 // NewFile creates a new file with the given data
@@ -237,9 +311,10 @@ func NewFile(name, output, path string) *File {
 // NewFileFrom copies the given file
 func NewFileFrom(file *File) *File {
 	return &File{
-		Name:   file.Name,
-		Output: file.Output,
-		Path:   file.Path,
+		Name:     file.Name,
+		Output:   file.Output,
+		Path:     file.Path,
+		Settings: *NewSettingsFrom(&file.Settings),
 	}
 }
 
@@ -247,26 +322,39 @@ func NewFileFrom(file *File) *File {
 type Format string
 
 // ConfigFromFile creates a new configuration in the given format,
-// from the data in the given reader
+// from the data in the given reader, validating it against the configuration schema
 func ConfigFromFile(reader io.Reader, format Format) (*Config, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read the configuration")
+	}
+	result, err := decodeConfig(data, format)
+	if err != nil {
+		return nil, err
+	}
+	if err = ValidateConfig(data, format); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// decodeConfig decodes the given data, in the given format, into a new configuration
+func decodeConfig(data []byte, format Format) (*Config, error) {
 	result := newConfig()
 	var err error
 	switch format {
 	case FormatJson:
-		decoder := jsoniter.NewDecoder(reader)
-		err = decoder.Decode(result)
+		err = jsoniter.Unmarshal(data, result)
 		if err != nil {
 			return nil, errors.Wrap(err, "Unable to decode the file as json")
 		}
 	case FormatToml:
-		decoder := toml.NewDecoder(reader)
-		err = decoder.Decode(result)
+		err = toml.Unmarshal(data, result)
 		if err != nil {
 			return nil, errors.Wrap(err, "Unable to decode the file as toml")
 		}
 	case FormatYaml:
-		decoder := yaml.NewDecoder(reader)
-		err = decoder.Decode(result)
+		err = yaml.Unmarshal(data, result)
 		if err != nil {
 			return nil, errors.Wrap(err, "Unable to decode the file as yaml")
 		}
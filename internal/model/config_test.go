@@ -294,6 +294,7 @@ func TestConfig(t *testing.T) {
 				So(config.Name, ShouldEqual, "sample")
 				So(config.Version, ShouldEqual, "0.0.1")
 				So(config.ContainsLicense("GPL-3.0-or-later"), ShouldBeTrue)
+				So(config.Authors, ShouldResemble, []Author{{Name: "carddamom", URL: "https://github.com/carddamom", Email: "carddamom@tutanota.com"}})
 			})
 
 			Convey("It should be able to write to it", func() {
@@ -0,0 +1,243 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/goccy/go-yaml"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/spf13/afero"
+)
+
+// Layer is one of the places where the configuration values come from
+type Layer string
+
+// The layers, from the lowest to the highest priority
+const (
+	LayerDefault     Layer = "default"
+	LayerUser        Layer = "user"
+	LayerProject     Layer = "project"
+	LayerEnvironment Layer = "environment"
+	LayerFlag        Layer = "flag"
+)
+
+// LayeredKeys are the keys of the values of the layered configuration, in the order they are shown
+var LayeredKeys = []string{"name", "version", "description", "license", "theme", "authors", "files"}
+
+// Origin represents where a configuration value came from
+type Origin struct {
+	// Layer is the layer with the value.
+	Layer Layer
+	// Source is the file, environment variable or flag with the value, if any.
+	Source string
+}
+
+func (o Origin) String() string {
+	if o.Source == "" {
+		return string(o.Layer)
+	}
+	return string(o.Layer) + " " + o.Source
+}
+
+// Layered is the effective configuration of a project, with the origin of each of its values
+type Layered struct {
+	// Config is the configuration with the merged values.
+	Config *Config
+	// Origins are the origins of the values, by key.
+	Origins map[string]Origin
+}
+
+// Sources are the places, besides the project configuration file, where the configuration values
+// come from, which are, from the lowest to the highest priority, the user configuration file,
+// below the project configuration file, the environment variables and the command line flags
+type Sources struct {
+	// Fs is the filesystem with the user configuration file.
+	Fs afero.Fs
+	// UserFile is the path of the user configuration file, in toml, there is none when empty.
+	UserFile string
+	// Lookup returns the value of an environment variable, and if it is set.
+	Lookup func(string) (string, bool)
+	// Flags are the values given in the command line, by key.
+	Flags map[string]string
+}
+
+// NewSources creates the sources of the running process, with the riconto/config.toml file in the
+// user configuration directory, the process environment and the given flags
+func NewSources(fs afero.Fs, flags map[string]string) *Sources {
+	sources := &Sources{Fs: fs, Lookup: os.LookupEnv, Flags: flags}
+	if home, err := userConfigHome(); err == nil && home != "" {
+		sources.UserFile = filepath.Join(home, "riconto", "config.toml")
+	}
+	return sources
+}
+
+// EnvironmentVariable returns the name of the environment variable with the value of the given key
+func EnvironmentVariable(key string) string {
+	return "RICONTO_" + strings.ToUpper(key)
+}
+
+// Merge merges the given project configuration file, with the given name and format, over the
+// user configuration file, and the environment variables and flags over both of them
+func (s *Sources) Merge(data []byte, format Format, filename string) (*Layered, error) {
+	result := &Layered{Config: newConfig(), Origins: make(map[string]Origin)}
+	for _, key := range LayeredKeys {
+		result.Origins[key] = Origin{Layer: LayerDefault}
+	}
+
+	// 1. The user configuration file, which has no files
+	if s.UserFile != "" && s.Fs != nil {
+		user, err := afero.ReadFile(s.Fs, s.UserFile)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return nil, errors.Wrapf(err, "Unable to read the user configuration file %s", s.UserFile)
+		default:
+			if err = result.merge(user, FormatToml, Origin{Layer: LayerUser, Source: s.UserFile}); err != nil {
+				return nil, errors.Wrapf(err, "Invalid user configuration file %s", s.UserFile)
+			}
+		}
+	}
+
+	// 2. The project configuration file
+	if err := ValidateConfig(data, format); err != nil {
+		return nil, errors.Wrapf(err, "Invalid configuration file %s", filename)
+	}
+	if err := result.merge(data, format, Origin{Layer: LayerProject, Source: filename}); err != nil {
+		return nil, errors.Wrapf(err, "Invalid configuration file %s", filename)
+	}
+
+	// 3. The environment variables
+	if s.Lookup != nil {
+		for _, key := range ConfigKeys {
+			variable := EnvironmentVariable(key)
+			if value, ok := s.Lookup(variable); ok {
+				_ = result.Config.Set(key, value)
+				result.Origins[key] = Origin{Layer: LayerEnvironment, Source: variable}
+			}
+		}
+	}
+
+	// 4. The flags
+	keys := make([]string, 0, len(s.Flags))
+	for key := range s.Flags {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if err := result.Config.Set(key, s.Flags[key]); err != nil {
+			return nil, errors.Wrap(err, "Invalid configuration flag")
+		}
+		result.Origins[key] = Origin{Layer: LayerFlag, Source: key}
+	}
+	return result, nil
+}
+
+// merge sets the values of the keys in the given configuration file over the current ones, where
+// the user configuration file can only have the layered keys, and the project configuration file
+// also sets the values that are not layered
+func (l *Layered) merge(data []byte, format Format, origin Origin) error {
+	keys, err := fileKeys(data, format)
+	if err != nil {
+		return err
+	}
+	var config *Config
+	if origin.Layer == LayerUser {
+		for key := range keys {
+			if key != "$schema" && (key == "files" || !slices.Contains(LayeredKeys, key)) {
+				return errors.Errorf("The user configuration file can not have %s", key)
+			}
+		}
+		config = newConfig()
+		if err = toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(config); err != nil {
+			return errors.Wrap(err, "Unable to decode the file as toml")
+		}
+	} else if config, err = decodeConfig(data, format); err != nil {
+		return err
+	}
+	if origin.Layer == LayerProject {
+		// the project configuration file has all the values, except the layered ones not in it
+		for _, key := range LayeredKeys {
+			if keys[key] {
+				l.Origins[key] = origin
+			} else {
+				copyLayeredValue(config, l.Config, key)
+			}
+		}
+		l.Config = config
+		return nil
+	}
+	for _, key := range LayeredKeys {
+		if keys[key] {
+			copyLayeredValue(l.Config, config, key)
+			l.Origins[key] = origin
+		}
+	}
+	return nil
+}
+
+// copyLayeredValue copies the value of the given layered key between the given configurations
+func copyLayeredValue(to *Config, from *Config, key string) {
+	switch key {
+	case "name":
+		to.Name = from.Name
+	case "version":
+		to.Version = from.Version
+	case "description":
+		to.Description = from.Description
+	case "license":
+		to.License = slices.Clone(from.License)
+	case "theme":
+		to.Theme = from.Theme
+	case "authors":
+		to.Authors = NewConfigFrom(from).Authors
+	case "files":
+		to.Files = NewConfigFrom(from).Files
+	}
+}
+
+// fileKeys returns the keys at the top of the given configuration file
+func fileKeys(data []byte, format Format) (map[string]bool, error) {
+	values := make(map[string]any)
+	var err error
+	switch format {
+	case FormatJson:
+		err = jsoniter.Unmarshal(data, &values)
+	case FormatToml:
+		err = toml.Unmarshal(data, &values)
+	case FormatYaml:
+		err = yaml.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to decode the file as %s", format)
+	}
+	keys := make(map[string]bool, len(values))
+	for key := range values {
+		keys[key] = true
+	}
+	return keys, nil
+}
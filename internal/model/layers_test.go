@@ -0,0 +1,123 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+const userContent = `
+theme = "book"
+license = ["CC-BY-4.0"]
+
+[[authors]]
+name = "someone"
+email = "someone@example.com"
+`
+
+func TestMerge(t *testing.T) {
+	Convey("#Merge", t, func() {
+		fs := afero.NewMemMapFs()
+		environment := make(map[string]string)
+		sources := &Sources{
+			Fs:       fs,
+			UserFile: "/home/user/.config/riconto/config.toml",
+			Lookup: func(name string) (string, bool) {
+				value, ok := environment[name]
+				return value, ok
+			},
+			Flags: make(map[string]string),
+		}
+		project := "name: sample\ndescription: Some description\nauthors: []\n"
+
+		Convey("It should use the defaults without other sources", func() {
+			layered, err := sources.Merge([]byte("name: sample\n"), FormatYaml, "riconto.yaml")
+			So(err, ShouldBeNil)
+			So(layered.Config.Name, ShouldEqual, "sample")
+			So(layered.Config.Version, ShouldEqual, "0.0.1")
+			So(layered.Origins["name"], ShouldResemble, Origin{Layer: LayerProject, Source: "riconto.yaml"})
+			So(layered.Origins["version"].String(), ShouldEqual, "default")
+		})
+
+		Convey("Given a user configuration file", func() {
+			So(afero.WriteFile(fs, sources.UserFile, []byte(userContent), 0o644), ShouldBeNil)
+
+			Convey("It should merge the project file over it", func() {
+				layered, err := sources.Merge([]byte(project), FormatYaml, "riconto.yaml")
+				So(err, ShouldBeNil)
+				So(layered.Config.Theme, ShouldEqual, "book")
+				So(layered.Config.License, ShouldResemble, []string{"CC-BY-4.0"})
+				So(layered.Config.Authors, ShouldBeEmpty)
+				So(layered.Origins["theme"].String(), ShouldEqual, "user /home/user/.config/riconto/config.toml")
+				So(layered.Origins["authors"].String(), ShouldEqual, "project riconto.yaml")
+			})
+
+			Convey("It should use its authors when the project has none", func() {
+				layered, err := sources.Merge([]byte("name: sample\n"), FormatYaml, "riconto.yaml")
+				So(err, ShouldBeNil)
+				So(layered.Config.Authors, ShouldResemble, []Author{{Name: "someone", Email: "someone@example.com"}})
+			})
+
+			Convey("It should merge the environment variables and the flags over both", func() {
+				environment["RICONTO_THEME"] = "compact"
+				environment["RICONTO_LICENSE"] = "MIT, CC0"
+				environment["RICONTO_DESCRIPTION"] = "From the environment"
+				sources.Flags["description"] = "From the flags"
+				layered, err := sources.Merge([]byte(project), FormatYaml, "riconto.yaml")
+				So(err, ShouldBeNil)
+				So(layered.Config.Theme, ShouldEqual, "compact")
+				So(layered.Config.License, ShouldResemble, []string{"MIT", "CC0"})
+				So(layered.Config.Description, ShouldEqual, "From the flags")
+				So(layered.Origins["theme"].String(), ShouldEqual, "environment RICONTO_THEME")
+				So(layered.Origins["description"].String(), ShouldEqual, "flag description")
+			})
+
+			Convey("It should fail when it has files", func() {
+				So(afero.WriteFile(fs, sources.UserFile, []byte(userContent+"\n[[files]]\nname = \"Book\"\n"), 0o644), ShouldBeNil)
+				_, err := sources.Merge([]byte(project), FormatYaml, "riconto.yaml")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "The user configuration file can not have files")
+			})
+
+			Convey("It should fail when it has unknown keys", func() {
+				So(afero.WriteFile(fs, sources.UserFile, []byte("style = \"plain\"\n"), 0o644), ShouldBeNil)
+				_, err := sources.Merge([]byte(project), FormatYaml, "riconto.yaml")
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "Invalid user configuration file")
+			})
+		})
+
+		Convey("It should fail on flags with unknown keys", func() {
+			sources.Flags["files"] = "Book"
+			_, err := sources.Merge([]byte(project), FormatYaml, "riconto.yaml")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "The key files is not supported")
+		})
+
+		Convey("It should fail on an invalid project file", func() {
+			_, err := sources.Merge([]byte("description: none\n"), FormatYaml, "riconto.yaml")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "Invalid configuration file riconto.yaml")
+		})
+	})
+}
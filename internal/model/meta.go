@@ -0,0 +1,168 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/goccy/go-yaml"
+)
+
+// DocumentMeta represents the metadata of a document, read from the front matter of its main
+// markdown file and completed with the project configuration
+type DocumentMeta struct {
+	Title       string        `json:"title" yaml:"title" toml:"title"`
+	Description string        `json:"description" yaml:"description" toml:"description"`
+	Authors     []Author      `json:"authors" yaml:"authors" toml:"authors"`
+	Tags        []string      `json:"tags" yaml:"tags" toml:"tags"`
+	Version     string        `json:"version" yaml:"version" toml:"version"`
+	License     []string      `json:"license" yaml:"license" toml:"license"`
+	Metadata    DocumentDates `json:"metadata" yaml:"metadata" toml:"metadata"`
+	// Bibliography is the path of the BibTeX file with the entries cited by the document, relative
+	// to its main markdown file.
+	Bibliography string `json:"bibliography" yaml:"bibliography" toml:"bibliography"`
+	// Project is the name of the project of the document, from the configuration.
+	Project string `json:"-" yaml:"-" toml:"-"`
+	// Extra contains every value of the front matter, including the ones without a field.
+	Extra map[string]any `json:"-" yaml:"-" toml:"-"`
+}
+
+// DocumentDates represents the dates in the life of a document
+type DocumentDates struct {
+	Created   time.Time `json:"created" yaml:"created" toml:"created"`
+	Published time.Time `json:"published" yaml:"published" toml:"published"`
+	Modified  time.Time `json:"modified" yaml:"modified" toml:"modified"`
+}
+
+// NewDocumentMeta creates new empty document metadata
+func NewDocumentMeta() *DocumentMeta {
+	return &DocumentMeta{
+		Authors: make([]Author, 0),
+		Tags:    make([]string, 0),
+		License: make([]string, 0),
+		Extra:   make(map[string]any),
+	}
+}
+
+// DocumentMetaFromFrontMatter creates the document metadata from the given yaml front matter,
+// without the --- delimiters
+func DocumentMetaFromFrontMatter(data []byte) (*DocumentMeta, error) {
+	result := NewDocumentMeta()
+	if len(bytes.TrimSpace(data)) == 0 {
+		return result, nil
+	}
+	if err := yaml.Unmarshal(data, result); err != nil {
+		return nil, errors.Wrap(err, "Unable to decode the front matter as yaml")
+	}
+	if err := yaml.Unmarshal(data, &result.Extra); err != nil {
+		return nil, errors.Wrap(err, "Unable to decode the front matter as yaml")
+	}
+	if result.Extra == nil {
+		result.Extra = make(map[string]any)
+	}
+	return result, nil
+}
+
+// Merge completes the metadata with the values of the given project configuration and file,
+// which are only used when the front matter does not have them
+func (m *DocumentMeta) Merge(config *Config, file *File) {
+	m.Project = config.Name
+	if m.Title == "" && file != nil {
+		m.Title = file.Name
+	}
+	if m.Title == "" {
+		m.Title = config.Name
+	}
+	if m.Description == "" {
+		m.Description = config.Description
+	}
+	if m.Version == "" {
+		m.Version = config.Version
+	}
+	if len(m.Authors) == 0 {
+		m.Authors = slices.Clone(config.Authors)
+	}
+	if len(m.License) == 0 {
+		m.License = slices.Clone(config.License)
+	}
+}
+
+// Validate checks the metadata, returning all the problems found
+func (m *DocumentMeta) Validate() error {
+	var result error
+	if strings.TrimSpace(m.Title) == "" {
+		result = errors.Append(result, errors.New("The document has no title"))
+	}
+	for i, author := range m.Authors {
+		if strings.TrimSpace(author.Name) == "" {
+			result = errors.Append(result, errors.Errorf("The author number %d has no name", i+1))
+		}
+	}
+	for i, tag := range m.Tags {
+		if strings.TrimSpace(tag) == "" {
+			result = errors.Append(result, errors.Errorf("The tag number %d is empty", i+1))
+		}
+	}
+	dates := m.Metadata
+	if !dates.Created.IsZero() && !dates.Published.IsZero() && dates.Published.Before(dates.Created) {
+		result = errors.Append(result, errors.New("The document was published before it was created"))
+	}
+	if !dates.Created.IsZero() && !dates.Modified.IsZero() && dates.Modified.Before(dates.Created) {
+		result = errors.Append(result, errors.New("The document was modified before it was created"))
+	}
+	return result
+}
+
+// AuthorNames returns the names of the authors
+func (m *DocumentMeta) AuthorNames() []string {
+	names := make([]string, 0, len(m.Authors))
+	for _, author := range m.Authors {
+		names = append(names, author.Name)
+	}
+	return names
+}
+
+// Variables returns the metadata as template variables, where the values of the front matter
+// without a field are also available
+func (m *DocumentMeta) Variables() map[string]any {
+	variables := make(map[string]any, len(m.Extra)+10)
+	for key, value := range m.Extra {
+		variables[key] = value
+	}
+	authors := make([]map[string]any, 0, len(m.Authors))
+	for _, author := range m.Authors {
+		authors = append(authors, map[string]any{"name": author.Name, "url": author.URL, "email": author.Email})
+	}
+	variables["title"] = m.Title
+	variables["description"] = m.Description
+	variables["authors"] = authors
+	variables["tags"] = slices.Clone(m.Tags)
+	variables["version"] = m.Version
+	variables["license"] = slices.Clone(m.License)
+	variables["project"] = m.Project
+	variables["created"] = m.Metadata.Created
+	variables["published"] = m.Metadata.Published
+	variables["modified"] = m.Metadata.Modified
+	return variables
+}
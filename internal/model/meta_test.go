@@ -0,0 +1,112 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const frontMatter = `
+title: "Documentation for Riconto"
+description: "This is the main documentation for riconto"
+authors:
+  - name: "carddamom"
+    email: "carddamom at tutanota dot com"
+tags:
+  - riconto
+  - documentation
+metadata:
+  created: "2024-10-09T11:42:12.791404Z"
+  published: "2024-10-09T11:42:12.791404Z"
+  modified: "2024-10-10T08:00:00Z"
+series: "Manuals"
+`
+
+func TestDocumentMeta(t *testing.T) {
+	Convey("#DocumentMeta", t, func() {
+
+		Convey("It should read the front matter", func() {
+			meta, err := DocumentMetaFromFrontMatter([]byte(frontMatter))
+			So(err, ShouldBeNil)
+			So(meta.Title, ShouldEqual, "Documentation for Riconto")
+			So(meta.Authors, ShouldResemble, []Author{{Name: "carddamom", Email: "carddamom at tutanota dot com"}})
+			So(meta.Tags, ShouldResemble, []string{"riconto", "documentation"})
+			So(meta.Metadata.Created.Equal(time.Date(2024, 10, 9, 11, 42, 12, 791404000, time.UTC)), ShouldBeTrue)
+			So(meta.Metadata.Modified.Equal(time.Date(2024, 10, 10, 8, 0, 0, 0, time.UTC)), ShouldBeTrue)
+			So(meta.Extra["series"], ShouldEqual, "Manuals")
+			So(meta.Validate(), ShouldBeNil)
+		})
+
+		Convey("It should fail on invalid front matter", func() {
+			_, err := DocumentMetaFromFrontMatter([]byte("title: [unclosed"))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("It should complete the metadata with the configuration", func() {
+			config := NewConfig("sample", "1.0.0", "A sample project")
+			config.AddAuthor(NewAuthor("someone"))
+			config.AddLicense("GPL-3.0-or-later")
+			meta := NewDocumentMeta()
+			meta.Merge(config, NewFile("Book A", "./dist/bookA", "./src/bookA/main.md"))
+			So(meta.Title, ShouldEqual, "Book A")
+			So(meta.Description, ShouldEqual, "A sample project")
+			So(meta.Version, ShouldEqual, "1.0.0")
+			So(meta.Project, ShouldEqual, "sample")
+			So(meta.AuthorNames(), ShouldResemble, []string{"someone"})
+			So(meta.License, ShouldResemble, []string{"GPL-3.0-or-later"})
+
+			fromFile, err := DocumentMetaFromFrontMatter([]byte(frontMatter))
+			So(err, ShouldBeNil)
+			fromFile.Merge(config, nil)
+			So(fromFile.Title, ShouldEqual, "Documentation for Riconto")
+			So(fromFile.AuthorNames(), ShouldResemble, []string{"carddamom"})
+		})
+
+		Convey("It should report every invalid value", func() {
+			meta := NewDocumentMeta()
+			meta.Authors = []Author{{Email: "someone@example.com"}}
+			meta.Tags = []string{""}
+			meta.Metadata.Created = time.Date(2024, 10, 9, 0, 0, 0, 0, time.UTC)
+			meta.Metadata.Modified = time.Date(2024, 10, 8, 0, 0, 0, 0, time.UTC)
+			err := meta.Validate()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "The document has no title")
+			So(err.Error(), ShouldContainSubstring, "The author number 1 has no name")
+			So(err.Error(), ShouldContainSubstring, "The tag number 1 is empty")
+			So(err.Error(), ShouldContainSubstring, "The document was modified before it was created")
+		})
+
+		Convey("It should expose the metadata as template variables", func() {
+			meta, err := DocumentMetaFromFrontMatter([]byte(frontMatter))
+			So(err, ShouldBeNil)
+			meta.Merge(NewConfig("sample", "1.0.0", ""), nil)
+			variables := meta.Variables()
+			So(variables["title"], ShouldEqual, "Documentation for Riconto")
+			So(variables["project"], ShouldEqual, "sample")
+			So(variables["series"], ShouldEqual, "Manuals")
+			So(variables["authors"], ShouldResemble, []map[string]any{
+				{"name": "carddamom", "url": "", "email": "carddamom at tutanota dot com"},
+			})
+		})
+	})
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/spf13/afero"
+)
+
+// Project represents a riconto project, the directory of a configuration file
+type Project struct {
+	// Root is the directory of the project.
+	Root string
+	// Filename is the name of the configuration file, in the root.
+	Filename string
+	// Format is the format of the configuration file.
+	Format Format
+	// Config is the configuration of the project, nil when the configuration file is not valid.
+	Config *Config
+	// Fs is the filesystem of the project, rooted at its directory.
+	Fs afero.Fs
+}
+
+// NoProjectError is returned when there is no configuration file in a directory or its parents
+type NoProjectError struct {
+	Dir string
+}
+
+func (e *NoProjectError) Error() string {
+	return fmt.Sprintf("There is no configuration file in %s or any of its parent directories", e.Dir)
+}
+
+// ConfigFilesError is returned when a directory has configuration files in several formats
+type ConfigFilesError struct {
+	Dir   string
+	Files []string
+}
+
+func (e *ConfigFilesError) Error() string {
+	return fmt.Sprintf("There are several configuration files in %s (%s), keep only one of them", e.Dir, strings.Join(e.Files, ", "))
+}
+
+// ConfigFile returns the name and format of the configuration file in the given directory, or
+// an empty name when there is none, failing when there are several in different formats
+func ConfigFile(fs afero.Fs, dir string) (string, Format, error) {
+	files := make([]string, 0, 1)
+	var format Format
+	for _, candidate := range []Format{FormatJson, FormatYaml, FormatToml} {
+		filename := "riconto." + candidate.String()
+		info, err := fs.Stat(filepath.Join(dir, filename))
+		if err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, filename)
+		format = candidate
+	}
+	switch len(files) {
+	case 0:
+		return "", "", nil
+	case 1:
+		return files[0], format, nil
+	default:
+		return "", "", &ConfigFilesError{Dir: dir, Files: files}
+	}
+}
+
+// FindProject returns the directory of the project containing the given directory, which is the
+// first one with a configuration file, searching from it up to the root of the filesystem
+func FindProject(fs afero.Fs, dir string) (string, error) {
+	dir = filepath.Clean(dir)
+	for current := dir; ; {
+		filename, _, err := ConfigFile(fs, current)
+		if err != nil {
+			return "", err
+		}
+		if filename != "" {
+			return current, nil
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", &NoProjectError{Dir: dir}
+		}
+		current = parent
+	}
+}
+
+// LoadProject finds the project containing the given directory, and loads its configuration,
+// returning the project without it, along with the error, when the configuration file is not valid
+func LoadProject(fs afero.Fs, dir string) (*Project, error) {
+	root, err := FindProject(fs, dir)
+	if err != nil {
+		return nil, err
+	}
+	filename, format, err := ConfigFile(fs, root)
+	if err != nil {
+		return nil, err
+	}
+	project := &Project{
+		Root:     root,
+		Filename: filename,
+		Format:   format,
+		Fs:       afero.NewBasePathFs(fs, root),
+	}
+	reader, err := fs.Open(filepath.Join(root, filename))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to open the configuration file %s", filepath.Join(root, filename))
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	project.Config, err = ConfigFromFile(reader, format)
+	if err != nil {
+		return project, errors.Wrapf(err, "Unable to load the configuration file %s", filepath.Join(root, filename))
+	}
+	return project, nil
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"path/filepath"
+	"testing"
+
+	"emperror.dev/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+func TestLoadProject(t *testing.T) {
+	Convey("#LoadProject", t, func() {
+		fs := afero.NewMemMapFs()
+		root := filepath.FromSlash("/home/user/books")
+		So(afero.WriteFile(fs, filepath.Join(root, "riconto.toml"), []byte(tomlContent), 0o644), ShouldBeNil)
+		So(fs.MkdirAll(filepath.Join(root, "src", "bookA"), 0o755), ShouldBeNil)
+
+		Convey("It should find the project in a parent directory", func() {
+			project, err := LoadProject(fs, filepath.Join(root, "src", "bookA"))
+			So(err, ShouldBeNil)
+			So(project.Root, ShouldEqual, root)
+			So(project.Filename, ShouldEqual, "riconto.toml")
+			So(project.Format, ShouldEqual, FormatToml)
+			So(project.Config.Name, ShouldEqual, "sample")
+			_, err = project.Fs.Stat("riconto.toml")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("It should find the project in the directory itself", func() {
+			project, err := LoadProject(fs, root)
+			So(err, ShouldBeNil)
+			So(project.Root, ShouldEqual, root)
+		})
+
+		Convey("It should prefer the nearest project", func() {
+			inner := filepath.Join(root, "src", "bookA")
+			So(afero.WriteFile(fs, filepath.Join(inner, "riconto.yaml"), []byte(yamlContent), 0o644), ShouldBeNil)
+			project, err := LoadProject(fs, inner)
+			So(err, ShouldBeNil)
+			So(project.Root, ShouldEqual, inner)
+			So(project.Format, ShouldEqual, FormatYaml)
+		})
+
+		Convey("It should fail when there are several configuration files", func() {
+			So(afero.WriteFile(fs, filepath.Join(root, "riconto.json"), []byte(jsonContent), 0o644), ShouldBeNil)
+			_, err := LoadProject(fs, filepath.Join(root, "src"))
+			var several *ConfigFilesError
+			So(errors.As(err, &several), ShouldBeTrue)
+			So(several.Files, ShouldResemble, []string{"riconto.json", "riconto.toml"})
+			So(err.Error(), ShouldEqual, "There are several configuration files in "+root+" (riconto.json, riconto.toml), keep only one of them")
+		})
+
+		Convey("It should fail when there is no project", func() {
+			_, err := LoadProject(fs, filepath.FromSlash("/home/other"))
+			var noProject *NoProjectError
+			So(errors.As(err, &noProject), ShouldBeTrue)
+			So(noProject.Dir, ShouldEqual, filepath.FromSlash("/home/other"))
+		})
+
+		Convey("It should fail with an invalid configuration file", func() {
+			So(afero.WriteFile(fs, filepath.Join(root, "riconto.toml"), []byte(tomlContentInvalid), 0o644), ShouldBeNil)
+			project, err := LoadProject(fs, root)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "Unable to load the configuration file "+filepath.Join(root, "riconto.toml"))
+			So(project, ShouldNotBeNil)
+			So(project.Root, ShouldEqual, root)
+			So(project.Config, ShouldBeNil)
+		})
+	})
+}
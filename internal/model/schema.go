@@ -0,0 +1,417 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/api"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// SchemaProblem is a value of a configuration file that does not follow the configuration schema
+type SchemaProblem struct {
+	// Pointer is the json pointer of the value, empty for the whole file.
+	Pointer string
+	// Line is the line of the value in the file, starting at one, or zero when unknown.
+	Line int
+	// Column is the column of the value in the line, starting at one, or zero when unknown.
+	Column int
+	// Message describes what is wrong with the value.
+	Message string
+}
+
+func (p SchemaProblem) String() string {
+	pointer := p.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", pointer, p.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", p.Line, p.Column, pointer, p.Message)
+}
+
+// SchemaError is returned when a configuration file does not follow the configuration schema
+type SchemaError struct {
+	Problems []SchemaProblem
+}
+
+func (e *SchemaError) Error() string {
+	problems := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		problems = append(problems, problem.String())
+	}
+	return "The configuration file does not follow its schema: " + strings.Join(problems, "; ")
+}
+
+// configSchema is the compiled configuration schema, with the formats asserted
+var configSchema = sync.OnceValues(func() (*jsonschema.Schema, error) {
+	document, err := jsonschema.UnmarshalJSON(bytes.NewReader(api.ConfigSchema))
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read the configuration schema")
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat()
+	compiler.RegisterFormat(&jsonschema.Format{Name: "idn-email", Validate: validateIdnEmail})
+	if err = compiler.AddResource("config.schema.json", document); err != nil {
+		return nil, errors.Wrap(err, "Unable to read the configuration schema")
+	}
+	schema, err := compiler.Compile("config.schema.json")
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to compile the configuration schema")
+	}
+	return schema, nil
+})
+
+// validateIdnEmail validates the idn-email format, which the schema validator does not support
+func validateIdnEmail(value any) error {
+	text, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	address, err := mail.ParseAddress(text)
+	if err != nil {
+		return err
+	}
+	if address.Name != "" || address.Address != text {
+		return errors.New("It must only have the address")
+	}
+	return nil
+}
+
+// ValidateConfig validates the configuration file data, in the given format, against the
+// configuration schema, returning a SchemaError with all the problems found
+func ValidateConfig(data []byte, format Format) error {
+	instance, err := schemaInstance(data, format)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to decode the file as %s", format)
+	}
+	schema, err := configSchema()
+	if err != nil {
+		return err
+	}
+	err = schema.Validate(instance)
+	var validation *jsonschema.ValidationError
+	if err == nil || !errors.As(err, &validation) {
+		return err
+	}
+
+	var positions map[string]position
+	switch format {
+	case FormatJson:
+		positions = jsonPositions(data)
+	case FormatYaml:
+		positions = yamlPositions(data)
+	case FormatToml:
+		positions = tomlPositions(data)
+	}
+	printer := message.NewPrinter(language.English)
+	problems := make([]SchemaProblem, 0)
+	for _, leaf := range validationLeaves(validation) {
+		pointer := ""
+		for _, part := range leaf.InstanceLocation {
+			pointer += "/" + escapePointer(part)
+		}
+		// Properties that are not allowed are reported at their own keys, not at their object
+		if additional, ok := leaf.ErrorKind.(*kind.AdditionalProperties); ok {
+			for _, property := range additional.Properties {
+				problem := SchemaProblem{
+					Pointer: pointer + "/" + escapePointer(property),
+					Message: (&kind.AdditionalProperties{Properties: []string{property}}).LocalizedString(printer),
+				}
+				problems = append(problems, problem.locate(positions))
+			}
+			continue
+		}
+		problem := SchemaProblem{Pointer: pointer, Message: leaf.ErrorKind.LocalizedString(printer)}
+		problems = append(problems, problem.locate(positions))
+	}
+	slices.SortStableFunc(problems, func(a, b SchemaProblem) int {
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column - b.Column
+		}
+		return strings.Compare(a.Pointer, b.Pointer)
+	})
+	return &SchemaError{Problems: problems}
+}
+
+// locate sets the line and column of the problem from the given positions, using the ones of
+// its parents when the value is missing
+func (p SchemaProblem) locate(positions map[string]position) SchemaProblem {
+	for current := p.Pointer; ; current = current[:strings.LastIndex(current, "/")] {
+		if found, ok := positions[current]; ok {
+			p.Line, p.Column = found.line, found.column
+			return p
+		}
+		if current == "" {
+			return p
+		}
+	}
+}
+
+// schemaInstance decodes the given data into the generic values used by the schema validator
+func schemaInstance(data []byte, format Format) (any, error) {
+	var value any
+	switch format {
+	case FormatJson:
+		return jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	case FormatYaml:
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+	case FormatToml:
+		if err := toml.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("The format %q is not supported", format)
+	}
+	// The decoded values of the other formats go through json, so they have the same types
+	converted, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return jsonschema.UnmarshalJSON(bytes.NewReader(converted))
+}
+
+// validationLeaves returns the errors, in the given error tree, that have no causes
+func validationLeaves(err *jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	result := make([]*jsonschema.ValidationError, 0, len(err.Causes))
+	for _, cause := range err.Causes {
+		result = append(result, validationLeaves(cause)...)
+	}
+	return result
+}
+
+// escapePointer escapes a json pointer token
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// position is a line and column in a file, both starting at one
+type position struct {
+	line   int
+	column int
+}
+
+// offsetPosition returns the position of the given byte offset in the data
+func offsetPosition(data []byte, offset int) position {
+	before := data[:min(offset, len(data))]
+	start := bytes.LastIndexByte(before, '\n') + 1
+	return position{
+		line:   bytes.Count(before, []byte{'\n'}) + 1,
+		column: utf8.RuneCount(before[start:]) + 1,
+	}
+}
+
+// jsonPositions returns the positions of the values of a json file by their pointer, the
+// position of an object member being the one of its key
+func jsonPositions(data []byte) map[string]position {
+	positions := make(map[string]position)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	start := func() position {
+		offset := int(decoder.InputOffset())
+		for offset < len(data) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+			offset++
+		}
+		return offsetPosition(data, offset)
+	}
+	var walk func(pointer string) error
+	walk = func(pointer string) error {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		delim, ok := token.(json.Delim)
+		if !ok {
+			return nil
+		}
+		for index := 0; decoder.More(); index++ {
+			child := pointer + "/" + strconv.Itoa(index)
+			found := start()
+			if delim == '{' {
+				key, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				child = pointer + "/" + escapePointer(fmt.Sprint(key))
+			}
+			positions[child] = found
+			if err = walk(child); err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+		return err
+	}
+	positions[""] = start()
+	_ = walk("")
+	return positions
+}
+
+// yamlPositions returns the positions of the values of a yaml file by their pointer, the
+// position of a mapping value being the one of its key
+func yamlPositions(data []byte) map[string]position {
+	positions := map[string]position{"": {line: 1, column: 1}}
+	file, err := parser.ParseBytes(data, 0)
+	if err != nil || len(file.Docs) == 0 {
+		return positions
+	}
+	var walk func(pointer string, node ast.Node)
+	walk = func(pointer string, node ast.Node) {
+		switch node := node.(type) {
+		case *ast.TagNode:
+			walk(pointer, node.Value)
+		case *ast.AnchorNode:
+			walk(pointer, node.Value)
+		case *ast.MappingNode:
+			for _, value := range node.Values {
+				walk(pointer, value)
+			}
+		case *ast.MappingValueNode:
+			key := node.Key.GetToken()
+			if key == nil {
+				return
+			}
+			child := pointer + "/" + escapePointer(key.Value)
+			positions[child], _ = yamlPosition(node.Key)
+			walk(child, node.Value)
+		case *ast.SequenceNode:
+			for index, value := range node.Values {
+				child := pointer + "/" + strconv.Itoa(index)
+				if found, ok := yamlPosition(value); ok {
+					positions[child] = found
+				}
+				walk(child, value)
+			}
+		}
+	}
+	walk("", file.Docs[0].Body)
+	return positions
+}
+
+// yamlPosition returns the position where the given yaml node starts, which for block
+// mappings is the one of their first key
+func yamlPosition(node ast.Node) (position, bool) {
+	switch mapping := node.(type) {
+	case *ast.MappingNode:
+		if !mapping.IsFlowStyle && len(mapping.Values) > 0 {
+			return yamlPosition(mapping.Values[0].Key)
+		}
+	case *ast.MappingValueNode:
+		return yamlPosition(mapping.Key)
+	}
+	if node == nil || node.GetToken() == nil {
+		return position{}, false
+	}
+	found := node.GetToken().Position
+	return position{line: found.Line, column: found.Column}, true
+}
+
+// tomlPositions returns the positions of the values of a toml file by their pointer, the
+// position of a table or key value being the one of its key
+func tomlPositions(data []byte) map[string]position {
+	positions := map[string]position{"": {line: 1, column: 1}}
+	p := unstable.Parser{}
+	p.Reset(data)
+	nodePosition := func(node *unstable.Node, fallback position) position {
+		if node.Raw.Length == 0 {
+			return fallback
+		}
+		shape := p.Shape(node.Raw)
+		return position{line: shape.Start.Line, column: shape.Start.Column}
+	}
+	// arrays counts the tables of each array of tables, so the pointers use their indexes
+	arrays := make(map[string]int)
+	keyPointer := func(pointer string, keys unstable.Iterator, array bool) string {
+		for keys.Next() {
+			key := keys.Node()
+			pointer += "/" + escapePointer(string(key.Data))
+			if array && keys.IsLast() {
+				arrays[pointer]++
+			}
+			if _, ok := positions[pointer]; !ok || (array && keys.IsLast()) {
+				positions[pointer] = nodePosition(key, positions[""])
+			}
+			if count, ok := arrays[pointer]; ok {
+				pointer += "/" + strconv.Itoa(count-1)
+				positions[pointer] = nodePosition(key, positions[""])
+			}
+		}
+		return pointer
+	}
+	var walk func(pointer string, node *unstable.Node)
+	walk = func(pointer string, node *unstable.Node) {
+		switch node.Kind {
+		case unstable.KeyValue:
+			child := keyPointer(pointer, node.Key(), false)
+			walk(child, node.Value())
+		case unstable.InlineTable:
+			children := node.Children()
+			for children.Next() {
+				walk(pointer, children.Node())
+			}
+		case unstable.Array:
+			children := node.Children()
+			for index := 0; children.Next(); index++ {
+				child := pointer + "/" + strconv.Itoa(index)
+				positions[child] = nodePosition(children.Node(), positions[pointer])
+				walk(child, children.Node())
+			}
+		}
+	}
+	table := ""
+	for p.NextExpression() {
+		expression := p.Expression()
+		switch expression.Kind {
+		case unstable.Table:
+			table = keyPointer("", expression.Key(), false)
+		case unstable.ArrayTable:
+			table = keyPointer("", expression.Key(), true)
+		case unstable.KeyValue:
+			walk(table, expression)
+		}
+	}
+	return positions
+}
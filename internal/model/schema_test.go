@@ -0,0 +1,152 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/api"
+	"github.com/chordflower/riconto/internal/schema"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const invalidJsonConfig = `{
+  "name": "sample",
+  "files": [
+    {
+      "name": "",
+      "path": "./src/bookA/main.md"
+    }
+  ],
+  "authors": [
+    {"name": "carddamom", "email": "not an email"}
+  ],
+  "extra": true
+}
+`
+
+const invalidYamlConfig = `name: sample
+files:
+  - name: ""
+    path: ./src/bookA/main.md
+authors:
+  - name: carddamom
+    email: not an email
+extra: true
+`
+
+const invalidTomlConfig = `name = "sample"
+extra = true
+
+[[files]]
+name = "Book A"
+path = "./src/bookA/main.md"
+output = "./dist/bookA"
+
+[[files]]
+name = ""
+path = "./src/bookB/main.md"
+
+[[authors]]
+name = "carddamom"
+email = "not an email"
+`
+
+// schemaProblems validates the given data and returns its problems
+func schemaProblems(data string, format Format) []string {
+	err := ValidateConfig([]byte(data), format)
+	So(err, ShouldNotBeNil)
+	var schemaErr *SchemaError
+	So(errors.As(err, &schemaErr), ShouldBeTrue)
+	result := make([]string, 0, len(schemaErr.Problems))
+	for _, problem := range schemaErr.Problems {
+		result = append(result, problem.String())
+	}
+	return result
+}
+
+func TestValidateConfig(t *testing.T) {
+	Convey("#ValidateConfig", t, func() {
+
+		Convey("It should use the schema generated from the configuration types", func() {
+			generated, err := schema.Generate(schema.Options{
+				Dir:         ".",
+				Type:        "Config",
+				Title:       "config",
+				Description: "This is the schema of a configuration file for riconto",
+			})
+			So(err, ShouldBeNil)
+			// run go generate in the model package when this fails
+			So(string(api.ConfigSchema), ShouldEqual, string(generated))
+		})
+
+		Convey("It should accept the sample configurations", func() {
+			for _, format := range []Format{FormatJson, FormatYaml, FormatToml} {
+				data, err := os.ReadFile(filepath.Join("..", "..", "api", "config."+format.String()))
+				So(err, ShouldBeNil)
+				So(ValidateConfig(data, format), ShouldBeNil)
+			}
+		})
+
+		Convey("It should report the pointer and position of the problems in json", func() {
+			problems := schemaProblems(invalidJsonConfig, FormatJson)
+			So(problems, ShouldHaveLength, 4)
+			So(problems[0], ShouldStartWith, "4:5: /files/0: missing property 'output'")
+			So(problems[1], ShouldStartWith, "5:7: /files/0/name: ")
+			So(problems[2], ShouldStartWith, "10:27: /authors/0/email: ")
+			So(problems[3], ShouldStartWith, "12:3: /extra: additional properties 'extra' not allowed")
+		})
+
+		Convey("It should report the pointer and position of the problems in yaml", func() {
+			problems := schemaProblems(invalidYamlConfig, FormatYaml)
+			So(problems, ShouldHaveLength, 4)
+			So(problems[0], ShouldStartWith, "3:5: /files/0: missing property 'output'")
+			So(problems[1], ShouldStartWith, "3:5: /files/0/name: ")
+			So(problems[2], ShouldStartWith, "7:5: /authors/0/email: ")
+			So(problems[3], ShouldStartWith, "8:1: /extra: additional properties 'extra' not allowed")
+		})
+
+		Convey("It should report the pointer and position of the problems in toml", func() {
+			problems := schemaProblems(invalidTomlConfig, FormatToml)
+			So(problems, ShouldHaveLength, 4)
+			So(problems[0], ShouldStartWith, "2:1: /extra: additional properties 'extra' not allowed")
+			So(problems[1], ShouldStartWith, "9:3: /files/1: missing property 'output'")
+			So(problems[2], ShouldStartWith, "10:1: /files/1/name: ")
+			So(problems[3], ShouldStartWith, "15:1: /authors/0/email: ")
+		})
+
+		Convey("It should fail on files that can not be decoded", func() {
+			err := ValidateConfig([]byte("name = "), FormatToml)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "Unable to decode the file as toml")
+		})
+
+		Convey("It should be used when loading a configuration", func() {
+			config, err := ConfigFromFile(strings.NewReader(invalidYamlConfig), FormatYaml)
+			So(config, ShouldBeNil)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "The configuration file does not follow its schema: 3:5: /files/0: ")
+		})
+	})
+}
@@ -0,0 +1,244 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// Settings represents how the files of a project are rendered
+type Settings struct {
+	// Theme is the name of the theme used to render the files.
+	Theme string `json:"theme,omitempty" yaml:"theme,omitempty" toml:"theme,omitempty" jsonschema:"enum=default,enum=book,enum=compact,default=default"`
+	// Watermark is a text written across every page, behind their content.
+	Watermark string `json:"watermark,omitempty" yaml:"watermark,omitempty" toml:"watermark,omitempty" jsonschema:"example=DRAFT"`
+	// LinkColor is the color of the links, in the #rrggbb notation, instead of the one of the theme.
+	LinkColor string `json:"link_color,omitempty" yaml:"link_color,omitempty" toml:"link_color,omitempty" jsonschema:"pattern=^#[0-9a-fA-F]{6}$,example=#000000"`
+	// Bleed is the size of the area around the pages that is cut when printing, like 3mm.
+	Bleed string `json:"bleed,omitempty" yaml:"bleed,omitempty" toml:"bleed,omitempty" jsonschema:"pattern=^[0-9]+([.][0-9]+)?(mm|cm|in|pt)$,example=3mm"`
+	// CropMarks are if the corners where the pages are cut when printing are marked.
+	CropMarks *bool `json:"crop_marks,omitempty" yaml:"crop_marks,omitempty" toml:"crop_marks,omitempty"`
+	// PageSize is the size of the pages.
+	PageSize string `json:"page_size,omitempty" yaml:"page_size,omitempty" toml:"page_size,omitempty" jsonschema:"enum=a4,enum=a5,enum=letter,default=a4"`
+	// Margins are the space between the edges of the pages and their content.
+	Margins *Margins `json:"margins,omitempty" yaml:"margins,omitempty" toml:"margins,omitempty"`
+	// Fonts are the TrueType font files used instead of the builtin fonts.
+	Fonts *Fonts `json:"fonts,omitempty" yaml:"fonts,omitempty" toml:"fonts,omitempty"`
+	// Language is the language of the text, like en-US.
+	Language string `json:"language,omitempty" yaml:"language,omitempty" toml:"language,omitempty" jsonschema:"pattern=^[a-zA-Z][a-zA-Z]+(-[a-zA-Z0-9]+)*$,example=en-US"`
+	// Formats are the formats of the output files, pdf by default.
+	Formats []string `json:"formats,omitempty" yaml:"formats,omitempty" toml:"formats,omitempty" jsonschema:"enum=pdf,enum=html,enum=epub,enum=docx,enum=odt,enum=latex"`
+	// Metadata are extra values written in the properties of the output files, like a publisher.
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty" toml:"metadata,omitempty"`
+	// Split is how the outputs with several pages, like html and epub, are divided: at the files included by the main file, at the headings of the highest level, or not at all.
+	Split string `json:"split,omitempty" yaml:"split,omitempty" toml:"split,omitempty" jsonschema:"enum=includes,enum=headings,enum=none,default=includes"`
+}
+
+// Margins represents the space between each edge of the pages and their content, like 20mm
+type Margins struct {
+	// Top is the space above the content.
+	Top string `json:"top,omitempty" yaml:"top,omitempty" toml:"top,omitempty" jsonschema:"pattern=^[0-9]+([.][0-9]+)?(mm|cm|in|pt)$,example=20mm"`
+	// Right is the space at the right of the content.
+	Right string `json:"right,omitempty" yaml:"right,omitempty" toml:"right,omitempty" jsonschema:"pattern=^[0-9]+([.][0-9]+)?(mm|cm|in|pt)$,example=20mm"`
+	// Bottom is the space below the content.
+	Bottom string `json:"bottom,omitempty" yaml:"bottom,omitempty" toml:"bottom,omitempty" jsonschema:"pattern=^[0-9]+([.][0-9]+)?(mm|cm|in|pt)$,example=20mm"`
+	// Left is the space at the left of the content.
+	Left string `json:"left,omitempty" yaml:"left,omitempty" toml:"left,omitempty" jsonschema:"pattern=^[0-9]+([.][0-9]+)?(mm|cm|in|pt)$,example=20mm"`
+}
+
+// Fonts represents the paths of the TrueType font files of each style of text
+type Fonts struct {
+	// Regular is the font of the normal text.
+	Regular string `json:"regular,omitempty" yaml:"regular,omitempty" toml:"regular,omitempty" jsonschema:"minLength=1,example=./fonts/Serif-Regular.ttf"`
+	// Bold is the font of the strong text and the headings.
+	Bold string `json:"bold,omitempty" yaml:"bold,omitempty" toml:"bold,omitempty" jsonschema:"minLength=1,example=./fonts/Serif-Bold.ttf"`
+	// Italic is the font of the emphasized text.
+	Italic string `json:"italic,omitempty" yaml:"italic,omitempty" toml:"italic,omitempty" jsonschema:"minLength=1,example=./fonts/Serif-Italic.ttf"`
+	// BoldItalic is the font of the strong and emphasized text.
+	BoldItalic string `json:"bold_italic,omitempty" yaml:"bold_italic,omitempty" toml:"bold_italic,omitempty" jsonschema:"minLength=1,example=./fonts/Serif-BoldItalic.ttf"`
+	// Mono is the font of the code.
+	Mono string `json:"mono,omitempty" yaml:"mono,omitempty" toml:"mono,omitempty" jsonschema:"minLength=1,example=./fonts/Mono-Regular.ttf"`
+}
+
+// NewSettingsFrom copies the given settings
+func NewSettingsFrom(settings *Settings) *Settings {
+	result := *settings
+	if settings.CropMarks != nil {
+		cropMarks := *settings.CropMarks
+		result.CropMarks = &cropMarks
+	}
+	if settings.Margins != nil {
+		margins := *settings.Margins
+		result.Margins = &margins
+	}
+	if settings.Fonts != nil {
+		fonts := *settings.Fonts
+		result.Fonts = &fonts
+	}
+	result.Formats = slices.Clone(settings.Formats)
+	result.Metadata = maps.Clone(settings.Metadata)
+	return &result
+}
+
+// Merge changes the settings to the ones given in the other settings
+func (s *Settings) Merge(other *Settings) {
+	if other.Theme != "" {
+		s.Theme = other.Theme
+	}
+	if other.Watermark != "" {
+		s.Watermark = other.Watermark
+	}
+	if other.LinkColor != "" {
+		s.LinkColor = other.LinkColor
+	}
+	if other.Bleed != "" {
+		s.Bleed = other.Bleed
+	}
+	if other.CropMarks != nil {
+		cropMarks := *other.CropMarks
+		s.CropMarks = &cropMarks
+	}
+	if other.PageSize != "" {
+		s.PageSize = other.PageSize
+	}
+	if other.Margins != nil {
+		if s.Margins == nil {
+			s.Margins = &Margins{}
+		}
+		s.Margins.Merge(other.Margins)
+	}
+	if other.Fonts != nil {
+		if s.Fonts == nil {
+			s.Fonts = &Fonts{}
+		}
+		s.Fonts.Merge(other.Fonts)
+	}
+	if other.Language != "" {
+		s.Language = other.Language
+	}
+	if len(other.Formats) > 0 {
+		s.Formats = slices.Clone(other.Formats)
+	}
+	if other.Split != "" {
+		s.Split = other.Split
+	}
+	if len(other.Metadata) > 0 {
+		if s.Metadata == nil {
+			s.Metadata = make(map[string]string, len(other.Metadata))
+		}
+		maps.Copy(s.Metadata, other.Metadata)
+	}
+}
+
+// Merge changes the margins to the ones given in the other margins
+func (m *Margins) Merge(other *Margins) {
+	if other.Top != "" {
+		m.Top = other.Top
+	}
+	if other.Right != "" {
+		m.Right = other.Right
+	}
+	if other.Bottom != "" {
+		m.Bottom = other.Bottom
+	}
+	if other.Left != "" {
+		m.Left = other.Left
+	}
+}
+
+// Merge changes the fonts to the ones given in the other fonts
+func (f *Fonts) Merge(other *Fonts) {
+	if other.Regular != "" {
+		f.Regular = other.Regular
+	}
+	if other.Bold != "" {
+		f.Bold = other.Bold
+	}
+	if other.Italic != "" {
+		f.Italic = other.Italic
+	}
+	if other.BoldItalic != "" {
+		f.BoldItalic = other.BoldItalic
+	}
+	if other.Mono != "" {
+		f.Mono = other.Mono
+	}
+}
+
+// Profile represents a named set of settings, which change the ones of the project when selected
+type Profile struct {
+	// Extends is the name of the profile whose settings are used when they are not given in this one.
+	Extends string `json:"extends,omitempty" yaml:"extends,omitempty" toml:"extends,omitempty" jsonschema:"minLength=1"`
+	// Settings are the settings that change the ones of the project.
+	Settings `yaml:",inline"`
+}
+
+// NewProfileFrom copies the given profile
+func NewProfileFrom(profile *Profile) *Profile {
+	return &Profile{
+		Extends:  profile.Extends,
+		Settings: *NewSettingsFrom(&profile.Settings),
+	}
+}
+
+// ProfileNames returns the names of the profiles of this configuration, sorted
+func (c *Config) ProfileNames() []string {
+	return slices.Sorted(maps.Keys(c.Profiles))
+}
+
+// ProfileSettings returns the settings of this configuration changed by the ones of the profile with
+// the given name, which are changed by the ones of the profile it extends, and so on, or just
+// the settings of this configuration when the name is empty
+func (c *Config) ProfileSettings(name string) (*Settings, error) {
+	return c.FileSettings(nil, name)
+}
+
+// FileSettings returns the settings of this configuration changed by the ones of the given file,
+// when there is one, and then by the ones of the profile with the given name, like in ProfileSettings
+func (c *Config) FileSettings(file *File, name string) (*Settings, error) {
+	chain := make([]string, 0)
+	for current := name; current != ""; {
+		if slices.Contains(chain, current) {
+			return nil, errors.Errorf("The profile %s extends itself: %s", current, strings.Join(append(chain, current), " -> "))
+		}
+		profile, ok := c.Profiles[current]
+		if !ok {
+			if len(chain) > 0 {
+				return nil, errors.Errorf("The profile %s extends %s, which does not exist", chain[len(chain)-1], current)
+			}
+			return nil, errors.Errorf("There is no profile named %s in the configuration, use %s", current, strings.Join(c.ProfileNames(), ", "))
+		}
+		chain = append(chain, current)
+		current = profile.Extends
+	}
+	result := NewSettingsFrom(&c.Settings)
+	if file != nil {
+		result.Merge(&file.Settings)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		profile := c.Profiles[chain[i]]
+		result.Merge(&profile.Settings)
+	}
+	return result, nil
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const profilesContent = `
+name = "sample"
+theme = "book"
+link_color = "#0000ff"
+
+[profiles.screen]
+watermark = ""
+
+[profiles.print]
+link_color = "#000000"
+bleed = "3mm"
+crop_marks = true
+
+[profiles.draft]
+extends = "print"
+watermark = "DRAFT"
+theme = "compact"
+
+[profiles.loop]
+extends = "other"
+
+[profiles.other]
+extends = "loop"
+
+[profiles.broken]
+extends = "missing"
+
+[[files]]
+name = "Novel"
+output = "./dist/novel"
+path = "./src/novel.md"
+page_size = "a5"
+theme = "default"
+link_color = "#ff0000"
+margins = { top = "15mm", bottom = "20mm" }
+metadata = { publisher = "Someone" }
+`
+
+func TestProfileSettings(t *testing.T) {
+	Convey("#ProfileSettings", t, func() {
+		config, err := ConfigFromFile(strings.NewReader(profilesContent), FormatToml)
+		So(err, ShouldBeNil)
+
+		Convey("It should return the project settings without a profile", func() {
+			settings, err := config.ProfileSettings("")
+			So(err, ShouldBeNil)
+			So(settings.Theme, ShouldEqual, "book")
+			So(settings.LinkColor, ShouldEqual, "#0000ff")
+			So(settings.CropMarks, ShouldBeNil)
+		})
+
+		Convey("It should merge the profile over the project settings", func() {
+			settings, err := config.ProfileSettings("print")
+			So(err, ShouldBeNil)
+			So(settings.Theme, ShouldEqual, "book")
+			So(settings.LinkColor, ShouldEqual, "#000000")
+			So(settings.Bleed, ShouldEqual, "3mm")
+			So(*settings.CropMarks, ShouldBeTrue)
+			So(config.Settings.CropMarks, ShouldBeNil)
+		})
+
+		Convey("It should merge the extended profiles first", func() {
+			settings, err := config.ProfileSettings("draft")
+			So(err, ShouldBeNil)
+			So(settings.Theme, ShouldEqual, "compact")
+			So(settings.Watermark, ShouldEqual, "DRAFT")
+			So(settings.LinkColor, ShouldEqual, "#000000")
+			So(settings.Bleed, ShouldEqual, "3mm")
+		})
+
+		Convey("It should merge the file settings between the project and the profile", func() {
+			settings, err := config.FileSettings(&config.Files[0], "print")
+			So(err, ShouldBeNil)
+			So(settings.Theme, ShouldEqual, "default")
+			So(settings.PageSize, ShouldEqual, "a5")
+			So(settings.LinkColor, ShouldEqual, "#000000")
+			So(settings.Margins, ShouldResemble, &Margins{Top: "15mm", Bottom: "20mm"})
+			So(settings.Metadata, ShouldResemble, map[string]string{"publisher": "Someone"})
+			settings.Margins.Left = "1cm"
+			So(config.Files[0].Margins.Left, ShouldBeEmpty)
+		})
+
+		Convey("It should fail on profiles extending themselves", func() {
+			_, err := config.ProfileSettings("loop")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "The profile loop extends itself: loop -> other -> loop")
+		})
+
+		Convey("It should fail on profiles extending unknown profiles", func() {
+			_, err := config.ProfileSettings("broken")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "The profile broken extends missing, which does not exist")
+		})
+
+		Convey("It should fail on unknown profiles", func() {
+			_, err := config.ProfileSettings("web")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "There is no profile named web in the configuration, use broken, draft, loop, other, print, screen")
+		})
+	})
+}
@@ -0,0 +1,424 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// UpdateTo saves the configuration to the given writer in the given format, like SaveTo,
+// but keeping the order of the top level fields of the original file.
+//
+// The yaml and toml files are changed in place, only where the configuration differs
+// from the original, keeping their comments and formatting.
+func (c *Config) UpdateTo(original []byte, writer io.Writer, format Format) error {
+	if len(bytes.TrimSpace(original)) == 0 {
+		return c.SaveTo(writer, format)
+	}
+	var data []byte
+	var err error
+	switch format {
+	case FormatJson:
+		data, err = c.updateJson(original)
+		if err != nil {
+			return errors.Wrap(err, "Unable to encode the file as json")
+		}
+	case FormatToml:
+		data, err = c.updateDocument(original, format)
+		if err != nil {
+			return errors.Wrap(err, "Unable to encode the file as toml")
+		}
+	case FormatYaml:
+		data, err = c.updateDocument(original, format)
+		if err != nil {
+			return errors.Wrap(err, "Unable to encode the file as yaml")
+		}
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+// updateJson changes the fields of the original json file that differ from the configuration,
+// keeping their order
+func (c *Config) updateJson(original []byte) ([]byte, error) {
+	fileKeys, _, err := jsonFields(original)
+	if err != nil {
+		return nil, err
+	}
+	old, err := decodeConfig(original, FormatJson)
+	if err != nil {
+		return nil, err
+	}
+	data, err := jsoniter.Marshal(old)
+	if err != nil {
+		return nil, err
+	}
+	_, oldValues, err := jsonFields(data)
+	if err != nil {
+		return nil, err
+	}
+	data, err = jsoniter.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	newKeys, newValues, err := jsonFields(data)
+	if err != nil {
+		return nil, err
+	}
+	keys, values := mergeFields(fileKeys, oldValues, newKeys, newValues)
+
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		name, _ := jsoniter.Marshal(key)
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		buffer.Write(values[key])
+	}
+	buffer.WriteByte('}')
+	var result bytes.Buffer
+	if err = json.Indent(&result, buffer.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	result.WriteByte('\n')
+	return result.Bytes(), nil
+}
+
+// jsonFields returns the keys, in order, and the raw values of the given json object
+func jsonFields(data []byte) ([]string, map[string][]byte, error) {
+	keys := make([]string, 0)
+	values := make(map[string][]byte)
+	iter := jsoniter.ParseBytes(jsoniter.ConfigDefault, data)
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, key string) bool {
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = append([]byte(nil), iter.SkipAndReturnBytes()...)
+		return true
+	})
+	if iter.Error != nil && iter.Error != io.EOF {
+		return nil, nil, iter.Error
+	}
+	return keys, values, nil
+}
+
+// mergeFields returns the keys of the fields of a file, in their order, followed by the keys of
+// the new fields that are not in it, together with their new values, given the fields of the old
+// and new configurations. The fields of the file that the new configuration leaves out are
+// removed, and the other new fields are only added when they differ from the old ones, so that
+// the empty and default values are not added
+func mergeFields(fileKeys []string, oldValues map[string][]byte, newKeys []string, newValues map[string][]byte) ([]string, map[string][]byte) {
+	keys := make([]string, 0, len(fileKeys)+len(newKeys))
+	values := make(map[string][]byte, len(fileKeys)+len(newKeys))
+	for _, key := range fileKeys {
+		if value, ok := newValues[key]; ok {
+			keys = append(keys, key)
+			values[key] = value
+		}
+	}
+	for _, key := range newKeys {
+		if !slices.Contains(fileKeys, key) && !bytes.Equal(newValues[key], oldValues[key]) {
+			keys = append(keys, key)
+			values[key] = newValues[key]
+		}
+	}
+	return keys, values
+}
+
+// updateDocument changes the values of the original yaml or toml file that differ from the
+// configuration, in place
+func (c *Config) updateDocument(original []byte, format Format) ([]byte, error) {
+	old, err := decodeConfig(original, format)
+	if err != nil {
+		return nil, err
+	}
+	var doc *document
+	var root configObject
+	switch format {
+	case FormatYaml:
+		doc, root, err = parseYamlDocument(original)
+	case FormatToml:
+		doc, root, err = parseTomlDocument(original)
+	default:
+		err = errors.Errorf("The format %q can not be changed in place", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = updateObject(root, format.String(), reflect.ValueOf(old).Elem(), reflect.ValueOf(c).Elem()); err != nil {
+		return nil, err
+	}
+	return doc.apply(), nil
+}
+
+// configObject is an object of a configuration file, whose values can be changed in place
+type configObject interface {
+	// set changes the value of the given key, adding it when it does not exist.
+	set(key string, value any) error
+	// remove removes the given key, when it exists.
+	remove(key string)
+	// array returns the array of the given key, or nil when it does not exist or its
+	// elements can not be changed one by one.
+	array(key string) configArray
+}
+
+// configArray is an array of a configuration file, whose elements can be changed in place
+type configArray interface {
+	// object returns the element with the given index as an object, or nil when it is not one.
+	object(index int) configObject
+	// remove removes the element with the given index.
+	remove(index int)
+	// append adds the given value after the last element.
+	append(value any) error
+}
+
+// updateObject changes the fields of the given object that differ between the old and new
+// values of a struct, using the names of the fields in the given tag
+func updateObject(object configObject, tag string, old, new reflect.Value) error {
+	for i := 0; i < new.NumField(); i++ {
+		// the fields of embedded structs are fields of the object
+		if new.Type().Field(i).Anonymous {
+			if err := updateObject(object, tag, old.Field(i), new.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		key, options, _ := strings.Cut(new.Type().Field(i).Tag.Get(tag), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		oldValue, newValue := old.Field(i), new.Field(i)
+		if sameValue(oldValue, newValue) {
+			continue
+		}
+		var err error
+		switch {
+		case newValue.Kind() == reflect.Slice && newValue.Len() > 0:
+			err = updateArray(object, key, tag, oldValue, newValue)
+		case newValue.IsZero() && strings.Contains(options, "omitempty"):
+			object.remove(key)
+		default:
+			err = object.set(key, newValue.Interface())
+		}
+		if err != nil {
+			return errors.Wrapf(err, "Unable to change %s", key)
+		}
+	}
+	return nil
+}
+
+// updateArray changes the elements of the array of the given key that differ between the old and
+// new values of a slice, matching structs by their name and other values by themselves, or
+// replaces the whole array when its elements can not be changed one by one
+func updateArray(object configObject, key string, tag string, old, new reflect.Value) error {
+	identity := func(value reflect.Value) any {
+		if value.Kind() == reflect.Struct {
+			return value.FieldByName("Name").Interface()
+		}
+		return value.Interface()
+	}
+	newIndexes := make(map[any]int, new.Len())
+	for i := 0; i < new.Len(); i++ {
+		newIndexes[identity(new.Index(i))] = i
+	}
+
+	array := object.array(key)
+	changed := make(map[int]configObject)
+	for i := 0; array != nil && i < old.Len(); i++ {
+		j, ok := newIndexes[identity(old.Index(i))]
+		if !ok || sameValue(old.Index(i), new.Index(j)) {
+			continue
+		}
+		if changed[i] = array.object(i); changed[i] == nil {
+			array = nil
+		}
+	}
+	if array == nil {
+		return object.set(key, new.Interface())
+	}
+
+	oldIdentities := make(map[any]bool, old.Len())
+	for i := 0; i < old.Len(); i++ {
+		oldIdentities[identity(old.Index(i))] = true
+		j, ok := newIndexes[identity(old.Index(i))]
+		switch {
+		case !ok:
+			array.remove(i)
+		case changed[i] != nil:
+			if err := updateObject(changed[i], tag, old.Index(i), new.Index(j)); err != nil {
+				return err
+			}
+		}
+	}
+	for i := 0; i < new.Len(); i++ {
+		if !oldIdentities[identity(new.Index(i))] {
+			if err := array.append(new.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sameValue checks if the given values are equal, taking empty and nil slices as the same
+func sameValue(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Slice && a.Len() == 0 && b.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// textEdit replaces the text between two offsets of a document
+type textEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// document is the text of a configuration file, with the changes to make to it
+type document struct {
+	data  []byte
+	edits []textEdit
+}
+
+// replace replaces the text between the given offsets of the original data
+func (d *document) replace(start, end int, text string) {
+	d.edits = append(d.edits, textEdit{start: start, end: end, text: text})
+}
+
+// insert inserts the given text at the given offset of the original data, after the text
+// inserted before at the same offset
+func (d *document) insert(offset int, text string) {
+	d.replace(offset, offset, text)
+}
+
+// apply returns the data with all the changes made
+func (d *document) apply() []byte {
+	indexes := make([]int, len(d.edits))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	// the changes are made from the end, so the offsets of the others stay valid
+	slices.SortStableFunc(indexes, func(a, b int) int {
+		if d.edits[a].start != d.edits[b].start {
+			return d.edits[b].start - d.edits[a].start
+		}
+		// a removal goes before the insertions at its start, so it does not remove them
+		if d.edits[a].end != d.edits[b].end {
+			return d.edits[b].end - d.edits[a].end
+		}
+		return b - a
+	})
+	result := slices.Clone(d.data)
+	limit := len(d.data)
+	for _, index := range indexes {
+		// removals of lines around others can overlap them, so they stop where the others start
+		edit := d.edits[index]
+		edit.end = min(edit.end, limit)
+		edit.start = min(edit.start, edit.end)
+		result = slices.Concat(result[:edit.start], []byte(edit.text), result[edit.end:])
+		limit = edit.start
+	}
+	return result
+}
+
+// lineStart returns the offset of the start of the line with the given offset
+func (d *document) lineStart(offset int) int {
+	return bytes.LastIndexByte(d.data[:offset], '\n') + 1
+}
+
+// nextLine returns the offset of the start of the line after the one with the given offset,
+// or the end of the data
+func (d *document) nextLine(offset int) int {
+	if index := bytes.IndexByte(d.data[offset:], '\n'); index >= 0 {
+		return offset + index + 1
+	}
+	return len(d.data)
+}
+
+// blankLine checks if the line with the given offset is empty or only has a comment
+func (d *document) blankLine(offset int) bool {
+	line := bytes.TrimSpace(d.data[d.lineStart(offset):d.nextLine(offset)])
+	return len(line) == 0 || line[0] == '#'
+}
+
+// contentEnd returns the end of the last line, between the given offsets, that is not empty
+// nor only has a comment, not counting the line of the start
+func (d *document) contentEnd(start, end int) int {
+	last := d.nextLine(start)
+	for offset := last; offset < end; offset = d.nextLine(offset) {
+		if !d.blankLine(offset) {
+			last = d.nextLine(offset)
+		}
+	}
+	last = min(last, end)
+	for last > start && (d.data[last-1] == '\n' || d.data[last-1] == '\r') {
+		last--
+	}
+	return last
+}
+
+// commentStart returns the offset of the comment at the end of the line with the given offset,
+// outside of strings, or the end of the line when it has none; in yaml, where plain is true,
+// the strings only start after an indicator and the comments need a space before them
+func (d *document) commentStart(offset int, plain bool) int {
+	start, end := d.lineStart(offset), d.nextLine(offset)
+	var quote, previous byte = 0, ' '
+	for i := start; i < end; i++ {
+		switch c := d.data[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (!plain || strings.IndexByte(" :-[{,", previous) >= 0):
+			quote = c
+		case c == '#' && (!plain || previous == ' ' || previous == '\t'):
+			return i
+		}
+		if i < end {
+			previous = d.data[i]
+		}
+	}
+	return len(bytes.TrimRight(d.data[:end], "\r\n"))
+}
+
+// valueEnd returns the end of the value that starts at the given offset, in the lines before
+// the given end, without the comment of its last line
+func (d *document) valueEnd(start, end int, plain bool) int {
+	last := d.contentEnd(start, end)
+	last = min(last, d.commentStart(last, plain))
+	for last > start && (d.data[last-1] == ' ' || d.data[last-1] == '\t') {
+		last--
+	}
+	return last
+}
@@ -0,0 +1,256 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// updateConfig reads the given content, adds a file and removes the license, returning the updated content
+// and configuration
+func updateConfig(content string, format Format) (string, *Config) {
+	config, err := decodeConfig([]byte(content), format)
+	So(err, ShouldBeNil)
+	config.AddFile(NewFile("Book B", "./dist/bookB", "./src/bookB/main.md"))
+	config.RemoveLicense("GPL-3.0-or-later")
+	var buffer bytes.Buffer
+	So(config.UpdateTo([]byte(content), &buffer, format), ShouldBeNil)
+	So(ValidateConfig(buffer.Bytes(), format), ShouldBeNil)
+	updated, err := decodeConfig(buffer.Bytes(), format)
+	So(err, ShouldBeNil)
+	return buffer.String(), updated
+}
+
+// shouldBeInOrder checks that the given values appear in the actual string in order
+func shouldBeInOrder(actual any, expected ...any) string {
+	text := actual.(string)
+	last := -1
+	for _, value := range expected {
+		index := strings.Index(text, value.(string))
+		if index <= last {
+			return "Expected " + value.(string) + " to come after the previous values in:\n" + text
+		}
+		last = index
+	}
+	return ""
+}
+
+const (
+	commentedToml = `# The project
+name = "sample" # the name
+version = "0.0.1"
+license = [
+  "GPL-3.0-or-later", # the code
+]
+
+# The books
+[[files]]
+name = "Book A"
+output = "./dist/bookA"
+path = "./src/bookA/main.md"
+
+# The people
+[[authors]]
+name = "carddamom"
+email = "carddamom@tutanota.com" # the mail
+`
+	commentedYaml = `# The project
+name: sample # the name
+version: "0.0.1"
+
+# The books
+files:
+  - name: Book A # the first
+    output: ./dist/bookA
+    path: ./src/bookA/main.md
+
+# The people
+authors:
+  - name: carddamom
+    email: carddamom@tutanota.com # the mail
+license: [GPL-3.0-or-later]
+`
+)
+
+// editConfig reads the given content, changes it with the given function, and returns the updated content
+func editConfig(content string, format Format, edit func(config *Config)) string {
+	config, err := decodeConfig([]byte(content), format)
+	So(err, ShouldBeNil)
+	edit(config)
+	var buffer bytes.Buffer
+	So(config.UpdateTo([]byte(content), &buffer, format), ShouldBeNil)
+	So(ValidateConfig(buffer.Bytes(), format), ShouldBeNil)
+	return buffer.String()
+}
+
+func TestUpdateTo(t *testing.T) {
+	Convey("#UpdateTo", t, func() {
+
+		Convey("It should keep the order of the fields of a json file", func() {
+			text, config := updateConfig(jsonContent, FormatJson)
+			So(config.Files, ShouldHaveLength, 2)
+			So(config.Files[1].Name, ShouldEqual, "Book B")
+			So(config.License, ShouldBeEmpty)
+			So(text, shouldBeInOrder, `"name"`, `"version"`, `"description"`, `"authors"`, `"files"`, `"license"`)
+			So(text, ShouldStartWith, "{\n  \"name\": \"sample\",\n")
+		})
+
+		Convey("It should only change the fields of a json file that changed", func() {
+			content := strings.Replace(jsonContent, `"version": "0.0.1",`+"\n  "+`"description": "Some description",`, `"theme": "book",`, 1)
+
+			Convey("When removing the theme", func() {
+				text := editConfig(content, FormatJson, func(config *Config) {
+					config.Theme = ""
+				})
+				So(text, ShouldNotContainSubstring, `"theme"`)
+				So(text, ShouldNotContainSubstring, `"version"`)
+				So(text, ShouldNotContainSubstring, `"description"`)
+				config, err := decodeConfig([]byte(text), FormatJson)
+				So(err, ShouldBeNil)
+				So(config.Theme, ShouldBeEmpty)
+			})
+
+			Convey("When adding a description", func() {
+				text := editConfig(content, FormatJson, func(config *Config) {
+					config.Description = "A description"
+				})
+				So(text, shouldBeInOrder, `"name"`, `"theme": "book"`, `"authors"`, `"files"`, `"license"`, `"description": "A description"`)
+				So(text, ShouldNotContainSubstring, `"version"`)
+			})
+		})
+
+		Convey("It should keep the order of the fields of a yaml file", func() {
+			text, config := updateConfig(yamlContent, FormatYaml)
+			So(config.Files, ShouldHaveLength, 2)
+			So(config.License, ShouldBeEmpty)
+			So(text, shouldBeInOrder, "name:", "version:", "description:", "authors:", "files:", "Book B", "license:")
+		})
+
+		Convey("It should keep the order of the fields of a toml file", func() {
+			text, config := updateConfig(tomlContent, FormatToml)
+			So(config.Files, ShouldHaveLength, 2)
+			So(config.License, ShouldBeEmpty)
+			So(text, shouldBeInOrder, "name =", "version =", "description =", "license =", "[[files]]", "Book B", "[[authors]]")
+		})
+
+		Convey("It should change a toml file in place, keeping its comments", func() {
+			Convey("When bumping the version", func() {
+				text := editConfig(commentedToml, FormatToml, func(config *Config) {
+					config.Version = "0.1.0"
+				})
+				So(text, ShouldEqual, strings.Replace(commentedToml, "0.0.1", "0.1.0", 1))
+			})
+
+			Convey("When adding a file and an author", func() {
+				text := editConfig(commentedToml, FormatToml, func(config *Config) {
+					config.AddFile(NewFile("Book B", "./dist/bookB", "./src/bookB/main.md"))
+					config.AddAuthor(NewAuthor("other"))
+				})
+				So(text, ShouldEqual, strings.Replace(commentedToml, "\n# The people", `
+[[files]]
+name = "Book B"
+output = "./dist/bookB"
+path = "./src/bookB/main.md"
+
+# The people`, 1)+"\n[[authors]]\nname = \"other\"\n")
+			})
+
+			Convey("When removing a file and an email", func() {
+				text := editConfig(commentedToml, FormatToml, func(config *Config) {
+					config.RemoveFile(NewFile("Book A", "", ""))
+					config.Authors[0].Email = ""
+				})
+				So(text, ShouldEqual, `# The project
+name = "sample" # the name
+version = "0.0.1"
+license = [
+  "GPL-3.0-or-later", # the code
+]
+files = []
+
+# The books
+# The people
+[[authors]]
+name = "carddamom"
+`)
+			})
+		})
+
+		Convey("It should change a yaml file in place, keeping its comments", func() {
+			Convey("When bumping the version", func() {
+				text := editConfig(commentedYaml, FormatYaml, func(config *Config) {
+					config.Version = "0.1.0"
+				})
+				So(text, ShouldEqual, strings.Replace(commentedYaml, "0.0.1", "0.1.0", 1))
+			})
+
+			Convey("When adding a file, an author and a license", func() {
+				text := editConfig(commentedYaml, FormatYaml, func(config *Config) {
+					config.AddFile(NewFile("Book B", "./dist/bookB", "./src/bookB/main.md"))
+					config.AddAuthor(NewAuthor("other"))
+					config.AddLicense("MIT")
+				})
+				So(text, ShouldEqual, `# The project
+name: sample # the name
+version: "0.0.1"
+
+# The books
+files:
+  - name: Book A # the first
+    output: ./dist/bookA
+    path: ./src/bookA/main.md
+  - name: Book B
+    output: ./dist/bookB
+    path: ./src/bookB/main.md
+
+# The people
+authors:
+  - name: carddamom
+    email: carddamom@tutanota.com # the mail
+  - name: other
+license: [GPL-3.0-or-later, MIT]
+`)
+			})
+
+			Convey("When removing a file and an email", func() {
+				text := editConfig(commentedYaml, FormatYaml, func(config *Config) {
+					config.RemoveFile(NewFile("Book A", "", ""))
+					config.Authors[0].Email = ""
+				})
+				So(text, ShouldEqual, `# The project
+name: sample # the name
+version: "0.0.1"
+
+# The books
+files: []
+
+# The people
+authors:
+  - name: carddamom
+license: [GPL-3.0-or-later]
+`)
+			})
+		})
+	})
+}
@@ -0,0 +1,379 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// tomlStatement is one of the top level expressions of a toml file
+type tomlStatement struct {
+	kind unstable.Kind
+	// keys are the parts of the key of a key value, or of the name of a table.
+	keys []string
+	// start is the offset of the start of its line.
+	start int
+	// value is the offset of the value of a key value.
+	value int
+}
+
+// tomlDocument is a toml file whose values can be changed in place
+type tomlDocument struct {
+	*document
+	statements []tomlStatement
+	// quote is the quote of the strings of the file.
+	quote byte
+}
+
+// parseTomlDocument parses the given toml file, returning it and its root table
+func parseTomlDocument(data []byte) (*document, configObject, error) {
+	doc := &tomlDocument{document: &document{data: data}, quote: '\''}
+	quoted := false
+	p := unstable.Parser{KeepComments: true}
+	p.Reset(data)
+	for p.NextExpression() {
+		expression := p.Expression()
+		statement := tomlStatement{kind: expression.Kind}
+		switch expression.Kind {
+		case unstable.Comment:
+			statement.start = doc.lineStart(int(expression.Raw.Offset))
+		case unstable.KeyValue, unstable.Table, unstable.ArrayTable:
+			keys := expression.Key()
+			for keys.Next() {
+				key := keys.Node()
+				if len(statement.keys) == 0 {
+					statement.start = doc.lineStart(int(key.Raw.Offset))
+				}
+				statement.keys = append(statement.keys, string(key.Data))
+				statement.value = int(key.Raw.Offset + key.Raw.Length)
+			}
+		default:
+			continue
+		}
+		if expression.Kind == unstable.KeyValue {
+			// the value comes after the equal sign that follows the key
+			statement.value += bytes.IndexByte(data[statement.value:], '=') + 1
+			for statement.value < len(data) && (data[statement.value] == ' ' || data[statement.value] == '\t') {
+				statement.value++
+			}
+			if value := expression.Value(); value.Kind == unstable.String && !quoted {
+				doc.quote, quoted = data[statement.value], true
+			}
+		}
+		doc.statements = append(doc.statements, statement)
+	}
+	if err := p.Error(); err != nil {
+		return nil, nil, err
+	}
+	return doc.document, &tomlTable{doc: doc, header: -1}, nil
+}
+
+// end returns the offset of the end of the given statement, which is the start of the next one
+func (d *tomlDocument) end(index int) int {
+	if index+1 < len(d.statements) {
+		return d.statements[index+1].start
+	}
+	return len(d.data)
+}
+
+// isHeader checks if the given statement is the header of a table or array of tables
+func (d *tomlDocument) isHeader(index int) bool {
+	kind := d.statements[index].kind
+	return kind == unstable.Table || kind == unstable.ArrayTable
+}
+
+// lineEnd returns the offset after the last line of the given key value
+func (d *tomlDocument) lineEnd(index int) int {
+	return d.nextLine(d.contentEnd(d.statements[index].start, d.end(index)))
+}
+
+// tableEnd returns the end of the array table with the given header, which is the header of the next
+// table that is not one of its sub tables, without the comments and blank lines just before it
+func (d *tomlDocument) tableEnd(header int) int {
+	keys := d.statements[header].keys
+	next := header + 1
+	for ; next < len(d.statements); next++ {
+		if d.isHeader(next) {
+			other := d.statements[next].keys
+			if len(other) <= len(keys) || !slicesHavePrefix(other, keys) {
+				break
+			}
+		}
+	}
+	return d.nextLine(d.contentEnd(d.statements[header].start, d.end(next-1)))
+}
+
+// value encodes the given value as a toml value, with strings in the quotes of the file
+func (d *tomlDocument) value(value any) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return tomlString(value, d.quote), nil
+	case []string:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, tomlString(item, d.quote))
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	}
+	data, err := toml.Marshal(map[string]any{"value": value})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimPrefix(string(data), "value = ")), nil
+}
+
+// arrayTable encodes the given struct as a table of the array of tables with the given key
+func (d *tomlDocument) arrayTable(key string, value any) (string, error) {
+	var result strings.Builder
+	result.WriteString("[[" + tomlKey(key) + "]]\n")
+	fields := reflect.ValueOf(value)
+	for i := 0; i < fields.NumField(); i++ {
+		name, options, _ := strings.Cut(fields.Type().Field(i).Tag.Get("toml"), ",")
+		if name == "" || name == "-" || (fields.Field(i).IsZero() && strings.Contains(options, "omitempty")) {
+			continue
+		}
+		encoded, err := d.value(fields.Field(i).Interface())
+		if err != nil {
+			return "", err
+		}
+		result.WriteString(tomlKey(name) + " = " + encoded + "\n")
+	}
+	return result.String(), nil
+}
+
+// tomlTable is the root table of a toml file, or one of the tables of an array of tables
+type tomlTable struct {
+	doc *tomlDocument
+	// header is the index of the statement of the table header, or -1 for the root table.
+	header int
+}
+
+// keyValues returns the indexes of the key values of the table
+func (t *tomlTable) keyValues() []int {
+	result := make([]int, 0)
+	for index := t.header + 1; index < len(t.doc.statements) && !t.doc.isHeader(index); index++ {
+		if t.doc.statements[index].kind == unstable.KeyValue {
+			result = append(result, index)
+		}
+	}
+	return result
+}
+
+// find returns the index of the key value with the given key, or -1 when there is none
+func (t *tomlTable) find(key string) int {
+	for _, index := range t.keyValues() {
+		if keys := t.doc.statements[index].keys; len(keys) == 1 && keys[0] == key {
+			return index
+		}
+	}
+	return -1
+}
+
+// arrayTables returns the headers of the array of tables with the given key, in the root table
+func (t *tomlTable) arrayTables(key string) []int {
+	result := make([]int, 0)
+	for index, statement := range t.doc.statements {
+		if t.header < 0 && statement.kind == unstable.ArrayTable && len(statement.keys) == 1 && statement.keys[0] == key {
+			result = append(result, index)
+		}
+	}
+	return result
+}
+
+func (t *tomlTable) set(key string, value any) error {
+	if reflect.TypeOf(value).Kind() == reflect.Slice && reflect.TypeOf(value).Elem().Kind() == reflect.Struct {
+		return t.setTables(key, reflect.ValueOf(value))
+	}
+	encoded, err := t.doc.value(value)
+	if err != nil {
+		return err
+	}
+	if index := t.find(key); index >= 0 {
+		start := t.doc.statements[index].value
+		t.doc.replace(start, t.doc.valueEnd(start, t.doc.end(index), false), encoded)
+		return nil
+	}
+	t.insert(tomlKey(key) + " = " + encoded + "\n")
+	return nil
+}
+
+// setTables replaces the array of the given key with an array of tables of the given structs,
+// written at the end of the file, or with an empty array when there are none
+func (t *tomlTable) setTables(key string, value reflect.Value) error {
+	t.remove(key)
+	for _, header := range t.arrayTables(key) {
+		(&tomlTables{doc: t.doc, headers: []int{header}}).remove(0)
+	}
+	if value.Len() == 0 {
+		t.insert(tomlKey(key) + " = []\n")
+		return nil
+	}
+	text := "\n"
+	if len(t.doc.data) > 0 && t.doc.data[len(t.doc.data)-1] != '\n' {
+		text = "\n\n"
+	}
+	for i := 0; i < value.Len(); i++ {
+		if i > 0 {
+			text += "\n"
+		}
+		table, err := t.doc.arrayTable(key, value.Index(i).Interface())
+		if err != nil {
+			return err
+		}
+		text += table
+	}
+	t.doc.insert(len(t.doc.data), text)
+	return nil
+}
+
+// insert inserts the given key value after the last one of the table
+func (t *tomlTable) insert(text string) {
+	keyValues := t.keyValues()
+	switch {
+	case len(keyValues) > 0:
+		t.doc.insert(t.doc.lineEnd(keyValues[len(keyValues)-1]), text)
+	case t.header >= 0:
+		t.doc.insert(t.doc.nextLine(t.doc.statements[t.header].start), text)
+	default:
+		// the root table has no key values, so they go before the first table
+		for index := range t.doc.statements {
+			if t.doc.isHeader(index) {
+				t.doc.insert(t.doc.statements[index].start, text+"\n")
+				return
+			}
+		}
+		if len(t.doc.data) > 0 && t.doc.data[len(t.doc.data)-1] != '\n' {
+			text = "\n" + text
+		}
+		t.doc.insert(len(t.doc.data), text)
+	}
+}
+
+func (t *tomlTable) remove(key string) {
+	if index := t.find(key); index >= 0 {
+		t.doc.replace(t.doc.statements[index].start, t.doc.lineEnd(index), "")
+	}
+}
+
+func (t *tomlTable) array(key string) configArray {
+	headers := t.arrayTables(key)
+	if len(headers) == 0 {
+		return nil
+	}
+	return &tomlTables{doc: t.doc, key: key, headers: headers}
+}
+
+// tomlTables is an array of tables of a toml file
+type tomlTables struct {
+	doc     *tomlDocument
+	key     string
+	headers []int
+}
+
+func (t *tomlTables) object(index int) configObject {
+	return &tomlTable{doc: t.doc, header: t.headers[index]}
+}
+
+func (t *tomlTables) remove(index int) {
+	start := t.doc.statements[t.headers[index]].start
+	end := t.doc.tableEnd(t.headers[index])
+	// the blank lines before the table go with it, or the ones after it when there are none
+	header := start
+	for start > 0 && len(bytes.TrimSpace(t.doc.data[t.doc.lineStart(start-1):start])) == 0 {
+		start = t.doc.lineStart(start - 1)
+	}
+	for start == header && end < len(t.doc.data) && len(bytes.TrimSpace(t.doc.data[end:t.doc.nextLine(end)])) == 0 {
+		end = t.doc.nextLine(end)
+	}
+	t.doc.replace(start, end, "")
+}
+
+func (t *tomlTables) append(value any) error {
+	table, err := t.doc.arrayTable(t.key, value)
+	if err != nil {
+		return err
+	}
+	end := t.doc.tableEnd(t.headers[len(t.headers)-1])
+	if end == len(t.doc.data) && (end == 0 || t.doc.data[end-1] != '\n') {
+		table = "\n" + table
+	}
+	t.doc.insert(end, "\n"+table)
+	return nil
+}
+
+// tomlKey encodes the given key, quoting it when it is not a bare key
+func tomlKey(key string) string {
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return tomlString(key, '"')
+		}
+	}
+	return key
+}
+
+// tomlString encodes the given string as a literal string, when the quote is a single one and it
+// can be one, or as a basic string
+func tomlString(value string, quote byte) string {
+	if quote == '\'' && !strings.ContainsFunc(value, func(c rune) bool {
+		return c == '\'' || c < 0x20 && c != '\t' || c == 0x7f
+	}) {
+		return "'" + value + "'"
+	}
+	var result strings.Builder
+	result.WriteByte('"')
+	for _, c := range value {
+		switch c {
+		case '"':
+			result.WriteString(`\"`)
+		case '\\':
+			result.WriteString(`\\`)
+		case '\b':
+			result.WriteString(`\b`)
+		case '\t':
+			result.WriteString(`\t`)
+		case '\n':
+			result.WriteString(`\n`)
+		case '\f':
+			result.WriteString(`\f`)
+		case '\r':
+			result.WriteString(`\r`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				result.WriteString(fmt.Sprintf(`\u%04X`, c))
+			} else {
+				result.WriteRune(c)
+			}
+		}
+	}
+	result.WriteByte('"')
+	return result.String()
+}
+
+// slicesHavePrefix checks if the given keys start with the given prefix
+func slicesHavePrefix(keys, prefix []string) bool {
+	return len(keys) >= len(prefix) && slices.Equal(keys[:len(prefix)], prefix)
+}
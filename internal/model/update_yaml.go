@@ -0,0 +1,335 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"emperror.dev/errors"
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/goccy/go-yaml/token"
+)
+
+// yamlDocument is a yaml file whose values can be changed in place
+type yamlDocument struct {
+	*document
+	// sequenceIndent is how much the items of block sequences are indented from their key.
+	sequenceIndent int
+}
+
+// parseYamlDocument parses the given yaml file, returning it and its root mapping
+func parseYamlDocument(data []byte) (*document, configObject, error) {
+	file, err := parser.ParseBytes(data, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(file.Docs) == 0 {
+		return nil, nil, errors.New("The yaml file is empty")
+	}
+	doc := &yamlDocument{document: &document{data: data}, sequenceIndent: 2}
+	entries, block := yamlEntries(file.Docs[0].Body)
+	if !block || len(entries) == 0 {
+		return nil, nil, errors.New("The yaml file is not a block mapping")
+	}
+	root := &yamlMapping{doc: doc, entries: entries, end: len(data)}
+	for _, entry := range entries {
+		if sequence, ok := entry.Value.(*ast.SequenceNode); ok && !sequence.IsFlowStyle && len(sequence.Values) > 0 {
+			dash := doc.dash(sequence.Values[0])
+			doc.sequenceIndent = (dash - doc.lineStart(dash)) - root.indent()
+			break
+		}
+	}
+	return doc.document, root, nil
+}
+
+// yamlEntries returns the entries of the given mapping node, and if it is a block mapping
+func yamlEntries(node ast.Node) ([]*ast.MappingValueNode, bool) {
+	switch node := node.(type) {
+	case *ast.MappingNode:
+		return node.Values, !node.IsFlowStyle
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{node}, true
+	}
+	return nil, false
+}
+
+// yamlToken returns the first token of the given node
+func yamlToken(node ast.Node) *token.Token {
+	switch node := node.(type) {
+	case *ast.MappingNode:
+		if !node.IsFlowStyle && len(node.Values) > 0 {
+			return yamlToken(node.Values[0])
+		}
+	case *ast.MappingValueNode:
+		return node.Key.GetToken()
+	}
+	return node.GetToken()
+}
+
+// offset returns the offset of the given token in the data
+func (d *yamlDocument) offset(tk *token.Token) int {
+	offset := 0
+	for line := 1; line < tk.Position.Line && offset < len(d.data); line++ {
+		offset = d.nextLine(offset)
+	}
+	for column := 1; column < tk.Position.Column && offset < len(d.data); column++ {
+		_, size := utf8.DecodeRune(d.data[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// dash returns the offset of the dash before the given item of a block sequence
+func (d *yamlDocument) dash(item ast.Node) int {
+	offset := d.offset(yamlToken(item))
+	for offset > 0 && d.data[offset] != '-' {
+		offset--
+	}
+	return offset
+}
+
+// value encodes the given value as the value of a key with the given indentation, using the
+// given quote for strings and a flow sequence for the sequences of strings when flow is true
+func (d *yamlDocument) value(value any, indent int, quote byte, flow bool) (string, error) {
+	reflected := reflect.ValueOf(value)
+	switch {
+	case reflected.Kind() == reflect.String:
+		return " " + yamlScalar(reflected.String(), quote), nil
+	case reflected.Kind() == reflect.Slice && reflected.Len() == 0:
+		return " []", nil
+	case reflected.Kind() == reflect.Slice && flow && reflected.Type().Elem().Kind() == reflect.String:
+		items := make([]string, 0, reflected.Len())
+		for i := 0; i < reflected.Len(); i++ {
+			items = append(items, yamlScalar(reflected.Index(i).String(), 0))
+		}
+		return " [" + strings.Join(items, ", ") + "]", nil
+	case reflected.Kind() == reflect.Slice:
+		result := "\n"
+		for i := 0; i < reflected.Len(); i++ {
+			result += yamlItem(reflected.Index(i).Interface(), indent+d.sequenceIndent)
+		}
+		return strings.TrimSuffix(result, "\n"), nil
+	}
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return " " + strings.TrimSpace(string(data)), nil
+}
+
+// yamlMapping is a block mapping of a yaml file
+type yamlMapping struct {
+	doc     *yamlDocument
+	entries []*ast.MappingValueNode
+	// end is the offset of the end of the lines of the mapping.
+	end int
+}
+
+// indent returns the indentation of the keys of the mapping
+func (m *yamlMapping) indent() int {
+	key := m.doc.offset(m.entries[0].Key.GetToken())
+	return key - m.doc.lineStart(key)
+}
+
+// find returns the index of the entry with the given key, or -1 when there is none
+func (m *yamlMapping) find(key string) int {
+	for index, entry := range m.entries {
+		if tk := entry.Key.GetToken(); tk != nil && tk.Value == key {
+			return index
+		}
+	}
+	return -1
+}
+
+// entryEnd returns the offset of the end of the lines of the entry with the given index
+func (m *yamlMapping) entryEnd(index int) int {
+	if index+1 < len(m.entries) {
+		return m.doc.lineStart(m.doc.offset(m.entries[index+1].Key.GetToken()))
+	}
+	return m.end
+}
+
+// colon returns the offset after the colon that follows the key of the entry with the given index
+func (m *yamlMapping) colon(index int) int {
+	offset := m.doc.offset(m.entries[index].Key.GetToken())
+	if quote := m.doc.data[offset]; quote == '"' || quote == '\'' {
+		for offset++; offset < len(m.doc.data) && m.doc.data[offset] != quote; offset++ {
+			if m.doc.data[offset] == '\\' && quote == '"' {
+				offset++
+			}
+		}
+	}
+	return offset + bytes.IndexByte(m.doc.data[offset:], ':') + 1
+}
+
+func (m *yamlMapping) set(key string, value any) error {
+	index := m.find(key)
+	if index < 0 {
+		encoded, err := m.doc.value(value, m.indent(), 0, true)
+		if err != nil {
+			return err
+		}
+		last := m.doc.lineStart(m.doc.offset(m.entries[len(m.entries)-1].Key.GetToken()))
+		offset := m.doc.nextLine(m.doc.contentEnd(last, m.end))
+		text := strings.Repeat(" ", m.indent()) + yamlScalar(key, 0) + ":" + encoded + "\n"
+		if offset == len(m.doc.data) && offset > 0 && m.doc.data[offset-1] != '\n' {
+			text = "\n" + text
+		}
+		m.doc.insert(offset, text)
+		return nil
+	}
+
+	// the old value is kept in the same line of the key, when it was there, and in the same style
+	start, end := m.colon(index), m.entryEnd(index)
+	rest := bytes.TrimSpace(m.doc.data[start:m.doc.commentStart(start, true)])
+	inline := len(rest) > 0 && rest[0] != '|' && rest[0] != '>'
+	var quote byte
+	if inline && (rest[0] == '"' || rest[0] == '\'') {
+		quote = rest[0]
+	}
+	encoded, err := m.doc.value(value, m.indent(), quote, inline)
+	if err != nil {
+		return err
+	}
+	if inline {
+		m.doc.replace(start, m.doc.valueEnd(start, end, true), encoded)
+	} else {
+		m.doc.replace(start, m.doc.contentEnd(start, end), encoded)
+	}
+	return nil
+}
+
+func (m *yamlMapping) remove(key string) {
+	index := m.find(key)
+	if index < 0 {
+		return
+	}
+	keyOffset := m.doc.offset(m.entries[index].Key.GetToken())
+	start, end := m.doc.lineStart(keyOffset), m.entryEnd(index)
+	if len(bytes.TrimSpace(m.doc.data[start:keyOffset])) == 0 {
+		m.doc.replace(start, m.doc.nextLine(m.doc.contentEnd(start, end)), "")
+		return
+	}
+	// the entry is in the line of the dash of a sequence item, so the next one takes its place
+	if index+1 < len(m.entries) {
+		m.doc.replace(keyOffset, m.doc.offset(m.entries[index+1].Key.GetToken()), "")
+	} else {
+		m.doc.replace(keyOffset, m.doc.contentEnd(start, end), "{}")
+	}
+}
+
+func (m *yamlMapping) array(key string) configArray {
+	index := m.find(key)
+	if index < 0 {
+		return nil
+	}
+	sequence, ok := m.entries[index].Value.(*ast.SequenceNode)
+	if !ok || sequence.IsFlowStyle || len(sequence.Values) == 0 {
+		return nil
+	}
+	return &yamlSequence{doc: m.doc, items: sequence.Values, end: m.entryEnd(index)}
+}
+
+// yamlSequence is a block sequence of a yaml file
+type yamlSequence struct {
+	doc   *yamlDocument
+	items []ast.Node
+	// end is the offset of the end of the lines of the sequence.
+	end int
+}
+
+// itemEnd returns the offset of the end of the lines of the item with the given index
+func (s *yamlSequence) itemEnd(index int) int {
+	if index+1 < len(s.items) {
+		return s.doc.lineStart(s.doc.dash(s.items[index+1]))
+	}
+	return s.end
+}
+
+func (s *yamlSequence) object(index int) configObject {
+	entries, block := yamlEntries(s.items[index])
+	if !block || len(entries) == 0 {
+		return nil
+	}
+	return &yamlMapping{doc: s.doc, entries: entries, end: s.itemEnd(index)}
+}
+
+func (s *yamlSequence) remove(index int) {
+	start := s.doc.lineStart(s.doc.dash(s.items[index]))
+	s.doc.replace(start, s.doc.nextLine(s.doc.contentEnd(start, s.itemEnd(index))), "")
+}
+
+func (s *yamlSequence) append(value any) error {
+	last := s.doc.dash(s.items[len(s.items)-1])
+	start := s.doc.lineStart(last)
+	offset := s.doc.nextLine(s.doc.contentEnd(start, s.itemEnd(len(s.items)-1)))
+	text := yamlItem(value, last-start)
+	if offset == len(s.doc.data) && s.doc.data[offset-1] != '\n' {
+		text = "\n" + text
+	}
+	s.doc.insert(offset, text)
+	return nil
+}
+
+// yamlItem encodes the given string or struct as an item of a block sequence, with its dash in
+// the given indentation
+func yamlItem(value any, indent int) string {
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() != reflect.Struct {
+		return strings.Repeat(" ", indent) + "- " + yamlScalar(reflected.String(), 0) + "\n"
+	}
+	var result strings.Builder
+	for i := 0; i < reflected.NumField(); i++ {
+		name, options, _ := strings.Cut(reflected.Type().Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" || (reflected.Field(i).IsZero() && strings.Contains(options, "omitempty")) {
+			continue
+		}
+		prefix := "  "
+		if result.Len() == 0 {
+			prefix = "- "
+		}
+		result.WriteString(strings.Repeat(" ", indent) + prefix + yamlScalar(name, 0) + ": " + yamlScalar(reflected.Field(i).String(), 0) + "\n")
+	}
+	return result.String()
+}
+
+// yamlScalar encodes the given string as a single line scalar, in the style of the given quote,
+// or plain, and quoted only when needed, without it
+func yamlScalar(value string, quote byte) string {
+	if quote == '\'' && !strings.ContainsAny(value, "\r\n") {
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	if quote == 0 {
+		if data, err := yaml.Marshal(value); err == nil && !bytes.Contains(bytes.TrimSpace(data), []byte{'\n'}) {
+			return string(bytes.TrimSpace(data))
+		}
+	}
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+	return strings.TrimSpace(buffer.String())
+}
@@ -0,0 +1,35 @@
+//go:build windows
+
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package model
+
+import (
+	"github.com/chordflower/riconto/pkg/userdirs"
+)
+
+// userConfigHome returns the directory of the user configuration files
+func userConfigHome() (string, error) {
+	dirs, err := userdirs.GetUserDirs()
+	if err != nil {
+		return "", err
+	}
+	return dirs.ConfigHome(), nil
+}
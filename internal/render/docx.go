@@ -0,0 +1,853 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"io"
+	"maps"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/document"
+	"github.com/spf13/afero"
+)
+
+const (
+	// docxNamespaces are the namespaces of the parts with text, like the document and the footnotes.
+	docxNamespaces = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"` +
+		` xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"` +
+		` xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing"` +
+		` xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"` +
+		` xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture"`
+	// docxRelationships is the base of the types of the relationships between the parts.
+	docxRelationships = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/"
+	// docxCoreProperties is the type of the relationship to the core properties.
+	docxCoreProperties = "http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties"
+	// docxXmlHeader is the declaration at the start of every xml part.
+	docxXmlHeader = "<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"yes\"?>\n"
+	// docxEmu is the number of english metric units, used by the drawings, in a point.
+	docxEmu = 12700
+	// docxMaxLevels is the number of levels of the lists and of the table of contents styles.
+	docxMaxLevels = 9
+)
+
+// docxRelationship is a link from a part of the document to another part or to an external uri
+type docxRelationship struct {
+	id       string
+	kind     string
+	target   string
+	external bool
+}
+
+// docxPart is a part of the document with text, with its own relationships
+type docxPart struct {
+	builder       strings.Builder
+	relationships []docxRelationship
+	// images are the relationships of the embedded images, by their file name.
+	images map[string]string
+}
+
+// relationship adds a relationship of the given type to the part, returning its identifier
+func (p *docxPart) relationship(kind, target string, external bool) string {
+	id := "rId" + strconv.Itoa(len(p.relationships)+1)
+	p.relationships = append(p.relationships, docxRelationship{id: id, kind: kind, target: target, external: external})
+	return id
+}
+
+// relationshipsXml returns the relationships part of the part
+func (p *docxPart) relationshipsXml() string {
+	var builder strings.Builder
+	builder.WriteString(docxXmlHeader)
+	builder.WriteString("<Relationships xmlns=\"http://schemas.openxmlformats.org/package/2006/relationships\">\n")
+	for _, relationship := range p.relationships {
+		fmt.Fprintf(&builder, "<Relationship Id=\"%s\" Type=\"%s\" Target=\"%s\"", relationship.id, relationship.kind,
+			html.EscapeString(relationship.target))
+		if relationship.external {
+			builder.WriteString(" TargetMode=\"External\"")
+		}
+		builder.WriteString("/>\n")
+	}
+	builder.WriteString("</Relationships>\n")
+	return builder.String()
+}
+
+// docxList is a list instance of the numbering part, which restarts the numbering at its level
+type docxList struct {
+	ordered bool
+	level   int
+	start   int
+}
+
+// docxMedia is an image embedded in the document
+type docxMedia struct {
+	name string
+	data []byte
+}
+
+// docxRun is the formatting of a run of text
+type docxRun struct {
+	bold   bool
+	italic bool
+	code   bool
+	// style is the character style of the run.
+	style string
+}
+
+// docxParagraph is the formatting of a paragraph, besides the one given by the lists and quotes around it
+type docxParagraph struct {
+	style string
+	align string
+	// border draws a line below the paragraph.
+	border bool
+}
+
+// DocxRenderer renders documents as office open xml documents, the format of the word processors
+type DocxRenderer struct {
+	options *Options
+}
+
+// NewDocxRenderer creates a new docx renderer, with the given options
+func NewDocxRenderer(options *Options) *DocxRenderer {
+	return &DocxRenderer{options: options}
+}
+
+func (r *DocxRenderer) Name() string {
+	return "docx"
+}
+
+func (r *DocxRenderer) Output(output string) string {
+	return output + ".docx"
+}
+
+func (r *DocxRenderer) Render(doc *document.Document, fs afero.Fs, output string) error {
+	writer := newDocxWriter(r.options, doc, fs)
+	writer.blocks(doc.Blocks)
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"[Content_Types].xml", []byte(r.contentTypes())},
+		{"_rels/.rels", []byte(r.packageRelationships())},
+		{"docProps/core.xml", []byte(r.coreProperties(doc))},
+		{"docProps/app.xml", []byte(docxXmlHeader + "<Properties xmlns=\"http://schemas.openxmlformats.org/officeDocument/2006/extended-properties\">" +
+			"<Application>riconto</Application></Properties>\n")},
+		{"word/document.xml", []byte(writer.documentXml())},
+		{"word/_rels/document.xml.rels", []byte(writer.body.relationshipsXml())},
+		{"word/styles.xml", []byte(r.styles())},
+		{"word/numbering.xml", []byte(writer.numberingXml())},
+		{"word/footnotes.xml", []byte(writer.footnotesXml())},
+		{"word/_rels/footnotes.xml.rels", []byte(writer.notes.relationshipsXml())},
+		{"word/settings.xml", []byte(docxXmlHeader + "<w:settings " + docxNamespaces + ">" +
+			"<w:updateFields w:val=\"true\"/><w:defaultTabStop w:val=\"720\"/>" +
+			"<w:footnotePr><w:footnote w:id=\"-1\"/><w:footnote w:id=\"0\"/></w:footnotePr></w:settings>\n")},
+	}
+	if len(r.options.Metadata) > 0 {
+		files = append(files, struct {
+			name string
+			data []byte
+		}{"docProps/custom.xml", []byte(r.customProperties())})
+	}
+	for _, media := range writer.media {
+		files = append(files, struct {
+			name string
+			data []byte
+		}{"word/" + media.name, media.data})
+	}
+
+	file, err := createFile(fs, r.Output(output))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	archive := zip.NewWriter(file)
+	for _, entry := range files {
+		if err != nil {
+			break
+		}
+		var part io.Writer
+		if part, err = archive.Create(entry.name); err == nil {
+			_, err = part.Write(entry.data)
+		}
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write the docx file %s", r.Output(output))
+	}
+	return nil
+}
+
+// contentTypes returns the content types of the parts of the document
+func (r *DocxRenderer) contentTypes() string {
+	var builder strings.Builder
+	builder.WriteString(docxXmlHeader)
+	builder.WriteString("<Types xmlns=\"http://schemas.openxmlformats.org/package/2006/content-types\">\n")
+	builder.WriteString("<Default Extension=\"rels\" ContentType=\"application/vnd.openxmlformats-package.relationships+xml\"/>\n")
+	builder.WriteString("<Default Extension=\"xml\" ContentType=\"application/xml\"/>\n")
+	builder.WriteString("<Default Extension=\"png\" ContentType=\"image/png\"/>\n")
+	builder.WriteString("<Default Extension=\"jpeg\" ContentType=\"image/jpeg\"/>\n")
+	builder.WriteString("<Default Extension=\"gif\" ContentType=\"image/gif\"/>\n")
+	overrides := []struct {
+		part, kind string
+	}{
+		{"/word/document.xml", "application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"},
+		{"/word/styles.xml", "application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"},
+		{"/word/numbering.xml", "application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"},
+		{"/word/footnotes.xml", "application/vnd.openxmlformats-officedocument.wordprocessingml.footnotes+xml"},
+		{"/word/settings.xml", "application/vnd.openxmlformats-officedocument.wordprocessingml.settings+xml"},
+		{"/docProps/core.xml", "application/vnd.openxmlformats-package.core-properties+xml"},
+		{"/docProps/app.xml", "application/vnd.openxmlformats-officedocument.extended-properties+xml"},
+	}
+	if len(r.options.Metadata) > 0 {
+		overrides = append(overrides, struct {
+			part, kind string
+		}{"/docProps/custom.xml", "application/vnd.openxmlformats-officedocument.custom-properties+xml"})
+	}
+	for _, override := range overrides {
+		fmt.Fprintf(&builder, "<Override PartName=\"%s\" ContentType=\"%s\"/>\n", override.part, override.kind)
+	}
+	builder.WriteString("</Types>\n")
+	return builder.String()
+}
+
+// packageRelationships returns the relationships of the package, to the document and its properties
+func (r *DocxRenderer) packageRelationships() string {
+	part := &docxPart{}
+	part.relationship(docxRelationships+"officeDocument", "word/document.xml", false)
+	part.relationship(docxCoreProperties, "docProps/core.xml", false)
+	part.relationship(docxRelationships+"extended-properties", "docProps/app.xml", false)
+	if len(r.options.Metadata) > 0 {
+		part.relationship(docxRelationships+"custom-properties", "docProps/custom.xml", false)
+	}
+	return part.relationshipsXml()
+}
+
+// coreProperties returns the properties of the document, from its metadata
+func (r *DocxRenderer) coreProperties(doc *document.Document) string {
+	var builder strings.Builder
+	element := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&builder, "<%s>%s</%s>\n", name, html.EscapeString(value), name)
+		}
+	}
+	date := func(name string, value time.Time) {
+		if !value.IsZero() {
+			fmt.Fprintf(&builder, "<%s xsi:type=\"dcterms:W3CDTF\">%s</%s>\n", name, value.UTC().Format("2006-01-02T15:04:05Z"), name)
+		}
+	}
+	builder.WriteString(docxXmlHeader)
+	builder.WriteString("<cp:coreProperties xmlns:cp=\"http://schemas.openxmlformats.org/package/2006/metadata/core-properties\"" +
+		" xmlns:dc=\"http://purl.org/dc/elements/1.1/\" xmlns:dcterms=\"http://purl.org/dc/terms/\"" +
+		" xmlns:xsi=\"http://www.w3.org/2001/XMLSchema-instance\">\n")
+	if meta := doc.Meta; meta != nil {
+		element("dc:title", meta.Title)
+		if meta.Project != meta.Title {
+			element("dc:subject", meta.Project)
+		}
+		element("dc:creator", strings.Join(meta.AuthorNames(), "; "))
+		element("cp:keywords", strings.Join(meta.Tags, ", "))
+		element("dc:description", meta.Description)
+		element("dc:rights", strings.Join(meta.License, ", "))
+		element("cp:version", meta.Version)
+		date("dcterms:created", meta.Metadata.Created)
+		date("dcterms:modified", meta.Metadata.Modified)
+	}
+	element("dc:language", r.options.Language)
+	builder.WriteString("</cp:coreProperties>\n")
+	return builder.String()
+}
+
+// customProperties returns the properties with the extra metadata of the settings
+func (r *DocxRenderer) customProperties() string {
+	var builder strings.Builder
+	builder.WriteString(docxXmlHeader)
+	builder.WriteString("<Properties xmlns=\"http://schemas.openxmlformats.org/officeDocument/2006/custom-properties\"" +
+		" xmlns:vt=\"http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes\">\n")
+	for index, key := range slices.Sorted(maps.Keys(r.options.Metadata)) {
+		// the identifiers of the custom properties start at 2
+		fmt.Fprintf(&builder, "<property fmtid=\"{D5CDD505-2E9C-101B-9397-08002B2CF9AE}\" pid=\"%d\" name=\"%s\"><vt:lpwstr>%s</vt:lpwstr></property>\n",
+			index+2, html.EscapeString(key), html.EscapeString(r.options.Metadata[key]))
+	}
+	builder.WriteString("</Properties>\n")
+	return builder.String()
+}
+
+// styles returns the styles of the document, with the sizes and colors of the theme
+func (r *DocxRenderer) styles() string {
+	theme := r.options.Theme
+	indent := docxTwips(theme.Indent)
+	var builder strings.Builder
+	builder.WriteString(docxXmlHeader)
+	builder.WriteString("<w:styles " + docxNamespaces + ">\n")
+	builder.WriteString("<w:docDefaults><w:rPrDefault><w:rPr>")
+	fmt.Fprintf(&builder, "<w:rFonts w:ascii=\"%s\" w:hAnsi=\"%s\" w:eastAsia=\"%s\" w:cs=\"%s\"/>", officeFont, officeFont, officeFont, officeFont)
+	fmt.Fprintf(&builder, "<w:color w:val=\"%s\"/>%s", docxColor(theme.TextColor), docxSize(theme.FontSize))
+	if r.options.Language != "" {
+		fmt.Fprintf(&builder, "<w:lang w:val=\"%s\"/>", html.EscapeString(r.options.Language))
+	}
+	builder.WriteString("</w:rPr></w:rPrDefault><w:pPrDefault><w:pPr>")
+	fmt.Fprintf(&builder, "<w:spacing w:after=\"%d\" w:line=\"%d\" w:lineRule=\"auto\"/>",
+		docxTwips(theme.FontSize/2), int(math.Round(theme.Leading*240)))
+	builder.WriteString("</w:pPr></w:pPrDefault></w:docDefaults>\n")
+
+	style := func(kind, id, name, properties string) {
+		fmt.Fprintf(&builder, "<w:style w:type=\"%s\" w:styleId=\"%s\"><w:name w:val=\"%s\"/>%s</w:style>\n", kind, id, name, properties)
+	}
+	style("paragraph\" w:default=\"1", "Normal", "Normal", "<w:qFormat/>")
+	for level := 1; level <= 6; level++ {
+		size := theme.HeadingSize(level)
+		style("paragraph", "Heading"+strconv.Itoa(level), "heading "+strconv.Itoa(level), fmt.Sprintf(
+			"<w:basedOn w:val=\"Normal\"/><w:next w:val=\"Normal\"/><w:qFormat/>"+
+				"<w:pPr><w:keepNext/><w:keepLines/><w:spacing w:before=\"%d\" w:after=\"%d\" w:line=\"288\" w:lineRule=\"auto\"/>"+
+				"<w:outlineLvl w:val=\"%d\"/></w:pPr><w:rPr><w:b/><w:bCs/>%s</w:rPr>",
+			docxTwips(size), docxTwips(size/2), level-1, docxSize(size)))
+	}
+	style("paragraph", "Quote", "Quote", fmt.Sprintf("<w:basedOn w:val=\"Normal\"/><w:qFormat/>"+
+		"<w:pPr><w:pBdr><w:left w:val=\"single\" w:sz=\"16\" w:space=\"8\" w:color=\"%s\"/></w:pBdr></w:pPr><w:rPr><w:i/><w:iCs/></w:rPr>",
+		docxColor(theme.RuleColor)))
+	style("paragraph", "SourceCode", "Source Code", fmt.Sprintf("<w:basedOn w:val=\"Normal\"/>"+
+		"<w:pPr><w:shd w:val=\"clear\" w:color=\"auto\" w:fill=\"%s\"/><w:spacing w:line=\"240\" w:lineRule=\"auto\"/></w:pPr>"+
+		"<w:rPr><w:rFonts w:ascii=\"%s\" w:hAnsi=\"%s\" w:cs=\"%s\"/>%s</w:rPr>",
+		docxColor(theme.CodeBackground), officeMonoFont, officeMonoFont, officeMonoFont, docxSize(theme.FontSize-1.5)))
+	style("paragraph", "ListParagraph", "List Paragraph", "<w:basedOn w:val=\"Normal\"/><w:qFormat/><w:pPr><w:contextualSpacing/></w:pPr>")
+	style("paragraph", "FootnoteText", "footnote text", fmt.Sprintf("<w:basedOn w:val=\"Normal\"/>"+
+		"<w:pPr><w:spacing w:after=\"0\" w:line=\"240\" w:lineRule=\"auto\"/></w:pPr><w:rPr>%s</w:rPr>", docxSize(theme.FontSize*0.9)))
+	style("paragraph", "TOCHeading", "TOC Heading", "<w:basedOn w:val=\"Heading1\"/><w:next w:val=\"Normal\"/><w:pPr><w:outlineLvl w:val=\"9\"/></w:pPr>")
+	for level := 1; level <= docxMaxLevels; level++ {
+		style("paragraph", "TOC"+strconv.Itoa(level), "toc "+strconv.Itoa(level), fmt.Sprintf("<w:basedOn w:val=\"Normal\"/>"+
+			"<w:next w:val=\"Normal\"/><w:pPr><w:spacing w:after=\"%d\"/><w:ind w:left=\"%d\"/></w:pPr>",
+			docxTwips(theme.FontSize/4), (level-1)*indent))
+	}
+	style("character", "Hyperlink", "Hyperlink", fmt.Sprintf("<w:rPr><w:color w:val=\"%s\"/><w:u w:val=\"single\"/></w:rPr>",
+		docxColor(theme.LinkColor)))
+	style("character", "FootnoteReference", "footnote reference", "<w:rPr><w:vertAlign w:val=\"superscript\"/></w:rPr>")
+	style("table", "Table", "Table Grid", fmt.Sprintf("<w:tblPr><w:tblBorders>"+
+		"<w:top w:val=\"single\" w:sz=\"4\" w:space=\"0\" w:color=\"%[1]s\"/><w:left w:val=\"single\" w:sz=\"4\" w:space=\"0\" w:color=\"%[1]s\"/>"+
+		"<w:bottom w:val=\"single\" w:sz=\"4\" w:space=\"0\" w:color=\"%[1]s\"/><w:right w:val=\"single\" w:sz=\"4\" w:space=\"0\" w:color=\"%[1]s\"/>"+
+		"<w:insideH w:val=\"single\" w:sz=\"4\" w:space=\"0\" w:color=\"%[1]s\"/><w:insideV w:val=\"single\" w:sz=\"4\" w:space=\"0\" w:color=\"%[1]s\"/>"+
+		"</w:tblBorders><w:tblCellMar><w:left w:w=\"%[2]d\" w:type=\"dxa\"/><w:right w:w=\"%[2]d\" w:type=\"dxa\"/></w:tblCellMar></w:tblPr>",
+		docxColor(theme.RuleColor), docxTwips(theme.FontSize/2)))
+	builder.WriteString("</w:styles>\n")
+	return builder.String()
+}
+
+// docxTwips returns the given length in points as twentieths of a point
+func docxTwips(points float64) int {
+	return int(math.Round(points * 20))
+}
+
+// docxSize returns the run properties with the given font size in points
+func docxSize(points float64) string {
+	half := int(math.Round(points * 2))
+	return fmt.Sprintf("<w:sz w:val=\"%d\"/><w:szCs w:val=\"%d\"/>", half, half)
+}
+
+// docxColor returns the given color in the notation of the documents
+func docxColor(color Color) string {
+	return strings.ToUpper(strings.TrimPrefix(color.Hex(), "#"))
+}
+
+// docxWriter writes the blocks of a document as the body and the footnotes of a docx document
+type docxWriter struct {
+	options *Options
+	theme   *Theme
+	doc     *document.Document
+	fs      afero.Fs
+	body    *docxPart
+	notes   *docxPart
+	// part is the part being written, either the body or the footnotes.
+	part *docxPart
+	// source is the path of the file of the blocks being written.
+	source string
+	// level is the depth of the lists around the blocks being written.
+	level int
+	// quotes is the depth of the quotes around the blocks being written.
+	quotes int
+	// number is the list instance of the next paragraph, that starts a list item, or zero.
+	number int
+	// footnote tells if the blocks being written are in a footnote, and mark that the next paragraph
+	// starts it, with its reference mark.
+	footnote bool
+	mark     bool
+	// bookmarks are the names of the bookmarks of the headings, by their identifiers.
+	bookmarks map[string]string
+	starts    map[string]string
+	lists     []docxList
+	footnotes int
+	media     []docxMedia
+	// drawings is the number of images in the document, since each one has an unique identifier.
+	drawings int
+}
+
+func newDocxWriter(options *Options, doc *document.Document, fs afero.Fs) *docxWriter {
+	writer := &docxWriter{
+		options:   options,
+		theme:     options.Theme,
+		doc:       doc,
+		fs:        fs,
+		body:      &docxPart{images: make(map[string]string)},
+		notes:     &docxPart{images: make(map[string]string)},
+		source:    doc.Path,
+		bookmarks: make(map[string]string),
+		starts:    make(map[string]string),
+		lists:     make([]docxList, 0),
+		media:     make([]docxMedia, 0),
+	}
+	writer.part = writer.body
+	// the fixed parts come first in the relationships of the body
+	for _, kind := range []string{"styles", "numbering", "footnotes", "settings"} {
+		writer.body.relationship(docxRelationships+kind, kind+".xml", false)
+	}
+	for index, heading := range document.Headings(doc.Blocks) {
+		if _, ok := writer.bookmarks[heading.ID]; heading.ID != "" && !ok {
+			writer.bookmarks[heading.ID] = "heading_" + strconv.Itoa(index+1)
+		}
+	}
+	headingStarts(writer.starts, doc.Path, doc.Blocks)
+	return writer
+}
+
+// textWidth returns the width of the text area of the pages, in points
+func (w *docxWriter) textWidth() float64 {
+	return w.options.PageSize[0] - w.options.Margins.Left - w.options.Margins.Right
+}
+
+// documentXml returns the main part of the document, with the body and the page settings
+func (w *docxWriter) documentXml() string {
+	var builder strings.Builder
+	builder.WriteString(docxXmlHeader)
+	builder.WriteString("<w:document " + docxNamespaces + "><w:body>\n")
+	builder.WriteString(w.body.builder.String())
+	margins := w.options.Margins
+	fmt.Fprintf(&builder, "<w:sectPr><w:pgSz w:w=\"%d\" w:h=\"%d\"/>", docxTwips(w.options.PageSize[0]), docxTwips(w.options.PageSize[1]))
+	fmt.Fprintf(&builder, "<w:pgMar w:top=\"%d\" w:right=\"%d\" w:bottom=\"%d\" w:left=\"%d\" w:header=\"708\" w:footer=\"708\" w:gutter=\"0\"/>",
+		docxTwips(margins.Top), docxTwips(margins.Right), docxTwips(margins.Bottom), docxTwips(margins.Left))
+	builder.WriteString("</w:sectPr>\n</w:body></w:document>\n")
+	return builder.String()
+}
+
+// footnotesXml returns the footnotes part, starting with the separators used by the word processors
+func (w *docxWriter) footnotesXml() string {
+	var builder strings.Builder
+	builder.WriteString(docxXmlHeader)
+	builder.WriteString("<w:footnotes " + docxNamespaces + ">\n")
+	builder.WriteString("<w:footnote w:type=\"separator\" w:id=\"-1\"><w:p><w:pPr><w:spacing w:after=\"0\" w:line=\"240\" w:lineRule=\"auto\"/></w:pPr>" +
+		"<w:r><w:separator/></w:r></w:p></w:footnote>\n")
+	builder.WriteString("<w:footnote w:type=\"continuationSeparator\" w:id=\"0\"><w:p><w:pPr><w:spacing w:after=\"0\" w:line=\"240\" w:lineRule=\"auto\"/></w:pPr>" +
+		"<w:r><w:continuationSeparator/></w:r></w:p></w:footnote>\n")
+	builder.WriteString(w.notes.builder.String())
+	builder.WriteString("</w:footnotes>\n")
+	return builder.String()
+}
+
+// numberingXml returns the numbering part, with a bullet and a decimal list definition, and an
+// instance of them for each list, so that each one starts at its own number
+func (w *docxWriter) numberingXml() string {
+	indent := docxTwips(w.theme.Indent)
+	bullets := []string{"•", "◦", "▪"}
+	var builder strings.Builder
+	builder.WriteString(docxXmlHeader)
+	builder.WriteString("<w:numbering " + docxNamespaces + ">\n")
+	for abstract, ordered := range []bool{false, true} {
+		fmt.Fprintf(&builder, "<w:abstractNum w:abstractNumId=\"%d\"><w:multiLevelType w:val=\"multilevel\"/>", abstract)
+		for level := 0; level < docxMaxLevels; level++ {
+			format, text := "bullet", bullets[level%len(bullets)]
+			if ordered {
+				format, text = "decimal", "%"+strconv.Itoa(level+1)+"."
+			}
+			fmt.Fprintf(&builder, "<w:lvl w:ilvl=\"%d\"><w:start w:val=\"1\"/><w:numFmt w:val=\"%s\"/><w:lvlText w:val=\"%s\"/>"+
+				"<w:lvlJc w:val=\"left\"/><w:pPr><w:ind w:left=\"%d\" w:hanging=\"%d\"/></w:pPr></w:lvl>",
+				level, format, text, (level+1)*indent, indent)
+		}
+		builder.WriteString("</w:abstractNum>\n")
+	}
+	for index, list := range w.lists {
+		abstract := 0
+		if list.ordered {
+			abstract = 1
+		}
+		fmt.Fprintf(&builder, "<w:num w:numId=\"%d\"><w:abstractNumId w:val=\"%d\"/>", index+1, abstract)
+		fmt.Fprintf(&builder, "<w:lvlOverride w:ilvl=\"%d\"><w:startOverride w:val=\"%d\"/></w:lvlOverride></w:num>\n", list.level, list.start)
+	}
+	builder.WriteString("</w:numbering>\n")
+	return builder.String()
+}
+
+func (w *docxWriter) blocks(blocks []document.Block) {
+	for _, block := range blocks {
+		w.block(block)
+	}
+}
+
+func (w *docxWriter) block(block document.Block) {
+	switch value := block.(type) {
+	case *document.Include:
+		source := w.source
+		w.source = value.Path
+		w.blocks(value.Blocks)
+		w.source = source
+	case *document.TableOfContents:
+		w.contents(value)
+	case *document.Heading:
+		level := min(max(value.Level, 1), 6)
+		w.paragraph(docxParagraph{style: "Heading" + strconv.Itoa(level)}, func() {
+			name, ok := w.bookmarks[value.ID]
+			// the headings of the footnotes are not bookmarked, since the bookmarks are in the body
+			if ok && !w.footnote {
+				id := strings.TrimPrefix(name, "heading_")
+				fmt.Fprintf(&w.part.builder, "<w:bookmarkStart w:id=\"%s\" w:name=\"%s\"/>", id, name)
+				w.inlines(value.Content, docxRun{})
+				fmt.Fprintf(&w.part.builder, "<w:bookmarkEnd w:id=\"%s\"/>", id)
+				return
+			}
+			w.inlines(value.Content, docxRun{})
+		})
+	case *document.Paragraph:
+		w.paragraph(docxParagraph{}, func() {
+			w.inlines(value.Content, docxRun{})
+		})
+	case *document.List:
+		w.level++
+		w.lists = append(w.lists, docxList{ordered: value.Ordered, level: min(w.level, docxMaxLevels) - 1, start: max(value.Start, 1)})
+		list := len(w.lists)
+		for _, item := range value.Items {
+			w.number = list
+			if len(item.Blocks) == 0 {
+				w.paragraph(docxParagraph{}, func() {})
+			}
+			w.blocks(item.Blocks)
+			w.number = 0
+		}
+		w.level--
+	case *document.BlockQuote:
+		w.quotes++
+		w.blocks(value.Blocks)
+		w.quotes--
+	case *document.CodeBlock:
+		w.paragraph(docxParagraph{style: "SourceCode"}, func() {
+			text := strings.ReplaceAll(strings.TrimRight(value.Code, "\n"), "\t", "    ")
+			for index, line := range strings.Split(text, "\n") {
+				if index > 0 {
+					w.part.builder.WriteString("<w:r><w:br/></w:r>")
+				}
+				w.text(line, docxRun{})
+			}
+		})
+	case *document.ThematicBreak:
+		w.paragraph(docxParagraph{border: true}, func() {})
+	case *document.Table:
+		w.table(value)
+	}
+}
+
+// paragraph writes a paragraph with the given formatting, inside the lists and quotes being written,
+// where content writes its runs
+func (w *docxWriter) paragraph(paragraph docxParagraph, content func()) {
+	builder := &w.part.builder
+	style := paragraph.style
+	if style == "" {
+		switch {
+		case w.footnote:
+			style = "FootnoteText"
+		case w.quotes > 0:
+			style = "Quote"
+		case w.level > 0:
+			style = "ListParagraph"
+		}
+	}
+	properties := ""
+	if style != "" {
+		properties += fmt.Sprintf("<w:pStyle w:val=\"%s\"/>", style)
+	}
+	if w.number > 0 {
+		properties += fmt.Sprintf("<w:numPr><w:ilvl w:val=\"%d\"/><w:numId w:val=\"%d\"/></w:numPr>", w.lists[w.number-1].level, w.number)
+	}
+	if paragraph.border {
+		properties += fmt.Sprintf("<w:pBdr><w:bottom w:val=\"single\" w:sz=\"4\" w:space=\"1\" w:color=\"%s\"/></w:pBdr>", docxColor(w.theme.RuleColor))
+	}
+	if indent := docxTwips(float64(w.level+w.quotes) * w.theme.Indent); indent > 0 {
+		if w.number > 0 {
+			properties += fmt.Sprintf("<w:ind w:left=\"%d\" w:hanging=\"%d\"/>", indent, docxTwips(w.theme.Indent))
+		} else {
+			properties += fmt.Sprintf("<w:ind w:left=\"%d\"/>", indent)
+		}
+	}
+	if paragraph.align != "" {
+		properties += fmt.Sprintf("<w:jc w:val=\"%s\"/>", paragraph.align)
+	}
+	w.number = 0
+	builder.WriteString("<w:p>")
+	if properties != "" {
+		builder.WriteString("<w:pPr>" + properties + "</w:pPr>")
+	}
+	if w.mark {
+		builder.WriteString("<w:r><w:rPr><w:rStyle w:val=\"FootnoteReference\"/></w:rPr><w:footnoteRef/></w:r>")
+		w.text(" ", docxRun{})
+		w.mark = false
+	}
+	content()
+	builder.WriteString("</w:p>\n")
+}
+
+// contents writes a table of contents field, filled with the current headings, that the word
+// processors update with the page numbers
+func (w *docxWriter) contents(contents *document.TableOfContents) {
+	depth := min(max(contents.Depth, 1), docxMaxLevels)
+	builder := &w.part.builder
+	builder.WriteString("<w:sdt><w:sdtPr><w:docPartObj><w:docPartGallery w:val=\"Table of Contents\"/><w:docPartUnique/>" +
+		"</w:docPartObj></w:sdtPr><w:sdtContent>\n")
+	builder.WriteString("<w:p><w:r><w:fldChar w:fldCharType=\"begin\" w:dirty=\"true\"/></w:r>")
+	fmt.Fprintf(builder, "<w:r><w:instrText xml:space=\"preserve\"> TOC \\o \"1-%d\" \\h \\z \\u </w:instrText></w:r>", depth)
+	builder.WriteString("<w:r><w:fldChar w:fldCharType=\"separate\"/></w:r></w:p>\n")
+	w.contentsEntries(document.Contents(w.doc.Blocks, depth, contents.Numbered), 1)
+	builder.WriteString("<w:p><w:r><w:fldChar w:fldCharType=\"end\"/></w:r></w:p>\n")
+	builder.WriteString("</w:sdtContent></w:sdt>\n")
+}
+
+// contentsEntries writes the given table of contents entries, with the given level
+func (w *docxWriter) contentsEntries(entries []*document.ContentsEntry, level int) {
+	builder := &w.part.builder
+	for _, entry := range entries {
+		fmt.Fprintf(builder, "<w:p><w:pPr><w:pStyle w:val=\"TOC%d\"/></w:pPr>", min(level, docxMaxLevels))
+		if name, ok := w.bookmarks[entry.Heading.ID]; ok {
+			fmt.Fprintf(builder, "<w:hyperlink w:anchor=\"%s\" w:history=\"1\">", name)
+			w.text(entry.Title(), docxRun{})
+			builder.WriteString("</w:hyperlink>")
+		} else {
+			w.text(entry.Title(), docxRun{})
+		}
+		builder.WriteString("</w:p>\n")
+		w.contentsEntries(entry.Children, level+1)
+	}
+}
+
+// table writes a table with columns of equal width, where the header row is repeated in each page
+func (w *docxWriter) table(table *document.Table) {
+	columns := len(table.Header)
+	for _, row := range table.Rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return
+	}
+	builder := &w.part.builder
+	width := docxTwips(w.textWidth()-float64(w.level+w.quotes)*w.theme.Indent) / columns
+	builder.WriteString("<w:tbl><w:tblPr><w:tblStyle w:val=\"Table\"/>")
+	fmt.Fprintf(builder, "<w:tblW w:w=\"%d\" w:type=\"dxa\"/>", width*columns)
+	if indent := docxTwips(float64(w.level+w.quotes) * w.theme.Indent); indent > 0 {
+		fmt.Fprintf(builder, "<w:tblInd w:w=\"%d\" w:type=\"dxa\"/>", indent)
+	}
+	builder.WriteString("<w:tblLayout w:type=\"fixed\"/></w:tblPr><w:tblGrid>")
+	for i := 0; i < columns; i++ {
+		fmt.Fprintf(builder, "<w:gridCol w:w=\"%d\"/>", width)
+	}
+	builder.WriteString("</w:tblGrid>\n")
+	if len(table.Header) > 0 {
+		w.row(table.Header, table.Alignments, columns, width, true)
+	}
+	for _, row := range table.Rows {
+		w.row(row, table.Alignments, columns, width, false)
+	}
+	builder.WriteString("</w:tbl>\n")
+	// a paragraph separates the table from a following one, which would be merged with it
+	w.paragraph(docxParagraph{}, func() {})
+}
+
+// row writes a table row, filling the missing cells and aligning each cell as its column
+func (w *docxWriter) row(cells []*document.TableCell, alignments []string, columns, width int, header bool) {
+	builder := &w.part.builder
+	builder.WriteString("<w:tr>")
+	if header {
+		builder.WriteString("<w:trPr><w:tblHeader/></w:trPr>")
+	}
+	for i := 0; i < columns; i++ {
+		fmt.Fprintf(builder, "<w:tc><w:tcPr><w:tcW w:w=\"%d\" w:type=\"dxa\"/>", width)
+		if header {
+			fmt.Fprintf(builder, "<w:shd w:val=\"clear\" w:color=\"auto\" w:fill=\"%s\"/>", docxColor(w.theme.CodeBackground))
+		}
+		builder.WriteString("</w:tcPr><w:p><w:pPr><w:spacing w:before=\"60\" w:after=\"60\"/>")
+		if i < len(alignments) {
+			switch alignments[i] {
+			case document.AlignCenter:
+				builder.WriteString("<w:jc w:val=\"center\"/>")
+			case document.AlignRight:
+				builder.WriteString("<w:jc w:val=\"right\"/>")
+			}
+		}
+		builder.WriteString("</w:pPr>")
+		if i < len(cells) {
+			w.inlines(cells[i].Content, docxRun{bold: header})
+		}
+		builder.WriteString("</w:p></w:tc>")
+	}
+	builder.WriteString("</w:tr>\n")
+}
+
+// text writes a run with the given text and formatting
+func (w *docxWriter) text(text string, run docxRun) {
+	builder := &w.part.builder
+	builder.WriteString("<w:r>")
+	properties := ""
+	if run.style != "" {
+		properties += fmt.Sprintf("<w:rStyle w:val=\"%s\"/>", run.style)
+	}
+	if run.code {
+		properties += fmt.Sprintf("<w:rFonts w:ascii=\"%s\" w:hAnsi=\"%s\" w:cs=\"%s\"/>", officeMonoFont, officeMonoFont, officeMonoFont)
+	}
+	if run.bold {
+		properties += "<w:b/><w:bCs/>"
+	}
+	if run.italic {
+		properties += "<w:i/><w:iCs/>"
+	}
+	if properties != "" {
+		builder.WriteString("<w:rPr>" + properties + "</w:rPr>")
+	}
+	fmt.Fprintf(builder, "<w:t xml:space=\"preserve\">%s</w:t></w:r>", html.EscapeString(text))
+}
+
+func (w *docxWriter) inlines(inlines []document.Inline, run docxRun) {
+	builder := &w.part.builder
+	for _, inline := range inlines {
+		switch value := inline.(type) {
+		case *document.Text:
+			w.text(value.Value, run)
+		case *document.Break:
+			if value.Hard {
+				builder.WriteString("<w:r><w:br/></w:r>")
+			} else {
+				w.text(" ", run)
+			}
+		case *document.Emphasis:
+			inner := run
+			inner.bold = inner.bold || value.Level >= 2
+			inner.italic = inner.italic || value.Level == 1
+			w.inlines(value.Content, inner)
+		case *document.Code:
+			inner := run
+			inner.code = true
+			w.text(value.Value, inner)
+		case *document.Link:
+			w.link(value, run)
+		case *document.Image:
+			w.image(value, run)
+		case *document.Footnote:
+			w.addFootnote(value, run)
+		case *document.Citation:
+			w.text(value.Text(), run)
+		}
+	}
+}
+
+// link writes a link, to a bookmark when it points to an heading of the document
+func (w *docxWriter) link(link *document.Link, run docxRun) {
+	builder := &w.part.builder
+	inner := run
+	inner.style = "Hyperlink"
+	if id, ok := headingTarget(link.Destination, w.source, w.starts); ok {
+		if name, ok := w.bookmarks[id]; ok {
+			fmt.Fprintf(builder, "<w:hyperlink w:anchor=\"%s\" w:history=\"1\">", name)
+			w.inlines(link.Content, inner)
+			builder.WriteString("</w:hyperlink>")
+			return
+		}
+	}
+	if link.Destination == "" || strings.HasPrefix(link.Destination, "#") {
+		w.inlines(link.Content, run)
+		return
+	}
+	id := w.part.relationship(docxRelationships+"hyperlink", link.Destination, true)
+	fmt.Fprintf(builder, "<w:hyperlink r:id=\"%s\" w:history=\"1\">", id)
+	w.inlines(link.Content, inner)
+	builder.WriteString("</w:hyperlink>")
+}
+
+// image writes an image embedded in the document, reduced to the text width, or its description
+// when the image can not be embedded
+func (w *docxWriter) image(value *document.Image, run docxRun) {
+	embedded, ok := readImage(w.fs, value.Destination, w.source, w.textWidth())
+	if !ok {
+		description := run
+		description.italic = true
+		w.text("["+strings.TrimSpace(value.Alt)+"]", description)
+		return
+	}
+	id, ok := w.part.images[embedded.path]
+	if !ok {
+		name := fmt.Sprintf("media/image%d.%s", len(w.media)+1, embedded.format)
+		w.media = append(w.media, docxMedia{name: name, data: embedded.data})
+		id = w.part.relationship(docxRelationships+"image", name, false)
+		w.part.images[embedded.path] = id
+	}
+	cx, cy := int(math.Round(embedded.width*docxEmu)), int(math.Round(embedded.height*docxEmu))
+	w.drawings++
+	alt := html.EscapeString(value.Alt)
+	fmt.Fprintf(&w.part.builder, "<w:r><w:drawing><wp:inline distT=\"0\" distB=\"0\" distL=\"0\" distR=\"0\">"+
+		"<wp:extent cx=\"%[1]d\" cy=\"%[2]d\"/><wp:docPr id=\"%[3]d\" name=\"Picture %[3]d\" descr=\"%[4]s\"/>"+
+		"<wp:cNvGraphicFramePr><a:graphicFrameLocks noChangeAspect=\"1\"/></wp:cNvGraphicFramePr>"+
+		"<a:graphic><a:graphicData uri=\"http://schemas.openxmlformats.org/drawingml/2006/picture\"><pic:pic>"+
+		"<pic:nvPicPr><pic:cNvPr id=\"%[3]d\" name=\"%[5]s\" descr=\"%[4]s\"/><pic:cNvPicPr/></pic:nvPicPr>"+
+		"<pic:blipFill><a:blip r:embed=\"%[6]s\"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>"+
+		"<pic:spPr><a:xfrm><a:off x=\"0\" y=\"0\"/><a:ext cx=\"%[1]d\" cy=\"%[2]d\"/></a:xfrm><a:prstGeom prst=\"rect\"><a:avLst/></a:prstGeom></pic:spPr>"+
+		"</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r>",
+		cx, cy, w.drawings, alt, html.EscapeString(path.Base(embedded.path)), id)
+}
+
+// addFootnote writes the given footnote in the footnotes part, and its reference mark in the text,
+// where the footnotes inside footnotes are written as text, since they are not allowed there
+func (w *docxWriter) addFootnote(footnote *document.Footnote, run docxRun) {
+	if w.footnote {
+		text := make([]string, 0, len(footnote.Blocks))
+		for _, block := range footnote.Blocks {
+			if paragraph, ok := block.(*document.Paragraph); ok {
+				text = append(text, document.PlainText(paragraph.Content))
+			}
+		}
+		w.text(" ("+strings.Join(text, " ")+")", run)
+		return
+	}
+	w.footnotes++
+	id := w.footnotes
+	fmt.Fprintf(&w.part.builder, "<w:r><w:rPr><w:rStyle w:val=\"FootnoteReference\"/></w:rPr><w:footnoteReference w:id=\"%d\"/></w:r>", id)
+
+	part, level, quotes, number := w.part, w.level, w.quotes, w.number
+	w.part, w.level, w.quotes, w.number = w.notes, 0, 0, 0
+	w.footnote, w.mark = true, true
+	fmt.Fprintf(&w.part.builder, "<w:footnote w:id=\"%d\">", id)
+	w.blocks(footnote.Blocks)
+	if w.mark {
+		w.paragraph(docxParagraph{}, func() {})
+	}
+	w.part.builder.WriteString("</w:footnote>\n")
+	w.footnote = false
+	w.part, w.level, w.quotes, w.number = part, level, quotes, number
+}
@@ -0,0 +1,233 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/chordflower/riconto/internal/markdown"
+	"github.com/chordflower/riconto/internal/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+// docxRelationshipsPart is a relationships part of a docx package
+type docxRelationshipsPart struct {
+	Relationships []struct {
+		ID         string `xml:"Id,attr"`
+		Type       string `xml:"Type,attr"`
+		Target     string `xml:"Target,attr"`
+		TargetMode string `xml:"TargetMode,attr"`
+	} `xml:"Relationship"`
+}
+
+// xmlAttributes parses the given xml document, returning the values of the attributes with the
+// given local name of the elements with the given local name
+func xmlAttributes(data []byte, element, attribute string) []string {
+	result := make([]string, 0)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return result
+		}
+		So(err, ShouldBeNil)
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == element {
+			for _, attr := range start.Attr {
+				if attr.Name.Local == attribute {
+					result = append(result, attr.Value)
+				}
+			}
+		}
+	}
+}
+
+// validateDocx checks the structure of the given docx file, returning its files
+func validateDocx(data []byte) map[string][]byte {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	So(err, ShouldBeNil)
+	files := make(map[string][]byte)
+	for _, file := range reader.File {
+		content, err := file.Open()
+		So(err, ShouldBeNil)
+		files[file.Name], err = io.ReadAll(content)
+		So(err, ShouldBeNil)
+	}
+
+	// every part has a content type, and the xml parts are well formed
+	var types struct {
+		Defaults []struct {
+			Extension string `xml:"Extension,attr"`
+		} `xml:"Default"`
+		Overrides []struct {
+			PartName string `xml:"PartName,attr"`
+		} `xml:"Override"`
+	}
+	So(xml.Unmarshal(files["[Content_Types].xml"], &types), ShouldBeNil)
+	extensions := make(map[string]bool)
+	for _, value := range types.Defaults {
+		extensions[value.Extension] = true
+	}
+	overrides := make(map[string]bool)
+	for _, value := range types.Overrides {
+		_, ok := files[strings.TrimPrefix(value.PartName, "/")]
+		So(ok, ShouldBeTrue)
+		overrides[value.PartName] = true
+	}
+	for name, content := range files {
+		if name == "[Content_Types].xml" {
+			continue
+		}
+		So(overrides["/"+name] || extensions[strings.TrimPrefix(path.Ext(name), ".")], ShouldBeTrue)
+		if extension := path.Ext(name); extension == ".xml" || extension == ".rels" {
+			xmlAttributes(content, "", "")
+		}
+	}
+
+	// the relationships point to existing parts, and their identifiers are used by the text
+	relationships := func(name string) map[string]string {
+		rels := "_rels/.rels"
+		if name != "" {
+			rels = path.Join(path.Dir(name), "_rels", path.Base(name)+".rels")
+		}
+		part := &docxRelationshipsPart{}
+		So(xml.Unmarshal(files[rels], part), ShouldBeNil)
+		result := make(map[string]string)
+		for _, relationship := range part.Relationships {
+			So(result, ShouldNotContainKey, relationship.ID)
+			result[relationship.ID] = relationship.Type
+			if relationship.TargetMode != "External" {
+				_, ok := files[path.Join(path.Dir(name), relationship.Target)]
+				So(ok, ShouldBeTrue)
+			}
+		}
+		return result
+	}
+	So(relationships("")["rId1"], ShouldEndWith, "/officeDocument")
+	bookmarks := xmlAttributes(files["word/document.xml"], "bookmarkStart", "name")
+	numbers := xmlAttributes(files["word/numbering.xml"], "num", "numId")
+	notes := xmlAttributes(files["word/footnotes.xml"], "footnote", "id")
+	styles := xmlAttributes(files["word/styles.xml"], "style", "styleId")
+	for _, name := range []string{"word/document.xml", "word/footnotes.xml"} {
+		targets := relationships(name)
+		for _, id := range xmlAttributes(files[name], "hyperlink", "id") {
+			So(targets[id], ShouldEndWith, "/hyperlink")
+		}
+		for _, id := range xmlAttributes(files[name], "blip", "embed") {
+			So(targets[id], ShouldEndWith, "/image")
+		}
+		for _, anchor := range xmlAttributes(files[name], "hyperlink", "anchor") {
+			So(bookmarks, ShouldContain, anchor)
+		}
+		for _, id := range xmlAttributes(files[name], "numId", "val") {
+			So(numbers, ShouldContain, id)
+		}
+		for _, element := range []string{"pStyle", "rStyle", "tblStyle"} {
+			for _, style := range xmlAttributes(files[name], element, "val") {
+				So(styles, ShouldContain, style)
+			}
+		}
+	}
+	for _, id := range xmlAttributes(files["word/document.xml"], "footnoteReference", "id") {
+		So(notes, ShouldContain, id)
+	}
+	return files
+}
+
+func TestDocxRenderer(t *testing.T) {
+	Convey("#DocxRenderer", t, func() {
+		fs := afero.NewMemMapFs()
+		var logo bytes.Buffer
+		So(png.Encode(&logo, image.NewRGBA(image.Rect(0, 0, 40, 20))), ShouldBeNil)
+		So(afero.WriteFile(fs, "resources/logo.png", logo.Bytes(), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "src/main.md", []byte("---\ntitle: Book\n---\n# Book\n\n::toc{depth=2}\n\n"+
+			"See [the end](./two.md#the-end), [one](one.md) & ![a logo](../resources/logo.png) ![missing](missing.png).\n\n"+
+			"::include[./one.md]\n::include[./two.md]\n"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "src/one.md", []byte("# One\n\n3. three\n4. four\n   - nested\n\n> A *quote*.\n\n"+
+			"```go\nx := 1\ny := 2\n```\n\nA note[^note] on the [site](https://example.com).\n\n"+
+			"[^note]: See the [other site](https://example.org) and [two](two.md).\n"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "src/two.md", []byte("# Two\n\n## The end\n\n| Name | Size |\n|:-----|-----:|\n| **One** | 1 |\n| Two |\n"), 0o644), ShouldBeNil)
+		doc, _, err := markdown.NewParser(fs).ParseFile("src/main.md")
+		So(err, ShouldBeNil)
+		config := model.NewConfig("books", "1.2.0", "Some books")
+		config.Authors = []model.Author{{Name: "Someone"}, {Name: "Other"}}
+		doc.Meta.Merge(config, nil)
+		options := DefaultOptions()
+		options.Language = "pt-PT"
+		options.Metadata = map[string]string{"reviewer": "Legal & Co"}
+
+		Convey("It should write a valid document", func() {
+			renderer := NewDocxRenderer(options)
+			So(renderer.Output("dist/book"), ShouldEqual, "dist/book.docx")
+			So(renderer.Render(doc, fs, "dist/book"), ShouldBeNil)
+			data, err := afero.ReadFile(fs, "dist/book.docx")
+			So(err, ShouldBeNil)
+			files := validateDocx(data)
+
+			body := string(files["word/document.xml"])
+			So(body, ShouldContainSubstring, `<w:pStyle w:val="Heading1"/></w:pPr><w:bookmarkStart w:id="1" w:name="heading_1"/>`)
+			So(body, ShouldContainSubstring, `<w:instrText xml:space="preserve"> TOC \o "1-2" \h \z \u </w:instrText>`)
+			So(body, ShouldContainSubstring, `<w:pStyle w:val="TOC2"/></w:pPr><w:hyperlink w:anchor="heading_4" w:history="1">`)
+			So(body, ShouldContainSubstring, `<w:hyperlink w:anchor="heading_4" w:history="1"><w:r><w:rPr><w:rStyle w:val="Hyperlink"/></w:rPr><w:t xml:space="preserve">the end</w:t>`)
+			So(body, ShouldContainSubstring, `<w:hyperlink w:anchor="heading_2" w:history="1"><w:r><w:rPr><w:rStyle w:val="Hyperlink"/></w:rPr><w:t xml:space="preserve">one</w:t>`)
+			So(body, ShouldContainSubstring, `<wp:extent cx="381000" cy="190500"/>`)
+			So(body, ShouldContainSubstring, `<w:i/><w:iCs/></w:rPr><w:t xml:space="preserve">[missing]</w:t>`)
+			So(body, ShouldContainSubstring, `<w:pStyle w:val="ListParagraph"/><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr>`)
+			So(body, ShouldContainSubstring, `<w:pStyle w:val="ListParagraph"/><w:numPr><w:ilvl w:val="1"/><w:numId w:val="2"/></w:numPr>`)
+			So(body, ShouldContainSubstring, `<w:pStyle w:val="Quote"/>`)
+			So(body, ShouldContainSubstring, `<w:t xml:space="preserve">x := 1</w:t></w:r><w:r><w:br/></w:r>`)
+			So(body, ShouldContainSubstring, `<w:footnoteReference w:id="1"/>`)
+			So(body, ShouldContainSubstring, `<w:trPr><w:tblHeader/></w:trPr>`)
+			So(body, ShouldContainSubstring, `<w:jc w:val="right"/></w:pPr><w:r><w:t xml:space="preserve">1</w:t>`)
+			So(body, ShouldContainSubstring, `<w:b/><w:bCs/></w:rPr><w:t xml:space="preserve">One</w:t>`)
+			So(strings.Count(body, "<w:tc>"), ShouldEqual, 6)
+
+			numbering := string(files["word/numbering.xml"])
+			So(numbering, ShouldContainSubstring, `<w:num w:numId="1"><w:abstractNumId w:val="1"/><w:lvlOverride w:ilvl="0"><w:startOverride w:val="3"/>`)
+			So(numbering, ShouldContainSubstring, `<w:num w:numId="2"><w:abstractNumId w:val="0"/>`)
+
+			notes := string(files["word/footnotes.xml"])
+			So(notes, ShouldContainSubstring, `<w:footnote w:id="1"><w:p><w:pPr><w:pStyle w:val="FootnoteText"/></w:pPr><w:r><w:rPr><w:rStyle w:val="FootnoteReference"/></w:rPr><w:footnoteRef/></w:r>`)
+			So(notes, ShouldContainSubstring, `<w:hyperlink w:anchor="heading_3" w:history="1">`)
+			So(string(files["word/_rels/footnotes.xml.rels"]), ShouldContainSubstring, `Target="https://example.org" TargetMode="External"`)
+			So(string(files["word/_rels/document.xml.rels"]), ShouldContainSubstring, `Target="media/image1.png"`)
+			So(files["word/media/image1.png"], ShouldResemble, logo.Bytes())
+
+			styles := string(files["word/styles.xml"])
+			So(styles, ShouldContainSubstring, `<w:lang w:val="pt-PT"/>`)
+			So(styles, ShouldContainSubstring, `<w:name w:val="heading 1"/>`)
+			So(styles, ShouldContainSubstring, `<w:outlineLvl w:val="5"/>`)
+
+			core := string(files["docProps/core.xml"])
+			So(core, ShouldContainSubstring, "<dc:title>Book</dc:title>")
+			So(core, ShouldContainSubstring, "<dc:creator>Someone; Other</dc:creator>")
+			So(core, ShouldContainSubstring, "<cp:version>1.2.0</cp:version>")
+			So(string(files["docProps/custom.xml"]), ShouldContainSubstring, `name="reviewer"><vt:lpwstr>Legal &amp; Co</vt:lpwstr>`)
+		})
+	})
+}
@@ -0,0 +1,376 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"fmt"
+	"html"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/document"
+	"github.com/spf13/afero"
+)
+
+const (
+	// epubMimetype is the content of the first file of the epub archives.
+	epubMimetype = "application/epub+zip"
+	// epubDir is the directory of the archives with the package and the content documents.
+	epubDir = "OEBPS"
+	// epubPackage is the name of the package document, with the metadata and the manifest.
+	epubPackage = "content.opf"
+	// epubNav is the name of the navigation document.
+	epubNav = "nav.xhtml"
+	// epubLanguage is the language of the books without one in their settings.
+	epubLanguage = "en"
+	// epubContainer is the container file, which points to the package document.
+	epubContainer = `<?xml version="1.0" encoding="utf-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="` + epubDir + "/" + epubPackage + `" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`
+)
+
+// epubMediaTypes are the media types of the files embedded in the books, by their extension
+var epubMediaTypes = map[string]string{
+	".gif":   "image/gif",
+	".jpeg":  "image/jpeg",
+	".jpg":   "image/jpeg",
+	".png":   "image/png",
+	".svg":   "image/svg+xml",
+	".webp":  "image/webp",
+	".otf":   "font/otf",
+	".ttf":   "font/ttf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+// EpubRenderer renders documents as epub 3 books, with one content document per chapter
+type EpubRenderer struct {
+	options *Options
+}
+
+// NewEpubRenderer creates a new epub renderer, with the given options
+func NewEpubRenderer(options *Options) *EpubRenderer {
+	return &EpubRenderer{options: options}
+}
+
+func (r *EpubRenderer) Name() string {
+	return "epub"
+}
+
+func (r *EpubRenderer) Output(output string) string {
+	return output + ".epub"
+}
+
+// epubItem is a file of the book, listed in the manifest of the package
+type epubItem struct {
+	id         string
+	href       string
+	mediaType  string
+	properties string
+	data       []byte
+}
+
+func (r *EpubRenderer) Render(doc *document.Document, fs afero.Fs, output string) error {
+	chapters := document.Chapters(doc, r.options.Split)
+	site := newHtmlSite(r.options, doc, chapters, htmlPages(chapters, ".xhtml"), true)
+
+	items := make([]*epubItem, 0, len(chapters)+2)
+	items = append(items, &epubItem{id: "nav", href: epubNav, mediaType: "application/xhtml+xml",
+		properties: "nav", data: []byte(r.nav(site))})
+	for index := range chapters {
+		items = append(items, &epubItem{id: "chapter-" + strconv.Itoa(index+1), href: site.pages[index],
+			mediaType: "application/xhtml+xml", data: []byte(r.page(site, index))})
+	}
+	fonts, fontPaths, err := r.fonts(fs)
+	if err != nil {
+		return err
+	}
+	items = append(items, &epubItem{id: "style", href: htmlStyle, mediaType: "text/css",
+		data: []byte(htmlStyleSheet(r.options.Theme, fontPaths))})
+	items = append(items, fonts...)
+	resources, err := epubResources(fs)
+	if err != nil {
+		return err
+	}
+	items = append(items, resources...)
+
+	file, err := createFile(fs, r.Output(output))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	archive := zip.NewWriter(file)
+	// the mimetype must be the first file, without compression
+	writer, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err == nil {
+		_, err = writer.Write([]byte(epubMimetype))
+	}
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"META-INF/container.xml", []byte(epubContainer)},
+		{epubDir + "/" + epubPackage, []byte(r.opf(doc, site, items))},
+	}
+	for _, item := range items {
+		files = append(files, struct {
+			name string
+			data []byte
+		}{epubDir + "/" + item.href, item.data})
+	}
+	for _, entry := range files {
+		if err != nil {
+			break
+		}
+		if writer, err = archive.Create(entry.name); err == nil {
+			_, err = writer.Write(entry.data)
+		}
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write the epub file %s", r.Output(output))
+	}
+	return nil
+}
+
+// language returns the language of the book
+func (r *EpubRenderer) language() string {
+	if r.options.Language != "" {
+		return r.options.Language
+	}
+	return epubLanguage
+}
+
+// title returns the title of the book, from its metadata or its first chapter
+func (r *EpubRenderer) title(site *htmlSite) string {
+	if site.doc.Meta != nil && site.doc.Meta.Title != "" {
+		return site.doc.Meta.Title
+	}
+	return site.pageTitle(0)
+}
+
+// xhtml returns a content document with the given title and body
+func (r *EpubRenderer) xhtml(title, body string) string {
+	var builder strings.Builder
+	language := html.EscapeString(r.language())
+	builder.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<!DOCTYPE html>\n")
+	fmt.Fprintf(&builder, "<html xmlns=\"http://www.w3.org/1999/xhtml\" xmlns:epub=\"http://www.idpf.org/2007/ops\""+
+		" xml:lang=\"%s\" lang=\"%s\">\n", language, language)
+	builder.WriteString("<head>\n<meta charset=\"utf-8\"/>\n")
+	fmt.Fprintf(&builder, "<title>%s</title>\n", html.EscapeString(title))
+	fmt.Fprintf(&builder, "<link rel=\"stylesheet\" type=\"text/css\" href=\"%s\"/>\n", htmlStyle)
+	builder.WriteString("</head>\n<body>\n")
+	builder.WriteString(body)
+	builder.WriteString("</body>\n</html>\n")
+	return builder.String()
+}
+
+// page returns the content document of the chapter with the given index
+func (r *EpubRenderer) page(site *htmlSite, index int) string {
+	var builder strings.Builder
+	builder.WriteString("<section epub:type=\"chapter\">\n")
+	site.writeBody(&builder, index)
+	builder.WriteString("</section>\n")
+	return r.xhtml(site.pageHeading(index), builder.String())
+}
+
+// nav returns the navigation document, with the headings of the document, or the chapters when it has none
+func (r *EpubRenderer) nav(site *htmlSite) string {
+	title := r.title(site)
+	var builder strings.Builder
+	builder.WriteString("<nav epub:type=\"toc\" id=\"toc\">\n")
+	fmt.Fprintf(&builder, "<h1>%s</h1>\n", html.EscapeString(title))
+	if contents := document.Contents(site.doc.Blocks, 6, false); len(contents) > 0 {
+		site.writeContents(&builder, contents, -1, "ol")
+	} else {
+		builder.WriteString("<ol>\n")
+		for index := range site.chapters {
+			fmt.Fprintf(&builder, "<li><a href=\"%s\">%s</a></li>\n", site.pages[index], html.EscapeString(site.pageTitle(index)))
+		}
+		builder.WriteString("</ol>\n")
+	}
+	builder.WriteString("</nav>\n")
+	return r.xhtml(title, builder.String())
+}
+
+// opf returns the package document, with the metadata of the document and the given items,
+// where the chapters are read in the order of their pages
+func (r *EpubRenderer) opf(doc *document.Document, site *htmlSite, items []*epubItem) string {
+	var builder strings.Builder
+	element := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&builder, "<%s>%s</%s>\n", name, html.EscapeString(value), name)
+		}
+	}
+	builder.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n")
+	fmt.Fprintf(&builder, "<package xmlns=\"http://www.idpf.org/2007/opf\" version=\"3.0\" unique-identifier=\"book-id\" xml:lang=\"%s\">\n",
+		html.EscapeString(r.language()))
+	builder.WriteString("<metadata xmlns:dc=\"http://purl.org/dc/elements/1.1/\">\n")
+	fmt.Fprintf(&builder, "<dc:identifier id=\"book-id\">%s</dc:identifier>\n", epubIdentifier(doc))
+	element("dc:title", r.title(site))
+	element("dc:language", r.language())
+	modified := time.Now()
+	if meta := doc.Meta; meta != nil {
+		for _, author := range meta.AuthorNames() {
+			element("dc:creator", author)
+		}
+		element("dc:description", meta.Description)
+		for _, tag := range meta.Tags {
+			element("dc:subject", tag)
+		}
+		element("dc:rights", strings.Join(meta.License, ", "))
+		if !meta.Metadata.Published.IsZero() {
+			element("dc:date", meta.Metadata.Published.UTC().Format(time.RFC3339))
+		}
+		if meta.Project != "" && meta.Project != meta.Title {
+			fmt.Fprintf(&builder, "<meta property=\"dcterms:isPartOf\">%s</meta>\n", html.EscapeString(meta.Project))
+		}
+		if meta.Version != "" {
+			fmt.Fprintf(&builder, "<meta property=\"dcterms:hasVersion\">%s</meta>\n", html.EscapeString(meta.Version))
+		}
+		switch {
+		case !meta.Metadata.Modified.IsZero():
+			modified = meta.Metadata.Modified
+		case !meta.Metadata.Created.IsZero():
+			modified = meta.Metadata.Created
+		}
+	}
+	fmt.Fprintf(&builder, "<meta property=\"dcterms:modified\">%s</meta>\n", modified.UTC().Format("2006-01-02T15:04:05Z"))
+	for _, key := range slices.Sorted(maps.Keys(r.options.Metadata)) {
+		fmt.Fprintf(&builder, "<meta name=\"%s\" content=\"%s\"/>\n", html.EscapeString(key), html.EscapeString(r.options.Metadata[key]))
+	}
+	builder.WriteString("</metadata>\n<manifest>\n")
+	for _, item := range items {
+		fmt.Fprintf(&builder, "<item id=\"%s\" href=\"%s\" media-type=\"%s\"", item.id, html.EscapeString(item.href), item.mediaType)
+		if item.properties != "" {
+			fmt.Fprintf(&builder, " properties=\"%s\"", item.properties)
+		}
+		builder.WriteString("/>\n")
+	}
+	builder.WriteString("</manifest>\n<spine>\n")
+	for index := range site.chapters {
+		fmt.Fprintf(&builder, "<itemref idref=\"chapter-%d\"/>\n", index+1)
+	}
+	builder.WriteString("</spine>\n</package>\n")
+	return builder.String()
+}
+
+// fonts returns the items of the font files of the settings, and their paths in the book by the
+// name of their style
+func (r *EpubRenderer) fonts(fs afero.Fs) ([]*epubItem, map[string]string, error) {
+	items := make([]*epubItem, 0)
+	paths := make(map[string]string)
+	fonts := []struct {
+		key, path string
+	}{
+		{"regular", r.options.Fonts.Regular},
+		{"bold", r.options.Fonts.Bold},
+		{"italic", r.options.Fonts.Italic},
+		{"bold_italic", r.options.Fonts.BoldItalic},
+		{"mono", r.options.Fonts.Mono},
+	}
+	// hrefs are the paths in the book of the font files, which are only added once, and used
+	// tells which paths were taken, since different files can have the same name
+	hrefs := make(map[string]string)
+	used := make(map[string]bool)
+	for _, font := range fonts {
+		if font.path == "" {
+			continue
+		}
+		source := path.Clean(font.path)
+		if href, ok := hrefs[source]; ok {
+			paths[font.key] = href
+			continue
+		}
+		data, err := afero.ReadFile(fs, source)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "Unable to read the font %s", font.path)
+		}
+		ext := path.Ext(source)
+		base := strings.TrimSuffix(path.Base(source), ext)
+		href := path.Join(htmlFontsDir, base+ext)
+		for i := 1; used[href]; i++ {
+			href = path.Join(htmlFontsDir, base+"-"+strconv.Itoa(i)+ext)
+		}
+		used[href] = true
+		hrefs[source] = href
+		mediaType, ok := epubMediaTypes[strings.ToLower(ext)]
+		if !ok {
+			mediaType = "font/ttf"
+		}
+		paths[font.key] = href
+		items = append(items, &epubItem{id: "font-" + strconv.Itoa(len(items)+1), href: href, mediaType: mediaType, data: data})
+	}
+	return items, paths, nil
+}
+
+// epubResources returns the items of the images and fonts in the resources directory of the project
+func epubResources(fs afero.Fs) ([]*epubItem, error) {
+	items := make([]*epubItem, 0)
+	if _, err := fs.Stat(ResourcesDir); errors.Is(err, os.ErrNotExist) {
+		return items, nil
+	}
+	err := afero.Walk(fs, ResourcesDir, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrapf(err, "Unable to read the directory %s", ResourcesDir)
+		}
+		mediaType, ok := epubMediaTypes[strings.ToLower(filepath.Ext(filename))]
+		if info.IsDir() || !ok {
+			return nil
+		}
+		data, err := afero.ReadFile(fs, filename)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to read the resource %s", filename)
+		}
+		items = append(items, &epubItem{id: "resource-" + strconv.Itoa(len(items)+1), href: filepath.ToSlash(filename),
+			mediaType: mediaType, data: data})
+		return nil
+	})
+	return items, err
+}
+
+// epubIdentifier returns an uuid that identifies the book, made from its project, version and title
+func epubIdentifier(doc *document.Document) string {
+	name := doc.Path
+	if doc.Meta != nil {
+		name = strings.Join([]string{doc.Meta.Project, doc.Meta.Version, doc.Meta.Title}, "\x00")
+	}
+	sum := sha1.Sum([]byte(name))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,262 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/chordflower/riconto/internal/markdown"
+	"github.com/chordflower/riconto/internal/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+// epubPackageDocument is the part of the package document that is checked
+type epubPackageDocument struct {
+	UniqueIdentifier string `xml:"unique-identifier,attr"`
+	Version          string `xml:"version,attr"`
+	Identifiers      []struct {
+		ID    string `xml:"id,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"metadata>identifier"`
+	Titles    []string `xml:"metadata>title"`
+	Languages []string `xml:"metadata>language"`
+	Creators  []string `xml:"metadata>creator"`
+	Rights    []string `xml:"metadata>rights"`
+	Metas     []struct {
+		Property string `xml:"property,attr"`
+		Value    string `xml:",chardata"`
+	} `xml:"metadata>meta"`
+	Items []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	ItemRefs []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// xmlReferences parses the given xml document, returning its identifiers and the targets of its links and images
+func xmlReferences(data []byte) ([]string, []string, error) {
+	ids, references := make([]string, 0), make([]string, 0)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return ids, references, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			for _, attribute := range start.Attr {
+				switch {
+				case attribute.Name.Local == "id":
+					ids = append(ids, attribute.Value)
+				case start.Name.Local == "a" && attribute.Name.Local == "href",
+					start.Name.Local == "img" && attribute.Name.Local == "src":
+					references = append(references, attribute.Value)
+				}
+			}
+		}
+	}
+}
+
+// validateEpub checks the structure of the given epub file, returning its package document
+func validateEpub(data []byte) *epubPackageDocument {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	So(err, ShouldBeNil)
+	files := make(map[string][]byte)
+	for _, file := range reader.File {
+		content, err := file.Open()
+		So(err, ShouldBeNil)
+		files[file.Name], err = io.ReadAll(content)
+		So(err, ShouldBeNil)
+	}
+
+	// the mimetype is the first file, without compression
+	So(reader.File[0].Name, ShouldEqual, "mimetype")
+	So(reader.File[0].Method, ShouldEqual, zip.Store)
+	So(string(files["mimetype"]), ShouldEqual, "application/epub+zip")
+
+	// the container points to the package document
+	var container struct {
+		RootFiles []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	So(xml.Unmarshal(files["META-INF/container.xml"], &container), ShouldBeNil)
+	So(container.RootFiles, ShouldHaveLength, 1)
+	So(container.RootFiles[0].MediaType, ShouldEqual, "application/oebps-package+xml")
+	opfPath := container.RootFiles[0].FullPath
+	opf := &epubPackageDocument{}
+	So(xml.Unmarshal(files[opfPath], opf), ShouldBeNil)
+
+	// the metadata has the required elements
+	So(opf.Version, ShouldEqual, "3.0")
+	So(opf.Identifiers, ShouldHaveLength, 1)
+	So(opf.Identifiers[0].ID, ShouldEqual, opf.UniqueIdentifier)
+	So(opf.Titles, ShouldNotBeEmpty)
+	So(opf.Languages, ShouldNotBeEmpty)
+	So(slices.ContainsFunc(opf.Metas, func(meta struct {
+		Property string `xml:"property,attr"`
+		Value    string `xml:",chardata"`
+	}) bool {
+		return meta.Property == "dcterms:modified"
+	}), ShouldBeTrue)
+
+	// the manifest has every file of the book, with one navigation document
+	base := path.Dir(opfPath)
+	manifest := make(map[string]string)
+	navs := 0
+	for _, item := range opf.Items {
+		_, ok := files[path.Join(base, item.Href)]
+		So(ok, ShouldBeTrue)
+		So(manifest, ShouldNotContainKey, item.ID)
+		manifest[item.ID] = item.MediaType
+		if slices.Contains(strings.Fields(item.Properties), "nav") {
+			navs++
+		}
+	}
+	So(navs, ShouldEqual, 1)
+	So(len(opf.Items), ShouldEqual, len(files)-3)
+	So(opf.ItemRefs, ShouldNotBeEmpty)
+	for _, itemRef := range opf.ItemRefs {
+		So(manifest[itemRef.IDRef], ShouldEqual, "application/xhtml+xml")
+	}
+
+	// the content documents are well formed, and their links point to files and identifiers of the book
+	ids := make(map[string][]string)
+	references := make(map[string][]string)
+	for name, content := range files {
+		if path.Ext(name) != ".xhtml" {
+			continue
+		}
+		ids[name], references[name], err = xmlReferences(content)
+		So(err, ShouldBeNil)
+	}
+	for name, targets := range references {
+		for _, target := range targets {
+			parsed, err := url.Parse(target)
+			So(err, ShouldBeNil)
+			if parsed.Scheme != "" {
+				continue
+			}
+			file := name
+			if parsed.Path != "" {
+				file = path.Join(path.Dir(name), parsed.Path)
+			}
+			_, ok := files[file]
+			So(ok, ShouldBeTrue)
+			if parsed.Fragment != "" {
+				So(ids[file], ShouldContain, parsed.Fragment)
+			}
+		}
+	}
+	return opf
+}
+
+func TestEpubRenderer(t *testing.T) {
+	Convey("#EpubRenderer", t, func() {
+		fs := afero.NewMemMapFs()
+		So(afero.WriteFile(fs, "src/main.md", []byte("---\ntitle: Book\n---\n# Book\n\n::toc\n\nSee [the end](./two.md#the-end) & "+
+			"![a logo](../resources/images/logo.png).\n\n::include[./one.md]\n::include[./two.md]\n"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "src/one.md", []byte("# One\n\nLine  \nbreak\n\n---\n\nGo to [two](two.md)[^two].\n\n[^two]: The [end](two.md#the-end).\n"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "src/two.md", []byte("# Two\n\n## The end\n\nBack to [one](./one.md).\n\n| Page | Size |\n|------|-----:|\n| One | 1 |\n"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "resources/images/logo.png", []byte("png"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "resources/notes.txt", []byte("notes"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "fonts/Serif.ttf", []byte("ttf"), 0o644), ShouldBeNil)
+		doc, _, err := markdown.NewParser(fs).ParseFile("src/main.md")
+		So(err, ShouldBeNil)
+		config := model.NewConfig("books", "1.2.0", "Some books")
+		config.License = []string{"CC-BY-4.0"}
+		config.Authors = []model.Author{{Name: "Someone"}}
+		doc.Meta.Merge(config, nil)
+		options := DefaultOptions()
+		options.Language = "pt-PT"
+		options.Fonts.Regular = "fonts/Serif.ttf"
+
+		Convey("It should write a valid book with a chapter per include", func() {
+			renderer := NewEpubRenderer(options)
+			So(renderer.Render(doc, fs, "dist/book"), ShouldBeNil)
+			data, err := afero.ReadFile(fs, "dist/book.epub")
+			So(err, ShouldBeNil)
+			opf := validateEpub(data)
+			So(opf.Titles, ShouldResemble, []string{"Book"})
+			So(opf.Languages, ShouldResemble, []string{"pt-PT"})
+			So(opf.Creators, ShouldResemble, []string{"Someone"})
+			So(opf.Rights, ShouldResemble, []string{"CC-BY-4.0"})
+			So(opf.ItemRefs, ShouldHaveLength, 3)
+			hrefs := make([]string, 0)
+			for _, item := range opf.Items {
+				hrefs = append(hrefs, item.Href)
+			}
+			So(hrefs, ShouldResemble, []string{"nav.xhtml", "index.xhtml", "one.xhtml", "two.xhtml", "style.css",
+				"fonts/Serif.ttf", "resources/images/logo.png"})
+		})
+
+		Convey("It should add each font file once, with a name of its own", func() {
+			So(afero.WriteFile(fs, "fonts/regular/Font.ttf", []byte("regular"), 0o644), ShouldBeNil)
+			So(afero.WriteFile(fs, "fonts/bold/Font.ttf", []byte("bold"), 0o644), ShouldBeNil)
+			options.Fonts.Regular = "fonts/regular/Font.ttf"
+			options.Fonts.Bold = "fonts/bold/Font.ttf"
+			options.Fonts.Italic = "./fonts/regular/Font.ttf"
+			items, paths, err := NewEpubRenderer(options).fonts(fs)
+			So(err, ShouldBeNil)
+			So(items, ShouldHaveLength, 2)
+			So(items[0].href, ShouldEqual, "fonts/Font.ttf")
+			So(string(items[0].data), ShouldEqual, "regular")
+			So(items[1].href, ShouldEqual, "fonts/Font-1.ttf")
+			So(string(items[1].data), ShouldEqual, "bold")
+			So(paths, ShouldResemble, map[string]string{"regular": "fonts/Font.ttf", "bold": "fonts/Font-1.ttf", "italic": "fonts/Font.ttf"})
+		})
+
+		Convey("It should write a valid book with a chapter per heading", func() {
+			options.Split = "headings"
+			renderer := NewEpubRenderer(options)
+			So(renderer.Render(doc, fs, "dist/book"), ShouldBeNil)
+			data, err := afero.ReadFile(fs, "dist/book.epub")
+			So(err, ShouldBeNil)
+			opf := validateEpub(data)
+			So(opf.ItemRefs, ShouldHaveLength, 3)
+		})
+
+		Convey("It should write a valid book in a single chapter", func() {
+			options.Split = "none"
+			renderer := NewEpubRenderer(options)
+			So(renderer.Render(doc, fs, "dist/book"), ShouldBeNil)
+			data, err := afero.ReadFile(fs, "dist/book.epub")
+			So(err, ShouldBeNil)
+			opf := validateEpub(data)
+			So(opf.ItemRefs, ShouldHaveLength, 1)
+		})
+	})
+}
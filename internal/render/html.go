@@ -0,0 +1,670 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/document"
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/spf13/afero"
+)
+
+const (
+	// ResourcesDir is the directory of the project with the resources used by the documents, like images.
+	ResourcesDir = "resources"
+	// htmlIndex is the name of the first page of the sites.
+	htmlIndex = "index"
+	// htmlStyle is the name of the style sheet of the sites.
+	htmlStyle = "style.css"
+	// htmlFontsDir is the directory of the sites where the fonts are copied.
+	htmlFontsDir = "fonts"
+)
+
+// HtmlRenderer renders documents as html sites, with one page per chapter
+type HtmlRenderer struct {
+	options *Options
+}
+
+// NewHtmlRenderer creates a new html renderer, with the given options
+func NewHtmlRenderer(options *Options) *HtmlRenderer {
+	return &HtmlRenderer{options: options}
+}
+
+func (r *HtmlRenderer) Name() string {
+	return "html"
+}
+
+// Output returns the directory of the site, which is the output path itself
+func (r *HtmlRenderer) Output(output string) string {
+	return output
+}
+
+func (r *HtmlRenderer) Render(doc *document.Document, fs afero.Fs, output string) error {
+	chapters := document.Chapters(doc, r.options.Split)
+	site := newHtmlSite(r.options, doc, chapters, htmlPages(chapters, ".html"), false)
+	for index := range chapters {
+		if err := site.writePage(fs, output, index); err != nil {
+			return err
+		}
+	}
+	fonts, err := copyFonts(fs, &r.options.Fonts, path.Join(output, htmlFontsDir))
+	if err != nil {
+		return err
+	}
+	if err = writeFile(fs, path.Join(output, htmlStyle), []byte(htmlStyleSheet(r.options.Theme, fonts))); err != nil {
+		return err
+	}
+	return copyDir(fs, ResourcesDir, path.Join(output, ResourcesDir))
+}
+
+// htmlPages returns the file names of the pages of the given chapters, with the given extension,
+// where the first one is the index
+func htmlPages(chapters []*document.Chapter, extension string) []string {
+	result := make([]string, 0, len(chapters))
+	used := map[string]bool{htmlIndex: true}
+	for index, chapter := range chapters {
+		if index == 0 {
+			result = append(result, htmlIndex+extension)
+			continue
+		}
+		name := chapter.Name
+		for i := 1; used[name]; i++ {
+			name = chapter.Name + "-" + strconv.Itoa(i)
+		}
+		used[name] = true
+		result = append(result, name+extension)
+	}
+	return result
+}
+
+// htmlSite writes the pages of a document
+type htmlSite struct {
+	options  *Options
+	doc      *document.Document
+	chapters []*document.Chapter
+	// pages are the file names of the pages of each chapter.
+	pages []string
+	// anchors are the chapters of each heading identifier.
+	anchors map[string]int
+	// starts are the identifiers of the first heading of each file.
+	starts map[string]string
+	// xhtml tells if the pages are xml documents, where the empty elements are closed.
+	xhtml bool
+	// notes is the number of footnotes written so far, since they are numbered across the pages.
+	notes int
+}
+
+// newHtmlSite creates the site of the given document, with the given chapters and the file names of their pages
+func newHtmlSite(options *Options, doc *document.Document, chapters []*document.Chapter, pages []string, xhtml bool) *htmlSite {
+	site := &htmlSite{
+		options:  options,
+		doc:      doc,
+		chapters: chapters,
+		pages:    pages,
+		anchors:  document.AnchorChapters(chapters),
+		starts:   make(map[string]string),
+		xhtml:    xhtml,
+	}
+	headingStarts(site.starts, doc.Path, doc.Blocks)
+	return site
+}
+
+// writePage writes the page of the chapter with the given index in the site directory
+func (s *htmlSite) writePage(fs afero.Fs, dir string, index int) error {
+	var builder strings.Builder
+	title := s.pageHeading(index)
+	builder.WriteString("<!DOCTYPE html>\n")
+	if s.options.Language != "" {
+		fmt.Fprintf(&builder, "<html lang=\"%s\">\n", html.EscapeString(s.options.Language))
+	} else {
+		builder.WriteString("<html>\n")
+	}
+	builder.WriteString("<head>\n<meta charset=\"utf-8\">\n")
+	builder.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(&builder, "<title>%s</title>\n", html.EscapeString(title))
+	s.writeMeta(&builder)
+	fmt.Fprintf(&builder, "<link rel=\"stylesheet\" href=\"%s\">\n", htmlStyle)
+	builder.WriteString("</head>\n<body>\n")
+	if contents := document.Contents(s.doc.Blocks, 6, false); len(contents) > 0 {
+		builder.WriteString("<nav class=\"navigation\">\n")
+		s.writeContents(&builder, contents, index, "ul")
+		builder.WriteString("</nav>\n")
+	}
+	builder.WriteString("<main>\n")
+	s.writeBody(&builder, index)
+	builder.WriteString("</main>\n")
+	if len(s.chapters) > 1 {
+		builder.WriteString("<nav class=\"pages\">\n")
+		if index > 0 {
+			fmt.Fprintf(&builder, "<a rel=\"prev\" href=\"%s\">%s</a>\n", s.pages[index-1], html.EscapeString(s.pageTitle(index-1)))
+		}
+		if index < len(s.chapters)-1 {
+			fmt.Fprintf(&builder, "<a rel=\"next\" href=\"%s\">%s</a>\n", s.pages[index+1], html.EscapeString(s.pageTitle(index+1)))
+		}
+		builder.WriteString("</nav>\n")
+	}
+	builder.WriteString("</body>\n</html>\n")
+	return writeFile(fs, path.Join(dir, s.pages[index]), []byte(builder.String()))
+}
+
+// pageHeading returns the title of the page of the chapter with the given index, with the title of the document
+func (s *htmlSite) pageHeading(index int) string {
+	title, chapter := "", s.chapters[index].Title
+	if s.doc.Meta != nil {
+		title = s.doc.Meta.Title
+	}
+	switch {
+	case title == "":
+		return chapter
+	case chapter != "" && chapter != title:
+		return chapter + " - " + title
+	}
+	return title
+}
+
+// writeBody writes the blocks of the chapter with the given index
+func (s *htmlSite) writeBody(builder *strings.Builder, index int) {
+	writer := &htmlWriter{site: s, builder: builder, source: s.chapters[index].Path, page: index}
+	writer.blocks(s.chapters[index].Blocks)
+	writer.writeNotes()
+}
+
+// pageTitle returns the title of the chapter with the given index, or its name when it has none
+func (s *htmlSite) pageTitle(index int) string {
+	if s.chapters[index].Title != "" {
+		return s.chapters[index].Title
+	}
+	return s.chapters[index].Name
+}
+
+// writeMeta writes the meta elements with the metadata of the document
+func (s *htmlSite) writeMeta(builder *strings.Builder) {
+	meta := func(name, content string) {
+		if content != "" {
+			fmt.Fprintf(builder, "<meta name=\"%s\" content=\"%s\">\n", html.EscapeString(name), html.EscapeString(content))
+		}
+	}
+	if s.doc.Meta != nil {
+		meta("author", strings.Join(s.doc.Meta.AuthorNames(), ", "))
+		meta("description", s.doc.Meta.Description)
+		meta("keywords", strings.Join(s.doc.Meta.Tags, ", "))
+	}
+	meta("generator", "riconto")
+	for _, key := range slices.Sorted(maps.Keys(s.options.Metadata)) {
+		meta(key, s.options.Metadata[key])
+	}
+}
+
+// writeContents writes the given table of contents entries as nested lists of links, from the given
+// page, with the given list element
+func (s *htmlSite) writeContents(builder *strings.Builder, entries []*document.ContentsEntry, page int, list string) {
+	fmt.Fprintf(builder, "<%s>\n", list)
+	for _, entry := range entries {
+		builder.WriteString("<li>")
+		title := html.EscapeString(entry.Title())
+		if entry.Heading.ID != "" {
+			fmt.Fprintf(builder, "<a href=\"%s\">%s</a>", html.EscapeString(s.anchor(entry.Heading.ID, page)), title)
+		} else {
+			fmt.Fprintf(builder, "<span>%s</span>", title)
+		}
+		if len(entry.Children) > 0 {
+			builder.WriteString("\n")
+			s.writeContents(builder, entry.Children, page, list)
+		}
+		builder.WriteString("</li>\n")
+	}
+	fmt.Fprintf(builder, "</%s>\n", list)
+}
+
+// anchor returns the link to the heading with the given identifier, from the given page
+func (s *htmlSite) anchor(id string, page int) string {
+	target, ok := s.anchors[id]
+	if !ok || target == page {
+		return "#" + id
+	}
+	return s.pages[target] + "#" + id
+}
+
+// destination returns the destination of a link in the given source file, where the links to
+// the included markdown files and their headings are changed to the pages where they are
+func (s *htmlSite) destination(destination, source string, page int) string {
+	target, err := url.Parse(destination)
+	if err != nil || target.Scheme != "" || target.Host != "" || path.IsAbs(target.Path) {
+		return destination
+	}
+	if target.Path == "" {
+		if target.Fragment == "" {
+			return destination
+		}
+		return s.anchor(target.Fragment, page)
+	}
+	if path.Ext(target.Path) != ".md" {
+		return destination
+	}
+	filename := path.Join(path.Dir(source), target.Path)
+	chapter := document.ChapterOf(s.chapters, filename)
+	if chapter < 0 {
+		return destination
+	}
+	if target.Fragment != "" {
+		return s.anchor(target.Fragment, page)
+	}
+	if chapter != page && s.chapters[chapter].Path == filename {
+		return s.pages[chapter]
+	}
+	if id, ok := s.starts[filename]; ok {
+		return s.anchor(id, page)
+	}
+	return s.pages[chapter]
+}
+
+// htmlWriter writes the blocks of a page
+type htmlWriter struct {
+	site    *htmlSite
+	builder *strings.Builder
+	// source is the path of the file of the blocks being written.
+	source string
+	page   int
+	// notes are the footnotes referenced in the page, written after its blocks.
+	notes []htmlNote
+}
+
+// htmlNote represents a footnote referenced in a page, with its number and the file where it is
+type htmlNote struct {
+	number   int
+	source   string
+	footnote *document.Footnote
+}
+
+func (w *htmlWriter) blocks(blocks []document.Block) {
+	for _, block := range blocks {
+		w.block(block, false)
+	}
+}
+
+// block writes the given block, where the paragraphs of tight lists are written without their element
+func (w *htmlWriter) block(block document.Block, tight bool) {
+	switch value := block.(type) {
+	case *document.Include:
+		source := w.source
+		w.source = value.Path
+		w.blocks(value.Blocks)
+		w.source = source
+	case *document.TableOfContents:
+		entries := document.Contents(w.site.doc.Blocks, value.Depth, value.Numbered)
+		if len(entries) > 0 {
+			w.builder.WriteString("<nav class=\"contents\">\n")
+			w.site.writeContents(w.builder, entries, w.page, "ul")
+			w.builder.WriteString("</nav>\n")
+		}
+	case *document.Heading:
+		level := min(max(value.Level, 1), 6)
+		if value.ID != "" {
+			fmt.Fprintf(w.builder, "<h%d id=\"%s\">", level, html.EscapeString(value.ID))
+		} else {
+			fmt.Fprintf(w.builder, "<h%d>", level)
+		}
+		w.inlines(value.Content)
+		fmt.Fprintf(w.builder, "</h%d>\n", level)
+	case *document.Paragraph:
+		if tight {
+			w.inlines(value.Content)
+			return
+		}
+		w.builder.WriteString("<p>")
+		w.inlines(value.Content)
+		w.builder.WriteString("</p>\n")
+	case *document.List:
+		switch {
+		case !value.Ordered:
+			w.builder.WriteString("<ul>\n")
+		case value.Start != 1:
+			fmt.Fprintf(w.builder, "<ol start=\"%d\">\n", value.Start)
+		default:
+			w.builder.WriteString("<ol>\n")
+		}
+		for _, item := range value.Items {
+			w.builder.WriteString("<li>")
+			for _, child := range item.Blocks {
+				w.block(child, value.Tight)
+			}
+			w.builder.WriteString("</li>\n")
+		}
+		if value.Ordered {
+			w.builder.WriteString("</ol>\n")
+		} else {
+			w.builder.WriteString("</ul>\n")
+		}
+	case *document.BlockQuote:
+		w.builder.WriteString("<blockquote>\n")
+		w.blocks(value.Blocks)
+		w.builder.WriteString("</blockquote>\n")
+	case *document.CodeBlock:
+		if value.Language != "" {
+			fmt.Fprintf(w.builder, "<pre><code class=\"language-%s\">", html.EscapeString(value.Language))
+		} else {
+			w.builder.WriteString("<pre><code>")
+		}
+		w.builder.WriteString(html.EscapeString(value.Code))
+		w.builder.WriteString("</code></pre>\n")
+	case *document.ThematicBreak:
+		w.builder.WriteString(w.empty("hr") + "\n")
+	case *document.Table:
+		w.builder.WriteString("<table>\n")
+		if len(value.Header) > 0 {
+			w.builder.WriteString("<thead>\n")
+			w.row(value.Header, value.Alignments, "th")
+			w.builder.WriteString("</thead>\n")
+		}
+		if len(value.Rows) > 0 {
+			w.builder.WriteString("<tbody>\n")
+			for _, row := range value.Rows {
+				w.row(row, value.Alignments, "td")
+			}
+			w.builder.WriteString("</tbody>\n")
+		}
+		w.builder.WriteString("</table>\n")
+	}
+}
+
+// row writes a table row, with the given cell element, aligning each cell as its column
+func (w *htmlWriter) row(cells []*document.TableCell, alignments []string, element string) {
+	w.builder.WriteString("<tr>")
+	for i, cell := range cells {
+		if i < len(alignments) && alignments[i] != document.AlignNone {
+			fmt.Fprintf(w.builder, "<%s style=\"text-align: %s\">", element, alignments[i])
+		} else {
+			fmt.Fprintf(w.builder, "<%s>", element)
+		}
+		w.inlines(cell.Content)
+		fmt.Fprintf(w.builder, "</%s>", element)
+	}
+	w.builder.WriteString("</tr>\n")
+}
+
+// writeNotes writes the footnotes referenced in the page, including the ones referenced by other notes
+func (w *htmlWriter) writeNotes() {
+	if len(w.notes) == 0 {
+		return
+	}
+	kind := func(value string) string {
+		if w.site.xhtml {
+			return " epub:type=\"" + value + "\""
+		}
+		return ""
+	}
+	fmt.Fprintf(w.builder, "<section class=\"footnotes\"%s>\n", kind("footnotes"))
+	fmt.Fprintf(w.builder, "<ol start=\"%d\">\n", w.notes[0].number)
+	for i := 0; i < len(w.notes); i++ {
+		note := w.notes[i]
+		fmt.Fprintf(w.builder, "<li id=\"footnote-%d\"%s>\n", note.number, kind("footnote"))
+		source := w.source
+		w.source = note.source
+		w.blocks(note.footnote.Blocks)
+		w.source = source
+		fmt.Fprintf(w.builder, "<a class=\"footnote-back\" href=\"#footnote-ref-%d\">&#8617;</a>\n", note.number)
+		w.builder.WriteString("</li>\n")
+	}
+	w.builder.WriteString("</ol>\n</section>\n")
+	w.notes = w.notes[:0]
+}
+
+func (w *htmlWriter) inlines(inlines []document.Inline) {
+	for _, inline := range inlines {
+		switch value := inline.(type) {
+		case *document.Text:
+			w.builder.WriteString(html.EscapeString(value.Value))
+		case *document.Break:
+			if value.Hard {
+				w.builder.WriteString(w.empty("br") + "\n")
+			} else {
+				w.builder.WriteString("\n")
+			}
+		case *document.Emphasis:
+			element := "em"
+			if value.Level >= 2 {
+				element = "strong"
+			}
+			fmt.Fprintf(w.builder, "<%s>", element)
+			w.inlines(value.Content)
+			fmt.Fprintf(w.builder, "</%s>", element)
+		case *document.Code:
+			fmt.Fprintf(w.builder, "<code>%s</code>", html.EscapeString(value.Value))
+		case *document.Link:
+			fmt.Fprintf(w.builder, "<a href=\"%s\"", html.EscapeString(w.site.destination(value.Destination, w.source, w.page)))
+			if value.Title != "" {
+				fmt.Fprintf(w.builder, " title=\"%s\"", html.EscapeString(value.Title))
+			}
+			w.builder.WriteString(">")
+			w.inlines(value.Content)
+			w.builder.WriteString("</a>")
+		case *document.Image:
+			attributes := fmt.Sprintf(" src=\"%s\" alt=\"%s\"", html.EscapeString(resourcePath(value.Destination, w.source)),
+				html.EscapeString(value.Alt))
+			if value.Title != "" {
+				attributes += fmt.Sprintf(" title=\"%s\"", html.EscapeString(value.Title))
+			}
+			w.builder.WriteString(w.empty("img" + attributes))
+		case *document.Footnote:
+			w.site.notes++
+			number := w.site.notes
+			w.notes = append(w.notes, htmlNote{number: number, source: w.source, footnote: value})
+			kind := ""
+			if w.site.xhtml {
+				kind = " epub:type=\"noteref\""
+			}
+			fmt.Fprintf(w.builder, "<sup><a class=\"footnote-ref\" id=\"footnote-ref-%d\" href=\"#footnote-%d\"%s>%d</a></sup>",
+				number, number, kind, number)
+		case *document.Citation:
+			fmt.Fprintf(w.builder, "<cite>%s</cite>", html.EscapeString(value.Text()))
+		}
+	}
+}
+
+// empty returns the given element, with its attributes, as an empty element
+func (w *htmlWriter) empty(element string) string {
+	if w.site.xhtml {
+		return "<" + element + "/>"
+	}
+	return "<" + element + ">"
+}
+
+// resourcePath returns the path of a file used in the given source file, like an image, relative
+// to the project directory when it is in the resources directory, or the given destination otherwise
+func resourcePath(destination, source string) string {
+	target, err := url.Parse(destination)
+	if err != nil || target.Scheme != "" || target.Host != "" || target.Path == "" || path.IsAbs(target.Path) {
+		return destination
+	}
+	resolved := path.Join(path.Dir(source), target.Path)
+	if !strings.HasPrefix(resolved, ResourcesDir+"/") {
+		return destination
+	}
+	return resolved
+}
+
+// htmlStyleSheet returns the style sheet of the sites with the given theme, using the given fonts,
+// which are the paths of the copied font files by their style
+func htmlStyleSheet(theme *Theme, fonts map[string]string) string {
+	var builder strings.Builder
+	family, mono := "sans-serif", "monospace"
+	faces := []struct {
+		key, family, weight, style string
+	}{
+		{"regular", "riconto", "normal", "normal"},
+		{"bold", "riconto", "bold", "normal"},
+		{"italic", "riconto", "normal", "italic"},
+		{"bold_italic", "riconto", "bold", "italic"},
+		{"mono", "riconto-mono", "normal", "normal"},
+	}
+	for _, face := range faces {
+		file, ok := fonts[face.key]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&builder, "@font-face { font-family: \"%s\"; src: url(\"%s\"); font-weight: %s; font-style: %s; }\n",
+			face.family, file, face.weight, face.style)
+		if face.key == "mono" {
+			mono = "\"riconto-mono\", monospace"
+		} else {
+			family = "\"riconto\", sans-serif"
+		}
+	}
+	fmt.Fprintf(&builder, "body { font-family: %s; font-size: %spt; line-height: %s; color: %s; "+
+		"max-width: 48em; margin: 0 auto; padding: 2em; }\n",
+		family, formatCss(theme.FontSize), formatCss(theme.Leading), theme.TextColor.Hex())
+	fmt.Fprintf(&builder, "a { color: %s; }\n", theme.LinkColor.Hex())
+	for level := 1; level <= 6; level++ {
+		fmt.Fprintf(&builder, "h%d { font-size: %spt; line-height: 1.2; }\n", level, formatCss(theme.HeadingSize(level)))
+	}
+	fmt.Fprintf(&builder, "code, pre { font-family: %s; }\n", mono)
+	fmt.Fprintf(&builder, "pre { background: %s; padding: %spt; overflow-x: auto; line-height: 1.3; }\n",
+		theme.CodeBackground.Hex(), formatCss(theme.FontSize/2))
+	fmt.Fprintf(&builder, "blockquote { margin-left: 0; padding-left: %spt; border-left: 2pt solid %s; }\n",
+		formatCss(theme.Indent), theme.RuleColor.Hex())
+	fmt.Fprintf(&builder, "hr { border: 0; border-top: 0.5pt solid %s; }\n", theme.RuleColor.Hex())
+	fmt.Fprintf(&builder, "ul, ol { padding-left: %spt; }\n", formatCss(theme.Indent))
+	builder.WriteString("img { max-width: 100%; }\n")
+	builder.WriteString("table { border-collapse: collapse; margin: 1em 0; }\n")
+	fmt.Fprintf(&builder, "th, td { border: 0.5pt solid %s; padding: 0.25em 0.5em; }\n", theme.RuleColor.Hex())
+	fmt.Fprintf(&builder, "th { background: %s; }\n", theme.CodeBackground.Hex())
+	fmt.Fprintf(&builder, "section.footnotes { border-top: 0.5pt solid %s; margin-top: 2em; font-size: 0.9em; }\n", theme.RuleColor.Hex())
+	builder.WriteString("a.footnote-back { text-decoration: none; }\n")
+	builder.WriteString("nav ul { list-style: none; }\n")
+	fmt.Fprintf(&builder, "nav.navigation, nav.pages { border-bottom: 0.5pt solid %s; margin-bottom: 1em; }\n", theme.RuleColor.Hex())
+	builder.WriteString("nav.pages { border-bottom: 0; display: flex; justify-content: space-between; }\n")
+	return builder.String()
+}
+
+// formatCss formats a number for the style sheets, without trailing zeros
+func formatCss(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// copyFonts copies the given font files to the given directory, returning their paths relative
+// to the parent of the directory, by the name of their style
+func copyFonts(fs afero.Fs, files *model.Fonts, dir string) (map[string]string, error) {
+	result := make(map[string]string)
+	fonts := []struct {
+		key, path string
+	}{
+		{"regular", files.Regular},
+		{"bold", files.Bold},
+		{"italic", files.Italic},
+		{"bold_italic", files.BoldItalic},
+		{"mono", files.Mono},
+	}
+	// names are the names of the copied font files, which are only copied once, and used tells
+	// which names were taken, since different files can have the same name
+	names := make(map[string]string)
+	used := make(map[string]bool)
+	for _, font := range fonts {
+		if font.path == "" {
+			continue
+		}
+		source := path.Clean(font.path)
+		name, ok := names[source]
+		if !ok {
+			ext := path.Ext(source)
+			base := strings.TrimSuffix(path.Base(source), ext)
+			name = base + ext
+			for i := 1; used[name]; i++ {
+				name = base + "-" + strconv.Itoa(i) + ext
+			}
+			used[name] = true
+			names[source] = name
+			if err := copyFile(fs, source, path.Join(dir, name)); err != nil {
+				return nil, errors.Wrapf(err, "Unable to copy the font %s", font.path)
+			}
+		}
+		result[font.key] = path.Join(path.Base(dir), name)
+	}
+	return result, nil
+}
+
+// writeFile writes the given data to a file, creating it and its parent directories
+func writeFile(fs afero.Fs, filename string, data []byte) error {
+	file, err := createFile(fs, filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	if _, err = file.Write(data); err != nil {
+		return errors.Wrapf(err, "Unable to write the file %s", filename)
+	}
+	return nil
+}
+
+// copyFile copies the given file to another one, creating it and its parent directories
+func copyFile(fs afero.Fs, from, to string) error {
+	source, err := fs.Open(from)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to read the file %s", from)
+	}
+	defer func() {
+		_ = source.Close()
+	}()
+	target, err := createFile(fs, to)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = target.Close()
+	}()
+	if _, err = io.Copy(target, source); err != nil {
+		return errors.Wrapf(err, "Unable to copy the file %s to %s", from, to)
+	}
+	return nil
+}
+
+// copyDir copies the files of the given directory, and of its subdirectories, to another one,
+// doing nothing when it does not exist
+func copyDir(fs afero.Fs, from, to string) error {
+	if _, err := fs.Stat(from); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return afero.Walk(fs, from, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrapf(err, "Unable to read the directory %s", from)
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relative, err := filepath.Rel(from, filename)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to read the directory %s", from)
+		}
+		return copyFile(fs, filename, path.Join(to, filepath.ToSlash(relative)))
+	})
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"testing"
+
+	"github.com/chordflower/riconto/internal/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+func TestCopyFonts(t *testing.T) {
+	Convey("#copyFonts", t, func() {
+		fs := afero.NewMemMapFs()
+		So(afero.WriteFile(fs, "fonts/regular/Font.ttf", []byte("regular"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "fonts/bold/Font.ttf", []byte("bold"), 0o644), ShouldBeNil)
+
+		Convey("It should copy each font file once, with a name of its own", func() {
+			paths, err := copyFonts(fs, &model.Fonts{
+				Regular: "fonts/regular/Font.ttf",
+				Bold:    "fonts/bold/Font.ttf",
+				Italic:  "./fonts/regular/Font.ttf",
+			}, "dist/site/fonts")
+			So(err, ShouldBeNil)
+			So(paths, ShouldResemble, map[string]string{"regular": "fonts/Font.ttf", "bold": "fonts/Font-1.ttf", "italic": "fonts/Font.ttf"})
+			content, err := afero.ReadFile(fs, "dist/site/fonts/Font.ttf")
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "regular")
+			content, err = afero.ReadFile(fs, "dist/site/fonts/Font-1.ttf")
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "bold")
+		})
+	})
+}
@@ -0,0 +1,566 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/document"
+	"github.com/spf13/afero"
+)
+
+const (
+	// latexFiguresDir is the directory of the projects where the images are copied.
+	latexFiguresDir = "figures"
+	// latexMaxLevel is the deepest heading level of the article class.
+	latexMaxLevel = 5
+)
+
+// latexSections are the sectioning commands of the heading levels, where the sixth level uses
+// the command of the fifth, since there is no deeper one
+var latexSections = []string{"section", "subsection", "subsubsection", "paragraph", "subparagraph", "subparagraph"}
+
+// latexLanguages are the babel names of the languages, by their primary subtag
+var latexLanguages = map[string]string{
+	"de": "ngerman",
+	"en": "english",
+	"es": "spanish",
+	"fr": "french",
+	"it": "italian",
+	"nl": "dutch",
+	"pt": "portuguese",
+}
+
+// latexEscapes are the replacements of the characters that are special in the text
+var latexEscapes = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`#`, `\#`,
+	`$`, `\$`,
+	`%`, `\%`,
+	`&`, `\&`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+// LatexRenderer renders documents as LaTeX projects, with a main file, a file for each included
+// markdown file, the images in a figures directory and the bibliography when there are citations
+type LatexRenderer struct {
+	options *Options
+}
+
+// NewLatexRenderer creates a new LaTeX renderer, with the given options
+func NewLatexRenderer(options *Options) *LatexRenderer {
+	return &LatexRenderer{options: options}
+}
+
+func (r *LatexRenderer) Name() string {
+	return "latex"
+}
+
+// Output returns the directory of the project, which is the output path with a latex suffix, so
+// that it does not clash with the html sites
+func (r *LatexRenderer) Output(output string) string {
+	return output + "-latex"
+}
+
+func (r *LatexRenderer) Render(doc *document.Document, fs afero.Fs, output string) error {
+	dir := r.Output(output)
+	main := path.Base(output) + ".tex"
+	writer := newLatexWriter(r.options, doc, fs, main)
+	if doc.Meta != nil && doc.Meta.Bibliography != "" {
+		filename := path.Join(path.Dir(doc.Path), doc.Meta.Bibliography)
+		data, err := afero.ReadFile(fs, filename)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to read the bibliography %s", filename)
+		}
+		writer.bibliography = &latexFile{name: strings.TrimSuffix(path.Base(filename), path.Ext(filename)) + ".bib", data: data}
+	}
+	writer.blocks(doc.Blocks)
+
+	var builder strings.Builder
+	builder.WriteString(r.preamble(doc, writer))
+	builder.WriteString("\\begin{document}\n\n")
+	builder.WriteString(writer.builder.String())
+	if writer.cited {
+		fmt.Fprintf(&builder, "\n\\bibliographystyle{plain}\n\\bibliography{%s}\n",
+			strings.TrimSuffix(writer.bibliography.name, ".bib"))
+	}
+	builder.WriteString("\n\\end{document}\n")
+
+	files := append([]latexFile{{name: main, data: []byte(builder.String())}}, writer.files...)
+	files = append(files, writer.figures...)
+	if writer.cited {
+		files = append(files, *writer.bibliography)
+	}
+	for _, file := range files {
+		if err := writeFile(fs, path.Join(dir, file.name), file.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// preamble returns the start of the main file, with the page geometry, the colors of the theme,
+// the language and the metadata of the document
+func (r *LatexRenderer) preamble(doc *document.Document, writer *latexWriter) string {
+	theme := r.options.Theme
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%% Generated by riconto from %s\n", doc.Path)
+	size := min(max(int(math.Round(theme.FontSize)), 10), 12)
+	fmt.Fprintf(&builder, "\\documentclass[%dpt]{article}\n", size)
+	builder.WriteString("\\usepackage[utf8]{inputenc}\n\\usepackage[T1]{fontenc}\n\\usepackage{lmodern}\n")
+	margins := r.options.Margins
+	fmt.Fprintf(&builder, "\\usepackage[paperwidth=%spt,paperheight=%spt,top=%spt,right=%spt,bottom=%spt,left=%spt]{geometry}\n",
+		formatNumber(r.options.PageSize[0]), formatNumber(r.options.PageSize[1]),
+		formatNumber(margins.Top), formatNumber(margins.Right), formatNumber(margins.Bottom), formatNumber(margins.Left))
+	language := strings.ToLower(strings.Split(r.options.Language, "-")[0])
+	if name, ok := latexLanguages[language]; ok {
+		fmt.Fprintf(&builder, "\\usepackage[%s]{babel}\n", name)
+	}
+	builder.WriteString("\\usepackage{xcolor}\n\\usepackage{graphicx}\n\\usepackage{longtable}\n\\usepackage{hyperref}\n")
+	for _, color := range []struct {
+		name  string
+		value Color
+	}{{"text", theme.TextColor}, {"link", theme.LinkColor}, {"rule", theme.RuleColor}} {
+		fmt.Fprintf(&builder, "\\definecolor{%s}{HTML}{%s}\n", color.name, strings.ToUpper(strings.TrimPrefix(color.value.Hex(), "#")))
+	}
+	fmt.Fprintf(&builder, "\\setcounter{secnumdepth}{%d}\n", writer.numbered)
+	builder.WriteString("\\setlength{\\parindent}{0pt}\n\\setlength{\\parskip}{0.5\\baselineskip}\n")
+
+	info := make(map[string]string)
+	maps.Copy(info, r.options.Metadata)
+	hyperref := []string{"colorlinks=true", "linkcolor=link", "urlcolor=link", "citecolor=link"}
+	if meta := doc.Meta; meta != nil {
+		authors := meta.AuthorNames()
+		fmt.Fprintf(&builder, "\\title{%s}\n", latexText(meta.Title))
+		escaped := make([]string, 0, len(authors))
+		for _, author := range authors {
+			escaped = append(escaped, latexText(author))
+		}
+		fmt.Fprintf(&builder, "\\author{%s}\n", strings.Join(escaped, " \\and "))
+		date := meta.Metadata.Published
+		if date.IsZero() {
+			date = meta.Metadata.Created
+		}
+		if !date.IsZero() {
+			fmt.Fprintf(&builder, "\\date{%s}\n", date.Format("2006-01-02"))
+		}
+		hyperref = append(hyperref, "pdftitle={"+latexText(meta.Title)+"}", "pdfauthor={"+strings.Join(escaped, ", ")+"}")
+		if meta.Description != "" {
+			hyperref = append(hyperref, "pdfsubject={"+latexText(meta.Description)+"}")
+		}
+		if len(meta.Tags) > 0 {
+			hyperref = append(hyperref, "pdfkeywords={"+latexText(strings.Join(meta.Tags, ", "))+"}")
+		}
+		if meta.Version != "" {
+			info["Version"] = meta.Version
+		}
+		if len(meta.License) > 0 {
+			info["License"] = strings.Join(meta.License, ", ")
+		}
+	}
+	if r.options.Language != "" {
+		hyperref = append(hyperref, "pdflang={"+latexText(r.options.Language)+"}")
+	}
+	values := make([]string, 0, len(info))
+	for _, key := range slices.Sorted(maps.Keys(info)) {
+		name := latexName(key)
+		if name != "" && info[key] != "" {
+			values = append(values, name+"={"+latexText(info[key])+"}")
+		}
+	}
+	if len(values) > 0 {
+		hyperref = append(hyperref, "pdfinfo={"+strings.Join(values, ",")+"}")
+	}
+	fmt.Fprintf(&builder, "\\hypersetup{%s}\n", strings.Join(hyperref, ",\n  "))
+	builder.WriteString("\\AtBeginDocument{\\color{text}}\n\n")
+	return builder.String()
+}
+
+// latexFile is a file of the LaTeX projects
+type latexFile struct {
+	// name is the path of the file, relative to the directory of the project.
+	name string
+	data []byte
+}
+
+// latexWriter writes the blocks of a document as LaTeX
+type latexWriter struct {
+	options *Options
+	doc     *document.Document
+	fs      afero.Fs
+	// builder is where the blocks of the file being written are written.
+	builder *strings.Builder
+	// source is the path of the markdown file of the blocks being written.
+	source string
+	// files are the files of the included markdown files, and names are their names, by their path.
+	files []latexFile
+	names map[string]string
+	// used are the names of the files of the project.
+	used    map[string]bool
+	figures []latexFile
+	// images are the names of the copied images, by their path.
+	images map[string]string
+	// bibliography is the BibTeX file of the document, when it has one, and cited tells if it was used.
+	bibliography *latexFile
+	cited        bool
+	// numbered is the deepest heading level that is numbered.
+	numbered int
+	// enumerations is the number of numbered lists around the blocks being written.
+	enumerations int
+	// footnote tells if the blocks being written are in a footnote.
+	footnote bool
+	headings map[string]bool
+	starts   map[string]string
+}
+
+func newLatexWriter(options *Options, doc *document.Document, fs afero.Fs, main string) *latexWriter {
+	writer := &latexWriter{
+		options:  options,
+		doc:      doc,
+		fs:       fs,
+		builder:  &strings.Builder{},
+		source:   doc.Path,
+		files:    make([]latexFile, 0),
+		names:    make(map[string]string),
+		used:     map[string]bool{main: true},
+		figures:  make([]latexFile, 0),
+		images:   make(map[string]string),
+		headings: make(map[string]bool),
+		starts:   make(map[string]string),
+	}
+	for _, heading := range document.Headings(doc.Blocks) {
+		if heading.ID != "" {
+			writer.headings[heading.ID] = true
+		}
+	}
+	headingStarts(writer.starts, doc.Path, doc.Blocks)
+	writer.numbered = latexNumbered(doc.Blocks)
+	return writer
+}
+
+// latexNumbered returns the depth of the numbered tables of contents in the given blocks, or zero
+// when the headings are not numbered
+func latexNumbered(blocks []document.Block) int {
+	result := 0
+	for _, block := range blocks {
+		switch value := block.(type) {
+		case *document.TableOfContents:
+			if value.Numbered {
+				result = max(result, min(value.Depth, latexMaxLevel))
+			}
+		case *document.Include:
+			result = max(result, latexNumbered(value.Blocks))
+		}
+	}
+	return result
+}
+
+func (w *latexWriter) blocks(blocks []document.Block) {
+	for index, block := range blocks {
+		if index > 0 {
+			w.builder.WriteString("\n")
+		}
+		w.block(block)
+	}
+}
+
+func (w *latexWriter) block(block document.Block) {
+	switch value := block.(type) {
+	case *document.Include:
+		w.include(value)
+	case *document.TableOfContents:
+		fmt.Fprintf(w.builder, "\\setcounter{tocdepth}{%d}\n\\tableofcontents\n", min(value.Depth, latexMaxLevel))
+	case *document.Heading:
+		level := min(max(value.Level, 1), len(latexSections))
+		fmt.Fprintf(w.builder, "\\%s{", latexSections[level-1])
+		w.inlines(value.Content)
+		w.builder.WriteString("}")
+		if value.ID != "" && !w.footnote {
+			fmt.Fprintf(w.builder, "\\label{%s}", value.ID)
+		}
+		w.builder.WriteString("\n")
+	case *document.Paragraph:
+		w.inlines(value.Content)
+		w.builder.WriteString("\n")
+	case *document.List:
+		environment := "itemize"
+		if value.Ordered {
+			environment = "enumerate"
+			w.enumerations++
+		}
+		fmt.Fprintf(w.builder, "\\begin{%s}\n", environment)
+		if value.Ordered && value.Start != 1 && w.enumerations <= 4 {
+			fmt.Fprintf(w.builder, "\\setcounter{enum%s}{%d}\n", strings.Repeat("i", w.enumerations), value.Start-1)
+		}
+		for _, item := range value.Items {
+			w.builder.WriteString("\\item ")
+			w.blocks(item.Blocks)
+			if len(item.Blocks) == 0 {
+				w.builder.WriteString("\n")
+			}
+		}
+		fmt.Fprintf(w.builder, "\\end{%s}\n", environment)
+		if value.Ordered {
+			w.enumerations--
+		}
+	case *document.BlockQuote:
+		w.builder.WriteString("\\begin{quote}\n")
+		w.blocks(value.Blocks)
+		w.builder.WriteString("\\end{quote}\n")
+	case *document.CodeBlock:
+		code := strings.TrimRight(value.Code, "\n")
+		if w.footnote {
+			// the verbatim environment can not be used inside the arguments of commands
+			lines := strings.Split(code, "\n")
+			for index, line := range lines {
+				lines[index] = "\\texttt{" + latexText(line) + "}"
+			}
+			w.builder.WriteString(strings.Join(lines, "\\\\\n") + "\n")
+			return
+		}
+		fmt.Fprintf(w.builder, "\\begin{verbatim}\n%s\n\\end{verbatim}\n", code)
+	case *document.ThematicBreak:
+		w.builder.WriteString("\\noindent\\textcolor{rule}{\\rule{\\linewidth}{0.5pt}}\n")
+	case *document.Table:
+		w.table(value)
+	}
+}
+
+// include writes the blocks of the given included file in a file of its own, which is input in
+// the current one
+func (w *latexWriter) include(include *document.Include) {
+	name, ok := w.names[include.Path]
+	if !ok {
+		name = w.includeName(include.Path)
+		w.names[include.Path] = name
+		builder, source := w.builder, w.source
+		w.builder, w.source = &strings.Builder{}, include.Path
+		fmt.Fprintf(w.builder, "%% Generated by riconto from %s\n\n", include.Path)
+		w.blocks(include.Blocks)
+		w.files = append(w.files, latexFile{name: name, data: []byte(w.builder.String())})
+		w.builder, w.source = builder, source
+	}
+	fmt.Fprintf(w.builder, "\\input{%s}\n", strings.TrimSuffix(name, ".tex"))
+}
+
+// includeName returns an unused name for the file of the given included markdown file, with its
+// path relative to the directory of the main file
+func (w *latexWriter) includeName(filename string) string {
+	relative := filename
+	if dir := path.Dir(w.doc.Path); dir != "." {
+		relative = strings.TrimPrefix(filename, dir+"/")
+	}
+	for strings.HasPrefix(relative, "../") {
+		relative = strings.TrimPrefix(relative, "../")
+	}
+	base := strings.TrimSuffix(relative, path.Ext(relative))
+	name := base + ".tex"
+	for i := 1; w.used[name]; i++ {
+		name = base + "-" + strconv.Itoa(i) + ".tex"
+	}
+	w.used[name] = true
+	return name
+}
+
+// table writes a table that can be split across pages, where the header row is repeated in each page
+func (w *latexWriter) table(table *document.Table) {
+	columns := len(table.Header)
+	for _, row := range table.Rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return
+	}
+	spec := make([]string, columns)
+	for i := range spec {
+		spec[i] = "l"
+		if i < len(table.Alignments) {
+			switch table.Alignments[i] {
+			case document.AlignCenter:
+				spec[i] = "c"
+			case document.AlignRight:
+				spec[i] = "r"
+			}
+		}
+	}
+	fmt.Fprintf(w.builder, "\\begin{longtable}{%s}\n\\hline\n", strings.Join(spec, ""))
+	if len(table.Header) > 0 {
+		w.row(table.Header, columns, true)
+		w.builder.WriteString("\\hline\n\\endhead\n")
+	}
+	for _, row := range table.Rows {
+		w.row(row, columns, false)
+	}
+	w.builder.WriteString("\\hline\n\\end{longtable}\n")
+}
+
+// row writes a table row, filling the missing cells
+func (w *latexWriter) row(cells []*document.TableCell, columns int, header bool) {
+	for i := 0; i < columns; i++ {
+		if i > 0 {
+			w.builder.WriteString(" & ")
+		}
+		if i >= len(cells) {
+			continue
+		}
+		if header {
+			w.builder.WriteString("\\textbf{")
+		}
+		w.inlines(cells[i].Content)
+		if header {
+			w.builder.WriteString("}")
+		}
+	}
+	w.builder.WriteString(" \\\\\n")
+}
+
+func (w *latexWriter) inlines(inlines []document.Inline) {
+	for _, inline := range inlines {
+		switch value := inline.(type) {
+		case *document.Text:
+			w.builder.WriteString(latexText(value.Value))
+		case *document.Break:
+			if value.Hard {
+				w.builder.WriteString("\\newline\n")
+			} else {
+				w.builder.WriteString("\n")
+			}
+		case *document.Emphasis:
+			command := "emph"
+			if value.Level >= 2 {
+				command = "textbf"
+			}
+			fmt.Fprintf(w.builder, "\\%s{", command)
+			w.inlines(value.Content)
+			w.builder.WriteString("}")
+		case *document.Code:
+			fmt.Fprintf(w.builder, "\\texttt{%s}", latexText(value.Value))
+		case *document.Link:
+			if id, ok := headingTarget(value.Destination, w.source, w.starts); ok && w.headings[id] {
+				fmt.Fprintf(w.builder, "\\hyperref[%s]{", id)
+			} else {
+				fmt.Fprintf(w.builder, "\\href{%s}{", latexUrl(value.Destination))
+			}
+			w.inlines(value.Content)
+			w.builder.WriteString("}")
+		case *document.Image:
+			w.image(value)
+		case *document.Footnote:
+			w.addFootnote(value)
+		case *document.Citation:
+			w.citation(value)
+		}
+	}
+}
+
+// image writes an image copied to the figures directory, reduced to the text width, or its
+// description when the image can not be copied
+func (w *latexWriter) image(value *document.Image) {
+	width := w.options.PageSize[0] - w.options.Margins.Left - w.options.Margins.Right
+	embedded, ok := readImage(w.fs, value.Destination, w.source, width)
+	if !ok || embedded.format == "gif" {
+		fmt.Fprintf(w.builder, "\\emph{[%s]}", latexText(strings.TrimSpace(value.Alt)))
+		return
+	}
+	name, ok := w.images[embedded.path]
+	if !ok {
+		base := strings.TrimSuffix(path.Base(embedded.path), path.Ext(embedded.path))
+		name = path.Join(latexFiguresDir, base+"."+embedded.format)
+		for i := 1; w.used[name]; i++ {
+			name = path.Join(latexFiguresDir, base+"-"+strconv.Itoa(i)+"."+embedded.format)
+		}
+		w.used[name] = true
+		w.images[embedded.path] = name
+		w.figures = append(w.figures, latexFile{name: name, data: embedded.data})
+	}
+	fmt.Fprintf(w.builder, "\\includegraphics[width=%spt]{%s}", formatNumber(embedded.width), name)
+}
+
+// addFootnote writes the given footnote where it is referenced, where the footnotes inside
+// footnotes are written as text, since they can not be nested
+func (w *latexWriter) addFootnote(footnote *document.Footnote) {
+	if w.footnote {
+		text := make([]string, 0, len(footnote.Blocks))
+		for _, block := range footnote.Blocks {
+			if paragraph, ok := block.(*document.Paragraph); ok {
+				text = append(text, document.PlainText(paragraph.Content))
+			}
+		}
+		w.builder.WriteString(latexText(" (" + strings.Join(text, " ") + ")"))
+		return
+	}
+	w.footnote = true
+	w.builder.WriteString("\\footnote{")
+	var builder strings.Builder
+	parent := w.builder
+	w.builder = &builder
+	w.blocks(footnote.Blocks)
+	w.builder = parent
+	w.builder.WriteString(strings.TrimRight(builder.String(), "\n"))
+	w.builder.WriteString("}")
+	w.footnote = false
+}
+
+// citation writes a citation of the bibliography, or its text when the document has no bibliography
+func (w *latexWriter) citation(citation *document.Citation) {
+	if w.bibliography == nil {
+		w.builder.WriteString(latexText(citation.Text()))
+		return
+	}
+	w.cited = true
+	w.builder.WriteString("\\cite")
+	if citation.Locator != "" {
+		fmt.Fprintf(w.builder, "[{%s}]", latexText(citation.Locator))
+	}
+	fmt.Fprintf(w.builder, "{%s}", strings.Join(citation.Keys, ","))
+}
+
+// latexText escapes the special characters of the given text
+func latexText(text string) string {
+	return latexEscapes.Replace(text)
+}
+
+// latexUrl escapes the characters of the given url that are special inside the href command
+func latexUrl(url string) string {
+	return strings.NewReplacer(`\`, `\\`, `#`, `\#`, `%`, `\%`, `{`, `\{`, `}`, `\}`).Replace(url)
+}
+
+// latexName returns the given metadata key with only the characters allowed in the names of the
+// pdf properties
+func latexName(key string) string {
+	var builder strings.Builder
+	for _, r := range key {
+		if r < 128 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
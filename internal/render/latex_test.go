@@ -0,0 +1,125 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/chordflower/riconto/internal/markdown"
+	"github.com/chordflower/riconto/internal/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+func TestLatexRenderer(t *testing.T) {
+	Convey("#LatexRenderer", t, func() {
+		fs := afero.NewMemMapFs()
+		var logo bytes.Buffer
+		So(png.Encode(&logo, image.NewRGBA(image.Rect(0, 0, 40, 20))), ShouldBeNil)
+		So(afero.WriteFile(fs, "resources/logo.png", logo.Bytes(), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "src/refs.bib", []byte("@book{knuth84, title={The TeXbook}}\n"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "src/main.md", []byte("---\ntitle: Book & Co\ntags: [one, two]\nbibliography: refs.bib\n---\n# Book\n\n::toc{depth=2 numbered}\n\n"+
+			"See [the end](./chapters/two.md#the-end), [one](chapters/one.md), 50% of $1 & ![a logo](../resources/logo.png) ![missing](missing.png).\n\n"+
+			"::include[./chapters/one.md]\n::include[./chapters/two.md]\n"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "src/chapters/one.md", []byte("# One\n\n3. three\n4. four\n   - nested\n\n> A *quote*.\n\n"+
+			"```go\nx := `{1}`\n```\n\nA note[^note] on the [site](https://example.com/a#b), as in :cite[knuth84]{locator=\"p. 12\"}.\n\n"+
+			"[^note]: See the **other** [site](https://example.org) and [two](two.md).\n"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "src/chapters/two.md", []byte("# Two\n\n## The end\n\n| Name | Size |\n|:-----|-----:|\n| **One** | 1 |\n| Two |\n"), 0o644), ShouldBeNil)
+		doc, _, err := markdown.NewParser(fs).ParseFile("src/main.md")
+		So(err, ShouldBeNil)
+		config := model.NewConfig("books", "1.2.0", "Some books")
+		config.Authors = []model.Author{{Name: "Someone"}, {Name: "Other"}}
+		doc.Meta.Merge(config, nil)
+		options := DefaultOptions()
+		options.Language = "pt-PT"
+		options.Metadata = map[string]string{"reviewer": "Legal & Co"}
+		renderer := NewLatexRenderer(options)
+		So(renderer.Output("dist/book"), ShouldEqual, "dist/book-latex")
+
+		Convey("It should write a project with a file per include", func() {
+			So(renderer.Render(doc, fs, "dist/book"), ShouldBeNil)
+			data, err := afero.ReadFile(fs, "dist/book-latex/book.tex")
+			So(err, ShouldBeNil)
+			main := string(data)
+			So(main, ShouldContainSubstring, "\\documentclass[11pt]{article}")
+			So(main, ShouldContainSubstring, "\\usepackage[paperwidth=595.28pt,paperheight=841.89pt,")
+			So(main, ShouldContainSubstring, "\\usepackage[portuguese]{babel}")
+			So(main, ShouldContainSubstring, "\\title{Book \\& Co}\n\\author{Someone \\and Other}")
+			So(main, ShouldContainSubstring, "pdfkeywords={one, two}")
+			So(main, ShouldContainSubstring, "pdfinfo={Version={1.2.0},reviewer={Legal \\& Co}}")
+			So(main, ShouldContainSubstring, "\\setcounter{secnumdepth}{2}")
+			So(main, ShouldContainSubstring, "\\section{Book}\\label{book}")
+			So(main, ShouldContainSubstring, "\\setcounter{tocdepth}{2}\n\\tableofcontents")
+			So(main, ShouldContainSubstring, "See \\hyperref[the-end]{the end}, \\hyperref[one]{one}, 50\\% of \\$1 \\& "+
+				"\\includegraphics[width=30pt]{figures/logo.png} \\emph{[missing]}.")
+			So(main, ShouldContainSubstring, "\\input{chapters/one}\n\n\\input{chapters/two}\n")
+			So(main, ShouldContainSubstring, "\\bibliographystyle{plain}\n\\bibliography{refs}\n")
+
+			data, err = afero.ReadFile(fs, "dist/book-latex/chapters/one.tex")
+			So(err, ShouldBeNil)
+			one := string(data)
+			So(one, ShouldStartWith, "% Generated by riconto from src/chapters/one.md\n")
+			So(one, ShouldContainSubstring, "\\begin{enumerate}\n\\setcounter{enumi}{2}\n\\item three\n")
+			So(one, ShouldContainSubstring, "\\begin{itemize}\n\\item nested\n\\end{itemize}")
+			So(one, ShouldContainSubstring, "\\begin{quote}\nA \\emph{quote}.\n\\end{quote}")
+			So(one, ShouldContainSubstring, "\\begin{verbatim}\nx := `{1}`\n\\end{verbatim}")
+			So(one, ShouldContainSubstring, "A note\\footnote{See the \\textbf{other} \\href{https://example.org}{site} and \\hyperref[two]{two}.} "+
+				"on the \\href{https://example.com/a\\#b}{site}, as in \\cite[{p. 12}]{knuth84}.")
+
+			data, err = afero.ReadFile(fs, "dist/book-latex/chapters/two.tex")
+			So(err, ShouldBeNil)
+			two := string(data)
+			So(two, ShouldContainSubstring, "\\subsection{The end}\\label{the-end}")
+			So(two, ShouldContainSubstring, "\\begin{longtable}{lr}\n\\hline\n\\textbf{Name} & \\textbf{Size} \\\\\n\\hline\n\\endhead\n"+
+				"\\textbf{One} & 1 \\\\\nTwo &  \\\\\n\\hline\n\\end{longtable}")
+
+			figure, err := afero.ReadFile(fs, "dist/book-latex/figures/logo.png")
+			So(err, ShouldBeNil)
+			So(figure, ShouldResemble, logo.Bytes())
+			bibliography, err := afero.ReadFile(fs, "dist/book-latex/refs.bib")
+			So(err, ShouldBeNil)
+			So(string(bibliography), ShouldStartWith, "@book{knuth84")
+		})
+
+		Convey("It should write the citations as text without a bibliography", func() {
+			doc.Meta.Bibliography = ""
+			So(renderer.Render(doc, fs, "dist/book"), ShouldBeNil)
+			data, err := afero.ReadFile(fs, "dist/book-latex/chapters/one.tex")
+			So(err, ShouldBeNil)
+			So(string(data), ShouldContainSubstring, "as in [knuth84, p. 12].")
+			main, err := afero.ReadFile(fs, "dist/book-latex/book.tex")
+			So(err, ShouldBeNil)
+			So(string(main), ShouldNotContainSubstring, "\\bibliography")
+			exists, err := afero.Exists(fs, "dist/book-latex/refs.bib")
+			So(err, ShouldBeNil)
+			So(exists, ShouldBeFalse)
+		})
+
+		Convey("It should fail when the bibliography does not exist", func() {
+			doc.Meta.Bibliography = "missing.bib"
+			err := renderer.Render(doc, fs, "dist/book")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "Unable to read the bibliography src/missing.bib")
+		})
+	})
+}
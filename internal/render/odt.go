@@ -0,0 +1,702 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"io"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/document"
+	"github.com/spf13/afero"
+)
+
+const (
+	// odtMimetype is the content of the first file of the odt archives.
+	odtMimetype = "application/vnd.oasis.opendocument.text"
+	// odtVersion is the version of the open document format of the documents.
+	odtVersion = "1.3"
+	// odtNamespaces are the namespaces of the xml files of the documents.
+	odtNamespaces = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"` +
+		` xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0"` +
+		` xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"` +
+		` xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"` +
+		` xmlns:draw="urn:oasis:names:tc:opendocument:xmlns:drawing:1.0"` +
+		` xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0"` +
+		` xmlns:xlink="http://www.w3.org/1999/xlink"` +
+		` xmlns:dc="http://purl.org/dc/elements/1.1/"` +
+		` xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0"` +
+		` xmlns:svg="urn:oasis:names:tc:opendocument:xmlns:svg-compatible:1.0"`
+	// odtXmlHeader is the declaration at the start of every xml file.
+	odtXmlHeader = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n"
+	// odtMaxLevels is the number of levels of the lists and of the table of contents styles.
+	odtMaxLevels = 10
+)
+
+// odtFrontMatter are the keys of the front matter that are written in their own metadata elements,
+// so they are not repeated in the user defined metadata
+var odtFrontMatter = []string{"title", "description", "authors", "tags", "version", "license", "metadata", "bibliography"}
+
+// OdtRenderer renders documents as open document text files, the format of the office suites like
+// LibreOffice
+type OdtRenderer struct {
+	options *Options
+}
+
+// NewOdtRenderer creates a new odt renderer, with the given options
+func NewOdtRenderer(options *Options) *OdtRenderer {
+	return &OdtRenderer{options: options}
+}
+
+func (r *OdtRenderer) Name() string {
+	return "odt"
+}
+
+func (r *OdtRenderer) Output(output string) string {
+	return output + ".odt"
+}
+
+func (r *OdtRenderer) Render(doc *document.Document, fs afero.Fs, output string) error {
+	writer := newOdtWriter(r.options, doc, fs)
+	writer.blocks(doc.Blocks)
+
+	files := []struct {
+		name      string
+		mediaType string
+		data      []byte
+	}{
+		{"content.xml", "text/xml", []byte(writer.contentXml())},
+		{"styles.xml", "text/xml", []byte(r.styles())},
+		{"meta.xml", "text/xml", []byte(r.meta(doc))},
+	}
+	for _, picture := range writer.pictures {
+		files = append(files, struct {
+			name      string
+			mediaType string
+			data      []byte
+		}{picture.name, epubMediaTypes["."+picture.format], picture.data})
+	}
+	var manifest strings.Builder
+	manifest.WriteString(odtXmlHeader)
+	fmt.Fprintf(&manifest, "<manifest:manifest xmlns:manifest=\"urn:oasis:names:tc:opendocument:xmlns:manifest:1.0\" manifest:version=\"%s\">\n", odtVersion)
+	fmt.Fprintf(&manifest, "<manifest:file-entry manifest:full-path=\"/\" manifest:version=\"%s\" manifest:media-type=\"%s\"/>\n", odtVersion, odtMimetype)
+	for _, entry := range files {
+		fmt.Fprintf(&manifest, "<manifest:file-entry manifest:full-path=\"%s\" manifest:media-type=\"%s\"/>\n", entry.name, entry.mediaType)
+	}
+	manifest.WriteString("</manifest:manifest>\n")
+
+	file, err := createFile(fs, r.Output(output))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	archive := zip.NewWriter(file)
+	// the mimetype must be the first file, without compression
+	part, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err == nil {
+		_, err = part.Write([]byte(odtMimetype))
+	}
+	files = append(files, struct {
+		name      string
+		mediaType string
+		data      []byte
+	}{"META-INF/manifest.xml", "", []byte(manifest.String())})
+	for _, entry := range files {
+		if err != nil {
+			break
+		}
+		var part io.Writer
+		if part, err = archive.Create(entry.name); err == nil {
+			_, err = part.Write(entry.data)
+		}
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write the odt file %s", r.Output(output))
+	}
+	return nil
+}
+
+// meta returns the metadata of the document, from its front matter completed by the configuration,
+// with the extra metadata of the settings and the other values of the front matter as user defined
+// metadata
+func (r *OdtRenderer) meta(doc *document.Document) string {
+	var builder strings.Builder
+	element := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&builder, "<%s>%s</%s>\n", name, html.EscapeString(value), name)
+		}
+	}
+	date := func(name string, value time.Time) {
+		if !value.IsZero() {
+			element(name, value.UTC().Format("2006-01-02T15:04:05Z"))
+		}
+	}
+	userDefined := make(map[string]string)
+	builder.WriteString(odtXmlHeader)
+	fmt.Fprintf(&builder, "<office:document-meta %s office:version=\"%s\">\n<office:meta>\n", odtNamespaces, odtVersion)
+	element("meta:generator", "riconto")
+	if meta := doc.Meta; meta != nil {
+		element("dc:title", meta.Title)
+		element("dc:description", meta.Description)
+		if meta.Project != meta.Title {
+			element("dc:subject", meta.Project)
+		}
+		for _, tag := range meta.Tags {
+			element("meta:keyword", tag)
+		}
+		if authors := meta.AuthorNames(); len(authors) > 0 {
+			element("meta:initial-creator", authors[0])
+			element("dc:creator", strings.Join(authors, ", "))
+		}
+		date("meta:creation-date", meta.Metadata.Created)
+		date("dc:date", meta.Metadata.Modified)
+		for key, value := range meta.Extra {
+			if slices.Contains(odtFrontMatter, key) {
+				continue
+			}
+			switch value.(type) {
+			case string, bool, int, int64, uint64, float64, time.Time:
+				userDefined[key] = fmt.Sprint(value)
+			}
+		}
+		userDefined["Version"] = meta.Version
+		userDefined["License"] = strings.Join(meta.License, ", ")
+		if !meta.Metadata.Published.IsZero() {
+			userDefined["Published"] = meta.Metadata.Published.UTC().Format(time.RFC3339)
+		}
+	}
+	element("dc:language", r.options.Language)
+	maps.Copy(userDefined, r.options.Metadata)
+	for _, key := range slices.Sorted(maps.Keys(userDefined)) {
+		if userDefined[key] != "" {
+			fmt.Fprintf(&builder, "<meta:user-defined meta:name=\"%s\">%s</meta:user-defined>\n",
+				html.EscapeString(key), html.EscapeString(userDefined[key]))
+		}
+	}
+	builder.WriteString("</office:meta>\n</office:document-meta>\n")
+	return builder.String()
+}
+
+// styles returns the styles of the document, with the sizes and colors of the theme, and the page
+// layout with the page size and margins of the settings
+func (r *OdtRenderer) styles() string {
+	theme := r.options.Theme
+	var builder strings.Builder
+	paragraph := func(name, display, parent, paragraphProperties, textProperties string) {
+		fmt.Fprintf(&builder, "<style:style style:name=\"%s\" style:display-name=\"%s\" style:family=\"paragraph\"", name, display)
+		if parent != "" {
+			fmt.Fprintf(&builder, " style:parent-style-name=\"%s\"", parent)
+		}
+		builder.WriteString(">")
+		if paragraphProperties != "" {
+			fmt.Fprintf(&builder, "<style:paragraph-properties %s/>", paragraphProperties)
+		}
+		if textProperties != "" {
+			fmt.Fprintf(&builder, "<style:text-properties %s/>", textProperties)
+		}
+		builder.WriteString("</style:style>\n")
+	}
+	text := func(name, display, textProperties string) {
+		fmt.Fprintf(&builder, "<style:style style:name=\"%s\" style:display-name=\"%s\" style:family=\"text\">"+
+			"<style:text-properties %s/></style:style>\n", name, display, textProperties)
+	}
+	builder.WriteString(odtXmlHeader)
+	fmt.Fprintf(&builder, "<office:document-styles %s office:version=\"%s\">\n", odtNamespaces, odtVersion)
+	builder.WriteString("<office:font-face-decls>")
+	fmt.Fprintf(&builder, "<style:font-face style:name=\"%s\" svg:font-family=\"'%s'\" style:font-family-generic=\"swiss\"/>", officeFont, officeFont)
+	fmt.Fprintf(&builder, "<style:font-face style:name=\"%s\" svg:font-family=\"'%s'\" style:font-pitch=\"fixed\"/>", officeMonoFont, officeMonoFont)
+	builder.WriteString("</office:font-face-decls>\n<office:styles>\n")
+
+	language := ""
+	if r.options.Language != "" {
+		parts := strings.Split(r.options.Language, "-")
+		language = fmt.Sprintf(" fo:language=\"%s\"", html.EscapeString(strings.ToLower(parts[0])))
+		if len(parts) > 1 && len(parts[1]) == 2 {
+			language += fmt.Sprintf(" fo:country=\"%s\"", html.EscapeString(strings.ToUpper(parts[1])))
+		}
+	}
+	fmt.Fprintf(&builder, "<style:default-style style:family=\"paragraph\"><style:paragraph-properties fo:line-height=\"%s%%\"/>"+
+		"<style:text-properties style:font-name=\"%s\" fo:font-size=\"%spt\" fo:color=\"%s\"%s/></style:default-style>\n",
+		formatNumber(theme.Leading*100), officeFont, formatNumber(theme.FontSize), theme.TextColor.Hex(), language)
+	paragraph("Standard", "Standard", "", "", "")
+	paragraph("Text_20_body", "Text body", "Standard", fmt.Sprintf("fo:margin-top=\"0pt\" fo:margin-bottom=\"%spt\"", formatNumber(theme.FontSize/2)), "")
+	for level := 1; level <= 6; level++ {
+		size := theme.HeadingSize(level)
+		fmt.Fprintf(&builder, "<style:style style:name=\"Heading_20_%d\" style:display-name=\"Heading %d\" style:family=\"paragraph\""+
+			" style:parent-style-name=\"Standard\" style:next-style-name=\"Text_20_body\" style:default-outline-level=\"%d\">"+
+			"<style:paragraph-properties fo:margin-top=\"%spt\" fo:margin-bottom=\"%spt\" fo:line-height=\"120%%\" fo:keep-with-next=\"always\"/>"+
+			"<style:text-properties fo:font-size=\"%spt\" fo:font-weight=\"bold\"/></style:style>\n",
+			level, level, level, formatNumber(size), formatNumber(size/2), formatNumber(size))
+	}
+	paragraph("Quotations", "Quotations", "Text_20_body", fmt.Sprintf("fo:margin-left=\"%spt\" fo:padding-left=\"%spt\""+
+		" fo:border-left=\"2pt solid %s\" fo:border-right=\"none\" fo:border-top=\"none\" fo:border-bottom=\"none\"",
+		formatNumber(theme.Indent), formatNumber(theme.Indent/2), theme.RuleColor.Hex()), "fo:font-style=\"italic\"")
+	paragraph("Preformatted_20_Text", "Preformatted Text", "Standard", fmt.Sprintf("fo:margin-bottom=\"%spt\" fo:padding=\"%spt\""+
+		" fo:background-color=\"%s\" fo:line-height=\"130%%\"", formatNumber(theme.FontSize/2), formatNumber(theme.FontSize/2), theme.CodeBackground.Hex()),
+		fmt.Sprintf("style:font-name=\"%s\" fo:font-size=\"%spt\"", officeMonoFont, formatNumber(theme.FontSize-1.5)))
+	paragraph("Horizontal_20_Line", "Horizontal Line", "Standard", fmt.Sprintf("fo:margin-top=\"%spt\" fo:margin-bottom=\"%spt\""+
+		" fo:border-bottom=\"0.5pt solid %s\" fo:padding=\"0pt\"", formatNumber(theme.FontSize/2), formatNumber(theme.FontSize), theme.RuleColor.Hex()), "fo:font-size=\"2pt\"")
+	paragraph("Table_20_Contents", "Table Contents", "Standard", "", "")
+	paragraph("Table_20_Heading", "Table Heading", "Table_20_Contents", "", "fo:font-weight=\"bold\"")
+	paragraph("Footnote", "Footnote", "Standard", fmt.Sprintf("fo:margin-left=\"%spt\" fo:text-indent=\"-%spt\"",
+		formatNumber(theme.Indent/2), formatNumber(theme.Indent/2)), fmt.Sprintf("fo:font-size=\"%spt\"", formatNumber(theme.FontSize*0.9)))
+	paragraph("Contents_20_Heading", "Contents Heading", "Heading_20_1", "", "")
+	for level := 1; level <= odtMaxLevels; level++ {
+		fmt.Fprintf(&builder, "<style:style style:name=\"Contents_20_%d\" style:display-name=\"Contents %d\" style:family=\"paragraph\""+
+			" style:parent-style-name=\"Standard\"><style:paragraph-properties fo:margin-left=\"%spt\" fo:margin-bottom=\"%spt\">"+
+			"<style:tab-stops><style:tab-stop style:position=\"%spt\" style:type=\"right\" style:leader-style=\"dotted\" style:leader-text=\".\"/>"+
+			"</style:tab-stops></style:paragraph-properties></style:style>\n",
+			level, level, formatNumber(float64(level-1)*theme.Indent), formatNumber(theme.FontSize/4),
+			formatNumber(r.textWidth()-float64(level-1)*theme.Indent))
+	}
+	text("Emphasis", "Emphasis", "fo:font-style=\"italic\"")
+	text("Strong_20_Emphasis", "Strong Emphasis", "fo:font-weight=\"bold\"")
+	text("Source_20_Text", "Source Text", fmt.Sprintf("style:font-name=\"%s\"", officeMonoFont))
+	text("Internet_20_link", "Internet link", fmt.Sprintf("fo:color=\"%s\" style:text-underline-style=\"solid\""+
+		" style:text-underline-width=\"auto\" style:text-underline-color=\"font-color\"", theme.LinkColor.Hex()))
+	text("Footnote_20_Symbol", "Footnote Symbol", "style:text-position=\"super 58%\"")
+	builder.WriteString("<text:notes-configuration text:note-class=\"footnote\" text:citation-style-name=\"Footnote_20_Symbol\"" +
+		" text:default-style-name=\"Footnote\" style:num-format=\"1\" text:start-value=\"0\" text:footnotes-position=\"page\"" +
+		" text:start-numbering-at=\"document\"/>\n")
+	r.listStyle(&builder, "List_20_Bullet", "List Bullet", false)
+	r.listStyle(&builder, "Numbering_20_123", "Numbering 123", true)
+	builder.WriteString("</office:styles>\n<office:automatic-styles>\n")
+	margins := r.options.Margins
+	fmt.Fprintf(&builder, "<style:page-layout style:name=\"Page\"><style:page-layout-properties fo:page-width=\"%spt\" fo:page-height=\"%spt\""+
+		" fo:margin-top=\"%spt\" fo:margin-right=\"%spt\" fo:margin-bottom=\"%spt\" fo:margin-left=\"%spt\"/></style:page-layout>\n",
+		formatNumber(r.options.PageSize[0]), formatNumber(r.options.PageSize[1]),
+		formatNumber(margins.Top), formatNumber(margins.Right), formatNumber(margins.Bottom), formatNumber(margins.Left))
+	builder.WriteString("</office:automatic-styles>\n<office:master-styles>\n")
+	builder.WriteString("<style:master-page style:name=\"Standard\" style:page-layout-name=\"Page\"/>\n")
+	builder.WriteString("</office:master-styles>\n</office:document-styles>\n")
+	return builder.String()
+}
+
+// listStyle writes a list style with bullets, or with numbers when ordered, indented by the theme
+func (r *OdtRenderer) listStyle(builder *strings.Builder, name, display string, ordered bool) {
+	indent := r.options.Theme.Indent
+	bullets := []string{"•", "◦", "▪"}
+	fmt.Fprintf(builder, "<text:list-style style:name=\"%s\" style:display-name=\"%s\">", name, display)
+	for level := 1; level <= odtMaxLevels; level++ {
+		if ordered {
+			fmt.Fprintf(builder, "<text:list-level-style-number text:level=\"%d\" style:num-suffix=\".\" style:num-format=\"1\">", level)
+		} else {
+			fmt.Fprintf(builder, "<text:list-level-style-bullet text:level=\"%d\" text:bullet-char=\"%s\">", level, bullets[(level-1)%len(bullets)])
+		}
+		fmt.Fprintf(builder, "<style:list-level-properties text:list-level-position-and-space-mode=\"label-alignment\">"+
+			"<style:list-level-label-alignment text:label-followed-by=\"listtab\" text:list-tab-stop-position=\"%spt\""+
+			" fo:text-indent=\"-%spt\" fo:margin-left=\"%spt\"/></style:list-level-properties>",
+			formatNumber(float64(level)*indent), formatNumber(indent), formatNumber(float64(level)*indent))
+		if ordered {
+			builder.WriteString("</text:list-level-style-number>")
+		} else {
+			builder.WriteString("</text:list-level-style-bullet>")
+		}
+	}
+	builder.WriteString("</text:list-style>\n")
+}
+
+// textWidth returns the width of the text area of the pages, in points
+func (r *OdtRenderer) textWidth() float64 {
+	return r.options.PageSize[0] - r.options.Margins.Left - r.options.Margins.Right
+}
+
+// odtPicture is an image embedded in the document
+type odtPicture struct {
+	name   string
+	format string
+	data   []byte
+}
+
+// odtWriter writes the blocks of a document as the body of an odt document
+type odtWriter struct {
+	options *Options
+	doc     *document.Document
+	fs      afero.Fs
+	builder strings.Builder
+	// styles are the automatic styles of the tables, which depend on their number of columns.
+	styles strings.Builder
+	// source is the path of the file of the blocks being written.
+	source string
+	// paragraphStyle is the style of the paragraphs being written, which depends on the blocks around them.
+	paragraphStyle string
+	// footnote tells if the blocks being written are in a footnote.
+	footnote  bool
+	footnotes int
+	tables    int
+	contents  int
+	pictures  []odtPicture
+	// images are the names of the embedded images, by their file name.
+	images map[string]string
+	// headings are the identifiers of the headings of the document.
+	headings map[string]bool
+	starts   map[string]string
+}
+
+func newOdtWriter(options *Options, doc *document.Document, fs afero.Fs) *odtWriter {
+	writer := &odtWriter{
+		options:        options,
+		doc:            doc,
+		fs:             fs,
+		source:         doc.Path,
+		paragraphStyle: "Text_20_body",
+		pictures:       make([]odtPicture, 0),
+		images:         make(map[string]string),
+		headings:       make(map[string]bool),
+		starts:         make(map[string]string),
+	}
+	for _, heading := range document.Headings(doc.Blocks) {
+		if heading.ID != "" {
+			writer.headings[heading.ID] = true
+		}
+	}
+	headingStarts(writer.starts, doc.Path, doc.Blocks)
+	return writer
+}
+
+// textWidth returns the width of the text area of the pages, in points
+func (w *odtWriter) textWidth() float64 {
+	return w.options.PageSize[0] - w.options.Margins.Left - w.options.Margins.Right
+}
+
+// contentXml returns the content of the document, with the automatic styles of its tables
+func (w *odtWriter) contentXml() string {
+	var builder strings.Builder
+	builder.WriteString(odtXmlHeader)
+	fmt.Fprintf(&builder, "<office:document-content %s office:version=\"%s\">\n", odtNamespaces, odtVersion)
+	builder.WriteString("<office:automatic-styles>\n")
+	for _, align := range []string{"center", "end"} {
+		for _, parent := range []string{"Table_20_Contents", "Table_20_Heading"} {
+			fmt.Fprintf(&builder, "<style:style style:name=\"%s_%s\" style:family=\"paragraph\" style:parent-style-name=\"%s\">"+
+				"<style:paragraph-properties fo:text-align=\"%s\"/></style:style>\n", parent, align, parent, align)
+		}
+	}
+	builder.WriteString(w.styles.String())
+	builder.WriteString("</office:automatic-styles>\n<office:body>\n<office:text>\n")
+	builder.WriteString(w.builder.String())
+	builder.WriteString("</office:text>\n</office:body>\n</office:document-content>\n")
+	return builder.String()
+}
+
+func (w *odtWriter) blocks(blocks []document.Block) {
+	for _, block := range blocks {
+		w.block(block)
+	}
+}
+
+func (w *odtWriter) block(block document.Block) {
+	switch value := block.(type) {
+	case *document.Include:
+		source := w.source
+		w.source = value.Path
+		w.blocks(value.Blocks)
+		w.source = source
+	case *document.TableOfContents:
+		w.tableOfContents(value)
+	case *document.Heading:
+		level := min(max(value.Level, 1), 6)
+		fmt.Fprintf(&w.builder, "<text:h text:style-name=\"Heading_20_%d\" text:outline-level=\"%d\">", level, level)
+		if value.ID != "" && !w.footnote {
+			fmt.Fprintf(&w.builder, "<text:bookmark text:name=\"%s\"/>", html.EscapeString(value.ID))
+		}
+		w.inlines(value.Content)
+		w.builder.WriteString("</text:h>\n")
+	case *document.Paragraph:
+		fmt.Fprintf(&w.builder, "<text:p text:style-name=\"%s\">", w.paragraphStyle)
+		w.inlines(value.Content)
+		w.builder.WriteString("</text:p>\n")
+	case *document.List:
+		style := "List_20_Bullet"
+		if value.Ordered {
+			style = "Numbering_20_123"
+		}
+		fmt.Fprintf(&w.builder, "<text:list text:style-name=\"%s\">\n", style)
+		for index, item := range value.Items {
+			if index == 0 && value.Ordered && value.Start != 1 {
+				fmt.Fprintf(&w.builder, "<text:list-item text:start-value=\"%d\">", value.Start)
+			} else {
+				w.builder.WriteString("<text:list-item>")
+			}
+			if len(item.Blocks) == 0 {
+				fmt.Fprintf(&w.builder, "<text:p text:style-name=\"%s\"/>", w.paragraphStyle)
+			}
+			w.blocks(item.Blocks)
+			w.builder.WriteString("</text:list-item>\n")
+		}
+		w.builder.WriteString("</text:list>\n")
+	case *document.BlockQuote:
+		style := w.paragraphStyle
+		if !w.footnote {
+			w.paragraphStyle = "Quotations"
+		}
+		w.blocks(value.Blocks)
+		w.paragraphStyle = style
+	case *document.CodeBlock:
+		w.builder.WriteString("<text:p text:style-name=\"Preformatted_20_Text\">")
+		text := strings.TrimRight(value.Code, "\n")
+		for index, line := range strings.Split(text, "\n") {
+			if index > 0 {
+				w.builder.WriteString("<text:line-break/>")
+			}
+			w.builder.WriteString(odtText(line, true))
+		}
+		w.builder.WriteString("</text:p>\n")
+	case *document.ThematicBreak:
+		w.builder.WriteString("<text:p text:style-name=\"Horizontal_20_Line\"/>\n")
+	case *document.Table:
+		w.table(value)
+	}
+}
+
+// tableOfContents writes a table of contents, filled with the current headings, that the office suites
+// update with the page numbers
+func (w *odtWriter) tableOfContents(contents *document.TableOfContents) {
+	depth := min(max(contents.Depth, 1), odtMaxLevels)
+	w.contents++
+	fmt.Fprintf(&w.builder, "<text:table-of-content text:name=\"Contents%d\" text:protected=\"true\">\n", w.contents)
+	fmt.Fprintf(&w.builder, "<text:table-of-content-source text:outline-level=\"%d\" text:use-index-marks=\"false\">\n", depth)
+	w.builder.WriteString("<text:index-title-template text:style-name=\"Contents_20_Heading\"/>\n")
+	for level := 1; level <= depth; level++ {
+		fmt.Fprintf(&w.builder, "<text:table-of-content-entry-template text:outline-level=\"%d\" text:style-name=\"Contents_20_%d\">"+
+			"<text:index-entry-link-start/><text:index-entry-chapter/><text:index-entry-text/><text:index-entry-link-end/>"+
+			"<text:index-entry-tab-stop style:type=\"right\" style:leader-char=\".\"/><text:index-entry-page-number/>"+
+			"</text:table-of-content-entry-template>\n", level, level)
+	}
+	w.builder.WriteString("</text:table-of-content-source>\n<text:index-body>\n")
+	w.contentsEntries(document.Contents(w.doc.Blocks, depth, contents.Numbered), 1)
+	w.builder.WriteString("</text:index-body>\n</text:table-of-content>\n")
+}
+
+// contentsEntries writes the given table of contents entries, with the given level
+func (w *odtWriter) contentsEntries(entries []*document.ContentsEntry, level int) {
+	for _, entry := range entries {
+		fmt.Fprintf(&w.builder, "<text:p text:style-name=\"Contents_20_%d\">", min(level, odtMaxLevels))
+		if entry.Heading.ID != "" {
+			fmt.Fprintf(&w.builder, "<text:a xlink:type=\"simple\" xlink:href=\"#%s\">%s</text:a>",
+				html.EscapeString(entry.Heading.ID), odtText(entry.Title(), false))
+		} else {
+			w.builder.WriteString(odtText(entry.Title(), false))
+		}
+		w.builder.WriteString("</text:p>\n")
+		w.contentsEntries(entry.Children, level+1)
+	}
+}
+
+// table writes a table with columns of equal width, where the header row is repeated in each page
+func (w *odtWriter) table(table *document.Table) {
+	columns := len(table.Header)
+	for _, row := range table.Rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return
+	}
+	w.tables++
+	name := "Table" + strconv.Itoa(w.tables)
+	width := w.textWidth()
+	fmt.Fprintf(&w.styles, "<style:style style:name=\"%s\" style:family=\"table\"><style:table-properties style:width=\"%spt\""+
+		" table:align=\"margins\" fo:margin-bottom=\"%spt\"/></style:style>\n", name, formatNumber(width), formatNumber(w.options.Theme.FontSize/2))
+	fmt.Fprintf(&w.styles, "<style:style style:name=\"%s.A\" style:family=\"table-column\"><style:table-column-properties"+
+		" style:column-width=\"%spt\"/></style:style>\n", name, formatNumber(width/float64(columns)))
+	border := "0.5pt solid " + w.options.Theme.RuleColor.Hex()
+	fmt.Fprintf(&w.styles, "<style:style style:name=\"%s.A1\" style:family=\"table-cell\"><style:table-cell-properties fo:padding=\"%spt\""+
+		" fo:border=\"%s\" fo:background-color=\"%s\"/></style:style>\n", name, formatNumber(w.options.Theme.FontSize/3), border,
+		w.options.Theme.CodeBackground.Hex())
+	fmt.Fprintf(&w.styles, "<style:style style:name=\"%s.A2\" style:family=\"table-cell\"><style:table-cell-properties fo:padding=\"%spt\""+
+		" fo:border=\"%s\"/></style:style>\n", name, formatNumber(w.options.Theme.FontSize/3), border)
+
+	fmt.Fprintf(&w.builder, "<table:table table:name=\"%s\" table:style-name=\"%s\">\n", name, name)
+	fmt.Fprintf(&w.builder, "<table:table-column table:style-name=\"%s.A\" table:number-columns-repeated=\"%d\"/>\n", name, columns)
+	if len(table.Header) > 0 {
+		w.builder.WriteString("<table:table-header-rows>\n")
+		w.row(table.Header, table.Alignments, columns, name+".A1", "Table_20_Heading")
+		w.builder.WriteString("</table:table-header-rows>\n")
+	}
+	for _, row := range table.Rows {
+		w.row(row, table.Alignments, columns, name+".A2", "Table_20_Contents")
+	}
+	w.builder.WriteString("</table:table>\n")
+}
+
+// row writes a table row, filling the missing cells and aligning each cell as its column
+func (w *odtWriter) row(cells []*document.TableCell, alignments []string, columns int, cellStyle, paragraphStyle string) {
+	w.builder.WriteString("<table:table-row>")
+	for i := 0; i < columns; i++ {
+		style := paragraphStyle
+		if i < len(alignments) {
+			switch alignments[i] {
+			case document.AlignCenter:
+				style += "_center"
+			case document.AlignRight:
+				style += "_end"
+			}
+		}
+		fmt.Fprintf(&w.builder, "<table:table-cell table:style-name=\"%s\" office:value-type=\"string\"><text:p text:style-name=\"%s\">",
+			cellStyle, style)
+		if i < len(cells) {
+			w.inlines(cells[i].Content)
+		}
+		w.builder.WriteString("</text:p></table:table-cell>")
+	}
+	w.builder.WriteString("</table:table-row>\n")
+}
+
+func (w *odtWriter) inlines(inlines []document.Inline) {
+	for _, inline := range inlines {
+		switch value := inline.(type) {
+		case *document.Text:
+			w.builder.WriteString(odtText(value.Value, false))
+		case *document.Break:
+			if value.Hard {
+				w.builder.WriteString("<text:line-break/>")
+			} else {
+				w.builder.WriteString(" ")
+			}
+		case *document.Emphasis:
+			style := "Emphasis"
+			if value.Level >= 2 {
+				style = "Strong_20_Emphasis"
+			}
+			fmt.Fprintf(&w.builder, "<text:span text:style-name=\"%s\">", style)
+			w.inlines(value.Content)
+			w.builder.WriteString("</text:span>")
+		case *document.Code:
+			fmt.Fprintf(&w.builder, "<text:span text:style-name=\"Source_20_Text\">%s</text:span>", odtText(value.Value, true))
+		case *document.Link:
+			destination := value.Destination
+			if id, ok := headingTarget(destination, w.source, w.starts); ok && w.headings[id] {
+				destination = "#" + id
+			}
+			fmt.Fprintf(&w.builder, "<text:a xlink:type=\"simple\" xlink:href=\"%s\" text:style-name=\"Internet_20_link\"", html.EscapeString(destination))
+			if value.Title != "" {
+				fmt.Fprintf(&w.builder, " office:title=\"%s\"", html.EscapeString(value.Title))
+			}
+			w.builder.WriteString(">")
+			w.inlines(value.Content)
+			w.builder.WriteString("</text:a>")
+		case *document.Image:
+			w.image(value)
+		case *document.Footnote:
+			w.addFootnote(value)
+		case *document.Citation:
+			w.builder.WriteString(odtText(value.Text(), false))
+		}
+	}
+}
+
+// image writes an image embedded in the document, reduced to the text width, or its description
+// when the image can not be embedded
+func (w *odtWriter) image(value *document.Image) {
+	width := w.textWidth()
+	embedded, ok := readImage(w.fs, value.Destination, w.source, width)
+	if !ok {
+		fmt.Fprintf(&w.builder, "<text:span text:style-name=\"Emphasis\">[%s]</text:span>", odtText(strings.TrimSpace(value.Alt), false))
+		return
+	}
+	name, ok := w.images[embedded.path]
+	if !ok {
+		name = fmt.Sprintf("Pictures/image%d.%s", len(w.pictures)+1, embedded.format)
+		w.pictures = append(w.pictures, odtPicture{name: name, format: embedded.format, data: embedded.data})
+		w.images[embedded.path] = name
+	}
+	fmt.Fprintf(&w.builder, "<draw:frame draw:name=\"%s\" text:anchor-type=\"as-char\" svg:width=\"%spt\" svg:height=\"%spt\">"+
+		"<draw:image xlink:href=\"%s\" xlink:type=\"simple\" xlink:show=\"embed\" xlink:actuate=\"onLoad\"/>",
+		html.EscapeString(path.Base(name)), formatNumber(embedded.width), formatNumber(embedded.height), name)
+	if value.Alt != "" {
+		fmt.Fprintf(&w.builder, "<svg:desc>%s</svg:desc>", html.EscapeString(value.Alt))
+	}
+	w.builder.WriteString("</draw:frame>")
+}
+
+// addFootnote writes the given footnote where it is referenced, where the footnotes inside
+// footnotes are written as text, since they are not allowed there
+func (w *odtWriter) addFootnote(footnote *document.Footnote) {
+	if w.footnote {
+		text := make([]string, 0, len(footnote.Blocks))
+		for _, block := range footnote.Blocks {
+			if paragraph, ok := block.(*document.Paragraph); ok {
+				text = append(text, document.PlainText(paragraph.Content))
+			}
+		}
+		w.builder.WriteString(odtText(" ("+strings.Join(text, " ")+")", false))
+		return
+	}
+	w.footnotes++
+	fmt.Fprintf(&w.builder, "<text:note text:id=\"footnote%d\" text:note-class=\"footnote\"><text:note-citation>%d</text:note-citation><text:note-body>",
+		w.footnotes, w.footnotes)
+	style := w.paragraphStyle
+	w.footnote, w.paragraphStyle = true, "Footnote"
+	w.blocks(footnote.Blocks)
+	if len(footnote.Blocks) == 0 {
+		w.builder.WriteString("<text:p text:style-name=\"Footnote\"/>")
+	}
+	w.footnote, w.paragraphStyle = false, style
+	w.builder.WriteString("</text:note-body></text:note>")
+}
+
+// odtText escapes the given text, where the sequences of spaces and the tabs are kept when preserve
+// is set, since they are collapsed otherwise
+func odtText(text string, preserve bool) string {
+	escaped := html.EscapeString(text)
+	if !preserve {
+		return escaped
+	}
+	var builder strings.Builder
+	spaces := 0
+	flush := func(start bool) {
+		switch {
+		case spaces == 0:
+		case start:
+			fmt.Fprintf(&builder, "<text:s text:c=\"%d\"/>", spaces)
+		case spaces == 1:
+			builder.WriteString(" ")
+		default:
+			fmt.Fprintf(&builder, " <text:s text:c=\"%d\"/>", spaces-1)
+		}
+		spaces = 0
+	}
+	for index, r := range escaped {
+		switch r {
+		case ' ':
+			spaces++
+		case '\t':
+			flush(index == spaces)
+			builder.WriteString("<text:tab/>")
+		default:
+			flush(index == spaces)
+			builder.WriteRune(r)
+		}
+	}
+	flush(len(escaped) == spaces)
+	return builder.String()
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/chordflower/riconto/internal/markdown"
+	"github.com/chordflower/riconto/internal/model"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+// validateOdt checks the structure of the given odt file, returning its files
+func validateOdt(data []byte) map[string][]byte {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	So(err, ShouldBeNil)
+	So(reader.File[0].Name, ShouldEqual, "mimetype")
+	So(reader.File[0].Method, ShouldEqual, zip.Store)
+	files := make(map[string][]byte)
+	for _, file := range reader.File {
+		content, err := file.Open()
+		So(err, ShouldBeNil)
+		files[file.Name], err = io.ReadAll(content)
+		So(err, ShouldBeNil)
+	}
+	So(string(files["mimetype"]), ShouldEqual, odtMimetype)
+
+	// every file is in the manifest, and the xml files are well formed
+	manifest := xmlAttributes(files["META-INF/manifest.xml"], "file-entry", "full-path")
+	So(manifest, ShouldContain, "/")
+	for name, content := range files {
+		if name != "mimetype" && name != "META-INF/manifest.xml" {
+			So(manifest, ShouldContain, name)
+		}
+		if strings.HasSuffix(name, ".xml") {
+			xmlAttributes(content, "", "")
+		}
+	}
+
+	// the internal links point to bookmarks, the images are embedded and the styles exist
+	content := files["content.xml"]
+	bookmarks := xmlAttributes(content, "bookmark", "name")
+	for _, target := range xmlAttributes(content, "a", "href") {
+		if id, ok := strings.CutPrefix(target, "#"); ok {
+			So(bookmarks, ShouldContain, id)
+		}
+	}
+	for _, target := range xmlAttributes(content, "image", "href") {
+		So(files, ShouldContainKey, target)
+	}
+	styles := append(xmlAttributes(files["styles.xml"], "style", "name"), xmlAttributes(content, "style", "name")...)
+	styles = append(styles, xmlAttributes(files["styles.xml"], "list-style", "name")...)
+	for _, element := range []string{"p", "h", "span", "a", "list", "table", "table-column", "table-cell"} {
+		for _, style := range xmlAttributes(content, element, "style-name") {
+			So(styles, ShouldContain, style)
+		}
+	}
+	return files
+}
+
+func TestOdtRenderer(t *testing.T) {
+	Convey("#OdtRenderer", t, func() {
+		fs := afero.NewMemMapFs()
+		var logo bytes.Buffer
+		So(png.Encode(&logo, image.NewRGBA(image.Rect(0, 0, 40, 20))), ShouldBeNil)
+		So(afero.WriteFile(fs, "resources/logo.png", logo.Bytes(), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "src/main.md", []byte("---\ntitle: Book\ntags: [one, two]\naudience: everyone\n---\n# Book\n\n::toc{depth=2}\n\n"+
+			"See [the end](./two.md#the-end), [one](one.md) & ![a logo](../resources/logo.png) ![missing](missing.png).\n\n"+
+			"::include[./one.md]\n::include[./two.md]\n"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "src/one.md", []byte("# One\n\n3. three\n4. four\n   - nested\n\n> A *quote*.\n\n"+
+			"```go\nif x {\n\treturn  1\n}\n```\n\nA note[^note] on the [site](https://example.com).\n\n"+
+			"[^note]: See the [other site](https://example.org) and [two](two.md).\n"), 0o644), ShouldBeNil)
+		So(afero.WriteFile(fs, "src/two.md", []byte("# Two\n\n## The end\n\n| Name | Size |\n|:-----|-----:|\n| **One** | 1 |\n| Two |\n"), 0o644), ShouldBeNil)
+		doc, _, err := markdown.NewParser(fs).ParseFile("src/main.md")
+		So(err, ShouldBeNil)
+		config := model.NewConfig("books", "1.2.0", "Some books")
+		config.Authors = []model.Author{{Name: "Someone"}, {Name: "Other"}}
+		doc.Meta.Merge(config, nil)
+		options := DefaultOptions()
+		options.Language = "pt-PT"
+		options.Metadata = map[string]string{"reviewer": "Legal & Co"}
+
+		Convey("It should write a valid document", func() {
+			renderer := NewOdtRenderer(options)
+			So(renderer.Output("dist/book"), ShouldEqual, "dist/book.odt")
+			So(renderer.Render(doc, fs, "dist/book"), ShouldBeNil)
+			data, err := afero.ReadFile(fs, "dist/book.odt")
+			So(err, ShouldBeNil)
+			files := validateOdt(data)
+
+			content := string(files["content.xml"])
+			So(content, ShouldContainSubstring, `<text:h text:style-name="Heading_20_1" text:outline-level="1"><text:bookmark text:name="book"/>Book</text:h>`)
+			So(content, ShouldContainSubstring, `<text:table-of-content-source text:outline-level="2" text:use-index-marks="false">`)
+			So(content, ShouldContainSubstring, `<text:p text:style-name="Contents_20_2"><text:a xlink:type="simple" xlink:href="#the-end">The end</text:a></text:p>`)
+			So(content, ShouldContainSubstring, `<text:a xlink:type="simple" xlink:href="#the-end" text:style-name="Internet_20_link">the end</text:a>`)
+			So(content, ShouldContainSubstring, `<text:a xlink:type="simple" xlink:href="#one" text:style-name="Internet_20_link">one</text:a>`)
+			So(content, ShouldContainSubstring, `svg:width="30pt" svg:height="15pt"><draw:image xlink:href="Pictures/image1.png"`)
+			So(content, ShouldContainSubstring, `<text:span text:style-name="Emphasis">[missing]</text:span>`)
+			So(content, ShouldContainSubstring, `<text:list text:style-name="Numbering_20_123">`+"\n"+`<text:list-item text:start-value="3">`)
+			So(content, ShouldContainSubstring, `<text:list text:style-name="List_20_Bullet">`)
+			So(content, ShouldContainSubstring, `<text:p text:style-name="Quotations">A <text:span text:style-name="Emphasis">quote</text:span>.</text:p>`)
+			So(content, ShouldContainSubstring, `if x {<text:line-break/><text:tab/>return <text:s text:c="1"/>1<text:line-break/>}`)
+			So(content, ShouldContainSubstring, `<text:note-citation>1</text:note-citation><text:note-body><text:p text:style-name="Footnote">See the`)
+			So(content, ShouldContainSubstring, `<text:a xlink:type="simple" xlink:href="#two" text:style-name="Internet_20_link">two</text:a>`)
+			So(content, ShouldContainSubstring, `<table:table-header-rows>`)
+			So(content, ShouldContainSubstring, `<text:p text:style-name="Table_20_Contents_end">1</text:p>`)
+			So(strings.Count(content, "<table:table-cell "), ShouldEqual, 6)
+			So(files["Pictures/image1.png"], ShouldResemble, logo.Bytes())
+
+			styles := string(files["styles.xml"])
+			So(styles, ShouldContainSubstring, `fo:font-size="10.5pt" fo:color="#000000" fo:language="pt" fo:country="PT"`)
+			So(styles, ShouldContainSubstring, `style:name="Heading_20_6"`)
+			So(styles, ShouldContainSubstring, `<style:page-layout-properties fo:page-width="595.28pt" fo:page-height="841.89pt"`)
+
+			meta := string(files["meta.xml"])
+			So(meta, ShouldContainSubstring, "<dc:title>Book</dc:title>")
+			So(meta, ShouldContainSubstring, "<dc:creator>Someone, Other</dc:creator>")
+			So(meta, ShouldContainSubstring, "<meta:keyword>two</meta:keyword>")
+			So(meta, ShouldContainSubstring, "<dc:language>pt-PT</dc:language>")
+			So(meta, ShouldContainSubstring, `<meta:user-defined meta:name="Version">1.2.0</meta:user-defined>`)
+			So(meta, ShouldContainSubstring, `<meta:user-defined meta:name="audience">everyone</meta:user-defined>`)
+			So(meta, ShouldContainSubstring, `<meta:user-defined meta:name="reviewer">Legal &amp; Co</meta:user-defined>`)
+			So(meta, ShouldNotContainSubstring, `meta:name="title"`)
+		})
+	})
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/document"
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/chordflower/riconto/pkg/pdf"
+)
+
+// DefaultFormat is the format of the output files when none is given
+const DefaultFormat = "pdf"
+
+// pageSizes are the sizes of the pages by their names, in points
+var pageSizes = map[string][2]float64{
+	"a4":     pdf.PageSizeA4,
+	"a5":     pdf.PageSizeA5,
+	"letter": pdf.PageSizeLetter,
+}
+
+// lengthUnits are the sizes of the units of the lengths, in points
+var lengthUnits = map[string]float64{
+	"pt": 1,
+	"in": 72,
+	"cm": 72 / 2.54,
+	"mm": 72 / 25.4,
+}
+
+var (
+	lengthPattern = regexp.MustCompile(`^([0-9]+(?:[.][0-9]+)?)(mm|cm|in|pt)$`)
+	colorPattern  = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+// Options are the options of a render, from the settings of the file being rendered
+type Options struct {
+	// Theme is the theme of the document.
+	Theme *Theme
+	// Watermark is a text written across every page, none when empty.
+	Watermark string
+	// Bleed is the size of the area around the pages that is cut when printing, in points.
+	Bleed float64
+	// CropMarks are if the corners where the pages are cut when printing are marked.
+	CropMarks bool
+	// PageSize is the width and height of the pages, in points.
+	PageSize [2]float64
+	// Margins are the space between the edges of the pages and their content, in points.
+	Margins pdf.Margins
+	// Fonts are the paths of the font files used instead of the builtin fonts, when not empty.
+	Fonts model.Fonts
+	// Language is the language of the text, none when empty.
+	Language string
+	// Formats are the formats of the output files.
+	Formats []string
+	// Metadata are extra values written in the properties of the output files.
+	Metadata map[string]string
+	// Split is how the outputs with several pages are divided, as one of the document splits.
+	Split string
+}
+
+// DefaultOptions returns the options with the default theme, page size and margins, and nothing else
+func DefaultOptions() *Options {
+	theme, _ := FindTheme(DefaultTheme)
+	return &Options{
+		Theme:    theme,
+		PageSize: pdf.PageSizeA4,
+		Margins:  pdf.Margins{Top: pdfMargin, Right: pdfMargin, Bottom: pdfMargin, Left: pdfMargin},
+		Formats:  []string{DefaultFormat},
+		Split:    document.SplitIncludes,
+	}
+}
+
+// NewOptions returns the options of the given settings, failing on unknown themes and invalid values
+func NewOptions(settings *model.Settings) (*Options, error) {
+	theme, err := FindTheme(settings.Theme)
+	if err != nil {
+		return nil, err
+	}
+	result := DefaultOptions()
+	result.Theme = theme
+	result.Watermark = settings.Watermark
+	result.Language = settings.Language
+	result.Metadata = maps.Clone(settings.Metadata)
+	if settings.Fonts != nil {
+		result.Fonts = *settings.Fonts
+	}
+	if settings.LinkColor != "" {
+		color, err := ParseColor(settings.LinkColor)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid link color")
+		}
+		changed := *theme
+		changed.LinkColor = color
+		result.Theme = &changed
+	}
+	if settings.Bleed != "" {
+		if result.Bleed, err = ParseLength(settings.Bleed); err != nil {
+			return nil, errors.Wrap(err, "Invalid bleed")
+		}
+	}
+	if settings.CropMarks != nil {
+		result.CropMarks = *settings.CropMarks
+	}
+	if settings.PageSize != "" {
+		size, ok := pageSizes[settings.PageSize]
+		if !ok {
+			return nil, errors.Errorf("The page size %s is not supported, use %s", settings.PageSize,
+				strings.Join(slices.Sorted(maps.Keys(pageSizes)), ", "))
+		}
+		result.PageSize = size
+	}
+	if settings.Margins != nil {
+		margins := []struct {
+			value string
+			to    *float64
+		}{
+			{settings.Margins.Top, &result.Margins.Top},
+			{settings.Margins.Right, &result.Margins.Right},
+			{settings.Margins.Bottom, &result.Margins.Bottom},
+			{settings.Margins.Left, &result.Margins.Left},
+		}
+		for _, margin := range margins {
+			if margin.value == "" {
+				continue
+			}
+			if *margin.to, err = ParseLength(margin.value); err != nil {
+				return nil, errors.Wrap(err, "Invalid margin")
+			}
+		}
+	}
+	if result.Margins.Left+result.Margins.Right >= result.PageSize[0] {
+		return nil, errors.Errorf("The left and right margins (%spt) leave no space in the width of the page (%spt)",
+			formatNumber(result.Margins.Left+result.Margins.Right), formatNumber(result.PageSize[0]))
+	}
+	if result.Margins.Top+result.Margins.Bottom >= result.PageSize[1] {
+		return nil, errors.Errorf("The top and bottom margins (%spt) leave no space in the height of the page (%spt)",
+			formatNumber(result.Margins.Top+result.Margins.Bottom), formatNumber(result.PageSize[1]))
+	}
+	if settings.Split != "" {
+		splits := []string{document.SplitIncludes, document.SplitHeadings, document.SplitNone}
+		if !slices.Contains(splits, settings.Split) {
+			return nil, errors.Errorf("The split %s is not supported, use %s", settings.Split, strings.Join(splits, ", "))
+		}
+		result.Split = settings.Split
+	}
+	if len(settings.Formats) > 0 {
+		// Each format is rendered once, in the order it first appears
+		result.Formats = make([]string, 0, len(settings.Formats))
+		seen := make(map[string]bool)
+		for _, format := range settings.Formats {
+			if !slices.Contains(Formats, format) {
+				return nil, errors.Errorf("The format %s is not supported, use %s", format, strings.Join(Formats, ", "))
+			}
+			if !seen[format] {
+				seen[format] = true
+				result.Formats = append(result.Formats, format)
+			}
+		}
+	}
+	return result, nil
+}
+
+// ParseLength returns the given length, a number followed by mm, cm, in or pt, in points
+func ParseLength(text string) (float64, error) {
+	match := lengthPattern.FindStringSubmatch(text)
+	if match == nil {
+		return 0, errors.Errorf("The length %s must be a number followed by mm, cm, in or pt", text)
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Invalid length %s", text)
+	}
+	return value * lengthUnits[match[2]], nil
+}
+
+// ParseColor returns the given color, in the #rrggbb notation
+func ParseColor(text string) (Color, error) {
+	if !colorPattern.MatchString(text) {
+		return Color{}, errors.Errorf("The color %s must be in the #rrggbb notation", text)
+	}
+	value, err := strconv.ParseUint(text[1:], 16, 32)
+	if err != nil {
+		return Color{}, errors.Wrapf(err, "Invalid color %s", text)
+	}
+	return Color{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value)}, nil
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"testing"
+
+	"github.com/chordflower/riconto/internal/model"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewOptions(t *testing.T) {
+	Convey("#NewOptions", t, func() {
+
+		Convey("It should render pdf files by default", func() {
+			options, err := NewOptions(&model.Settings{})
+			So(err, ShouldBeNil)
+			So(options.Formats, ShouldResemble, []string{DefaultFormat})
+		})
+
+		Convey("It should keep the first of the repeated formats", func() {
+			options, err := NewOptions(&model.Settings{Formats: []string{"pdf", "html", "pdf", "epub", "html"}})
+			So(err, ShouldBeNil)
+			So(options.Formats, ShouldResemble, []string{"pdf", "html", "epub"})
+		})
+
+		Convey("It should use the margins in the page", func() {
+			options, err := NewOptions(&model.Settings{PageSize: "a5", Margins: &model.Margins{Left: "20mm", Right: "1in"}})
+			So(err, ShouldBeNil)
+			So(options.Margins.Right, ShouldEqual, 72)
+		})
+
+		Convey("It should fail on margins that leave no space in the page", func() {
+			_, err := NewOptions(&model.Settings{PageSize: "a5", Margins: &model.Margins{Left: "100mm", Right: "100mm"}})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "The left and right margins (566.93pt) leave no space in the width of the page (419.53pt)")
+			_, err = NewOptions(&model.Settings{Margins: &model.Margins{Top: "15cm", Bottom: "15cm"}})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "The top and bottom margins")
+		})
+
+		Convey("It should fail on unknown formats", func() {
+			_, err := NewOptions(&model.Settings{Formats: []string{"pdf", "rtf"}})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "The format rtf is not supported")
+		})
+	})
+}
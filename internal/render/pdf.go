@@ -0,0 +1,521 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"maps"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/document"
+	"github.com/chordflower/riconto/internal/model"
+	"github.com/chordflower/riconto/pkg/pdf"
+	"github.com/spf13/afero"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+const (
+	pdfMargin = 56.0
+	// pdfWatermarkRatio is the length of the watermarks, as a ratio of the page diagonal.
+	pdfWatermarkRatio = 0.7
+	// pdfMaxPasses is the maximum number of layouts done to find the page numbers of the headings.
+	pdfMaxPasses = 4
+)
+
+// pdfFonts represents the fonts used to render a document
+type pdfFonts struct {
+	regular    pdf.Font
+	bold       pdf.Font
+	italic     pdf.Font
+	boldItalic pdf.Font
+	mono       pdf.Font
+}
+
+var (
+	defaultFonts     *pdfFonts
+	defaultFontsErr  error
+	defaultFontsOnce sync.Once
+)
+
+// loadDefaultFonts parses the builtin Go fonts, which are used when no other fonts are given
+func loadDefaultFonts() (*pdfFonts, error) {
+	defaultFontsOnce.Do(func() {
+		fonts := make([]pdf.Font, 0, 5)
+		for _, data := range [][]byte{goregular.TTF, gobold.TTF, goitalic.TTF, gobolditalic.TTF, gomono.TTF} {
+			font, err := pdf.ParseFont(data)
+			if err != nil {
+				defaultFontsErr = errors.Wrap(err, "Unable to parse the default fonts")
+				return
+			}
+			fonts = append(fonts, font)
+		}
+		defaultFonts = &pdfFonts{
+			regular:    fonts[0],
+			bold:       fonts[1],
+			italic:     fonts[2],
+			boldItalic: fonts[3],
+			mono:       fonts[4],
+		}
+	})
+	return defaultFonts, defaultFontsErr
+}
+
+// loadFonts returns the default fonts, replaced by the given font files when they are not empty
+func loadFonts(fs afero.Fs, files *model.Fonts) (*pdfFonts, error) {
+	defaults, err := loadDefaultFonts()
+	if err != nil {
+		return nil, err
+	}
+	result := *defaults
+	replacements := []struct {
+		path string
+		font *pdf.Font
+	}{
+		{files.Regular, &result.regular},
+		{files.Bold, &result.bold},
+		{files.Italic, &result.italic},
+		{files.BoldItalic, &result.boldItalic},
+		{files.Mono, &result.mono},
+	}
+	for _, replacement := range replacements {
+		if replacement.path == "" {
+			continue
+		}
+		data, err := afero.ReadFile(fs, replacement.path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to read the font %s", replacement.path)
+		}
+		if *replacement.font, err = pdf.ParseFont(data); err != nil {
+			return nil, errors.Wrapf(err, "Unable to parse the font %s", replacement.path)
+		}
+	}
+	return &result, nil
+}
+
+// PdfRenderer renders documents as pdf files
+type PdfRenderer struct {
+	options *Options
+}
+
+// NewPdfRenderer creates a new pdf renderer, with the given options
+func NewPdfRenderer(options *Options) *PdfRenderer {
+	return &PdfRenderer{options: options}
+}
+
+func (r *PdfRenderer) Name() string {
+	return "pdf"
+}
+
+func (r *PdfRenderer) Output(output string) string {
+	return output + ".pdf"
+}
+
+func (r *PdfRenderer) Render(doc *document.Document, fs afero.Fs, output string) error {
+	fonts, err := loadFonts(fs, &r.options.Fonts)
+	if err != nil {
+		return err
+	}
+	watermark, err := pdfWatermark(fonts.bold, r.options.Watermark)
+	if err != nil {
+		return err
+	}
+	file, err := createFile(fs, r.Output(output))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	// The page numbers of the table of contents are only known after the layout, so the layout is
+	// repeated with the numbers of the previous one, until they do not change
+	builder := &pdfBuilder{fonts: fonts, options: r.options, theme: r.options.Theme, blocks: doc.Blocks, pages: make(map[string]int)}
+	var pdfDocument *pdf.Document
+	for pass := 0; pass < pdfMaxPasses; pass++ {
+		pdfDocument = builder.layout()
+		pages := builder.anchorPages(pdfDocument)
+		if !builder.hasContents || maps.Equal(pages, builder.pages) {
+			break
+		}
+		builder.pages = pages
+	}
+	pdfDocument.SetBleed(r.options.Bleed, r.options.CropMarks)
+	if watermark != "" {
+		diagonal := math.Hypot(pdfDocument.Width(), pdfDocument.Height())
+		size := pdfWatermarkRatio * diagonal / fonts.bold.Width(watermark, 1)
+		pdfDocument.SetWatermark(watermark, fonts.bold, size, pdf.Color{R: 0.88, G: 0.88, B: 0.88})
+	}
+	pdfDocument.SetOutline(builder.outline(document.Contents(doc.Blocks, 6, builder.numbered)))
+	if doc.Meta != nil {
+		pdfDocument.SetInfo(pdf.Info{
+			Title:    doc.Meta.Title,
+			Authors:  doc.Meta.AuthorNames(),
+			Subject:  doc.Meta.Description,
+			Keywords: doc.Meta.Tags,
+			Creator:  "riconto",
+			Producer: "riconto",
+			Language: r.options.Language,
+			Created:  doc.Meta.Metadata.Created,
+			Modified: doc.Meta.Metadata.Modified,
+			Extra:    r.options.Metadata,
+		})
+	}
+	_, err = pdfDocument.WriteTo(file)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write the pdf file %s", r.Output(output))
+	}
+	return nil
+}
+
+// pdfWatermark returns the given watermark without its surrounding spaces, failing when it has
+// no width in the given font, since the size of the watermark depends on it
+func pdfWatermark(font pdf.Font, watermark string) (string, error) {
+	watermark = strings.TrimSpace(watermark)
+	if watermark != "" && font.Width(watermark, 1) <= 0 {
+		return "", errors.Errorf("The watermark %q has no width in the font %s", watermark, font.Name())
+	}
+	return watermark, nil
+}
+
+// pdfBuilder converts the document blocks into pdf layout blocks
+type pdfBuilder struct {
+	fonts   *pdfFonts
+	options *Options
+	theme   *Theme
+	blocks  []document.Block
+	// pages contains the page numbers of the headings, from the previous layout.
+	pages       map[string]int
+	hasContents bool
+	numbered    bool
+	// notes are the blocks of the converted footnotes, written after the last block of the document.
+	notes [][]pdf.Block
+}
+
+// layout places the document blocks in the pages of a new pdf document
+func (b *pdfBuilder) layout() *pdf.Document {
+	pdfDocument := pdf.NewDocument(b.options.PageSize[0], b.options.PageSize[1])
+	layout := pdf.NewLayout(pdfDocument, b.options.Margins)
+	b.notes = make([][]pdf.Block, 0)
+	layout.Add(b.convert(b.blocks)...)
+	if len(b.notes) > 0 {
+		layout.Add(&pdf.Rule{
+			Color:       b.theme.RuleColor.pdf(),
+			Width:       0.5,
+			SpaceBefore: b.theme.FontSize,
+			SpaceAfter:  b.theme.FontSize / 2,
+		})
+		for _, note := range b.notes {
+			layout.Add(note...)
+		}
+	}
+	return pdfDocument
+}
+
+// anchorPages returns the page numbers of the headings in the given pdf document
+func (b *pdfBuilder) anchorPages(pdfDocument *pdf.Document) map[string]int {
+	pages := make(map[string]int)
+	for _, heading := range document.Headings(b.blocks) {
+		if page, ok := pdfDocument.AnchorPage(heading.ID); ok {
+			pages[heading.ID] = page
+		}
+	}
+	return pages
+}
+
+// outline converts the table of contents entries into outline items
+func (b *pdfBuilder) outline(entries []*document.ContentsEntry) []*pdf.OutlineItem {
+	items := make([]*pdf.OutlineItem, 0, len(entries))
+	for _, entry := range entries {
+		items = append(items, &pdf.OutlineItem{
+			Title:    entry.Title(),
+			Anchor:   entry.Heading.ID,
+			Children: b.outline(entry.Children),
+		})
+	}
+	return items
+}
+
+// contents converts the table of contents entries into blocks, with the given indentation level
+func (b *pdfBuilder) contents(entries []*document.ContentsEntry, level int) []pdf.Block {
+	result := make([]pdf.Block, 0, len(entries))
+	for _, entry := range entries {
+		style := b.style(level == 0, false, b.theme.FontSize)
+		spans := make([]pdf.Span, 0)
+		if entry.Number != "" {
+			spans = append(spans, pdf.Span{Text: entry.Number + " ", Style: style})
+		}
+		spans = b.appendSpans(spans, entry.Heading.Content, style, level == 0, false)
+		number := ""
+		if page, ok := b.pages[entry.Heading.ID]; ok {
+			number = strconv.Itoa(page)
+		}
+		contentsEntry := &pdf.ContentsEntry{
+			Spans:      spans,
+			Number:     pdf.Span{Text: number, Style: style},
+			Indent:     float64(level) * b.theme.Indent,
+			Leading:    b.theme.Leading,
+			SpaceAfter: b.theme.FontSize / 4,
+		}
+		if level == 0 {
+			contentsEntry.SpaceBefore = b.theme.FontSize / 2
+		}
+		if entry.Heading.ID != "" {
+			contentsEntry.Link = "#" + entry.Heading.ID
+		}
+		result = append(result, contentsEntry)
+		result = append(result, b.contents(entry.Children, level+1)...)
+	}
+	return result
+}
+
+// style returns the text style for the given emphasis
+func (b *pdfBuilder) style(bold, italic bool, size float64) pdf.Style {
+	font := b.fonts.regular
+	switch {
+	case bold && italic:
+		font = b.fonts.boldItalic
+	case bold:
+		font = b.fonts.bold
+	case italic:
+		font = b.fonts.italic
+	}
+	return pdf.Style{Font: font, Size: size, Color: b.theme.TextColor.pdf()}
+}
+
+// convert converts the given blocks, expanding the included files and tables of contents
+func (b *pdfBuilder) convert(blocks []document.Block) []pdf.Block {
+	result := make([]pdf.Block, 0, len(blocks))
+	for _, block := range blocks {
+		switch value := block.(type) {
+		case *document.Include:
+			result = append(result, b.convert(value.Blocks)...)
+			continue
+		case *document.TableOfContents:
+			if !b.hasContents {
+				b.hasContents, b.numbered = true, value.Numbered
+			}
+			entries := b.contents(document.Contents(b.blocks, value.Depth, value.Numbered), 0)
+			if len(entries) > 0 {
+				entries[len(entries)-1].(*pdf.ContentsEntry).SpaceAfter = b.theme.FontSize
+			}
+			result = append(result, entries...)
+			continue
+		}
+		if converted := b.block(block); converted != nil {
+			result = append(result, converted)
+		}
+	}
+	return result
+}
+
+func (b *pdfBuilder) block(block document.Block) pdf.Block {
+	switch value := block.(type) {
+	case *document.Heading:
+		size := b.theme.HeadingSize(value.Level)
+		return &pdf.Paragraph{
+			Spans:        b.spans(value.Content, b.style(true, false, size)),
+			Leading:      1.2,
+			SpaceBefore:  size,
+			SpaceAfter:   size / 2,
+			KeepWithNext: true,
+			Anchor:       value.ID,
+		}
+	case *document.Paragraph:
+		return &pdf.Paragraph{
+			Spans:      b.spans(value.Content, b.style(false, false, b.theme.FontSize)),
+			Align:      pdf.AlignJustify,
+			Leading:    b.theme.Leading,
+			SpaceAfter: b.theme.FontSize / 2,
+		}
+	case *document.List:
+		list := &pdf.List{
+			Items:      make([]pdf.ListItem, 0, len(value.Items)),
+			Indent:     b.theme.Indent,
+			SpaceAfter: b.theme.FontSize / 2,
+		}
+		for i, item := range value.Items {
+			marker := "•"
+			if value.Ordered {
+				marker = strconv.Itoa(value.Start+i) + "."
+			}
+			blocks := b.convert(item.Blocks)
+			if value.Tight {
+				for _, child := range blocks {
+					if paragraph, ok := child.(*pdf.Paragraph); ok {
+						paragraph.SpaceAfter = 0
+					}
+				}
+			}
+			list.Items = append(list.Items, pdf.ListItem{
+				Marker: []pdf.Span{{Text: marker, Style: b.style(false, false, b.theme.FontSize)}},
+				Blocks: blocks,
+			})
+		}
+		return list
+	case *document.BlockQuote:
+		return &pdf.BlockQuote{
+			Blocks:     b.convert(value.Blocks),
+			Indent:     b.theme.Indent,
+			BarColor:   b.theme.RuleColor.pdf(),
+			BarWidth:   2,
+			SpaceAfter: b.theme.FontSize / 2,
+		}
+	case *document.CodeBlock:
+		return &pdf.CodeBlock{
+			Text:       value.Code,
+			Style:      pdf.Style{Font: b.fonts.mono, Size: b.theme.FontSize - 1.5, Color: b.theme.TextColor.pdf()},
+			Background: b.theme.CodeBackground.pdf(),
+			Padding:    b.theme.FontSize / 2,
+			Leading:    1.3,
+			SpaceAfter: b.theme.FontSize / 2,
+		}
+	case *document.ThematicBreak:
+		return &pdf.Rule{
+			Color:       b.theme.RuleColor.pdf(),
+			Width:       0.5,
+			SpaceBefore: b.theme.FontSize / 2,
+			SpaceAfter:  b.theme.FontSize,
+		}
+	case *document.Table:
+		background := b.theme.CodeBackground.pdf()
+		table := &pdf.Table{
+			Header:           b.cells(value.Header, value.Alignments, true),
+			Rows:             make([][]pdf.TableCell, 0, len(value.Rows)),
+			Padding:          b.theme.FontSize / 3,
+			Leading:          b.theme.Leading,
+			BorderColor:      b.theme.RuleColor.pdf(),
+			BorderWidth:      0.5,
+			HeaderBackground: &background,
+			SpaceAfter:       b.theme.FontSize / 2,
+		}
+		for _, row := range value.Rows {
+			table.Rows = append(table.Rows, b.cells(row, value.Alignments, false))
+		}
+		return table
+	}
+	return nil
+}
+
+// cells converts the cells of a table row, aligned as their columns
+func (b *pdfBuilder) cells(cells []*document.TableCell, alignments []string, header bool) []pdf.TableCell {
+	result := make([]pdf.TableCell, 0, len(cells))
+	for i, cell := range cells {
+		align := pdf.AlignLeft
+		if i < len(alignments) {
+			switch alignments[i] {
+			case document.AlignCenter:
+				align = pdf.AlignCenter
+			case document.AlignRight:
+				align = pdf.AlignRight
+			}
+		}
+		result = append(result, pdf.TableCell{
+			Spans: b.spans(cell.Content, b.style(header, false, b.theme.FontSize)),
+			Align: align,
+		})
+	}
+	return result
+}
+
+// footnote converts the blocks of a footnote into a note, returning the marker that links to it
+func (b *pdfBuilder) footnote(footnote *document.Footnote, style pdf.Style) pdf.Span {
+	number := strconv.Itoa(len(b.notes) + 1)
+	anchor := "footnote-" + number
+	// The note is reserved before converting its blocks, so that any nested notes follow it
+	index := len(b.notes)
+	b.notes = append(b.notes, nil)
+	label := pdf.Span{Text: number + ". ", Style: b.style(true, false, b.theme.FontSize)}
+	blocks := b.convert(footnote.Blocks)
+	if paragraph, ok := firstParagraph(blocks); ok {
+		paragraph.Spans = append([]pdf.Span{label}, paragraph.Spans...)
+		paragraph.Anchor = anchor
+	} else {
+		blocks = append([]pdf.Block{&pdf.Paragraph{Spans: []pdf.Span{label}, Anchor: anchor}}, blocks...)
+	}
+	b.notes[index] = blocks
+	marker := style
+	marker.Color = b.theme.LinkColor.pdf()
+	marker.Size = style.Size * 0.8
+	marker.Link = "#" + anchor
+	return pdf.Span{Text: "[" + number + "]", Style: marker}
+}
+
+// firstParagraph returns the first of the given blocks when it is a paragraph
+func firstParagraph(blocks []pdf.Block) (*pdf.Paragraph, bool) {
+	if len(blocks) == 0 {
+		return nil, false
+	}
+	paragraph, ok := blocks[0].(*pdf.Paragraph)
+	return paragraph, ok
+}
+
+// spans converts the inlines into text spans, starting with the given style
+func (b *pdfBuilder) spans(inlines []document.Inline, style pdf.Style) []pdf.Span {
+	result := make([]pdf.Span, 0, len(inlines))
+	return b.appendSpans(result, inlines, style, false, false)
+}
+
+func (b *pdfBuilder) appendSpans(result []pdf.Span, inlines []document.Inline, style pdf.Style, bold, italic bool) []pdf.Span {
+	bold = bold || style.Font == b.fonts.bold || style.Font == b.fonts.boldItalic
+	for _, inline := range inlines {
+		switch value := inline.(type) {
+		case *document.Text:
+			result = append(result, pdf.Span{Text: value.Value, Style: style})
+		case *document.Break:
+			if value.Hard {
+				result = append(result, pdf.Span{Text: "\n", Style: style})
+			} else {
+				result = append(result, pdf.Span{Text: " ", Style: style})
+			}
+		case *document.Emphasis:
+			strong := bold || value.Level >= 2
+			emphasized := italic || value.Level == 1
+			inner := b.style(strong, emphasized, style.Size)
+			inner.Color, inner.Link = style.Color, style.Link
+			result = b.appendSpans(result, value.Content, inner, strong, emphasized)
+		case *document.Code:
+			code := style
+			code.Font = b.fonts.mono
+			code.Size = style.Size * 0.9
+			result = append(result, pdf.Span{Text: value.Value, Style: code})
+		case *document.Link:
+			link := style
+			link.Color = b.theme.LinkColor.pdf()
+			link.Link = value.Destination
+			result = b.appendSpans(result, value.Content, link, bold, italic)
+		case *document.Image:
+			alt := b.style(bold, true, style.Size)
+			alt.Color = b.theme.RuleColor.pdf()
+			result = append(result, pdf.Span{Text: "[" + strings.TrimSpace(value.Alt) + "]", Style: alt})
+		case *document.Footnote:
+			result = append(result, b.footnote(value, style))
+		case *document.Citation:
+			result = append(result, pdf.Span{Text: value.Text(), Style: style})
+		}
+	}
+	return result
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"testing"
+
+	"github.com/chordflower/riconto/internal/markdown"
+	"github.com/chordflower/riconto/pkg/pdf"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+// zeroWidthFont is a font whose glyphs have no width
+type zeroWidthFont struct {
+	pdf.Font
+}
+
+func (f zeroWidthFont) Width(string, float64) float64 {
+	return 0
+}
+
+func TestPdfRenderer(t *testing.T) {
+	Convey("#PdfRenderer", t, func() {
+		fs := afero.NewMemMapFs()
+		So(afero.WriteFile(fs, "src/main.md", []byte("# Book\n\nSome text.\n"), 0o644), ShouldBeNil)
+		doc, _, err := markdown.NewParser(fs).ParseFile("src/main.md")
+		So(err, ShouldBeNil)
+
+		Convey("It should not write a watermark made of spaces", func() {
+			So(NewPdfRenderer(DefaultOptions()).Render(doc, fs, "dist/plain"), ShouldBeNil)
+			options := DefaultOptions()
+			options.Watermark = " \t "
+			So(NewPdfRenderer(options).Render(doc, fs, "dist/spaces"), ShouldBeNil)
+			plain, err := afero.ReadFile(fs, "dist/plain.pdf")
+			So(err, ShouldBeNil)
+			spaces, err := afero.ReadFile(fs, "dist/spaces.pdf")
+			So(err, ShouldBeNil)
+			So(spaces, ShouldResemble, plain)
+		})
+
+		Convey("It should fail on a watermark without width", func() {
+			fonts, err := loadDefaultFonts()
+			So(err, ShouldBeNil)
+			watermark, err := pdfWatermark(fonts.bold, " DRAFT ")
+			So(err, ShouldBeNil)
+			So(watermark, ShouldEqual, "DRAFT")
+			_, err = pdfWatermark(zeroWidthFont{fonts.bold}, "DRAFT")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "The watermark \"DRAFT\" has no width in the font")
+		})
+	})
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/internal/document"
+	"github.com/spf13/afero"
+)
+
+// Renderer renders a document into one output format
+type Renderer interface {
+	// Name returns the name of the output format.
+	Name() string
+
+	// Render renders the document into the given output path, which has no extension,
+	// in the given filesystem.
+	Render(doc *document.Document, fs afero.Fs, output string) error
+
+	// Output returns the path of the file or directory written for the given output path.
+	Output(output string) string
+}
+
+const (
+	// officeFont and officeMonoFont are the fonts of the text and of the code of the word processor
+	// documents, since the fonts of the settings are not embedded in them.
+	officeFont     = "Arial"
+	officeMonoFont = "Courier New"
+)
+
+// Formats are the names of the formats of the renderers
+var Formats = []string{"pdf", "html", "epub", "docx", "odt", "latex"}
+
+// NewRenderer returns the renderer of the given format, with the given options
+func NewRenderer(format string, options *Options) (Renderer, error) {
+	switch format {
+	case "pdf":
+		return NewPdfRenderer(options), nil
+	case "html":
+		return NewHtmlRenderer(options), nil
+	case "epub":
+		return NewEpubRenderer(options), nil
+	case "docx":
+		return NewDocxRenderer(options), nil
+	case "odt":
+		return NewOdtRenderer(options), nil
+	case "latex":
+		return NewLatexRenderer(options), nil
+	}
+	return nil, errors.Errorf("The format %s is not supported, use %s", format, strings.Join(Formats, ", "))
+}
+
+// formatNumber formats a length or percentage for the documents, rounded to hundredths and
+// without trailing zeros
+func formatNumber(value float64) string {
+	return formatCss(math.Round(value*100) / 100)
+}
+
+// createFile creates the given file and its parent directories, truncating it if it exists
+func createFile(fs afero.Fs, filename string) (afero.File, error) {
+	err := fs.MkdirAll(path.Dir(filename), 0750)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to create the output directory of %s", filename)
+	}
+	file, err := fs.OpenFile(filename, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to create the output file %s", filename)
+	}
+	return file, nil
+}
+
+// headingStarts records in starts the identifier of the first heading of the given file, with the
+// given blocks, and of the files it includes
+func headingStarts(starts map[string]string, filename string, blocks []document.Block) {
+	if headings := document.Headings(blocks); len(headings) > 0 && headings[0].ID != "" {
+		if _, ok := starts[filename]; !ok {
+			starts[filename] = headings[0].ID
+		}
+	}
+	for _, block := range blocks {
+		if include, ok := block.(*document.Include); ok {
+			headingStarts(starts, include.Path, include.Blocks)
+		}
+	}
+}
+
+// headingTarget returns the identifier of the heading that a link in the given source file points
+// to, when it points to an heading of the document or to an included file, whose first headings are
+// in starts
+func headingTarget(destination, source string, starts map[string]string) (string, bool) {
+	target, err := url.Parse(destination)
+	if err != nil || target.Scheme != "" || target.Host != "" || path.IsAbs(target.Path) {
+		return "", false
+	}
+	if target.Path != "" && path.Ext(target.Path) != ".md" {
+		return "", false
+	}
+	if target.Fragment != "" {
+		return target.Fragment, true
+	}
+	id, ok := starts[path.Join(path.Dir(source), target.Path)]
+	return id, ok && target.Path != ""
+}
+
+// embeddedImage is an image of the project, embedded in the documents that do not link to their images
+type embeddedImage struct {
+	// path is the path of the image file in the project.
+	path string
+	data []byte
+	// format is the name of the image format, which is also its file extension.
+	format string
+	// width and height are the size of the image in points, with 96 pixels per inch.
+	width  float64
+	height float64
+}
+
+// imagePath returns the path of the image in the given destination, of the given source file,
+// when it is a file of the project
+func imagePath(destination, source string) (string, bool) {
+	target, err := url.Parse(destination)
+	if err != nil || target.Scheme != "" || target.Host != "" || target.Path == "" || path.IsAbs(target.Path) {
+		return "", false
+	}
+	return path.Join(path.Dir(source), target.Path), true
+}
+
+// readImage reads the image in the given destination, of the given source file, when it is a png,
+// jpeg or gif file of the project, reducing its size to fit the given width
+func readImage(fs afero.Fs, destination, source string, width float64) (*embeddedImage, bool) {
+	filename, ok := imagePath(destination, source)
+	if !ok {
+		return nil, false
+	}
+	data, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return nil, false
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+	result := &embeddedImage{
+		path:   filename,
+		data:   data,
+		format: format,
+		width:  float64(config.Width) * 0.75,
+		height: float64(config.Height) * 0.75,
+	}
+	if result.width > width {
+		result.width, result.height = width, result.height*width/result.width
+	}
+	return result, true
+}
+
+// Sources returns the files, other than its markdown files, that the renderers read to render the
+// given document with the given options, which are the images it shows, the files of the resources
+// directory, the fonts and the bibliography, when they exist
+func Sources(fs afero.Fs, doc *document.Document, options *Options) ([]string, error) {
+	result := make([]string, 0)
+	add := func(filename string) {
+		filename = path.Clean(filename)
+		if info, err := fs.Stat(filename); err == nil && !info.IsDir() && !slices.Contains(result, filename) {
+			result = append(result, filename)
+		}
+	}
+	imageSources(doc.Blocks, doc.Path, add)
+	if _, err := fs.Stat(ResourcesDir); err == nil {
+		err = afero.Walk(fs, ResourcesDir, func(filename string, info os.FileInfo, err error) error {
+			if err != nil {
+				return errors.Wrapf(err, "Unable to read the directory %s", ResourcesDir)
+			}
+			add(filepath.ToSlash(filename))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, font := range []string{options.Fonts.Regular, options.Fonts.Bold, options.Fonts.Italic, options.Fonts.BoldItalic, options.Fonts.Mono} {
+		if font != "" {
+			add(font)
+		}
+	}
+	if doc.Meta != nil && doc.Meta.Bibliography != "" {
+		add(path.Join(path.Dir(doc.Path), doc.Meta.Bibliography))
+	}
+	slices.Sort(result)
+	return result, nil
+}
+
+// imageSources calls add with the path of each image of the given blocks, of the given source file
+func imageSources(blocks []document.Block, source string, add func(filename string)) {
+	inlines := func(values []document.Inline) {
+		imageInlines(values, source, add)
+	}
+	for _, block := range blocks {
+		switch value := block.(type) {
+		case *document.Heading:
+			inlines(value.Content)
+		case *document.Paragraph:
+			inlines(value.Content)
+		case *document.List:
+			for _, item := range value.Items {
+				imageSources(item.Blocks, source, add)
+			}
+		case *document.ListItem:
+			imageSources(value.Blocks, source, add)
+		case *document.BlockQuote:
+			imageSources(value.Blocks, source, add)
+		case *document.Table:
+			for _, cell := range value.Header {
+				inlines(cell.Content)
+			}
+			for _, row := range value.Rows {
+				for _, cell := range row {
+					inlines(cell.Content)
+				}
+			}
+		case *document.Include:
+			imageSources(value.Blocks, value.Path, add)
+		}
+	}
+}
+
+// imageInlines calls add with the path of each image of the given inlines, of the given source file
+func imageInlines(inlines []document.Inline, source string, add func(filename string)) {
+	for _, inline := range inlines {
+		switch value := inline.(type) {
+		case *document.Image:
+			if filename, ok := imagePath(value.Destination, source); ok {
+				add(filename)
+			}
+		case *document.Emphasis:
+			imageInlines(value.Content, source, add)
+		case *document.Link:
+			imageInlines(value.Content, source, add)
+		case *document.Footnote:
+			imageSources(value.Blocks, source, add)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package render
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/chordflower/riconto/pkg/pdf"
+)
+
+// DefaultTheme is the name of the theme used when none is given
+const DefaultTheme = "default"
+
+// Color represents a rgb color, with components from 0 to 255
+type Color struct {
+	R, G, B uint8
+}
+
+// Hex returns the color in the #rrggbb notation
+func (c Color) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// pdf returns the color with the components used by the pdf files
+func (c Color) pdf() pdf.Color {
+	return pdf.Color{R: float64(c.R) / 255, G: float64(c.G) / 255, B: float64(c.B) / 255}
+}
+
+// Theme represents the look of the rendered documents
+type Theme struct {
+	// Name is the name of the theme, used in the configuration.
+	Name string
+	// FontSize is the size of the body text, in points.
+	FontSize float64
+	// Leading is the line height of the body text, as a multiple of the font size.
+	Leading float64
+	// Indent is the indentation of lists, quotes and table of contents levels, in points.
+	Indent float64
+	// HeadingScales are the font sizes of the headings of each level, as multiples of the font size.
+	HeadingScales [6]float64
+	// TextColor is the color of the text.
+	TextColor Color
+	// LinkColor is the color of the links.
+	LinkColor Color
+	// RuleColor is the color of the thematic breaks, the bars of the quotes and the image descriptions.
+	RuleColor Color
+	// CodeBackground is the background color of the code blocks.
+	CodeBackground Color
+}
+
+// HeadingSize returns the font size of the headings of the given level
+func (t *Theme) HeadingSize(level int) float64 {
+	return t.FontSize * t.HeadingScales[min(max(level, 1), 6)-1]
+}
+
+// themes are the builtin themes, by name
+var themes = map[string]*Theme{
+	DefaultTheme: {
+		Name:           DefaultTheme,
+		FontSize:       10.5,
+		Leading:        1.4,
+		Indent:         18,
+		HeadingScales:  [6]float64{2, 1.6, 1.35, 1.2, 1.1, 1},
+		TextColor:      Color{0, 0, 0},
+		LinkColor:      Color{0, 51, 204},
+		RuleColor:      Color{128, 128, 128},
+		CodeBackground: Color{240, 240, 240},
+	},
+	"book": {
+		Name:           "book",
+		FontSize:       11.5,
+		Leading:        1.5,
+		Indent:         20,
+		HeadingScales:  [6]float64{1.8, 1.5, 1.3, 1.15, 1.05, 1},
+		TextColor:      Color{26, 26, 26},
+		LinkColor:      Color{26, 26, 26},
+		RuleColor:      Color{102, 102, 102},
+		CodeBackground: Color{245, 245, 245},
+	},
+	"compact": {
+		Name:           "compact",
+		FontSize:       9,
+		Leading:        1.3,
+		Indent:         14,
+		HeadingScales:  [6]float64{1.7, 1.45, 1.25, 1.1, 1.05, 1},
+		TextColor:      Color{0, 0, 0},
+		LinkColor:      Color{0, 77, 153},
+		RuleColor:      Color{140, 140, 140},
+		CodeBackground: Color{240, 240, 240},
+	},
+}
+
+// ThemeNames returns the names of the builtin themes, sorted
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// FindTheme returns the builtin theme with the given name, or the default one when it is empty
+func FindTheme(name string) (*Theme, error) {
+	if name == "" {
+		name = DefaultTheme
+	}
+	theme, ok := themes[name]
+	if !ok {
+		return nil, errors.Errorf("There is no theme named %s, use %s", name, strings.Join(ThemeNames(), ", "))
+	}
+	return theme, nil
+}
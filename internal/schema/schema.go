@@ -0,0 +1,403 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package schema generates the json schemas of the riconto files from the go types that
+// represent them, reading their sources so that their doc comments become descriptions.
+//
+// The properties of an object are the fields of its struct, named by their json tag, and
+// described by their doc comments, without the field name that starts them. The jsonschema
+// tag adds the rules of a field, as a comma separated list of:
+//
+//   - required => The property is required in its object;
+//   - minLength=<n> => The minimum length of a string;
+//   - minimum=<n> => The minimum value of a number;
+//   - pattern=<regexp> => The regular expression that a string must match, without commas;
+//   - format=<format> => The format of a string, like idn-email;
+//   - enum=<value> => One of the allowed values of the property, or of the items of an array,
+//     it can be given more than once;
+//   - default=<value> => The default value of the property;
+//   - example=<value> => An example of the value, it can be given more than once.
+//
+// All the objects are closed, they do not accept properties that are not fields, and the fields
+// of embedded structs are properties of the object that embeds them. Maps with string keys are
+// objects whose properties are their values.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"emperror.dev/errors"
+)
+
+// Draft is the json schema version of the generated schemas
+const Draft = "http://json-schema.org/draft-07/schema"
+
+// Options are the options of the schema generation
+type Options struct {
+	// Dir is the directory of the go package with the types.
+	Dir string
+	// Type is the name of the struct type of the root object.
+	Type string
+	// Title is the title of the schema.
+	Title string
+	// Description is the description of the schema.
+	Description string
+}
+
+// Schema is a json schema, with its keywords in the order they are written
+type Schema struct {
+	Schema               string      `json:"$schema,omitempty"`
+	Title                string      `json:"title,omitempty"`
+	Type                 string      `json:"type,omitempty"`
+	Description          string      `json:"description,omitempty"`
+	Format               string      `json:"format,omitempty"`
+	MinLength            *int        `json:"minLength,omitempty"`
+	Minimum              *float64    `json:"minimum,omitempty"`
+	Pattern              string      `json:"pattern,omitempty"`
+	Enum                 []any       `json:"enum,omitempty"`
+	Default              any         `json:"default,omitempty"`
+	Examples             []any       `json:"examples,omitempty"`
+	AdditionalProperties any         `json:"additionalProperties,omitempty"`
+	Required             []string    `json:"required,omitempty"`
+	Properties           *Properties `json:"properties,omitempty"`
+	Items                *Schema     `json:"items,omitempty"`
+}
+
+// Properties are the properties of an object schema, in the order of the fields
+type Properties struct {
+	Names   []string
+	Schemas map[string]*Schema
+}
+
+// Add adds a property to the end of the properties
+func (p *Properties) Add(name string, schema *Schema) {
+	p.Names = append(p.Names, name)
+	p.Schemas[name] = schema
+}
+
+func (p *Properties) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for index, name := range p.Names {
+		if index > 0 {
+			buffer.WriteByte(',')
+		}
+		key, err := marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshal(p.Schemas[name])
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// marshal encodes the given value as json, without escaping html characters
+func marshal(value any) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte{'\n'}), nil
+}
+
+// Generate generates the json schema of the given type, indented with two spaces
+func Generate(options Options) ([]byte, error) {
+	types, err := structTypes(options.Dir)
+	if err != nil {
+		return nil, err
+	}
+	generator := &generator{types: types, visiting: make(map[string]bool)}
+	root, err := generator.object(options.Type)
+	if err != nil {
+		return nil, err
+	}
+	root.Schema = Draft
+	root.Title = options.Title
+	root.Description = options.Description
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(root); err != nil {
+		return nil, errors.Wrapf(err, "Unable to encode the schema of %s", options.Type)
+	}
+	return buffer.Bytes(), nil
+}
+
+// structTypes returns the struct types declared in the go files of the given directory, without its tests
+func structTypes(dir string) (map[string]*ast.StructType, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read the package in %s", dir)
+	}
+	types := make(map[string]*ast.StructType)
+	fileSet := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".go" || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fileSet, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to parse %s", filepath.Join(dir, name))
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			if spec, ok := node.(*ast.TypeSpec); ok {
+				if structType, ok := spec.Type.(*ast.StructType); ok {
+					types[spec.Name.Name] = structType
+				}
+			}
+			return true
+		})
+	}
+	return types, nil
+}
+
+// generator generates the schemas of the struct types of a package
+type generator struct {
+	types map[string]*ast.StructType
+	// visiting has the types being generated, to fail on recursive types
+	visiting map[string]bool
+}
+
+// object returns the schema of the struct type with the given name
+func (g *generator) object(name string) (*Schema, error) {
+	structType, ok := g.types[name]
+	if !ok {
+		return nil, errors.Errorf("There is no struct type %s in the package", name)
+	}
+	if g.visiting[name] {
+		return nil, errors.Errorf("The type %s is recursive", name)
+	}
+	g.visiting[name] = true
+	defer delete(g.visiting, name)
+
+	result := &Schema{
+		Type:                 "object",
+		AdditionalProperties: false,
+		Properties:           &Properties{Schemas: make(map[string]*Schema)},
+	}
+	for _, field := range structType.Fields.List {
+		if len(field.Names) == 0 {
+			if err := g.embed(result, field.Type); err != nil {
+				return nil, errors.Wrapf(err, "Unable to generate the schema of %s", name)
+			}
+			continue
+		}
+		if !field.Names[0].IsExported() {
+			continue
+		}
+		tag := reflect.StructTag("")
+		if field.Tag != nil {
+			value, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Invalid tag in %s.%s", name, field.Names[0].Name)
+			}
+			tag = reflect.StructTag(value)
+		}
+		property, _, _ := strings.Cut(tag.Get("json"), ",")
+		if property == "-" {
+			continue
+		}
+		if property == "" {
+			property = field.Names[0].Name
+		}
+		schema, err := g.field(field.Type)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to generate the schema of %s.%s", name, field.Names[0].Name)
+		}
+		schema.Description = description(field.Names[0].Name, field.Doc)
+		required, err := rules(schema, tag.Get("jsonschema"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid jsonschema tag in %s.%s", name, field.Names[0].Name)
+		}
+		if required {
+			result.Required = append(result.Required, property)
+		}
+		result.Properties.Add(property, schema)
+	}
+	return result, nil
+}
+
+// embed adds the properties of the embedded struct with the given type to the given object
+func (g *generator) embed(object *Schema, expression ast.Expr) error {
+	if star, ok := expression.(*ast.StarExpr); ok {
+		expression = star.X
+	}
+	ident, ok := expression.(*ast.Ident)
+	if !ok {
+		return errors.Errorf("The embedded type %T is not supported", expression)
+	}
+	embedded, err := g.object(ident.Name)
+	if err != nil {
+		return err
+	}
+	for _, name := range embedded.Properties.Names {
+		object.Properties.Add(name, embedded.Properties.Schemas[name])
+	}
+	object.Required = append(object.Required, embedded.Required...)
+	return nil
+}
+
+// field returns the schema of a field with the given type
+func (g *generator) field(expression ast.Expr) (*Schema, error) {
+	switch expression := expression.(type) {
+	case *ast.StarExpr:
+		return g.field(expression.X)
+	case *ast.ArrayType:
+		items, err := g.field(expression.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case *ast.MapType:
+		if key, ok := expression.Key.(*ast.Ident); !ok || key.Name != "string" {
+			return nil, errors.New("Only maps with string keys are supported")
+		}
+		values, err := g.field(expression.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case *ast.Ident:
+		switch expression.Name {
+		case "string":
+			return &Schema{Type: "string"}, nil
+		case "bool":
+			return &Schema{Type: "boolean"}, nil
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+			return &Schema{Type: "integer"}, nil
+		case "float32", "float64":
+			return &Schema{Type: "number"}, nil
+		default:
+			return g.object(expression.Name)
+		}
+	}
+	return nil, errors.Errorf("The type %T is not supported", expression)
+}
+
+// rules adds the rules of the given jsonschema tag to the schema, returning if it is required
+func rules(schema *Schema, tag string) (bool, error) {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch key {
+		case "":
+		case "required":
+			required = true
+		case "minLength":
+			length, err := strconv.Atoi(value)
+			if err != nil {
+				return false, errors.Wrapf(err, "Invalid minLength %s", value)
+			}
+			schema.MinLength = &length
+		case "minimum":
+			minimum, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, errors.Wrapf(err, "Invalid minimum %s", value)
+			}
+			schema.Minimum = &minimum
+		case "pattern":
+			schema.Pattern = value
+		case "format":
+			schema.Format = value
+		case "enum":
+			target := schema
+			if schema.Type == "array" {
+				target = schema.Items
+			}
+			typed, err := typedValue(target.Type, value)
+			if err != nil {
+				return false, errors.Wrapf(err, "Invalid enum value %s", value)
+			}
+			target.Enum = append(target.Enum, typed)
+		case "default":
+			typed, err := typedValue(schema.Type, value)
+			if err != nil {
+				return false, errors.Wrapf(err, "Invalid default %s", value)
+			}
+			schema.Default = typed
+		case "example":
+			typed, err := typedValue(schema.Type, value)
+			if err != nil {
+				return false, errors.Wrapf(err, "Invalid example %s", value)
+			}
+			schema.Examples = append(schema.Examples, typed)
+		default:
+			return false, errors.Errorf("Unknown rule %s", key)
+		}
+	}
+	return required, nil
+}
+
+// typedValue converts the text of a value in a tag to the given schema type
+func typedValue(schemaType string, value string) (any, error) {
+	switch schemaType {
+	case "integer":
+		return strconv.ParseInt(value, 10, 64)
+	case "number":
+		return strconv.ParseFloat(value, 64)
+	case "boolean":
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
+
+// description returns the description of a field from its doc comment, without the field
+// name and verb that start it, and the final period
+func description(name string, doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	text := strings.Join(strings.Fields(doc.Text()), " ")
+	for _, verb := range []string{" is ", " are "} {
+		if rest, ok := strings.CutPrefix(text, name+verb); ok {
+			text = rest
+			break
+		}
+	}
+	text = strings.TrimSuffix(text, ".")
+	if text == "" {
+		return ""
+	}
+	first, size := utf8.DecodeRuneInString(text)
+	return string(unicode.ToUpper(first)) + text[size:]
+}
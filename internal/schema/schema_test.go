@@ -0,0 +1,132 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package schema
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const shelfSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema",
+  "title": "shelf",
+  "type": "object",
+  "description": "A shelf of books",
+  "additionalProperties": false,
+  "required": [
+    "name",
+    "room"
+  ],
+  "properties": {
+    "name": {
+      "type": "string",
+      "description": "The name of the shelf",
+      "minLength": 1,
+      "examples": [
+        "Fiction",
+        "Poetry"
+      ]
+    },
+    "books": {
+      "type": "array",
+      "description": "The books in the shelf",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "properties": {
+          "Title": {
+            "type": "string",
+            "description": "The title of the book, with <em>markup</em>"
+          },
+          "tags": {
+            "type": "array",
+            "description": "Some tags",
+            "items": {
+              "type": "string",
+              "enum": [
+                "new",
+                "used"
+              ]
+            }
+          },
+          "price": {
+            "type": "number",
+            "minimum": 0
+          },
+          "read": {
+            "type": "boolean"
+          }
+        }
+      }
+    },
+    "size": {
+      "type": "integer",
+      "description": "The number of books that fit in the shelf",
+      "enum": [
+        10,
+        20
+      ],
+      "default": 10
+    },
+    "notes": {
+      "type": "object",
+      "description": "Notes about the books, by title",
+      "additionalProperties": {
+        "type": "string"
+      }
+    },
+    "room": {
+      "type": "string",
+      "description": "The room where it is",
+      "pattern": "^[A-Z][0-9]+$"
+    }
+  }
+}
+`
+
+func TestGenerate(t *testing.T) {
+	Convey("#Generate", t, func() {
+
+		Convey("It should generate the schema of a type from its fields, tags and comments", func() {
+			data, err := Generate(Options{Dir: "testdata", Type: "Shelf", Title: "shelf", Description: "A shelf of books"})
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, shelfSchema)
+		})
+
+		Convey("It should fail on unknown types", func() {
+			_, err := Generate(Options{Dir: "testdata", Type: "Table"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "There is no struct type Table in the package")
+		})
+
+		Convey("It should fail on recursive types", func() {
+			_, err := Generate(Options{Dir: "testdata", Type: "Loop"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "The type Loop is recursive")
+		})
+
+		Convey("It should fail on unknown rules", func() {
+			_, err := Generate(Options{Dir: "testdata", Type: "Invalid"})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "Unknown rule unknown")
+		})
+	})
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Command schemagen writes the json schema of a go struct type, to be used with go generate:
+//
+//	//go:generate go run ../schema/schemagen -type Config -output ../../api/config.schema.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/chordflower/riconto/internal/schema"
+)
+
+func main() {
+	options := schema.Options{}
+	output := ""
+	flag.StringVar(&options.Dir, "dir", ".", "The directory of the go package with the type")
+	flag.StringVar(&options.Type, "type", "", "The name of the struct type of the root object")
+	flag.StringVar(&options.Title, "title", "", "The title of the schema")
+	flag.StringVar(&options.Description, "description", "", "The description of the schema")
+	flag.StringVar(&output, "output", "", "The file where to write the schema, by default the standard output")
+	flag.Parse()
+	if options.Type == "" {
+		fmt.Fprintln(os.Stderr, "The type is required")
+		os.Exit(2)
+	}
+
+	data, err := schema.Generate(options)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = os.WriteFile(output, data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
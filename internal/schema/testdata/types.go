@@ -0,0 +1,43 @@
+package testdata
+
+// Shelf is a shelf of books
+type Shelf struct {
+	// Name is the name of the shelf.
+	Name string `json:"name" jsonschema:"required,minLength=1,example=Fiction,example=Poetry"`
+	// Books are the books in the shelf.
+	Books []*Book `json:"books,omitempty"`
+	// Size is the number of books that fit in the shelf.
+	Size int `json:"size" jsonschema:"enum=10,enum=20,default=10"`
+	// Notes are notes about the books, by title.
+	Notes map[string]string `json:"notes,omitempty"`
+	Place
+	// Hidden fields are not in the schema.
+	Hidden  bool `json:"-"`
+	private string
+}
+
+// Book is a book
+type Book struct {
+	// The title of the book, with <em>markup</em>.
+	Title string
+	// Tags are some tags.
+	Tags  []string `json:"tags" jsonschema:"enum=new,enum=used"`
+	Price float64  `json:"price" jsonschema:"minimum=0"`
+	Read  bool     `json:"read"`
+}
+
+// Place is where something is
+type Place struct {
+	// Room is the room where it is.
+	Room string `json:"room" jsonschema:"required,pattern=^[A-Z][0-9]+$"`
+}
+
+// Loop is a recursive type
+type Loop struct {
+	Next *Loop `json:"next"`
+}
+
+// Invalid has an unknown rule
+type Invalid struct {
+	Name string `json:"name" jsonschema:"unknown"`
+}
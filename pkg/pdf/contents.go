@@ -0,0 +1,107 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pdf
+
+import "strings"
+
+// leaderGap is the minimum space between the text of a contents entry and its leader dots
+const leaderGap = 6.0
+
+// ContentsEntry represents an entry of a table of contents, with the text at the left and
+// the page number at the right, joined by a dotted leader
+type ContentsEntry struct {
+	Spans []Span
+	// Number is the page number, written at the right of the last line.
+	Number Span
+	// Indent is the space at the left of the entry, to show its level.
+	Indent float64
+	// Link is the destination of the entry, where "#name" links to an anchor.
+	Link        string
+	Leading     float64
+	SpaceBefore float64
+	SpaceAfter  float64
+}
+
+// reserved returns the width kept at the right of the entry for the page number, which does not
+// depend on the number itself, so that the entry has the same lines for any number up to 9999
+func (e *ContentsEntry) reserved() float64 {
+	style := e.Number.Style
+	return max(style.Font.Width("0000", style.Size), style.Font.Width(e.Number.Text, style.Size)) + 2*leaderGap
+}
+
+func (e *ContentsEntry) lines(l *Layout) []*line {
+	spans := make([]Span, len(e.Spans))
+	for i, span := range e.Spans {
+		span.Style.Link = e.Link
+		spans[i] = span
+	}
+	leading := e.Leading
+	if leading <= 0 {
+		leading = defaultLeading
+	}
+	return breakLines(spans, l.right-l.left-e.Indent-e.reserved(), leading)
+}
+
+func (e *ContentsEntry) layout(l *Layout) {
+	l.space(e.SpaceBefore)
+	lines := e.lines(l)
+	height := 0.0
+	for _, line := range lines {
+		height += line.height
+	}
+	l.ensure(height)
+	l.indent(e.Indent)
+	defer l.indent(-e.Indent)
+	for i, line := range lines {
+		if i < len(lines)-1 {
+			line.draw(l, AlignLeft, false)
+			continue
+		}
+		baseline := l.y - (line.height-line.ascent-line.descent)/2 - line.ascent
+		line.draw(l, AlignLeft, true)
+		e.drawNumber(l, baseline, l.left+line.width, line.ascent, line.descent)
+	}
+	l.space(e.SpaceAfter)
+}
+
+// drawNumber writes the leader dots, from the end of the text, and the page number
+func (e *ContentsEntry) drawNumber(l *Layout, baseline, end, ascent, descent float64) {
+	style := e.Number.Style
+	width := style.Font.Width(e.Number.Text, style.Size)
+	x := l.right - width
+	l.page.ColoredText(x, baseline, style.Font, style.Size, style.Color, e.Number.Text)
+	dot := style.Font.Width(". ", style.Size)
+	if count := int((x - leaderGap - end - leaderGap) / dot); count > 0 && dot > 0 {
+		start := x - leaderGap - float64(count)*dot
+		l.page.ColoredText(start, baseline, style.Font, style.Size, style.Color, strings.Repeat(". ", count))
+	}
+	if strings.HasPrefix(e.Link, "#") {
+		rect := Rect{X: x, Y: baseline - descent, Width: width, Height: ascent + descent}
+		l.page.InternalLink(rect, strings.TrimPrefix(e.Link, "#"))
+	}
+}
+
+func (e *ContentsEntry) leadHeight(l *Layout) float64 {
+	height := e.SpaceBefore
+	for _, line := range e.lines(l) {
+		height += line.height
+	}
+	return height
+}
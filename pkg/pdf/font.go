@@ -0,0 +1,103 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Font represents a font that can be used to write text in a page
+type Font interface {
+	// Name returns the postscript name of the font.
+	Name() string
+
+	// Width returns the width of the given text in points, for the given font size.
+	Width(text string, size float64) float64
+
+	// Ascent returns the maximum height above the baseline in points, for the given font size.
+	Ascent(size float64) float64
+
+	// Descent returns the maximum depth below the baseline in points, for the given font size,
+	// as a positive number.
+	Descent(size float64) float64
+
+	// encode returns the given text as a pdf string, in the font encoding, registering
+	// the used glyphs in the document.
+	encode(document *Document, text string) string
+
+	// write writes the font objects to the document.
+	write(document *Document, ref Ref)
+}
+
+// The standard monospaced fonts, that every pdf reader must provide
+var (
+	Courier            Font = &standardFont{name: "Courier"}
+	CourierBold        Font = &standardFont{name: "Courier-Bold"}
+	CourierOblique     Font = &standardFont{name: "Courier-Oblique"}
+	CourierBoldOblique Font = &standardFont{name: "Courier-BoldOblique"}
+)
+
+// standardFont represents one of the standard 14 fonts, which are not embedded
+type standardFont struct {
+	name string
+}
+
+func (f *standardFont) Name() string {
+	return f.name
+}
+
+// Width returns the width of the text, assuming that every glyph is 600 units wide
+func (f *standardFont) Width(text string, size float64) float64 {
+	return float64(len([]rune(text))) * 0.6 * size
+}
+
+func (f *standardFont) Ascent(size float64) float64 {
+	return 0.629 * size
+}
+
+func (f *standardFont) Descent(size float64) float64 {
+	return 0.157 * size
+}
+
+// encode encodes the text in WinAnsiEncoding, replacing the unknown characters
+func (f *standardFont) encode(_ *Document, text string) string {
+	var buffer bytes.Buffer
+	buffer.WriteString("(")
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			buffer.WriteByte('\\')
+			buffer.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			buffer.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&buffer, "\\%03o", r)
+		default:
+			buffer.WriteByte('?')
+		}
+	}
+	buffer.WriteString(")")
+	return buffer.String()
+}
+
+func (f *standardFont) write(document *Document, ref Ref) {
+	document.set(ref, "<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", f.name)
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Info represents the metadata of a document, written both in the document information
+// dictionary and in the xmp metadata stream
+type Info struct {
+	Title    string
+	Authors  []string
+	Subject  string
+	Keywords []string
+	// Language is the natural language of the document, as in en-US.
+	Language string
+	Creator  string
+	Producer string
+	Created  time.Time
+	Modified time.Time
+	// Extra are other entries of the document information dictionary, like a publisher, which
+	// are written after the standard ones.
+	Extra map[string]string
+}
+
+// SetInfo changes the metadata of the document
+func (d *Document) SetInfo(info Info) {
+	d.info = &info
+}
+
+// writeInfo writes the information dictionary and the xmp metadata, returning the reference of
+// the dictionary and the catalog entries, or an empty reference if there is no metadata
+func (d *Document) writeInfo() (Ref, string) {
+	if d.info == nil {
+		return 0, ""
+	}
+	info := d.info
+	var buffer bytes.Buffer
+	buffer.WriteString("<<")
+	entry := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&buffer, " /%s %s", key, textString(value))
+		}
+	}
+	entry("Title", info.Title)
+	entry("Author", strings.Join(info.Authors, ", "))
+	entry("Subject", info.Subject)
+	entry("Keywords", strings.Join(info.Keywords, ", "))
+	entry("Creator", info.Creator)
+	entry("Producer", info.Producer)
+	if !info.Created.IsZero() {
+		fmt.Fprintf(&buffer, " /CreationDate %s", pdfString(pdfDate(info.Created)))
+	}
+	if !info.Modified.IsZero() {
+		fmt.Fprintf(&buffer, " /ModDate %s", pdfString(pdfDate(info.Modified)))
+	}
+	for _, key := range slices.Sorted(maps.Keys(info.Extra)) {
+		if key != "" && info.Extra[key] != "" {
+			fmt.Fprintf(&buffer, " /%s %s", pdfName(key), textString(info.Extra[key]))
+		}
+	}
+	buffer.WriteString(" >>")
+	ref := d.alloc()
+	d.set(ref, "%s", buffer.String())
+
+	metadata := d.alloc()
+	data := info.xmp()
+	d.set(metadata, "<< /Type /Metadata /Subtype /XML /Length %d >>\nstream\n%s\nendstream", len(data), data)
+	catalog := fmt.Sprintf(" /Metadata %s", metadata)
+	if info.Language != "" {
+		catalog += fmt.Sprintf(" /Lang %s", textString(info.Language))
+	}
+	return ref, catalog
+}
+
+// pdfName escapes the given text to be used as a pdf name, without the starting slash
+func pdfName(text string) string {
+	var builder strings.Builder
+	for _, b := range []byte(text) {
+		if b <= ' ' || b > '~' || b == '#' || strings.IndexByte("()<>[]{}/%", b) >= 0 {
+			fmt.Fprintf(&builder, "#%02X", b)
+			continue
+		}
+		builder.WriteByte(b)
+	}
+	return builder.String()
+}
+
+// pdfDate formats the given time as a pdf date
+func pdfDate(value time.Time) string {
+	_, offset := value.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("%s%c%02d'%02d'", value.Format("D:20060102150405"), sign, offset/3600, offset%3600/60)
+}
+
+// xmlText escapes the given text for xml
+func xmlText(text string) string {
+	var builder strings.Builder
+	_ = xml.EscapeText(&builder, []byte(text))
+	return builder.String()
+}
+
+// xmp returns the xmp metadata packet with the information
+func (info *Info) xmp() []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("<?xpacket begin=\"\xef\xbb\xbf\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	buffer.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	buffer.WriteString("<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	buffer.WriteString("<rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\"" +
+		" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\" xmlns:pdf=\"http://ns.adobe.com/pdf/1.3/\">\n")
+	buffer.WriteString("<dc:format>application/pdf</dc:format>\n")
+	if info.Title != "" {
+		fmt.Fprintf(&buffer, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", xmlText(info.Title))
+	}
+	if len(info.Authors) > 0 {
+		buffer.WriteString("<dc:creator><rdf:Seq>")
+		for _, author := range info.Authors {
+			fmt.Fprintf(&buffer, "<rdf:li>%s</rdf:li>", xmlText(author))
+		}
+		buffer.WriteString("</rdf:Seq></dc:creator>\n")
+	}
+	if info.Subject != "" {
+		fmt.Fprintf(&buffer, "<dc:description><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:description>\n", xmlText(info.Subject))
+	}
+	if len(info.Keywords) > 0 {
+		buffer.WriteString("<dc:subject><rdf:Bag>")
+		for _, keyword := range info.Keywords {
+			fmt.Fprintf(&buffer, "<rdf:li>%s</rdf:li>", xmlText(keyword))
+		}
+		buffer.WriteString("</rdf:Bag></dc:subject>\n")
+		fmt.Fprintf(&buffer, "<pdf:Keywords>%s</pdf:Keywords>\n", xmlText(strings.Join(info.Keywords, ", ")))
+	}
+	if info.Language != "" {
+		fmt.Fprintf(&buffer, "<dc:language><rdf:Bag><rdf:li>%s</rdf:li></rdf:Bag></dc:language>\n", xmlText(info.Language))
+	}
+	if info.Creator != "" {
+		fmt.Fprintf(&buffer, "<xmp:CreatorTool>%s</xmp:CreatorTool>\n", xmlText(info.Creator))
+	}
+	if info.Producer != "" {
+		fmt.Fprintf(&buffer, "<pdf:Producer>%s</pdf:Producer>\n", xmlText(info.Producer))
+	}
+	if !info.Created.IsZero() {
+		fmt.Fprintf(&buffer, "<xmp:CreateDate>%s</xmp:CreateDate>\n", info.Created.Format(time.RFC3339))
+	}
+	if !info.Modified.IsZero() {
+		fmt.Fprintf(&buffer, "<xmp:ModifyDate>%s</xmp:ModifyDate>\n", info.Modified.Format(time.RFC3339))
+		fmt.Fprintf(&buffer, "<xmp:MetadataDate>%s</xmp:MetadataDate>\n", info.Modified.Format(time.RFC3339))
+	}
+	buffer.WriteString("</rdf:Description>\n</rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>")
+	return buffer.Bytes()
+}
@@ -0,0 +1,336 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pdf
+
+import "slices"
+
+// Margins represents the empty space around the text area of a page
+type Margins struct {
+	Top, Right, Bottom, Left float64
+}
+
+// Block represents an element that the layout places vertically, one after the other
+type Block interface {
+	// layout places the block in the layout, breaking it across pages as needed.
+	layout(l *Layout)
+
+	// leadHeight returns the height of the first part of the block, that can not be
+	// separated from the previous block when it must be kept with the next one.
+	leadHeight(l *Layout) float64
+}
+
+// marker represents a list marker, waiting to be written next to the first line of its item
+type marker struct {
+	spans []Span
+	right float64
+}
+
+// Layout flows blocks into the pages of a document, adding new pages as needed.
+//
+// Paragraphs are broken into lines at spaces, and are split across pages respecting the minimum
+// number of lines at the bottom (orphans) and at the top (widows) of a page.
+type Layout struct {
+	// Orphans is the minimum number of lines of a paragraph left at the bottom of a page.
+	Orphans int
+	// Widows is the minimum number of lines of a paragraph carried to the top of a page.
+	Widows int
+
+	document *Document
+	margins  Margins
+	page     *Page
+	y        float64
+	left     float64
+	right    float64
+	markers  []marker
+}
+
+// NewLayout creates a new layout that adds pages to the given document, with the given margins
+func NewLayout(document *Document, margins Margins) *Layout {
+	return &Layout{
+		Orphans:  2,
+		Widows:   2,
+		document: document,
+		margins:  margins,
+		left:     margins.Left,
+		right:    document.Width() - margins.Right,
+		markers:  make([]marker, 0),
+	}
+}
+
+// Document returns the document where the pages are added
+func (l *Layout) Document() *Document {
+	return l.document
+}
+
+// Page returns the current page, adding the first page if needed
+func (l *Layout) Page() *Page {
+	if l.page == nil {
+		l.newPage()
+	}
+	return l.page
+}
+
+// PageNumber returns the number of the current page, starting at 1
+func (l *Layout) PageNumber() int {
+	return slices.Index(l.document.pages, l.Page()) + 1
+}
+
+// Add places the given blocks after the ones already in the layout
+func (l *Layout) Add(blocks ...Block) {
+	l.blocks(blocks)
+}
+
+// blocks places the blocks, keeping the ones that require it with the next one
+func (l *Layout) blocks(blocks []Block) {
+	for i, block := range blocks {
+		if paragraph, ok := block.(*Paragraph); ok && paragraph.KeepWithNext && i+1 < len(blocks) {
+			l.ensure(paragraph.height(l) + blocks[i+1].leadHeight(l))
+		}
+		block.layout(l)
+	}
+}
+
+// top returns the vertical position of the top of the text area
+func (l *Layout) top() float64 {
+	return l.document.Height() - l.margins.Top
+}
+
+// atTop checks if nothing was placed yet in the current page
+func (l *Layout) atTop() bool {
+	return l.page == nil || l.y >= l.top()
+}
+
+// available returns the vertical space left in the current page
+func (l *Layout) available() float64 {
+	if l.page == nil {
+		return l.top() - l.margins.Bottom
+	}
+	return l.y - l.margins.Bottom
+}
+
+// newPage starts a new page
+func (l *Layout) newPage() {
+	l.page = l.document.AddPage()
+	l.y = l.top()
+}
+
+// ensure starts a new page if there is not enough space in the current one for the given height
+func (l *Layout) ensure(height float64) {
+	if l.page == nil || (height > l.available() && !l.atTop()) {
+		l.newPage()
+	}
+}
+
+// space adds vertical space, unless at the top of a page
+func (l *Layout) space(height float64) {
+	if !l.atTop() {
+		l.y -= min(height, l.available())
+	}
+}
+
+// indent changes the left limit of the text area
+func (l *Layout) indent(amount float64) {
+	l.left += amount
+}
+
+// writeMarkers writes the pending list markers, in the line with the given baseline
+func (l *Layout) writeMarkers(baseline float64) {
+	for _, marker := range l.markers {
+		width := spansWidth(marker.spans)
+		x := marker.right - width
+		for _, span := range marker.spans {
+			l.page.ColoredText(x, baseline, span.Style.Font, span.Style.Size, span.Style.Color, span.Text)
+			x += span.Style.Font.Width(span.Text, span.Style.Size)
+		}
+	}
+	l.markers = l.markers[:0]
+}
+
+// split returns how many of the given line heights to place in the current page, respecting
+// the orphans and widows, where first tells if the lines are the first ones of a block;
+// zero means that a new page must be started before placing any lines
+func (l *Layout) split(heights []float64, first bool) int {
+	available := l.available()
+	count := 0
+	used := 0.0
+	for count < len(heights) && used+heights[count] <= available+0.001 {
+		used += heights[count]
+		count++
+	}
+	if count == len(heights) {
+		return count
+	}
+	if first && count < min(l.Orphans, len(heights)) {
+		count = 0
+	}
+	if len(heights)-count < l.Widows {
+		count = len(heights) - l.Widows
+		if first && count < min(l.Orphans, len(heights)) {
+			count = 0
+		}
+	}
+	if count <= 0 {
+		if l.atTop() {
+			return 1
+		}
+		return 0
+	}
+	return count
+}
+
+// spansWidth returns the total width of the given spans
+func spansWidth(spans []Span) float64 {
+	width := 0.0
+	for _, span := range spans {
+		width += span.Style.Font.Width(span.Text, span.Style.Size)
+	}
+	return width
+}
+
+// Rule represents an horizontal line, across the text area
+type Rule struct {
+	Color       Color
+	Width       float64
+	SpaceBefore float64
+	SpaceAfter  float64
+}
+
+func (r *Rule) layout(l *Layout) {
+	l.space(r.SpaceBefore)
+	l.ensure(r.Width)
+	y := l.y - r.Width/2
+	l.page.ColoredLine(l.left, y, l.right, y, r.Width, r.Color)
+	l.y -= r.Width
+	l.space(r.SpaceAfter)
+}
+
+func (r *Rule) leadHeight(_ *Layout) float64 {
+	return r.SpaceBefore + r.Width
+}
+
+// PageBreak represents a forced page break
+type PageBreak struct{}
+
+func (b *PageBreak) layout(l *Layout) {
+	if !l.atTop() {
+		l.newPage()
+	}
+}
+
+func (b *PageBreak) leadHeight(_ *Layout) float64 {
+	return 0
+}
+
+// List represents a list of items, with a marker next to each one
+type List struct {
+	Items []ListItem
+	// Indent is the space reserved for the markers, at the left of the items.
+	Indent      float64
+	SpaceBefore float64
+	SpaceAfter  float64
+}
+
+// ListItem represents an item of a list
+type ListItem struct {
+	Marker []Span
+	Blocks []Block
+}
+
+// markerGap is the space between a list marker and the item text
+const markerGap = 4.0
+
+func (b *List) layout(l *Layout) {
+	l.space(b.SpaceBefore)
+	for _, item := range b.Items {
+		l.indent(b.Indent)
+		l.markers = append(l.markers, marker{spans: item.Marker, right: l.left - markerGap})
+		l.blocks(item.Blocks)
+		if len(l.markers) > 0 {
+			l.ensure(0)
+			l.writeMarkers(l.y - spansAscent(item.Marker))
+		}
+		l.indent(-b.Indent)
+	}
+	l.space(b.SpaceAfter)
+}
+
+func (b *List) leadHeight(l *Layout) float64 {
+	if len(b.Items) == 0 || len(b.Items[0].Blocks) == 0 {
+		return b.SpaceBefore
+	}
+	l.indent(b.Indent)
+	defer l.indent(-b.Indent)
+	return b.SpaceBefore + b.Items[0].Blocks[0].leadHeight(l)
+}
+
+// spansAscent returns the maximum ascent of the given spans
+func spansAscent(spans []Span) float64 {
+	ascent := 0.0
+	for _, span := range spans {
+		ascent = max(ascent, span.Style.Font.Ascent(span.Style.Size))
+	}
+	return ascent
+}
+
+// BlockQuote represents a group of indented blocks, with a vertical bar at their left
+type BlockQuote struct {
+	Blocks      []Block
+	Indent      float64
+	BarColor    Color
+	BarWidth    float64
+	SpaceBefore float64
+	SpaceAfter  float64
+}
+
+func (b *BlockQuote) layout(l *Layout) {
+	l.space(b.SpaceBefore)
+	l.ensure(0)
+	start := slices.Index(l.document.pages, l.page)
+	startY := l.y
+	x := l.left + b.BarWidth/2
+	l.indent(b.Indent)
+	l.blocks(b.Blocks)
+	l.indent(-b.Indent)
+	end := slices.Index(l.document.pages, l.page)
+	if b.BarWidth > 0 {
+		for index := start; index <= end; index++ {
+			top, bottom := l.top(), l.margins.Bottom
+			if index == start {
+				top = startY
+			}
+			if index == end {
+				bottom = l.y
+			}
+			if top > bottom {
+				l.document.pages[index].ColoredLine(x, top, x, bottom, b.BarWidth, b.BarColor)
+			}
+		}
+	}
+	l.space(b.SpaceAfter)
+}
+
+func (b *BlockQuote) leadHeight(l *Layout) float64 {
+	if len(b.Blocks) == 0 {
+		return b.SpaceBefore
+	}
+	l.indent(b.Indent)
+	defer l.indent(-b.Indent)
+	return b.SpaceBefore + b.Blocks[0].leadHeight(l)
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// OutlineItem represents an entry of the document outline, also known as bookmarks, which
+// readers show next to the pages to navigate the document
+type OutlineItem struct {
+	Title string
+	// Anchor is the name of the destination of the item, which is ignored if it does not exist.
+	Anchor   string
+	Children []*OutlineItem
+}
+
+// SetOutline changes the top level items of the document outline
+func (d *Document) SetOutline(items []*OutlineItem) {
+	d.outline = items
+}
+
+// outlineCount returns the number of items in the given items and all of their descendants
+func outlineCount(items []*OutlineItem) int {
+	count := len(items)
+	for _, item := range items {
+		count += outlineCount(item.Children)
+	}
+	return count
+}
+
+// writeOutline writes the outline objects, returning the catalog entries that refer to them,
+// or an empty string if there is no outline
+func (d *Document) writeOutline() string {
+	if len(d.outline) == 0 {
+		return ""
+	}
+	root := d.alloc()
+	first, last := d.writeOutlineItems(root, d.outline)
+	d.set(root, "<< /Type /Outlines /First %s /Last %s /Count %d >>", first, last, outlineCount(d.outline))
+	return fmt.Sprintf(" /Outlines %s /PageMode /UseOutlines", root)
+}
+
+// writeOutlineItems writes the given sibling items with the given parent, returning the first
+// and last of them
+func (d *Document) writeOutlineItems(parent Ref, items []*OutlineItem) (Ref, Ref) {
+	refs := make([]Ref, len(items))
+	for i := range items {
+		refs[i] = d.alloc()
+	}
+	for i, item := range items {
+		var buffer bytes.Buffer
+		fmt.Fprintf(&buffer, "<< /Title %s /Parent %s", textString(item.Title), parent)
+		if i > 0 {
+			fmt.Fprintf(&buffer, " /Prev %s", refs[i-1])
+		}
+		if i+1 < len(items) {
+			fmt.Fprintf(&buffer, " /Next %s", refs[i+1])
+		}
+		if len(item.Children) > 0 {
+			first, last := d.writeOutlineItems(refs[i], item.Children)
+			fmt.Fprintf(&buffer, " /First %s /Last %s /Count %d", first, last, outlineCount(item.Children))
+		}
+		if d.HasAnchor(item.Anchor) {
+			fmt.Fprintf(&buffer, " /Dest %s", pdfString(item.Anchor))
+		}
+		buffer.WriteString(" >>")
+		d.set(refs[i], "%s", buffer.String())
+	}
+	return refs[0], refs[len(refs)-1]
+}
+
+// textString formats the given text as a pdf text string, which is encoded in UTF-16 when
+// it is not plain ascii
+func textString(text string) string {
+	ascii := true
+	for _, r := range text {
+		if r >= 0x7f {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return pdfString(text)
+	}
+	var builder strings.Builder
+	builder.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(text)) {
+		fmt.Fprintf(&builder, "%04X", unit)
+	}
+	builder.WriteString(">")
+	return builder.String()
+}
@@ -0,0 +1,468 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package pdf implements a pdf writer and typesetter, without any external dependencies.
+//
+// The Document type writes the low level pdf objects, while the Layout type flows blocks of
+// text into the pages of a document, breaking lines and pages as needed.
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+
+	"emperror.dev/errors"
+)
+
+// Page sizes in points, as width and height
+var (
+	PageSizeA4     = [2]float64{595.28, 841.89}
+	PageSizeA5     = [2]float64{419.53, 595.28}
+	PageSizeLetter = [2]float64{612, 792}
+)
+
+// Ref represents a reference to an indirect pdf object
+type Ref int
+
+// String returns the reference in pdf syntax
+func (r Ref) String() string {
+	return strconv.Itoa(int(r)) + " 0 R"
+}
+
+// Color represents a rgb color, with components from 0 to 1
+type Color struct {
+	R, G, B float64
+}
+
+// Some common colors
+var (
+	Black     = Color{0, 0, 0}
+	White     = Color{1, 1, 1}
+	Gray      = Color{0.5, 0.5, 0.5}
+	LightGray = Color{0.94, 0.94, 0.94}
+	Blue      = Color{0, 0.2, 0.8}
+)
+
+// Rect represents a rectangle, from its lower left corner
+type Rect struct {
+	X, Y, Width, Height float64
+}
+
+// destination represents a position in a page, that can be the target of links
+type destination struct {
+	page *Page
+	x, y float64
+}
+
+// Document represents a pdf document being built
+type Document struct {
+	width    float64
+	height   float64
+	compress bool
+	objects  [][]byte
+	pages    []*Page
+	fonts    map[Font]Ref
+	order    []Font
+	glyphs   map[Font]map[uint16]rune
+	anchors  map[string]destination
+	outline  []*OutlineItem
+	info     *Info
+	// bleed is the size of the area around the pages that is cut when printing.
+	bleed     float64
+	cropMarks bool
+	watermark *watermark
+}
+
+// NewDocument creates a new empty document, whose pages have the given size in points
+func NewDocument(width, height float64) *Document {
+	return &Document{
+		width:    width,
+		height:   height,
+		compress: true,
+		objects:  make([][]byte, 0),
+		pages:    make([]*Page, 0),
+		fonts:    make(map[Font]Ref),
+		order:    make([]Font, 0),
+		glyphs:   make(map[Font]map[uint16]rune),
+		anchors:  make(map[string]destination),
+	}
+}
+
+// Width returns the page width in points
+func (d *Document) Width() float64 {
+	return d.width
+}
+
+// Height returns the page height in points
+func (d *Document) Height() float64 {
+	return d.height
+}
+
+// SetCompression sets if the streams are compressed, which is the default
+func (d *Document) SetCompression(compress bool) {
+	d.compress = compress
+}
+
+// AddPage adds a new empty page at the end of the document
+func (d *Document) AddPage() *Page {
+	page := &Page{
+		document:    d,
+		fonts:       make(map[Font]string),
+		order:       make([]Font, 0),
+		annotations: make([]annotation, 0),
+	}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// Pages returns the pages of this document
+func (d *Document) Pages() []*Page {
+	return d.pages
+}
+
+// AddAnchor adds a named destination, that internal links can point to
+func (d *Document) AddAnchor(name string, page *Page, x, y float64) {
+	if _, ok := d.anchors[name]; !ok {
+		d.anchors[name] = destination{page: page, x: x, y: y}
+	}
+}
+
+// HasAnchor checks if there is a named destination with the given name
+func (d *Document) HasAnchor(name string) bool {
+	_, ok := d.anchors[name]
+	return ok
+}
+
+// AnchorPage returns the number of the page with the given anchor, starting at 1
+func (d *Document) AnchorPage(name string) (int, bool) {
+	anchor, ok := d.anchors[name]
+	if !ok {
+		return 0, false
+	}
+	return slices.Index(d.pages, anchor.page) + 1, true
+}
+
+// alloc reserves a new object number
+func (d *Document) alloc() Ref {
+	d.objects = append(d.objects, nil)
+	return Ref(len(d.objects))
+}
+
+// set sets the contents of the given object
+func (d *Document) set(ref Ref, format string, args ...any) {
+	d.objects[ref-1] = []byte(fmt.Sprintf(format, args...))
+}
+
+// setStream sets the contents of the given object to a stream with the given dictionary entries,
+// compressing it if needed
+func (d *Document) setStream(ref Ref, dict string, data []byte) {
+	filter := ""
+	if d.compress {
+		var compressed bytes.Buffer
+		writer, _ := zlib.NewWriterLevel(&compressed, zlib.BestCompression)
+		_, _ = writer.Write(data)
+		_ = writer.Close()
+		data = compressed.Bytes()
+		filter = " /Filter /FlateDecode"
+	}
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "<< /Length %d%s%s >>\nstream\n", len(data), filter, dict)
+	buffer.Write(data)
+	buffer.WriteString("\nendstream")
+	d.objects[ref-1] = buffer.Bytes()
+}
+
+// fontRef returns the object of the given font, reserving it if needed
+func (d *Document) fontRef(font Font) Ref {
+	if ref, ok := d.fonts[font]; ok {
+		return ref
+	}
+	ref := d.alloc()
+	d.fonts[font] = ref
+	d.order = append(d.order, font)
+	return ref
+}
+
+// useGlyph registers that the given glyph of the font is used to represent the given rune
+func (d *Document) useGlyph(font Font, glyph uint16, r rune) {
+	used, ok := d.glyphs[font]
+	if !ok {
+		used = make(map[uint16]rune)
+		d.glyphs[font] = used
+	}
+	if _, ok := used[glyph]; !ok {
+		used[glyph] = r
+	}
+}
+
+// WriteTo writes the whole document to the given writer
+func (d *Document) WriteTo(writer io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	d.objects = d.objects[:0]
+	d.fonts = make(map[Font]Ref)
+	d.order = d.order[:0]
+
+	catalog := d.alloc()
+	pages := d.alloc()
+	for _, page := range d.pages {
+		page.ref = d.alloc()
+	}
+	kids := make([]Ref, 0, len(d.pages))
+	for _, page := range d.pages {
+		page.write(pages)
+		kids = append(kids, page.ref)
+	}
+	for _, font := range d.order {
+		font.write(d, d.fonts[font])
+	}
+	info, metadata := d.writeInfo()
+	d.set(catalog, "<< /Type /Catalog /Pages %s%s%s%s >>", pages, d.names(), d.writeOutline(), metadata)
+	d.set(pages, "<< /Type /Pages /Kids %s /Count %d /MediaBox [0 0 %s %s] >>",
+		refArray(kids), len(kids), formatNumber(d.width+2*d.offset()), formatNumber(d.height+2*d.offset()))
+
+	counter := &countingWriter{writer: bufio.NewWriter(writer)}
+	offsets := make([]int64, len(d.objects))
+	_, _ = counter.Write([]byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"))
+	for i, object := range d.objects {
+		offsets[i] = counter.count
+		_, _ = fmt.Fprintf(counter, "%d 0 obj\n", i+1)
+		_, _ = counter.Write(object)
+		_, _ = counter.Write([]byte("\nendobj\n"))
+	}
+	xref := counter.count
+	_, _ = fmt.Fprintf(counter, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, offset := range offsets {
+		_, _ = fmt.Fprintf(counter, "%010d 00000 n \n", offset)
+	}
+	trailer := ""
+	if info != 0 {
+		trailer = fmt.Sprintf(" /Info %s", info)
+	}
+	_, _ = fmt.Fprintf(counter, "trailer\n<< /Size %d /Root %s%s >>\nstartxref\n%d\n%%%%EOF\n",
+		len(d.objects)+1, catalog, trailer, xref)
+	if counter.err != nil {
+		return counter.count, errors.Wrap(counter.err, "Unable to write the pdf document")
+	}
+	if err := counter.writer.Flush(); err != nil {
+		return counter.count, errors.Wrap(err, "Unable to write the pdf document")
+	}
+	return counter.count, nil
+}
+
+// names returns the catalog entry with the named destinations, if there are any
+func (d *Document) names() string {
+	if len(d.anchors) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(d.anchors))
+	for name := range d.anchors {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var buffer bytes.Buffer
+	buffer.WriteString(" /Names << /Dests << /Names [")
+	for _, name := range names {
+		anchor := d.anchors[name]
+		fmt.Fprintf(&buffer, " %s [%s /XYZ %s %s 0]", pdfString(name), anchor.page.ref,
+			formatNumber(anchor.x+d.offset()), formatNumber(anchor.y+d.offset()))
+	}
+	buffer.WriteString(" ] >> >>")
+	return buffer.String()
+}
+
+// annotation represents a link annotation in a page
+type annotation struct {
+	rect   Rect
+	uri    string
+	anchor string
+}
+
+// Page represents a page of a document
+type Page struct {
+	document    *Document
+	ref         Ref
+	content     bytes.Buffer
+	fonts       map[Font]string
+	order       []Font
+	annotations []annotation
+}
+
+// Document returns the document of this page
+func (p *Page) Document() *Document {
+	return p.document
+}
+
+// fontName returns the resource name of the font in this page
+func (p *Page) fontName(font Font) string {
+	name, ok := p.fonts[font]
+	if !ok {
+		name = "F" + strconv.Itoa(len(p.fonts)+1)
+		p.fonts[font] = name
+		p.order = append(p.order, font)
+	}
+	return name
+}
+
+// Text writes the given text, with its baseline starting in the given position
+func (p *Page) Text(x, y float64, font Font, size float64, text string) {
+	p.ColoredText(x, y, font, size, Black, text)
+}
+
+// ColoredText writes the given text in the given color, with its baseline starting in the given position
+func (p *Page) ColoredText(x, y float64, font Font, size float64, color Color, text string) {
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s rg %s %s Td %s Tj ET\n",
+		p.fontName(font), formatNumber(size), color.operands(), formatNumber(x), formatNumber(y),
+		font.encode(p.document, text))
+}
+
+// Line draws a line between the two given points, with the given width
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	p.ColoredLine(x1, y1, x2, y2, width, Black)
+}
+
+// ColoredLine draws a line between the two given points, with the given width and color
+func (p *Page) ColoredLine(x1, y1, x2, y2, width float64, color Color) {
+	fmt.Fprintf(&p.content, "%s RG %s w %s %s m %s %s l S\n", color.operands(), formatNumber(width),
+		formatNumber(x1), formatNumber(y1), formatNumber(x2), formatNumber(y2))
+}
+
+// Fill fills the given rectangle with the given color
+func (p *Page) Fill(rect Rect, color Color) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n", color.operands(), formatNumber(rect.X),
+		formatNumber(rect.Y), formatNumber(rect.Width), formatNumber(rect.Height))
+}
+
+// Link adds a link in the given rectangle, to the given uri
+func (p *Page) Link(rect Rect, uri string) {
+	p.annotations = append(p.annotations, annotation{rect: rect, uri: uri})
+}
+
+// InternalLink adds a link in the given rectangle, to the given named destination
+func (p *Page) InternalLink(rect Rect, anchor string) {
+	p.annotations = append(p.annotations, annotation{rect: rect, anchor: anchor})
+}
+
+// write writes this page objects
+func (p *Page) write(parent Ref) {
+	contents := p.document.alloc()
+	content := p.document.pageContent(p)
+	offset := p.document.offset()
+	var resources bytes.Buffer
+	resources.WriteString("<< /Font <<")
+	for _, font := range p.order {
+		fmt.Fprintf(&resources, " /%s %s", p.fonts[font], p.document.fontRef(font))
+	}
+	resources.WriteString(" >> >>")
+	annotations := make([]Ref, 0, len(p.annotations))
+	for _, annotation := range p.annotations {
+		if annotation.anchor != "" && !p.document.HasAnchor(annotation.anchor) {
+			continue
+		}
+		ref := p.document.alloc()
+		action := fmt.Sprintf("/S /URI /URI %s", pdfString(annotation.uri))
+		if annotation.anchor != "" {
+			action = fmt.Sprintf("/S /GoTo /D %s", pdfString(annotation.anchor))
+		}
+		p.document.set(ref, "<< /Type /Annot /Subtype /Link /Rect [%s %s %s %s] /Border [0 0 0] /A << %s >> >>",
+			formatNumber(offset+annotation.rect.X), formatNumber(offset+annotation.rect.Y),
+			formatNumber(offset+annotation.rect.X+annotation.rect.Width), formatNumber(offset+annotation.rect.Y+annotation.rect.Height),
+			action)
+		annotations = append(annotations, ref)
+	}
+	annots := ""
+	if len(annotations) > 0 {
+		annots = " /Annots " + refArray(annotations)
+	}
+	p.document.set(p.ref, "<< /Type /Page /Parent %s%s /Resources %s /Contents %s%s >>",
+		parent, p.document.boxes(), resources.String(), contents, annots)
+	p.document.setStream(contents, "", content)
+}
+
+// countingWriter counts the bytes written and keeps the first error
+type countingWriter struct {
+	writer *bufio.Writer
+	count  int64
+	err    error
+}
+
+func (w *countingWriter) Write(data []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.writer.Write(data)
+	w.count += int64(n)
+	w.err = err
+	return n, err
+}
+
+// operands returns the color as the operands of a color operator
+func (c Color) operands() string {
+	return formatNumber(c.R) + " " + formatNumber(c.G) + " " + formatNumber(c.B)
+}
+
+// formatNumber formats a number in pdf syntax, with at most 3 decimal places
+func formatNumber(value float64) string {
+	value = math.Round(value*1000) / 1000
+	if value == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// pdfString formats the given text as a pdf literal string, escaping it as needed
+func pdfString(text string) string {
+	var buffer bytes.Buffer
+	buffer.WriteString("(")
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			buffer.WriteByte('\\')
+			buffer.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&buffer, "\\%03o", c)
+		default:
+			buffer.WriteByte(c)
+		}
+	}
+	buffer.WriteString(")")
+	return buffer.String()
+}
+
+// refArray formats the given references as a pdf array
+func refArray(refs []Ref) string {
+	var buffer bytes.Buffer
+	buffer.WriteString("[")
+	for i, ref := range refs {
+		if i > 0 {
+			buffer.WriteString(" ")
+		}
+		buffer.WriteString(ref.String())
+	}
+	buffer.WriteString("]")
+	return buffer.String()
+}
@@ -0,0 +1,397 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// lines returns a text with the given number of forced lines
+func lines(count int) string {
+	result := make([]string, count)
+	for i := range result {
+		result[i] = "line"
+	}
+	return strings.Join(result, "\n")
+}
+
+// newSmallLayout creates a layout with room for exactly 8 lines of 10pt courier per page
+func newSmallLayout() *Layout {
+	document := NewDocument(200, 100)
+	document.SetCompression(false)
+	return NewLayout(document, Margins{Top: 10, Right: 10, Bottom: 10, Left: 10})
+}
+
+func paragraph(count int) *Paragraph {
+	return &Paragraph{
+		Spans:   []Span{{Text: lines(count), Style: Style{Font: Courier, Size: 10}}},
+		Leading: 1,
+	}
+}
+
+func TestDocument(t *testing.T) {
+	Convey("#Document", t, func() {
+
+		Convey("It should write the page content streams", func() {
+			document := NewDocument(200, 100)
+			document.SetCompression(false)
+			document.AddPage().Text(10, 20, Courier, 12, "Hello (world)")
+			var buffer bytes.Buffer
+			_, err := document.WriteTo(&buffer)
+			So(err, ShouldBeNil)
+			output := buffer.String()
+			So(output, ShouldStartWith, "%PDF-1.7\n")
+			So(output, ShouldEndWith, "%%EOF\n")
+			So(output, ShouldContainSubstring, "<< /Type /Catalog /Pages 2 0 R >>")
+			So(output, ShouldContainSubstring, "<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 200 100] >>")
+			So(output, ShouldContainSubstring, "stream\nBT /F1 12 Tf 0 0 0 rg 10 20 Td (Hello \\(world\\)) Tj ET\n\nendstream")
+			So(output, ShouldContainSubstring, "/BaseFont /Courier /Encoding /WinAnsiEncoding")
+		})
+
+		Convey("It should write the same bytes every time", func() {
+			write := func() []byte {
+				font, _ := ParseFont(goregular.TTF)
+				document := NewDocument(200, 100)
+				document.AddPage().Text(10, 20, font, 12, "Hello")
+				var buffer bytes.Buffer
+				_, _ = document.WriteTo(&buffer)
+				return buffer.Bytes()
+			}
+			So(bytes.Equal(write(), write()), ShouldBeTrue)
+		})
+
+		Convey("It should write links and named destinations", func() {
+			document := NewDocument(200, 100)
+			document.SetCompression(false)
+			page := document.AddPage()
+			document.AddAnchor("start", page, 10, 90)
+			page.InternalLink(Rect{X: 10, Y: 10, Width: 20, Height: 10}, "start")
+			page.InternalLink(Rect{X: 10, Y: 10, Width: 20, Height: 10}, "missing")
+			page.Link(Rect{X: 10, Y: 30, Width: 20, Height: 10}, "https://example.com")
+			var buffer bytes.Buffer
+			_, err := document.WriteTo(&buffer)
+			So(err, ShouldBeNil)
+			output := buffer.String()
+			So(output, ShouldContainSubstring, "/Names << /Dests << /Names [ (start) [3 0 R /XYZ 10 90 0] ] >> >>")
+			So(output, ShouldContainSubstring, "/A << /S /GoTo /D (start) >>")
+			So(output, ShouldNotContainSubstring, "(missing)")
+			So(output, ShouldContainSubstring, "/A << /S /URI /URI (https://example.com) >>")
+		})
+
+		Convey("It should write the document information and xmp metadata", func() {
+			document := NewDocument(200, 100)
+			document.SetCompression(false)
+			document.SetInfo(Info{
+				Title:    "A <Title>",
+				Authors:  []string{"One", "Two"},
+				Keywords: []string{"a", "b"},
+				Producer: "riconto",
+				Created:  time.Date(2024, 10, 9, 11, 42, 12, 0, time.UTC),
+				Extra:    map[string]string{"Publisher": "Someone", "Print run": "First"},
+			})
+			var buffer bytes.Buffer
+			_, err := document.WriteTo(&buffer)
+			So(err, ShouldBeNil)
+			output := buffer.String()
+			So(output, ShouldContainSubstring, "<< /Title (A <Title>) /Author (One, Two) /Keywords (a, b) /Producer (riconto)"+
+				" /CreationDate (D:20241009114212+00'00') /Print#20run (First) /Publisher (Someone) >>")
+			So(output, ShouldContainSubstring, "/Metadata 6 0 R >>")
+			So(output, ShouldContainSubstring, "<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">A &lt;Title&gt;</rdf:li></rdf:Alt></dc:title>")
+			So(output, ShouldContainSubstring, "<dc:creator><rdf:Seq><rdf:li>One</rdf:li><rdf:li>Two</rdf:li></rdf:Seq></dc:creator>")
+			So(output, ShouldContainSubstring, "<xmp:CreateDate>2024-10-09T11:42:12Z</xmp:CreateDate>")
+			So(output, ShouldContainSubstring, "trailer\n<< /Size 7 /Root 1 0 R /Info 5 0 R >>")
+		})
+
+		Convey("It should write the bleed, the crop marks and the watermark", func() {
+			document := NewDocument(200, 100)
+			document.SetCompression(false)
+			document.SetBleed(9, true)
+			document.SetWatermark("DRAFT", Courier, 10, Color{0.9, 0.9, 0.9})
+			page := document.AddPage()
+			document.AddAnchor("start", page, 10, 90)
+			page.Text(10, 20, Courier, 12, "Hello")
+			page.Link(Rect{X: 10, Y: 30, Width: 20, Height: 10}, "https://example.com")
+			var buffer bytes.Buffer
+			_, err := document.WriteTo(&buffer)
+			So(err, ShouldBeNil)
+			output := buffer.String()
+			So(output, ShouldContainSubstring, "/MediaBox [0 0 248 148]")
+			So(output, ShouldContainSubstring, "/TrimBox [24 24 224 124] /BleedBox [15 15 233 133]")
+			So(output, ShouldContainSubstring, "stream\nq 1 0 0 1 24 24 cm\nBT /F1 10 Tf 0.9 0.9 0.9 rg 0.894 0.447 -0.447 0.894 ")
+			So(output, ShouldContainSubstring, "(DRAFT) Tj ET\nBT /F1 12 Tf 0 0 0 rg 10 20 Td (Hello) Tj ET\nQ\n")
+			So(output, ShouldContainSubstring, "0 0 0 RG 0.25 w 12 24 m 0 24 l S\n0 0 0 RG 0.25 w 24 12 m 24 0 l S\n")
+			So(output, ShouldContainSubstring, "0 0 0 RG 0.25 w 236 124 m 248 124 l S\n0 0 0 RG 0.25 w 224 136 m 224 148 l S\n")
+			So(output, ShouldContainSubstring, "/Rect [34 54 54 64]")
+			So(output, ShouldContainSubstring, "(start) [3 0 R /XYZ 34 114 0]")
+		})
+
+		Convey("It should write the outline, linked to the anchors", func() {
+			document := NewDocument(200, 100)
+			document.SetCompression(false)
+			first := document.AddPage()
+			second := document.AddPage()
+			document.AddAnchor("one", first, 10, 90)
+			document.AddAnchor("two", second, 10, 90)
+			document.SetOutline([]*OutlineItem{
+				{Title: "One", Anchor: "one", Children: []*OutlineItem{{Title: "Two ½", Anchor: "two"}}},
+				{Title: "Missing", Anchor: "missing"},
+			})
+			page, ok := document.AnchorPage("two")
+			So(ok, ShouldBeTrue)
+			So(page, ShouldEqual, 2)
+			var buffer bytes.Buffer
+			_, err := document.WriteTo(&buffer)
+			So(err, ShouldBeNil)
+			output := buffer.String()
+			So(output, ShouldContainSubstring, "/Outlines 7 0 R /PageMode /UseOutlines >>")
+			So(output, ShouldContainSubstring, "7 0 obj\n<< /Type /Outlines /First 8 0 R /Last 9 0 R /Count 3 >>")
+			So(output, ShouldContainSubstring, "<< /Title (One) /Parent 7 0 R /Next 9 0 R /First 10 0 R /Last 10 0 R /Count 1 /Dest (one) >>")
+			So(output, ShouldContainSubstring, "<< /Title (Missing) /Parent 7 0 R /Prev 8 0 R >>")
+			So(output, ShouldContainSubstring, "<< /Title <FEFF00540077006F002000BD> /Parent 8 0 R /Dest (two) >>")
+		})
+	})
+}
+
+func TestTrueTypeFont(t *testing.T) {
+	Convey("#TrueTypeFont", t, func() {
+		font, err := ParseFont(goregular.TTF)
+		So(err, ShouldBeNil)
+
+		Convey("It should read the font name and metrics", func() {
+			So(font.Name(), ShouldEqual, "GoRegular")
+			glyph, ok := font.Glyph('A')
+			So(ok, ShouldBeTrue)
+			So(glyph, ShouldBeGreaterThan, 0)
+			So(font.Width("AA", 10), ShouldAlmostEqual, 2*font.Width("A", 10))
+			So(font.Ascent(10), ShouldBeGreaterThan, 0)
+			So(font.Descent(10), ShouldBeGreaterThan, 0)
+		})
+
+		Convey("It should reject invalid fonts", func() {
+			_, err := ParseFont([]byte("not a font file"))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("It should subset the font to the used glyphs", func() {
+			a, _ := font.Glyph('a')
+			b, _ := font.Glyph('b')
+			subset, err := font.subset([]uint16{0, a, b})
+			So(err, ShouldBeNil)
+			So(len(subset), ShouldBeLessThan, len(goregular.TTF)/4)
+
+			parsed, err := sfnt.Parse(subset)
+			So(err, ShouldBeNil)
+			So(parsed.NumGlyphs(), ShouldEqual, font.numGlyphs)
+			var buffer sfnt.Buffer
+			outlined := 0
+			for glyph := 0; glyph < parsed.NumGlyphs(); glyph++ {
+				segments, err := parsed.LoadGlyph(&buffer, sfnt.GlyphIndex(glyph), fixed.I(12), nil)
+				So(err, ShouldBeNil)
+				if len(segments) > 0 {
+					outlined++
+				}
+			}
+			So(outlined, ShouldEqual, 3)
+		})
+
+		Convey("It should embed the subset with a unicode map", func() {
+			document := NewDocument(200, 100)
+			document.SetCompression(false)
+			document.AddPage().Text(10, 20, font, 12, "ab")
+			var buffer bytes.Buffer
+			_, err := document.WriteTo(&buffer)
+			So(err, ShouldBeNil)
+			output := buffer.String()
+			a, _ := font.Glyph('a')
+			So(output, ShouldContainSubstring, "/Subtype /Type0")
+			So(output, ShouldContainSubstring, "+GoRegular /Encoding /Identity-H")
+			So(output, ShouldContainSubstring, "/CIDToGIDMap /Identity")
+			So(output, ShouldContainSubstring, "/FontFile2 ")
+			So(output, ShouldContainSubstring, fmt.Sprintf("2 beginbfchar\n<%04X> <0061>\n", a))
+		})
+	})
+}
+
+func TestLayout(t *testing.T) {
+	Convey("#Layout", t, func() {
+
+		Convey("It should break lines at the available width", func() {
+			layout := newSmallLayout()
+			layout.Add(&Paragraph{
+				Spans: []Span{{Text: "one two three four five six seven eight nine ten", Style: Style{Font: Courier, Size: 10}}},
+			})
+			pages := layout.Document().Pages()
+			So(pages, ShouldHaveLength, 1)
+			So(pages[0].content.String(), ShouldEqual, ""+
+				"BT /F1 10 Tf 0 0 0 rg 10 81.64 Td (one two three four five six) Tj ET\n"+
+				"BT /F1 10 Tf 0 0 0 rg 10 69.64 Td (seven eight nine ten) Tj ET\n")
+		})
+
+		Convey("It should justify lines, except the last one", func() {
+			layout := newSmallLayout()
+			layout.Add(&Paragraph{
+				Spans: []Span{{Text: "aaaaaaaaaaaaaaaaaaaaaaaaaa bb cc", Style: Style{Font: Courier, Size: 10}}},
+				Align: AlignJustify,
+			})
+			content := layout.Document().Pages()[0].content.String()
+			So(content, ShouldContainSubstring, "10 81.64 Td (aaaaaaaaaaaaaaaaaaaaaaaaaa) Tj")
+			So(content, ShouldContainSubstring, "178 81.64 Td (bb) Tj")
+			So(content, ShouldContainSubstring, "10 69.64 Td (cc) Tj")
+		})
+
+		Convey("It should add pages when the text does not fit", func() {
+			layout := newSmallLayout()
+			layout.Add(paragraph(20))
+			pages := layout.Document().Pages()
+			So(pages, ShouldHaveLength, 3)
+			So(strings.Count(pages[0].content.String(), "Tj"), ShouldEqual, 8)
+			So(strings.Count(pages[1].content.String(), "Tj"), ShouldEqual, 8)
+			So(strings.Count(pages[2].content.String(), "Tj"), ShouldEqual, 4)
+		})
+
+		Convey("It should not leave orphan lines at the bottom of a page", func() {
+			layout := newSmallLayout()
+			layout.Add(paragraph(7), paragraph(3))
+			pages := layout.Document().Pages()
+			So(pages, ShouldHaveLength, 2)
+			So(strings.Count(pages[0].content.String(), "Tj"), ShouldEqual, 7)
+			So(strings.Count(pages[1].content.String(), "Tj"), ShouldEqual, 3)
+		})
+
+		Convey("It should not leave widow lines at the top of a page", func() {
+			layout := newSmallLayout()
+			layout.Add(paragraph(5), paragraph(4))
+			pages := layout.Document().Pages()
+			So(pages, ShouldHaveLength, 2)
+			So(strings.Count(pages[0].content.String(), "Tj"), ShouldEqual, 7)
+			So(strings.Count(pages[1].content.String(), "Tj"), ShouldEqual, 2)
+		})
+
+		Convey("It should keep headings with the next paragraph", func() {
+			layout := newSmallLayout()
+			heading := paragraph(1)
+			heading.KeepWithNext = true
+			heading.Anchor = "heading"
+			layout.Add(paragraph(6), heading, paragraph(4))
+			pages := layout.Document().Pages()
+			So(pages, ShouldHaveLength, 2)
+			So(strings.Count(pages[0].content.String(), "Tj"), ShouldEqual, 6)
+			So(strings.Count(pages[1].content.String(), "Tj"), ShouldEqual, 5)
+			So(layout.Document().anchors["heading"].page, ShouldEqual, pages[1])
+		})
+
+		Convey("It should write list markers, quotes and code blocks", func() {
+			layout := newSmallLayout()
+			style := Style{Font: Courier, Size: 10}
+			layout.Add(
+				&List{
+					Indent: 20,
+					Items: []ListItem{
+						{Marker: []Span{{Text: "1.", Style: style}}, Blocks: []Block{paragraph(1)}},
+						{Marker: []Span{{Text: "2.", Style: style}}, Blocks: []Block{paragraph(1)}},
+					},
+				},
+				&BlockQuote{Blocks: []Block{paragraph(1)}, Indent: 20, BarWidth: 2, BarColor: Gray},
+				&CodeBlock{Text: "x := 1\n", Style: style, Background: LightGray, Padding: 2, Leading: 1},
+			)
+			content := layout.Document().Pages()[0].content.String()
+			So(content, ShouldContainSubstring, "BT /F1 10 Tf 0 0 0 rg 14 82.64 Td (1.) Tj ET\n"+
+				"BT /F1 10 Tf 0 0 0 rg 30 82.64 Td (line) Tj ET\n")
+			So(content, ShouldContainSubstring, "(2.) Tj")
+			So(content, ShouldContainSubstring, "0.5 0.5 0.5 RG 2 w 11 70 m 11 60 l S\n")
+			So(content, ShouldContainSubstring, "0.94 0.94 0.94 rg 10 46 180 14 re f\n")
+			So(content, ShouldContainSubstring, "12 50.64 Td (x := 1) Tj")
+		})
+
+		Convey("It should write table of contents entries with leaders and page numbers", func() {
+			layout := newSmallLayout()
+			style := Style{Font: Courier, Size: 10}
+			layout.Add(&ContentsEntry{
+				Spans:   []Span{{Text: "Intro", Style: style}},
+				Number:  Span{Text: "12", Style: style},
+				Indent:  10,
+				Link:    "#intro",
+				Leading: 1,
+			})
+			page := layout.Document().Pages()[0]
+			content := page.content.String()
+			So(content, ShouldContainSubstring, "20 82.64 Td (Intro) Tj")
+			So(content, ShouldContainSubstring, "178 82.64 Td (12) Tj")
+			So(content, ShouldContainSubstring, "64 82.64 Td (. . . . . . . . . ) Tj")
+			So(page.annotations, ShouldHaveLength, 2)
+			So(page.annotations[0].anchor, ShouldEqual, "intro")
+			So(page.annotations[1].rect.X, ShouldAlmostEqual, 178)
+		})
+
+		Convey("It should write tables, repeating the header in each page", func() {
+			layout := newSmallLayout()
+			style := Style{Font: Courier, Size: 10}
+			cell := func(text string) TableCell {
+				return TableCell{Spans: []Span{{Text: text, Style: style}}}
+			}
+			rows := make([][]TableCell, 0)
+			for i := 0; i < 10; i++ {
+				rows = append(rows, []TableCell{cell("row"), {Spans: []Span{{Text: "9", Style: style}}, Align: AlignRight}})
+			}
+			layout.Add(&Table{
+				Header:           []TableCell{cell("name"), cell("size")},
+				Rows:             rows,
+				Leading:          1,
+				BorderColor:      Gray,
+				BorderWidth:      1,
+				HeaderBackground: &LightGray,
+			})
+			pages := layout.Document().Pages()
+			So(pages, ShouldHaveLength, 2)
+			first := pages[0].content.String()
+			So(first, ShouldContainSubstring, "0.94 0.94 0.94 rg 10 80 180 10 re f\n")
+			So(first, ShouldContainSubstring, "10 82.64 Td (name) Tj")
+			So(first, ShouldContainSubstring, "100 82.64 Td (size) Tj")
+			So(first, ShouldContainSubstring, "184 72.64 Td (9) Tj")
+			So(first, ShouldContainSubstring, "0.5 0.5 0.5 RG 1 w 100 90 m 100 80 l S\n")
+			So(strings.Count(first, "(row) Tj"), ShouldEqual, 7)
+			second := pages[1].content.String()
+			So(second, ShouldContainSubstring, "10 82.64 Td (name) Tj")
+			So(strings.Count(second, "(row) Tj"), ShouldEqual, 3)
+		})
+
+		Convey("It should add link annotations to linked text", func() {
+			layout := newSmallLayout()
+			layout.Add(&Paragraph{Spans: []Span{
+				{Text: "see ", Style: Style{Font: Courier, Size: 10}},
+				{Text: "the site", Style: Style{Font: Courier, Size: 10, Link: "https://example.com"}},
+			}})
+			annotations := layout.Document().Pages()[0].annotations
+			So(annotations, ShouldHaveLength, 1)
+			So(annotations[0].uri, ShouldEqual, "https://example.com")
+			So(annotations[0].rect.X, ShouldAlmostEqual, 34)
+			So(annotations[0].rect.Width, ShouldAlmostEqual, 48)
+		})
+	})
+}
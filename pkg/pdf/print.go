@@ -0,0 +1,134 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+const (
+	// cropMarkLength is the length of the crop marks.
+	cropMarkLength = 12.0
+	// cropMarkGap is the distance between the crop marks and the bleed area.
+	cropMarkGap = 3.0
+	// cropMarkWidth is the line width of the crop marks.
+	cropMarkWidth = 0.25
+)
+
+// watermark represents a text written across every page
+type watermark struct {
+	text  string
+	font  Font
+	size  float64
+	color Color
+}
+
+// SetBleed makes the pages bigger than their size by the given bleed, in points, on each side, so
+// that the content can go past the edges where the pages are cut when printing, and marks the
+// corners where they are cut when cropMarks is true; the coordinates of the pages do not change
+func (d *Document) SetBleed(bleed float64, cropMarks bool) {
+	d.bleed = max(bleed, 0)
+	d.cropMarks = cropMarks
+}
+
+// SetWatermark writes the given text across the diagonal of every page, behind their content,
+// with the given font, size and color
+func (d *Document) SetWatermark(text string, font Font, size float64, color Color) {
+	if text == "" {
+		d.watermark = nil
+		return
+	}
+	d.watermark = &watermark{text: text, font: font, size: size, color: color}
+}
+
+// offset returns the distance between the corners of the media box and of the page, which has
+// the bleed and the crop marks
+func (d *Document) offset() float64 {
+	if d.cropMarks {
+		return d.bleed + cropMarkGap + cropMarkLength
+	}
+	return d.bleed
+}
+
+// boxes returns the page entries with the trim and bleed boxes, when the pages have a bleed
+func (d *Document) boxes() string {
+	offset := d.offset()
+	if offset == 0 {
+		return ""
+	}
+	box := func(margin float64) string {
+		return fmt.Sprintf("[%s %s %s %s]", formatNumber(offset-margin), formatNumber(offset-margin),
+			formatNumber(offset+d.width+margin), formatNumber(offset+d.height+margin))
+	}
+	return fmt.Sprintf(" /TrimBox %s /BleedBox %s", box(0), box(d.bleed))
+}
+
+// pageContent returns the content stream of the given page, with the watermark behind it and
+// moved by the offset of the media box, followed by the crop marks
+func (d *Document) pageContent(page *Page) []byte {
+	offset := d.offset()
+	if offset == 0 && d.watermark == nil {
+		return page.content.Bytes()
+	}
+	var buffer bytes.Buffer
+	if offset != 0 {
+		fmt.Fprintf(&buffer, "q 1 0 0 1 %s %s cm\n", formatNumber(offset), formatNumber(offset))
+	}
+	if d.watermark != nil {
+		buffer.WriteString(page.watermark(d.watermark))
+	}
+	buffer.Write(page.content.Bytes())
+	if offset != 0 {
+		buffer.WriteString("Q\n")
+	}
+	if d.cropMarks {
+		left, bottom := offset, offset
+		right, top := offset+d.width, offset+d.height
+		near, far := d.bleed+cropMarkGap, d.bleed+cropMarkGap+cropMarkLength
+		for _, corner := range [][4]float64{{left, bottom, -1, -1}, {right, bottom, 1, -1}, {left, top, -1, 1}, {right, top, 1, 1}} {
+			x, y, dx, dy := corner[0], corner[1], corner[2], corner[3]
+			fmt.Fprintf(&buffer, "%s RG %s w %s %s m %s %s l S\n", Black.operands(), formatNumber(cropMarkWidth),
+				formatNumber(x+dx*near), formatNumber(y), formatNumber(x+dx*far), formatNumber(y))
+			fmt.Fprintf(&buffer, "%s RG %s w %s %s m %s %s l S\n", Black.operands(), formatNumber(cropMarkWidth),
+				formatNumber(x), formatNumber(y+dy*near), formatNumber(x), formatNumber(y+dy*far))
+		}
+	}
+	return buffer.Bytes()
+}
+
+// watermark returns the content that writes the given watermark along the diagonal of this page,
+// from its lower left to its upper right corner, centered
+func (p *Page) watermark(w *watermark) string {
+	width := p.document.width
+	height := p.document.height
+	angle := math.Atan2(height, width)
+	cos, sin := math.Cos(angle), math.Sin(angle)
+	length := w.font.Width(w.text, w.size)
+	// the middle of the text height, not its baseline, is on the diagonal
+	middle := (w.font.Ascent(w.size) - w.font.Descent(w.size)) / 2
+	x := width/2 - cos*length/2 + sin*middle
+	y := height/2 - sin*length/2 - cos*middle
+	return fmt.Sprintf("BT /%s %s Tf %s rg %s %s %s %s %s %s Tm %s Tj ET\n",
+		p.fontName(w.font), formatNumber(w.size), w.color.operands(),
+		formatNumber(cos), formatNumber(sin), formatNumber(-sin), formatNumber(cos), formatNumber(x), formatNumber(y),
+		w.font.encode(p.document, w.text))
+}
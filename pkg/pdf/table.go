@@ -0,0 +1,175 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pdf
+
+// Table represents a grid of cells, with columns of equal width across the text area.
+//
+// Rows are never split across pages; when a row does not fit, it is moved to the next page
+// together with a copy of the header row.
+type Table struct {
+	Header []TableCell
+	Rows   [][]TableCell
+	// Padding is the space between the borders and the text of each cell.
+	Padding float64
+	// Leading is the line height, as a multiple of the font size.
+	Leading     float64
+	BorderColor Color
+	BorderWidth float64
+	// HeaderBackground is the color behind the header row, that is not filled when nil.
+	HeaderBackground *Color
+	SpaceBefore      float64
+	SpaceAfter       float64
+}
+
+// TableCell represents the text of a table cell
+type TableCell struct {
+	Spans []Span
+	Align Alignment
+}
+
+// tableRow represents a row of a table, with the lines of each cell already broken
+type tableRow struct {
+	cells  [][]*line
+	aligns []Alignment
+	height float64
+	header bool
+}
+
+// columns returns the number of columns of the table
+func (t *Table) columns() int {
+	count := len(t.Header)
+	for _, row := range t.Rows {
+		count = max(count, len(row))
+	}
+	return count
+}
+
+// columnWidth returns the width of each column in the given layout
+func (t *Table) columnWidth(l *Layout) float64 {
+	columns := t.columns()
+	if columns == 0 {
+		return 0
+	}
+	return (l.right - l.left) / float64(columns)
+}
+
+func (t *Table) leading() float64 {
+	if t.Leading <= 0 {
+		return defaultLeading
+	}
+	return t.Leading
+}
+
+// row breaks the cells of a row into lines, for columns of the given width
+func (t *Table) row(cells []TableCell, width float64, header bool) *tableRow {
+	row := &tableRow{
+		cells:  make([][]*line, t.columns()),
+		aligns: make([]Alignment, t.columns()),
+		header: header,
+	}
+	for i := range row.cells {
+		if i < len(cells) {
+			row.cells[i] = breakLines(cells[i].Spans, width-2*t.Padding, t.leading())
+			row.aligns[i] = cells[i].Align
+		}
+		height := 0.0
+		for _, line := range row.cells[i] {
+			height += line.height
+		}
+		row.height = max(row.height, height)
+	}
+	row.height += 2 * t.Padding
+	return row
+}
+
+func (t *Table) layout(l *Layout) {
+	if t.columns() == 0 {
+		return
+	}
+	l.space(t.SpaceBefore)
+	width := t.columnWidth(l)
+	var header *tableRow
+	if len(t.Header) > 0 {
+		header = t.row(t.Header, width, true)
+	}
+	rows := make([]*tableRow, 0, len(t.Rows)+1)
+	if header != nil {
+		rows = append(rows, header)
+	}
+	for _, cells := range t.Rows {
+		rows = append(rows, t.row(cells, width, false))
+	}
+	for i, row := range rows {
+		needed := row.height
+		if row.header && i+1 < len(rows) {
+			needed += rows[i+1].height
+		}
+		if l.page == nil || (needed > l.available() && !l.atTop()) {
+			l.newPage()
+			if header != nil && !row.header {
+				t.drawRow(l, header, width)
+			}
+		}
+		t.drawRow(l, row, width)
+	}
+	l.space(t.SpaceAfter)
+}
+
+// drawRow writes the given row at the top of the free area of the layout, with its borders
+func (t *Table) drawRow(l *Layout, row *tableRow, width float64) {
+	top := l.y
+	bottom := top - row.height
+	left, right := l.left, l.right
+	if row.header && t.HeaderBackground != nil {
+		l.page.Fill(Rect{X: left, Y: bottom, Width: right - left, Height: row.height}, *t.HeaderBackground)
+	}
+	for i, lines := range row.cells {
+		l.left = left + float64(i)*width + t.Padding
+		l.right = l.left + width - 2*t.Padding
+		l.y = top - t.Padding
+		for j, line := range lines {
+			line.draw(l, row.aligns[i], j == len(lines)-1)
+		}
+	}
+	l.left, l.right, l.y = left, right, bottom
+	if t.BorderWidth > 0 {
+		l.page.ColoredLine(left, top, right, top, t.BorderWidth, t.BorderColor)
+		l.page.ColoredLine(left, bottom, right, bottom, t.BorderWidth, t.BorderColor)
+		for i := 0; i <= len(row.cells); i++ {
+			x := left + float64(i)*width
+			l.page.ColoredLine(x, top, x, bottom, t.BorderWidth, t.BorderColor)
+		}
+	}
+}
+
+func (t *Table) leadHeight(l *Layout) float64 {
+	if t.columns() == 0 {
+		return t.SpaceBefore
+	}
+	width := t.columnWidth(l)
+	height := t.SpaceBefore
+	if len(t.Header) > 0 {
+		height += t.row(t.Header, width, true).height
+	}
+	if len(t.Rows) > 0 {
+		height += t.row(t.Rows[0], width, false).height
+	}
+	return height
+}
@@ -0,0 +1,462 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pdf
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Alignment represents the horizontal alignment of the lines of a paragraph
+type Alignment int
+
+const (
+	AlignLeft Alignment = iota
+	AlignRight
+	AlignCenter
+	AlignJustify
+)
+
+// defaultLeading is the line height, as a multiple of the font size, when none is given
+const defaultLeading = 1.2
+
+// Style represents how a span of text is drawn
+type Style struct {
+	Font  Font
+	Size  float64
+	Color Color
+	// Link is the target of the text, either an uri or the name of an anchor prefixed with #.
+	Link string
+}
+
+// Span represents a run of text with the same style, where new lines are forced line breaks
+type Span struct {
+	Text  string
+	Style Style
+}
+
+// Paragraph represents a block of text, broken into lines to fill the width of the text area
+type Paragraph struct {
+	Spans []Span
+	Align Alignment
+	// Leading is the line height, as a multiple of the font size.
+	Leading     float64
+	SpaceBefore float64
+	SpaceAfter  float64
+	// KeepWithNext keeps the paragraph in the same page as the beginning of the next block.
+	KeepWithNext bool
+	// Anchor is the name of a destination at the paragraph start, for internal links.
+	Anchor string
+}
+
+func (p *Paragraph) leading() float64 {
+	if p.Leading <= 0 {
+		return defaultLeading
+	}
+	return p.Leading
+}
+
+func (p *Paragraph) layout(l *Layout) {
+	l.space(p.SpaceBefore)
+	lines := breakLines(p.Spans, l.right-l.left, p.leading())
+	heights := make([]float64, len(lines))
+	for i, line := range lines {
+		heights[i] = line.height
+	}
+	for start := 0; start < len(lines); {
+		if l.page == nil {
+			l.newPage()
+		}
+		count := l.split(heights[start:], start == 0)
+		if count == 0 {
+			l.newPage()
+			continue
+		}
+		if start == 0 && p.Anchor != "" {
+			l.document.AddAnchor(p.Anchor, l.page, l.left, l.y)
+		}
+		for i := start; i < start+count; i++ {
+			lines[i].draw(l, p.Align, i == len(lines)-1)
+		}
+		start += count
+		if start < len(lines) {
+			l.newPage()
+		}
+	}
+	l.space(p.SpaceAfter)
+}
+
+func (p *Paragraph) leadHeight(l *Layout) float64 {
+	lines := breakLines(p.Spans, l.right-l.left, p.leading())
+	height := p.SpaceBefore
+	for i := 0; i < len(lines) && i < l.Orphans; i++ {
+		height += lines[i].height
+	}
+	return height
+}
+
+// height returns the height of the whole paragraph
+func (p *Paragraph) height(l *Layout) float64 {
+	height := p.SpaceBefore
+	for _, line := range breakLines(p.Spans, l.right-l.left, p.leading()) {
+		height += line.height
+	}
+	return height
+}
+
+// CodeBlock represents preformatted text, where spaces are kept and lines are only broken
+// when they do not fit the text area
+type CodeBlock struct {
+	Text        string
+	Style       Style
+	Background  Color
+	Padding     float64
+	Leading     float64
+	SpaceBefore float64
+	SpaceAfter  float64
+}
+
+func (c *CodeBlock) lines(width float64) []string {
+	text := strings.ReplaceAll(strings.TrimRight(c.Text, "\n"), "\t", "    ")
+	result := make([]string, 0)
+	for _, source := range strings.Split(text, "\n") {
+		runes := []rune(source)
+		for len(runes) > 0 {
+			count := 0
+			used := 0.0
+			for count < len(runes) {
+				advance := c.Style.Font.Width(string(runes[count]), c.Style.Size)
+				if used+advance > width && count > 0 {
+					break
+				}
+				used += advance
+				count++
+			}
+			result = append(result, string(runes[:count]))
+			runes = runes[count:]
+		}
+		if len(source) == 0 {
+			result = append(result, "")
+		}
+	}
+	return result
+}
+
+func (c *CodeBlock) lineHeight() float64 {
+	leading := c.Leading
+	if leading <= 0 {
+		leading = defaultLeading
+	}
+	return c.Style.Size * leading
+}
+
+func (c *CodeBlock) layout(l *Layout) {
+	l.space(c.SpaceBefore)
+	lines := c.lines(l.right - l.left - 2*c.Padding)
+	lineHeight := c.lineHeight()
+	heights := make([]float64, len(lines))
+	for i := range heights {
+		heights[i] = lineHeight
+	}
+	ascent := c.Style.Font.Ascent(c.Style.Size)
+	descent := c.Style.Font.Descent(c.Style.Size)
+	for start := 0; start < len(lines); {
+		if l.page == nil {
+			l.newPage()
+		}
+		l.y -= c.Padding
+		count := l.split(heights[start:], start == 0)
+		l.y += c.Padding
+		if count == 0 {
+			l.newPage()
+			continue
+		}
+		l.page.Fill(Rect{
+			X:      l.left,
+			Y:      l.y - float64(count)*lineHeight - 2*c.Padding,
+			Width:  l.right - l.left,
+			Height: float64(count)*lineHeight + 2*c.Padding,
+		}, c.Background)
+		l.y -= c.Padding
+		for _, line := range lines[start : start+count] {
+			baseline := l.y - (lineHeight-ascent-descent)/2 - ascent
+			l.writeMarkers(baseline)
+			if line != "" {
+				l.page.ColoredText(l.left+c.Padding, baseline, c.Style.Font, c.Style.Size, c.Style.Color, line)
+			}
+			l.y -= lineHeight
+		}
+		l.y -= c.Padding
+		start += count
+		if start < len(lines) {
+			l.newPage()
+		}
+	}
+	l.space(c.SpaceAfter)
+}
+
+func (c *CodeBlock) leadHeight(l *Layout) float64 {
+	lines := c.lines(l.right - l.left - 2*c.Padding)
+	return c.SpaceBefore + c.Padding + float64(min(len(lines), l.Orphans))*c.lineHeight()
+}
+
+// piece represents a part of a word with a single style
+type piece struct {
+	text  string
+	style Style
+	width float64
+}
+
+// word represents an unbreakable sequence of pieces
+type word struct {
+	pieces []piece
+	width  float64
+	// space is the width of the space before the word, if any.
+	space float64
+	// gap is the space before the word in its line, that is zero for the first word.
+	gap float64
+	// hard marks a forced line break after the word.
+	hard bool
+}
+
+func (w *word) add(text string, style Style) {
+	width := style.Font.Width(text, style.Size)
+	if n := len(w.pieces); n > 0 && w.pieces[n-1].style == style {
+		w.pieces[n-1].text += text
+		w.pieces[n-1].width += width
+	} else {
+		w.pieces = append(w.pieces, piece{text: text, style: style, width: width})
+	}
+	w.width += width
+}
+
+// line represents a line of a paragraph
+type line struct {
+	words   []word
+	width   float64
+	ascent  float64
+	descent float64
+	height  float64
+	hard    bool
+}
+
+// splitWords splits the spans into words at the spaces, marking forced line breaks
+func splitWords(spans []Span) []word {
+	words := make([]word, 0)
+	current := word{}
+	space := 0.0
+	flush := func(hard bool) {
+		if len(current.pieces) > 0 || hard {
+			current.hard = hard
+			words = append(words, current)
+			space = 0
+		}
+		current = word{}
+	}
+	for _, span := range spans {
+		var text strings.Builder
+		for _, r := range span.Text {
+			switch {
+			case r == '\n':
+				if text.Len() > 0 {
+					current.add(text.String(), span.Style)
+					text.Reset()
+				}
+				flush(true)
+			case unicode.IsSpace(r) && r != 0xa0:
+				if text.Len() > 0 {
+					current.add(text.String(), span.Style)
+					text.Reset()
+				}
+				if len(current.pieces) > 0 {
+					flush(false)
+				}
+				if len(words) > 0 && !words[len(words)-1].hard {
+					space = span.Style.Font.Width(" ", span.Style.Size)
+				}
+			default:
+				if text.Len() == 0 && len(current.pieces) == 0 {
+					current.space = space
+				}
+				text.WriteRune(r)
+			}
+		}
+		if text.Len() > 0 {
+			current.add(text.String(), span.Style)
+		}
+	}
+	flush(false)
+	return words
+}
+
+// splitWord splits a word that does not fit the given width into several words that do
+func splitWord(long word, width float64) []word {
+	result := make([]word, 0)
+	current := word{space: long.space}
+	for _, piece := range long.pieces {
+		for _, r := range piece.text {
+			advance := piece.style.Font.Width(string(r), piece.style.Size)
+			if current.width+advance > width && len(current.pieces) > 0 {
+				result = append(result, current)
+				current = word{}
+			}
+			current.add(string(r), piece.style)
+		}
+	}
+	current.hard = long.hard
+	return append(result, current)
+}
+
+// breakLines breaks the spans into lines of at most the given width, filling each line
+// with as many words as possible
+func breakLines(spans []Span, width float64, leading float64) []*line {
+	lines := make([]*line, 0)
+	current := &line{}
+	for _, w := range splitWords(spans) {
+		parts := []word{w}
+		if w.width > width {
+			parts = splitWord(w, width)
+		}
+		for _, part := range parts {
+			part.gap = part.space
+			if len(current.words) == 0 {
+				part.gap = 0
+			} else if current.width+part.gap+part.width > width {
+				lines = append(lines, current)
+				current = &line{}
+				part.gap = 0
+			}
+			current.words = append(current.words, part)
+			current.width += part.gap + part.width
+			if part.hard {
+				current.hard = true
+				lines = append(lines, current)
+				current = &line{}
+			}
+		}
+	}
+	if len(current.words) > 0 || len(lines) == 0 {
+		lines = append(lines, current)
+	}
+	fallback := Style{Font: Courier, Size: 10}
+	if len(spans) > 0 {
+		fallback = spans[0].Style
+	}
+	for _, line := range lines {
+		line.measure(fallback, leading)
+	}
+	return lines
+}
+
+// measure calculates the vertical metrics of the line
+func (ln *line) measure(fallback Style, leading float64) {
+	size := 0.0
+	for _, w := range ln.words {
+		for _, piece := range w.pieces {
+			size = max(size, piece.style.Size)
+			ln.ascent = max(ln.ascent, piece.style.Font.Ascent(piece.style.Size))
+			ln.descent = max(ln.descent, piece.style.Font.Descent(piece.style.Size))
+		}
+	}
+	if size == 0 {
+		size = fallback.Size
+		ln.ascent = fallback.Font.Ascent(size)
+		ln.descent = fallback.Font.Descent(size)
+	}
+	ln.height = max(size*leading, ln.ascent+ln.descent)
+}
+
+// run represents consecutive text with the same style, written at once
+type run struct {
+	x     float64
+	text  string
+	style Style
+	width float64
+}
+
+// draw writes the line at the top of the free area of the layout
+func (ln *line) draw(l *Layout, align Alignment, last bool) {
+	baseline := l.y - (ln.height-ln.ascent-ln.descent)/2 - ln.ascent
+	l.writeMarkers(baseline)
+	extra := l.right - l.left - ln.width
+	x := l.left
+	gapExtra := 0.0
+	switch align {
+	case AlignRight:
+		x += extra
+	case AlignCenter:
+		x += extra / 2
+	case AlignJustify:
+		if gaps := len(ln.words) - 1; !last && !ln.hard && gaps > 0 && extra > 0 {
+			gapExtra = extra / float64(gaps)
+		}
+	}
+
+	runs := make([]run, 0, len(ln.words))
+	for i, w := range ln.words {
+		gap := 0.0
+		if i > 0 {
+			gap = w.gap + gapExtra
+		}
+		for j, piece := range w.pieces {
+			n := len(runs)
+			switch {
+			case n > 0 && j == 0 && gap > 0 && gapExtra == 0 && runs[n-1].style == piece.style &&
+				piece.style.Font.Width(" ", piece.style.Size) == w.gap:
+				runs[n-1].text += " " + piece.text
+				runs[n-1].width += gap + piece.width
+			case n > 0 && j == 0 && gap == 0 && runs[n-1].style == piece.style:
+				runs[n-1].text += piece.text
+				runs[n-1].width += piece.width
+			default:
+				start := x + gap
+				if j > 0 {
+					start = runs[n-1].x + runs[n-1].width
+				}
+				runs = append(runs, run{x: start, text: piece.text, style: piece.style, width: piece.width})
+			}
+			if j == 0 {
+				x += gap
+			}
+			x += piece.width
+		}
+	}
+
+	for i, r := range runs {
+		l.page.ColoredText(r.x, baseline, r.style.Font, r.style.Size, r.style.Color, r.text)
+		if r.style.Link == "" || (i > 0 && runs[i-1].style.Link == r.style.Link) {
+			continue
+		}
+		end := r.x + r.width
+		for _, next := range runs[i+1:] {
+			if next.style.Link != r.style.Link {
+				break
+			}
+			end = next.x + next.width
+		}
+		rect := Rect{X: r.x, Y: baseline - ln.descent, Width: end - r.x, Height: ln.ascent + ln.descent}
+		if strings.HasPrefix(r.style.Link, "#") {
+			l.page.InternalLink(rect, strings.TrimPrefix(r.style.Link, "#"))
+		} else {
+			l.page.Link(rect, r.style.Link)
+		}
+	}
+	l.y -= ln.height
+}
@@ -0,0 +1,614 @@
+/*
+ * Copyright (C) 2024 carddamom
+ *
+ * This file is part of riconto.
+ *
+ * riconto is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * riconto is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with riconto.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"emperror.dev/errors"
+)
+
+// TrueTypeFont represents a TrueType or OpenType font, which is embedded in the documents that use it.
+//
+// Fonts with TrueType outlines are subset, keeping only the glyphs used in each document, while
+// fonts with CFF outlines are embedded whole.
+type TrueTypeFont struct {
+	name        string
+	data        []byte
+	tables      map[string][]byte
+	unitsPerEm  float64
+	ascent      float64
+	descent     float64
+	capHeight   float64
+	italicAngle float64
+	bbox        [4]int16
+	fixedPitch  bool
+	longLoca    bool
+	cff         bool
+	numGlyphs   int
+	widths      []uint16
+	cmap        map[rune]uint16
+}
+
+// ParseFont parses a TrueType (.ttf) or OpenType (.otf) font
+func ParseFont(data []byte) (*TrueTypeFont, error) {
+	if len(data) < 12 {
+		return nil, errors.New("The font file is too small")
+	}
+	version := binary.BigEndian.Uint32(data)
+	if version != 0x00010000 && version != 0x74727565 && version != 0x4f54544f {
+		return nil, errors.Errorf("The font file has an unsupported version %08x", version)
+	}
+	font := &TrueTypeFont{
+		data:   data,
+		tables: make(map[string][]byte),
+		cff:    version == 0x4f54544f,
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+numTables*16 {
+		return nil, errors.New("The font file has a truncated table directory")
+	}
+	for i := 0; i < numTables; i++ {
+		record := data[12+i*16:]
+		tag := string(record[:4])
+		offset := int(binary.BigEndian.Uint32(record[8:]))
+		length := int(binary.BigEndian.Uint32(record[12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return nil, errors.Errorf("The font table %s is out of bounds", tag)
+		}
+		font.tables[tag] = data[offset : offset+length]
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "maxp", "cmap"} {
+		if _, ok := font.tables[tag]; !ok {
+			return nil, errors.Errorf("The font is missing the required table %s", tag)
+		}
+	}
+	if !font.cff {
+		for _, tag := range []string{"loca", "glyf"} {
+			if _, ok := font.tables[tag]; !ok {
+				return nil, errors.Errorf("The font is missing the required table %s", tag)
+			}
+		}
+	}
+	if err := font.parseMetrics(); err != nil {
+		return nil, err
+	}
+	if err := font.parseCmap(); err != nil {
+		return nil, err
+	}
+	font.name = font.parseName()
+	return font, nil
+}
+
+// parseMetrics parses the global metrics and the glyph widths of the font
+func (f *TrueTypeFont) parseMetrics() error {
+	head, hhea, maxp, hmtx := f.tables["head"], f.tables["hhea"], f.tables["maxp"], f.tables["hmtx"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return errors.New("The font has truncated head, hhea or maxp tables")
+	}
+	f.unitsPerEm = float64(binary.BigEndian.Uint16(head[18:]))
+	if f.unitsPerEm == 0 {
+		return errors.New("The font has zero units per em")
+	}
+	for i := range f.bbox {
+		f.bbox[i] = int16(binary.BigEndian.Uint16(head[36+i*2:]))
+	}
+	f.longLoca = binary.BigEndian.Uint16(head[50:]) == 1
+	f.ascent = float64(int16(binary.BigEndian.Uint16(hhea[4:])))
+	f.descent = -float64(int16(binary.BigEndian.Uint16(hhea[6:])))
+	f.capHeight = f.ascent
+	f.numGlyphs = int(binary.BigEndian.Uint16(maxp[4:]))
+	metrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	if metrics == 0 || len(hmtx) < metrics*4 {
+		return errors.New("The font has a truncated hmtx table")
+	}
+	f.widths = make([]uint16, f.numGlyphs)
+	for i := range f.widths {
+		if i < metrics {
+			f.widths[i] = binary.BigEndian.Uint16(hmtx[i*4:])
+		} else {
+			f.widths[i] = f.widths[metrics-1]
+		}
+	}
+	if os2 := f.tables["OS/2"]; len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+		f.capHeight = float64(int16(binary.BigEndian.Uint16(os2[88:])))
+	}
+	if post := f.tables["post"]; len(post) >= 16 {
+		f.italicAngle = float64(int32(binary.BigEndian.Uint32(post[4:]))) / 65536
+		f.fixedPitch = binary.BigEndian.Uint32(post[12:]) != 0
+	}
+	return nil
+}
+
+// parseCmap parses the unicode character to glyph mapping of the font
+func (f *TrueTypeFont) parseCmap() error {
+	cmap := f.tables["cmap"]
+	if len(cmap) < 4 {
+		return errors.New("The font has a truncated cmap table")
+	}
+	var best []byte
+	bestScore := 0
+	count := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i := 0; i < count && 4+i*8+8 <= len(cmap); i++ {
+		record := cmap[4+i*8:]
+		platform, encoding := binary.BigEndian.Uint16(record), binary.BigEndian.Uint16(record[2:])
+		offset := int(binary.BigEndian.Uint32(record[4:]))
+		if offset+4 > len(cmap) {
+			continue
+		}
+		subtable := cmap[offset:]
+		format := binary.BigEndian.Uint16(subtable)
+		score := 0
+		switch {
+		case format == 12 && (platform == 0 || (platform == 3 && encoding == 10)):
+			score = 3
+		case format == 4 && (platform == 0 || (platform == 3 && encoding == 1)):
+			score = 2
+		}
+		if score > bestScore {
+			best, bestScore = subtable, score
+		}
+	}
+	f.cmap = make(map[rune]uint16)
+	switch bestScore {
+	case 3:
+		return f.parseCmap12(best)
+	case 2:
+		return f.parseCmap4(best)
+	}
+	return errors.New("The font has no unicode cmap subtable")
+}
+
+func (f *TrueTypeFont) parseCmap4(table []byte) error {
+	if len(table) < 14 {
+		return errors.New("The font has a truncated cmap subtable")
+	}
+	segments := int(binary.BigEndian.Uint16(table[6:])) / 2
+	if len(table) < 16+segments*8 {
+		return errors.New("The font has a truncated cmap subtable")
+	}
+	ends := table[14:]
+	starts := table[16+segments*2:]
+	deltas := table[16+segments*4:]
+	ranges := table[16+segments*6:]
+	for i := 0; i < segments; i++ {
+		end := binary.BigEndian.Uint16(ends[i*2:])
+		start := binary.BigEndian.Uint16(starts[i*2:])
+		delta := binary.BigEndian.Uint16(deltas[i*2:])
+		rangeOffset := int(binary.BigEndian.Uint16(ranges[i*2:]))
+		for c := uint32(start); c <= uint32(end) && c != 0xffff; c++ {
+			var glyph uint16
+			if rangeOffset == 0 {
+				glyph = uint16(c) + delta
+			} else {
+				index := 16 + segments*6 + i*2 + rangeOffset + int(c-uint32(start))*2
+				if index+2 > len(table) {
+					continue
+				}
+				glyph = binary.BigEndian.Uint16(table[index:])
+				if glyph != 0 {
+					glyph += delta
+				}
+			}
+			if glyph != 0 && int(glyph) < f.numGlyphs {
+				f.cmap[rune(c)] = glyph
+			}
+		}
+	}
+	return nil
+}
+
+func (f *TrueTypeFont) parseCmap12(table []byte) error {
+	if len(table) < 16 {
+		return errors.New("The font has a truncated cmap subtable")
+	}
+	groups := int(binary.BigEndian.Uint32(table[12:]))
+	if len(table) < 16+groups*12 {
+		return errors.New("The font has a truncated cmap subtable")
+	}
+	for i := 0; i < groups; i++ {
+		group := table[16+i*12:]
+		start, end := binary.BigEndian.Uint32(group), binary.BigEndian.Uint32(group[4:])
+		glyph := binary.BigEndian.Uint32(group[8:])
+		for c := start; c <= end && c <= 0x10ffff; c++ {
+			if int(glyph) < f.numGlyphs {
+				f.cmap[rune(c)] = uint16(glyph)
+			}
+			glyph++
+		}
+	}
+	return nil
+}
+
+// parseName returns the postscript name of the font, removing any invalid characters
+func (f *TrueTypeFont) parseName() string {
+	name := ""
+	table := f.tables["name"]
+	if len(table) >= 6 {
+		count := int(binary.BigEndian.Uint16(table[2:]))
+		storage := int(binary.BigEndian.Uint16(table[4:]))
+		for i := 0; i < count && 6+i*12+12 <= len(table); i++ {
+			record := table[6+i*12:]
+			platform, id := binary.BigEndian.Uint16(record), binary.BigEndian.Uint16(record[6:])
+			length, offset := int(binary.BigEndian.Uint16(record[8:])), int(binary.BigEndian.Uint16(record[10:]))
+			if id != 6 || storage+offset+length > len(table) {
+				continue
+			}
+			value := table[storage+offset : storage+offset+length]
+			if platform == 3 || platform == 0 {
+				units := make([]uint16, len(value)/2)
+				for j := range units {
+					units[j] = binary.BigEndian.Uint16(value[j*2:])
+				}
+				name = string(utf16.Decode(units))
+			} else {
+				name = string(value)
+			}
+			break
+		}
+	}
+	name = strings.Map(func(r rune) rune {
+		if r <= 0x20 || r >= 0x7f || strings.ContainsRune("[](){}<>/%#", r) {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" {
+		hash := fnv.New32a()
+		_, _ = hash.Write(f.data)
+		name = fmt.Sprintf("Font%08X", hash.Sum32())
+	}
+	return name
+}
+
+// Name returns the postscript name of the font
+func (f *TrueTypeFont) Name() string {
+	return f.name
+}
+
+// Glyph returns the glyph that represents the given rune, and if the font has it
+func (f *TrueTypeFont) Glyph(r rune) (uint16, bool) {
+	glyph, ok := f.cmap[r]
+	return glyph, ok
+}
+
+func (f *TrueTypeFont) Width(text string, size float64) float64 {
+	total := 0.0
+	for _, r := range text {
+		glyph := f.cmap[r]
+		total += float64(f.widths[glyph])
+	}
+	return total * size / f.unitsPerEm
+}
+
+func (f *TrueTypeFont) Ascent(size float64) float64 {
+	return f.ascent * size / f.unitsPerEm
+}
+
+func (f *TrueTypeFont) Descent(size float64) float64 {
+	return f.descent * size / f.unitsPerEm
+}
+
+// encode encodes the text as a sequence of two byte glyph identifiers
+func (f *TrueTypeFont) encode(document *Document, text string) string {
+	var buffer bytes.Buffer
+	buffer.WriteString("<")
+	for _, r := range text {
+		glyph := f.cmap[r]
+		document.useGlyph(f, glyph, r)
+		fmt.Fprintf(&buffer, "%04X", glyph)
+	}
+	buffer.WriteString(">")
+	return buffer.String()
+}
+
+// scale converts a value in font units into pdf glyph space units
+func (f *TrueTypeFont) scale(value float64) int {
+	return int(value * 1000 / f.unitsPerEm)
+}
+
+// write writes the font as a Type0 font, with a CIDFontType2 (or CIDFontType0) descendant,
+// using the identity encoding over the glyph identifiers
+func (f *TrueTypeFont) write(document *Document, ref Ref) {
+	used := document.glyphs[f]
+	glyphs := make([]uint16, 0, len(used)+1)
+	glyphs = append(glyphs, 0)
+	for glyph := range used {
+		if glyph != 0 {
+			glyphs = append(glyphs, glyph)
+		}
+	}
+	slices.Sort(glyphs)
+
+	name := f.name
+	program := f.data
+	if !f.cff {
+		subset, err := f.subset(glyphs)
+		if err == nil {
+			program = subset
+			name = subsetTag(glyphs) + "+" + f.name
+		}
+	}
+
+	descendant := document.alloc()
+	descriptor := document.alloc()
+	file := document.alloc()
+	toUnicode := document.alloc()
+
+	document.set(ref, "<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H "+
+		"/DescendantFonts [%s] /ToUnicode %s >>", name, descendant, toUnicode)
+
+	var widths bytes.Buffer
+	for _, glyph := range glyphs {
+		fmt.Fprintf(&widths, " %d [%d]", glyph, f.scale(float64(f.widths[glyph])))
+	}
+	subtype, gidMap := "CIDFontType2", " /CIDToGIDMap /Identity"
+	fileKey, fileDict := "FontFile2", fmt.Sprintf(" /Length1 %d", len(program))
+	if f.cff {
+		subtype, gidMap = "CIDFontType0", ""
+		fileKey, fileDict = "FontFile3", " /Subtype /OpenType"
+	}
+	document.set(descendant, "<< /Type /Font /Subtype /%s /BaseFont /%s "+
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+		"/FontDescriptor %s /DW %d /W [%s ]%s >>",
+		subtype, name, descriptor, f.scale(float64(f.widths[0])), widths.String(), gidMap)
+
+	flags := 32
+	if f.fixedPitch {
+		flags |= 1
+	}
+	if f.italicAngle != 0 {
+		flags |= 64
+	}
+	document.set(descriptor, "<< /Type /FontDescriptor /FontName /%s /Flags %d /FontBBox [%d %d %d %d] "+
+		"/ItalicAngle %s /Ascent %d /Descent %d /CapHeight %d /StemV 80 /%s %s >>",
+		name, flags, f.scale(float64(f.bbox[0])), f.scale(float64(f.bbox[1])),
+		f.scale(float64(f.bbox[2])), f.scale(float64(f.bbox[3])), formatNumber(f.italicAngle),
+		f.scale(f.ascent), -f.scale(f.descent), f.scale(f.capHeight), fileKey, file)
+	document.setStream(file, fileDict, program)
+	document.setStream(toUnicode, "", toUnicodeCMap(glyphs, used))
+}
+
+// subset creates a new font program, where every glyph not in the given sorted list is empty,
+// keeping the glyph identifiers unchanged
+func (f *TrueTypeFont) subset(glyphs []uint16) ([]byte, error) {
+	offsets, err := f.glyphOffsets()
+	if err != nil {
+		return nil, err
+	}
+	glyf := f.tables["glyf"]
+
+	// Add the components of the composite glyphs
+	keep := make(map[uint16]bool, len(glyphs))
+	pending := slices.Clone(glyphs)
+	for len(pending) > 0 {
+		glyph := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if keep[glyph] || int(glyph) >= f.numGlyphs {
+			continue
+		}
+		keep[glyph] = true
+		pending = append(pending, compositeComponents(glyf[offsets[glyph]:offsets[glyph+1]])...)
+	}
+
+	var newGlyf bytes.Buffer
+	newLoca := make([]byte, 0, (f.numGlyphs+1)*4)
+	for glyph := 0; glyph < f.numGlyphs; glyph++ {
+		newLoca = binary.BigEndian.AppendUint32(newLoca, uint32(newGlyf.Len()))
+		if keep[uint16(glyph)] {
+			newGlyf.Write(glyf[offsets[glyph]:offsets[glyph+1]])
+			for newGlyf.Len()%4 != 0 {
+				newGlyf.WriteByte(0)
+			}
+		}
+	}
+	newLoca = binary.BigEndian.AppendUint32(newLoca, uint32(newGlyf.Len()))
+
+	head := slices.Clone(f.tables["head"])
+	binary.BigEndian.PutUint32(head[8:], 0)
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	tables := map[string][]byte{
+		"head": head,
+		"hhea": f.tables["hhea"],
+		"maxp": f.tables["maxp"],
+		"hmtx": f.tables["hmtx"],
+		"loca": newLoca,
+		"glyf": newGlyf.Bytes(),
+	}
+	for _, tag := range []string{"cmap", "cvt ", "fpgm", "prep", "OS/2", "name", "post"} {
+		if table, ok := f.tables[tag]; ok {
+			tables[tag] = table
+		}
+	}
+	return writeFontTables(tables), nil
+}
+
+// glyphOffsets returns the offsets of every glyph in the glyf table, plus the end offset
+func (f *TrueTypeFont) glyphOffsets() ([]int, error) {
+	loca := f.tables["loca"]
+	glyf := f.tables["glyf"]
+	offsets := make([]int, f.numGlyphs+1)
+	for i := range offsets {
+		if f.longLoca {
+			if len(loca) < i*4+4 {
+				return nil, errors.New("The font has a truncated loca table")
+			}
+			offsets[i] = int(binary.BigEndian.Uint32(loca[i*4:]))
+		} else {
+			if len(loca) < i*2+2 {
+				return nil, errors.New("The font has a truncated loca table")
+			}
+			offsets[i] = int(binary.BigEndian.Uint16(loca[i*2:])) * 2
+		}
+		if offsets[i] > len(glyf) || (i > 0 && offsets[i] < offsets[i-1]) {
+			return nil, errors.New("The font has an invalid loca table")
+		}
+	}
+	return offsets, nil
+}
+
+// compositeComponents returns the glyphs used by the given glyph, if it is a composite glyph
+func compositeComponents(glyph []byte) []uint16 {
+	result := make([]uint16, 0)
+	if len(glyph) < 10 || int16(binary.BigEndian.Uint16(glyph)) >= 0 {
+		return result
+	}
+	const (
+		argsAreWords   = 0x0001
+		haveScale      = 0x0008
+		moreComponents = 0x0020
+		haveXYScale    = 0x0040
+		haveTwoByTwo   = 0x0080
+	)
+	offset := 10
+	for offset+4 <= len(glyph) {
+		flags := binary.BigEndian.Uint16(glyph[offset:])
+		result = append(result, binary.BigEndian.Uint16(glyph[offset+2:]))
+		offset += 4
+		if flags&argsAreWords != 0 {
+			offset += 4
+		} else {
+			offset += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			offset += 2
+		case flags&haveXYScale != 0:
+			offset += 4
+		case flags&haveTwoByTwo != 0:
+			offset += 8
+		}
+		if flags&moreComponents == 0 {
+			break
+		}
+	}
+	return result
+}
+
+// writeFontTables writes a font file with the given tables, updating its checksums
+func writeFontTables(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+
+	count := len(tags)
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= count {
+		searchRange *= 2
+		entrySelector++
+	}
+	searchRange *= 16
+
+	var buffer bytes.Buffer
+	header := make([]byte, 12, 12+count*16)
+	binary.BigEndian.PutUint32(header, 0x00010000)
+	binary.BigEndian.PutUint16(header[4:], uint16(count))
+	binary.BigEndian.PutUint16(header[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(header[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(header[10:], uint16(count*16-searchRange))
+	offset := 12 + count*16
+	headOffset := 0
+	for _, tag := range tags {
+		table := tables[tag]
+		header = append(header, tag...)
+		header = binary.BigEndian.AppendUint32(header, fontChecksum(table))
+		header = binary.BigEndian.AppendUint32(header, uint32(offset))
+		header = binary.BigEndian.AppendUint32(header, uint32(len(table)))
+		if tag == "head" {
+			headOffset = offset
+		}
+		offset += (len(table) + 3) &^ 3
+	}
+	buffer.Write(header)
+	for _, tag := range tags {
+		buffer.Write(tables[tag])
+		for buffer.Len()%4 != 0 {
+			buffer.WriteByte(0)
+		}
+	}
+	result := buffer.Bytes()
+	binary.BigEndian.PutUint32(result[headOffset+8:], 0xB1B0AFBA-fontChecksum(result))
+	return result
+}
+
+// fontChecksum calculates the checksum of a font table
+func fontChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// subsetTag returns the six uppercase letters that prefix the name of a subset font,
+// derived from the glyphs in the subset
+func subsetTag(glyphs []uint16) string {
+	hash := fnv.New32a()
+	for _, glyph := range glyphs {
+		_ = binary.Write(hash, binary.BigEndian, glyph)
+	}
+	value := hash.Sum32()
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = byte('A' + value%26)
+		value /= 26
+	}
+	return string(tag)
+}
+
+// toUnicodeCMap returns a cmap that maps the given glyphs into their unicode text
+func toUnicodeCMap(glyphs []uint16, used map[uint16]rune) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	mapped := make([]uint16, 0, len(glyphs))
+	for _, glyph := range glyphs {
+		if _, ok := used[glyph]; ok && glyph != 0 {
+			mapped = append(mapped, glyph)
+		}
+	}
+	for start := 0; start < len(mapped); start += 100 {
+		end := min(start+100, len(mapped))
+		fmt.Fprintf(&buffer, "%d beginbfchar\n", end-start)
+		for _, glyph := range mapped[start:end] {
+			buffer.WriteString(fmt.Sprintf("<%04X> <", glyph))
+			for _, unit := range utf16.Encode([]rune{used[glyph]}) {
+				fmt.Fprintf(&buffer, "%04X", unit)
+			}
+			buffer.WriteString(">\n")
+		}
+		buffer.WriteString("endbfchar\n")
+	}
+	buffer.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return buffer.Bytes()
+}
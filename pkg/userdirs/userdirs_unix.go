@@ -26,11 +26,11 @@ import "os"
 type userdirsImpl struct{}
 
 // getEnvironmentVariable returns the environment variable with the given key or
-// the defaultValue if the key is not an environment variable.
+// the defaultValue, with its variables expanded, if the key is not an environment variable.
 func (impl userdirsImpl) getEnvironmentVariable(key, defaultValue string) string {
 	res := os.Getenv(key)
 	if len(res) == 0 {
-		return defaultValue
+		return os.ExpandEnv(defaultValue)
 	}
 	return res
 }